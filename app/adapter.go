@@ -24,6 +24,8 @@ func (a *coreNotifierAdapter) Send(msg any) {
 	switch e := msg.(type) {
 	case core.TokenEvent:
 		a.ui.Send(ui.ChatTokenMsg{Text: e.Text})
+	case core.ThinkingEvent:
+		a.ui.Send(ui.ChatThinkingMsg{Text: e.Text})
 	case core.CompletionEvent:
 		a.ui.Send(ui.ChatCompletionMsg{})
 	case core.ErrorEvent:
@@ -93,12 +95,32 @@ func (a *coreNotifierAdapter) Send(msg any) {
 			ToolCallID: e.ToolCallID,
 			Allowed:    e.Allowed,
 		})
+	case core.ProviderRetryEvent:
+		a.ui.Send(ui.ChatSystemMsg{Text: fmt.Sprintf("%s — retrying in %s (attempt %d/%d)",
+			e.Reason, e.Delay.Round(100*time.Millisecond), e.Attempt, e.MaxRetries)})
+	case core.ResponseTruncatedEvent:
+		if e.RetryMaxTokens > 0 {
+			a.ui.Send(ui.ChatSystemMsg{Text: fmt.Sprintf("Reply cut off at the %d-token output limit where it cannot be continued — retrying with %d",
+				e.MaxTokens, e.RetryMaxTokens)})
+		} else {
+			a.ui.Send(ui.ChatSystemMsg{Text: fmt.Sprintf("Reply reached the %d-token output limit — continuing", e.MaxTokens)})
+		}
+	case core.PricingMissingEvent:
+		a.ui.Send(ui.ChatSystemMsg{Text: fmt.Sprintf("No price known for %s — its cost counts as zero. Set one under [pricing.overrides] in config.toml.", e.ModelID)})
+	case core.BudgetWarningEvent:
+		a.ui.Send(ui.ChatSystemMsg{Text: fmt.Sprintf("Budget warning: %.0f%% of the %s budget used ($%.2f of $%.2f).", e.Threshold*100, e.Scope, e.Spent, e.Limit)})
+	case core.BudgetExceededEvent:
+		a.ui.Send(ui.ChatSystemMsg{Text: fmt.Sprintf("Budget reached: $%.2f of the $%.2f %s budget. Paused before the next model request — raise it with /budget %s <usd> to continue.", e.Spent, e.Limit, e.Scope, e.Scope)})
+	case core.BudgetStatusEvent:
+		a.ui.Send(ui.ChatSystemMsg{Text: e.Summary})
 	case core.ModelChangedEvent:
 		a.ui.Send(ui.StatusItemUpdateMsg{
 			Key:   "model",
 			Value: "⚙ " + ui.FormatModelName(e.ModelID),
 		})
 		a.ui.Send(ui.ChatSystemMsg{Text: "Model changed to " + e.ModelID})
+	case core.TurnOptionsEvent:
+		a.ui.Send(ui.ChatSystemMsg{Text: e.Summary})
 	case core.HistoryClearedEvent:
 		a.ui.Send(ui.ChatClearMsg{})
 		a.ui.Send(ui.ChatSystemMsg{Text: "Conversation cleared."})
@@ -110,8 +132,46 @@ func (a *coreNotifierAdapter) Send(msg any) {
 			text = fmt.Sprintf("Context: ~%d tokens used (context window unknown for %s)", e.Used, e.ModelID)
 		}
 		a.ui.Send(ui.ChatSystemMsg{Text: text})
+	case core.TurnCancelledEvent:
+		a.ui.Send(ui.ChatTurnCancelledMsg{})
+	case core.PlanModeEvent:
+		var text string
+		switch {
+		case e.Active:
+			a.ui.Send(ui.StatusItemUpdateMsg{Key: "mode", Value: "◇ plan"})
+			text = "Plan mode: only read-only tools are available. Review the plan, then /execute it or leave with /plan off."
+		case e.Executing:
+			a.ui.Send(ui.StatusItemUpdateMsg{Key: "mode", Value: "✎ edit"})
+			text = "Executing the plan."
+		default:
+			a.ui.Send(ui.StatusItemUpdateMsg{Key: "mode", Value: "✎ edit"})
+			text = "Left plan mode."
+		}
+		a.ui.Send(ui.ChatSystemMsg{Text: text})
+	case core.BranchSwitchedEvent:
+		if e.Created {
+			a.ui.Send(ui.ChatSystemMsg{Text: fmt.Sprintf("Started branch %d; branch %d is kept — /branch lists branches.", e.ID, e.Parent)})
+		} else {
+			a.ui.Send(ui.ChatSystemMsg{Text: fmt.Sprintf("Switched to branch %d: %s", e.ID, e.Label)})
+			entries := make([]ui.ChatReplayEntry, len(e.Replay))
+			for i, m := range e.Replay {
+				entries[i] = ui.ChatReplayEntry{IsUser: m.User, Text: m.Text}
+			}
+			a.ui.Send(ui.ChatReplayMsg{Entries: entries})
+		}
+		if e.FilesChanged {
+			a.ui.Send(ui.ChatSystemMsg{Text: "Files were changed on the branch you left. Run /branch restore to put them back as they were at the branch point."})
+		}
+	case core.BranchListEvent:
+		a.ui.Send(ui.ChatSystemMsg{Text: e.Summary})
+	case core.FilesRestoredEvent:
+		a.ui.Send(ui.ChatSystemMsg{Text: fmt.Sprintf("Restored %d file(s) to the branch point.", len(e.Paths))})
 	case core.SessionRestoredEvent:
-		a.ui.Send(ui.ChatSystemMsg{Text: fmt.Sprintf("Restored: %s (%d messages)", e.Description, e.MessageCount)})
+		detail := fmt.Sprintf("%d messages", e.MessageCount)
+		if e.Cost != "" {
+			detail += ", " + e.Cost
+		}
+		a.ui.Send(ui.ChatSystemMsg{Text: fmt.Sprintf("Restored: %s (%s)", e.Description, detail)})
 		if e.SessionID != "" && a.cosmosDir != "" {
 			go a.replayChangelog(e.SessionID)
 		}
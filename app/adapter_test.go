@@ -6,6 +6,7 @@ import (
 	"cosmos/ui"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 
@@ -21,6 +22,7 @@ import (
 func TestAllCoreEventsHandled(t *testing.T) {
 	// Instantiate all core event types
 	var _ interface{} = core.TokenEvent{}
+	var _ interface{} = core.ThinkingEvent{}
 	var _ interface{} = core.CompletionEvent{}
 	var _ interface{} = core.ErrorEvent{}
 	var _ interface{} = core.ToolUseEvent{}
@@ -35,7 +37,19 @@ func TestAllCoreEventsHandled(t *testing.T) {
 	var _ interface{} = core.CompactionFailedEvent{}
 	var _ interface{} = core.PermissionRequestEvent{}
 	var _ interface{} = core.PermissionTimeoutEvent{}
+	var _ interface{} = core.ProviderRetryEvent{}
+	var _ interface{} = core.ResponseTruncatedEvent{}
+	var _ interface{} = core.PricingMissingEvent{}
+	var _ interface{} = core.BudgetWarningEvent{}
+	var _ interface{} = core.BudgetExceededEvent{}
+	var _ interface{} = core.BudgetStatusEvent{}
+	var _ interface{} = core.TurnCancelledEvent{}
+	var _ interface{} = core.PlanModeEvent{}
+	var _ interface{} = core.BranchSwitchedEvent{}
+	var _ interface{} = core.BranchListEvent{}
+	var _ interface{} = core.FilesRestoredEvent{}
 	var _ interface{} = core.ModelChangedEvent{}
+	var _ interface{} = core.TurnOptionsEvent{}
 	var _ interface{} = core.HistoryClearedEvent{}
 	var _ interface{} = core.ContextInfoEvent{}
 	var _ interface{} = core.SessionRestoredEvent{}
@@ -211,6 +225,26 @@ func TestAdapterSessionRestoredEvent(t *testing.T) {
 	}
 }
 
+func TestAdapterSessionRestoredWithCost(t *testing.T) {
+	col := &collectingUINotifier{}
+	adapter := &coreNotifierAdapter{ui: col}
+
+	adapter.Send(core.SessionRestoredEvent{
+		Description:  "Hello world",
+		MessageCount: 12,
+		Cost:         "€ 1.20",
+	})
+
+	msgs := col.all()
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	}
+	expected := "Restored: Hello world (12 messages, € 1.20)"
+	if sys, ok := msgs[0].(ui.ChatSystemMsg); !ok || sys.Text != expected {
+		t.Errorf("expected %q, got %#v", expected, msgs[0])
+	}
+}
+
 // --- replayChangelog and formatChangeDesc tests ---
 
 func TestFormatChangeDesc_BothNames(t *testing.T) {
@@ -349,3 +383,46 @@ func TestReplayChangelog_MissingAuditLogStillProducesEntries(t *testing.T) {
 		t.Errorf("expected 1 file, got %d", len(entry.Files))
 	}
 }
+
+func TestAdapterPlanModeEvent(t *testing.T) {
+	col := &collectingUINotifier{}
+	adapter := &coreNotifierAdapter{ui: col}
+
+	adapter.Send(core.PlanModeEvent{Active: true})
+	adapter.Send(core.PlanModeEvent{Executing: true})
+
+	msgs := col.all()
+	if len(msgs) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(msgs))
+	}
+	for i, want := range []string{"◇ plan", "✎ edit"} {
+		status, ok := msgs[2*i].(ui.StatusItemUpdateMsg)
+		if !ok || status.Key != "mode" || status.Value != want {
+			t.Errorf("message %d = %+v, want mode %q", 2*i, msgs[2*i], want)
+		}
+	}
+}
+
+func TestAdapterBranchSwitchedEvent(t *testing.T) {
+	col := &collectingUINotifier{}
+	adapter := &coreNotifierAdapter{ui: col}
+
+	adapter.Send(core.BranchSwitchedEvent{
+		ID:           0,
+		Label:        "Question",
+		Replay:       []core.BranchMessage{{User: true, Text: "Again"}, {Text: "Answer"}},
+		FilesChanged: true,
+	})
+
+	msgs := col.all()
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(msgs))
+	}
+	replay, ok := msgs[1].(ui.ChatReplayMsg)
+	if !ok || len(replay.Entries) != 2 || !replay.Entries[0].IsUser || replay.Entries[1].Text != "Answer" {
+		t.Errorf("message 1 = %+v, want the branch replayed", msgs[1])
+	}
+	if sys, ok := msgs[2].(ui.ChatSystemMsg); !ok || !strings.Contains(sys.Text, "/branch restore") {
+		t.Errorf("message 2 = %+v, want the restore offer", msgs[2])
+	}
+}
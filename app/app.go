@@ -4,9 +4,11 @@ import (
 	"context"
 	"cosmos/config"
 	"cosmos/core"
+	"cosmos/core/provider"
 	"cosmos/engine/runtime"
 	"cosmos/ui"
 	"fmt"
+	"io"
 	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -20,7 +22,10 @@ type Application struct {
 	Program           *tea.Program
 	CurrencyFormatter *core.CurrencyFormatter
 	Tracker           *core.Tracker
+	Ledger            *core.Ledger        // nil if it could not be opened; closed on exit
+	Journal           *core.Journal       // nil if it could not be opened; removed once the session is saved
 	Executor          *runtime.V8Executor // V8 isolates; Close() on exit
+	Provider          provider.Provider   // closed on exit if it implements io.Closer
 }
 
 // Run starts the application and blocks until it exits.
@@ -46,11 +51,42 @@ func (a *Application) Run(ctx context.Context) error {
 	cancel()
 	a.Session.Stop()
 
+	closeProvider(a.Provider)
+
 	// Now it's safe to snapshot and persist the session.
+	saveSession(a.Session, a.Tracker, a.Config, a.Journal, a.Ledger)
+
+	return runErr
+}
+
+// closeProvider releases provider resources, e.g. flushes a cassette being
+// recorded.
+func closeProvider(p provider.Provider) {
+	if closer, ok := p.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "cosmos: warning: provider close failed: %v\n", err)
+		}
+	}
+}
+
+// saveSession persists a stopped session and closes its ledger. The
+// journal is removed once the session is saved, and otherwise kept for the
+// next start to offer resuming it.
+func saveSession(session *core.Session, tracker *core.Tracker, cfg config.Config, journal *core.Journal, ledger *core.Ledger) {
 	workDir, _ := os.Getwd()
-	if err := core.SaveSession(a.Session, a.Tracker, a.Config.SessionsDir, workDir); err != nil {
+	if err := core.SaveSession(session, tracker, cfg.SessionsDir, workDir); err != nil {
 		fmt.Fprintf(os.Stderr, "cosmos: warning: session save failed: %v\n", err)
+		if journal != nil {
+			_ = journal.Close()
+		}
+	} else if journal != nil {
+		if err := journal.Remove(); err != nil {
+			fmt.Fprintf(os.Stderr, "cosmos: warning: %v\n", err)
+		}
+	}
+	if ledger != nil {
+		if err := ledger.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "cosmos: warning: usage ledger close failed: %v\n", err)
+		}
 	}
-
-	return runErr
 }
@@ -10,12 +10,21 @@ import (
 	"cosmos/engine/policy"
 	"cosmos/engine/runtime"
 	"cosmos/engine/vfs"
+	"cosmos/providers/anthropic"
 	"cosmos/providers/bedrock"
+	"cosmos/providers/cassette"
+	"cosmos/providers/fake"
+	"cosmos/providers/middleware"
+	"cosmos/providers/openai"
+	"cosmos/providers/pricetable"
+	"cosmos/providers/router"
 	"cosmos/ui"
 	"fmt"
+	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
@@ -23,9 +32,17 @@ import (
 	"github.com/google/uuid"
 )
 
+// Options holds command-line overrides for Bootstrap.
+type Options struct {
+	// OfflineScript, if set, replaces the configured provider with a
+	// scripted fake provider loaded from this JSON or YAML file. No network
+	// access or credentials are needed in this mode.
+	OfflineScript string
+}
+
 // Bootstrap creates and wires all application dependencies.
 // Each phase is separate for testability.
-func Bootstrap(ctx context.Context) (*Application, error) {
+func Bootstrap(ctx context.Context, opts Options) (*Application, error) {
 	// 1. Load configuration
 	cfg, warnings, err := loadConfig()
 	if err != nil {
@@ -37,7 +54,7 @@ func Bootstrap(ctx context.Context) (*Application, error) {
 
 	// 1.5. Clean up old session data
 	cleanupOpts := maintenance.CleanupOptions{
-		CosmosDir:   ".cosmos",
+		CosmosDir:   cosmosDir,
 		SessionsDir: cfg.SessionsDir,
 		MaxAge:      30 * 24 * time.Hour,
 		DryRun:      false,
@@ -55,36 +72,54 @@ func Bootstrap(ctx context.Context) (*Application, error) {
 		fmt.Fprintf(os.Stderr, "cosmos: cleaned up old session data: %d files\n", totalDeleted)
 	}
 
-	// 2. Initialize currency formatter
-	currencyFormatter, err := setupCurrencyFormatter(ctx, cfg)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "cosmos: warning: currency setup failed: %v\n", err)
-		currencyFormatter = core.DefaultCurrencyFormatter()
+	// 1.6. Offer to resume a session that did not exit cleanly
+	var resumed *core.RecoveredSession
+	if isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		resumed = offerResume(unfinishedSessions(journalDir), cfg.SessionsDir, os.Stdin, os.Stdout)
 	}
 
-	// 3. Initialize LLM provider
-	llmProvider, err := setupProvider(ctx, cfg)
-	if err != nil {
-		return nil, fmt.Errorf("initializing provider: %w", err)
-	}
+	// 2. Initialize currency formatter
+	currencyFormatter := displayCurrency(ctx, cfg, opts)
 
-	// 4. Set up UI and notifier
+	// 3. Set up UI and notifier
 	scaffold := ui.NewScaffold()
 	notifier := scaffold.GetNotifier()
 
-	// 5. Create pricing tracker with UI callbacks
+	// 4. Initialize LLM provider
+	llmProvider, err := selectProvider(ctx, &cfg, opts, func(served router.Served) {
+		notifier.Send(ui.StatusItemUpdateMsg{
+			Key:   "backend",
+			Value: formatServed(served),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 5. Create pricing tracker with UI callbacks and spend limits
 	tracker := setupTracker(notifier, currencyFormatter)
+	setupBudget(tracker, cfg)
 
 	// 6. Create core session (executor, tools, adapter, snapshotter)
-	sr, err := setupSession(ctx, cfg, llmProvider, tracker, notifier)
+	adapter := &coreNotifierAdapter{ui: notifier, cosmosDir: cosmosDir}
+	sr, err := setupSession(ctx, cfg, llmProvider, tracker, adapter, resumed)
 	if err != nil {
 		return nil, fmt.Errorf("initializing session: %w", err)
 	}
+	ledger := setupLedger(tracker, cfg, sr.session.ID())
+	scaffold.SetUsageReportFunc(usageReport(cfg.UsageLedger, currencyFormatter))
+
 	// From here, failures must clean up the executor (V8 isolates).
 	cleanup := func() {
 		if sr.executor != nil {
 			sr.executor.Close()
 		}
+		if ledger != nil {
+			_ = ledger.Close()
+		}
+		if sr.journal != nil {
+			_ = sr.journal.Close()
+		}
 	}
 
 	// Build restore function for Changelog UI.
@@ -126,7 +161,10 @@ func Bootstrap(ctx context.Context) (*Application, error) {
 		Program:           program,
 		CurrencyFormatter: currencyFormatter,
 		Tracker:           tracker,
+		Ledger:            ledger,
+		Journal:           sr.journal,
 		Executor:          sr.executor,
+		Provider:          llmProvider,
 	}, nil
 }
 
@@ -142,45 +180,204 @@ func loadConfig() (config.Config, []string, error) {
 	return cfg, warnings, nil
 }
 
-// setupCurrencyFormatter initializes currency conversion if needed.
-// Retries up to 3 times with exponential backoff (1s, 2s, 4s) before
-// returning an error that triggers fallback to USD.
+// displayCurrency returns the formatter for the configured currency, falling
+// back to USD with a warning. Offline mode stays in USD: no network.
+func displayCurrency(ctx context.Context, cfg config.Config, opts Options) *core.CurrencyFormatter {
+	if opts.OfflineScript != "" {
+		return core.DefaultCurrencyFormatter()
+	}
+	formatter, err := setupCurrencyFormatter(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cosmos: warning: showing costs in USD: %v\n", err)
+		return core.DefaultCurrencyFormatter()
+	}
+	return formatter
+}
+
+// currencyFetchTimeout bounds the single startup request for an exchange
+// rate; a slow API falls back to the cached rate.
+const currencyFetchTimeout = 5 * time.Second
+
+// setupCurrencyFormatter initializes currency conversion if needed. Rates
+// are cached on disk for currency_cache_ttl hours; when the currency API
+// is unreachable the last cached rate is used, with a warning. An error
+// means no rate is known at all.
 func setupCurrencyFormatter(ctx context.Context, cfg config.Config) (*core.CurrencyFormatter, error) {
 	if cfg.Currency == "USD" {
 		return core.DefaultCurrencyFormatter(), nil
 	}
 
-	engine := core.NewCurrencyEngine(&http.Client{})
+	engine := core.NewCurrencyEngineWithBaseURL(&http.Client{Timeout: currencyFetchTimeout}, cfg.CurrencyAPIURL)
+	engine.SetCache(cfg.CurrencyCacheDir, time.Duration(cfg.CurrencyCacheTTL)*time.Hour)
 
-	var lastErr error
-	for attempt := range 3 {
-		rate, err := engine.FetchRate(ctx, "USD", cfg.Currency)
-		if err == nil {
-			symbol := core.CurrencySymbol(cfg.Currency)
-			return core.NewCurrencyFormatter(cfg.Currency, symbol, rate), nil
+	rate, err := engine.Rate(ctx, "USD", cfg.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("no USD→%s rate available: %w", cfg.Currency, err)
+	}
+	if rate.Stale {
+		fmt.Fprintf(os.Stderr, "cosmos: warning: currency API unreachable, using the USD→%s rate from %s\n",
+			rate.To, rate.FetchedAt.Local().Format(time.DateOnly))
+	}
+	return core.NewCurrencyFormatter(cfg.Currency, core.CurrencySymbol(cfg.Currency), rate.Rate), nil
+}
+
+// selectProvider returns the scripted fake provider in offline mode, making
+// its model the default, and otherwise the configured provider.
+func selectProvider(ctx context.Context, cfg *config.Config, opts Options, onServe func(router.Served)) (provider.Provider, error) {
+	if opts.OfflineScript != "" {
+		fakeProvider, err := fake.NewFromFile(opts.OfflineScript)
+		if err != nil {
+			return nil, fmt.Errorf("loading offline script: %w", err)
 		}
-		lastErr = err
-
-		// Exponential backoff: 1s, 2s, 4s
-		backoff := time.Duration(1<<attempt) * time.Second
-		select {
-		case <-time.After(backoff):
-		case <-ctx.Done():
-			return nil, fmt.Errorf("currency fetch cancelled: %w", ctx.Err())
+		cfg.DefaultModel = fakeProvider.DefaultModel()
+		return fakeProvider, nil
+	}
+	llmProvider, err := setupProvider(ctx, *cfg, onServe)
+	if err != nil {
+		return nil, fmt.Errorf("initializing provider: %w", err)
+	}
+	return llmProvider, nil
+}
+
+// setupProvider initializes the LLM provider selected by cfg.Provider, or a
+// router over several providers when routes or fallbacks are configured,
+// wrapped in a cassette recorder or replaced by a replayer when requested.
+// onServe is called with the backend that answered each routed request.
+func setupProvider(ctx context.Context, cfg config.Config, onServe func(router.Served)) (provider.Provider, error) {
+	mode, path := cassetteSettings(cfg)
+	switch mode {
+	case "":
+		return newProvider(ctx, cfg, onServe)
+	case "replay":
+		// Replay never touches the network, so no real provider is built.
+		return cassette.NewReplayer(path)
+	case "record":
+		inner, err := newProvider(ctx, cfg, onServe)
+		if err != nil {
+			return nil, err
 		}
+		return cassette.NewRecorder(inner, path)
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q (want \"record\" or \"replay\")", mode)
 	}
+}
 
-	return nil, fmt.Errorf("currency fetch failed after 3 attempts: %w", lastErr)
+// cassetteSettings resolves the cassette mode and path, letting environment
+// variables override the config file. The path defaults to a project-local file.
+func cassetteSettings(cfg config.Config) (mode, path string) {
+	mode, path = cfg.CassetteMode, cfg.CassettePath
+	if env := os.Getenv("COSMOS_CASSETTE_MODE"); env != "" {
+		mode = env
+	}
+	if env := os.Getenv("COSMOS_CASSETTE_PATH"); env != "" {
+		path = env
+	}
+	if path == "" {
+		path = filepath.Join(".cosmos", "cassette.jsonl")
+	}
+	return mode, path
 }
 
-// setupProvider initializes the LLM provider (currently Bedrock).
-func setupProvider(ctx context.Context, cfg config.Config) (provider.Provider, error) {
-	pricingCfg := provider.PricingConfig{
-		Enabled:  cfg.PricingEnabled,
-		CacheDir: cfg.PricingCacheDir,
-		CacheTTL: cfg.PricingCacheTTL,
+// newProvider constructs the real LLM provider selected by cfg.Provider. With
+// routes or fallbacks configured, every referenced backend is constructed
+// and placed behind a router.
+func newProvider(ctx context.Context, cfg config.Config, onServe func(router.Served)) (provider.Provider, error) {
+	def := cfg.Provider
+	if def == "" {
+		def = "bedrock"
+	}
+	if len(cfg.Routes) == 0 && len(cfg.Fallbacks) == 0 {
+		return newBackend(ctx, cfg, def)
+	}
+
+	backends := make(map[string]provider.Provider)
+	for _, name := range append([]string{def}, slices.Sorted(maps.Values(cfg.Routes))...) {
+		if _, ok := backends[name]; ok {
+			continue
+		}
+		p, err := newBackend(ctx, cfg, name)
+		if err != nil {
+			return nil, fmt.Errorf("backend %s: %w", name, err)
+		}
+		backends[name] = p
+	}
+	return router.NewRouter(router.Config{
+		Backends:  backends,
+		Routes:    cfg.Routes,
+		Default:   def,
+		Fallbacks: cfg.Fallbacks,
+		OnServe:   onServe,
+	})
+}
+
+// newBackend constructs a single provider by name.
+func newBackend(ctx context.Context, cfg config.Config, name string) (provider.Provider, error) {
+	switch name {
+	case "bedrock":
+		pricingCfg := provider.PricingConfig{
+			Enabled:  cfg.PricingEnabled,
+			CacheDir: cfg.PricingCacheDir,
+			CacheTTL: cfg.PricingCacheTTL,
+		}
+		return bedrock.NewBedrock(ctx, cfg.AWSRegion, cfg.AWSProfile, pricingCfg)
+	case "anthropic":
+		apiKey := cfg.AnthropicAPIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("ANTHROPIC_API_KEY")
+		}
+		return anthropic.NewAnthropic(apiKey, cfg.AnthropicBaseURL, &http.Client{})
+	case "openai":
+		apiKey := cfg.OpenAIAPIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("OPENAI_API_KEY")
+		}
+		models := make(map[string]provider.ModelInfo, len(cfg.OpenAIModels))
+		for id, m := range cfg.OpenAIModels {
+			models[id] = provider.ModelInfo{
+				ID:              id,
+				Name:            m.Name,
+				ContextWindow:   m.ContextWindow,
+				InputCostPer1M:  m.InputCostPer1M,
+				OutputCostPer1M: m.OutputCostPer1M,
+			}
+		}
+		return openai.NewOpenAI(cfg.OpenAIBaseURL, apiKey, models, &http.Client{}), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q (want \"bedrock\", \"anthropic\" or \"openai\")", name)
 	}
-	return bedrock.NewBedrock(ctx, cfg.AWSRegion, cfg.AWSProfile, pricingCfg)
+}
+
+// priceOverrides converts [pricing.overrides] entries to price table form.
+func priceOverrides(overrides map[string]config.ModelPrice) map[string]pricetable.Price {
+	prices := make(map[string]pricetable.Price, len(overrides))
+	for id, p := range overrides {
+		prices[id] = tablePrice(p)
+	}
+	return prices
+}
+
+func tablePrice(p config.ModelPrice) pricetable.Price {
+	price := pricetable.Price{
+		InputCostPer1M:       p.InputCostPer1M,
+		OutputCostPer1M:      p.OutputCostPer1M,
+		CacheReadCostPer1M:   p.CacheReadCostPer1M,
+		CacheWriteCostPer1M:  p.CacheWriteCostPer1M,
+		LongContextThreshold: p.LongContextThreshold,
+	}
+	if p.LongContext != nil {
+		lc := tablePrice(*p.LongContext)
+		price.LongContext = &lc
+	}
+	return price
+}
+
+// formatServed renders the backend status bar item. The model is shown only
+// when a fallback answered instead of the requested one.
+func formatServed(served router.Served) string {
+	if served.Fallback {
+		return fmt.Sprintf("⇄ %s · %s", served.Backend, served.Model)
+	}
+	return "⇄ " + served.Backend
 }
 
 // setupTracker creates a pricing tracker with UI update callbacks.
@@ -200,27 +397,92 @@ func setupTracker(notifier *ui.Notifier, formatter *core.CurrencyFormatter) *cor
 	)
 }
 
+// setupBudget sets the configured spend limits, counting the ledger's
+// earlier spend toward the daily and project limits.
+func setupBudget(tracker *core.Tracker, cfg config.Config) {
+	b := cfg.Budget
+	limits := core.Budget{Session: b.Session, Daily: b.Daily, Project: b.Project, WarnAt: b.WarnAt}
+	if limits.Daily == 0 && limits.Project == 0 {
+		tracker.SetBudget(limits, core.PriorSpend{})
+		return
+	}
+	entries, err := core.ReadLedger(cfg.UsageLedger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cosmos: warning: reading past spend: %v\n", err)
+	}
+	workDir, _ := os.Getwd()
+	tracker.SetBudget(limits, core.PriorSpendFrom(entries, workDir, time.Now()))
+}
+
+// setupLedger persists the tracker's usage to the ledger. Usage is still
+// tracked for the session if the ledger cannot be opened.
+func setupLedger(tracker *core.Tracker, cfg config.Config, sessionID string) *core.Ledger {
+	workDir, _ := os.Getwd()
+	ledger, err := core.OpenLedger(cfg.UsageLedger, sessionID, workDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cosmos: warning: %v\n", err)
+		return nil
+	}
+	tracker.SetLedger(ledger)
+	return ledger
+}
+
+// usageReport returns the cost breakdown's report loader: the last 30 days
+// of the ledger grouped by day, project, model or source, with costs in the
+// display currency.
+func usageReport(path string, formatter *core.CurrencyFormatter) ui.UsageReportFunc {
+	return func(by string) tea.Cmd {
+		return func() tea.Msg {
+			entries, err := core.ReadLedger(path)
+			if err != nil {
+				return ui.UsageReportMsg{By: by, Err: err}
+			}
+			since := time.Now().AddDate(0, 0, -30)
+			recent := slices.DeleteFunc(entries, func(e core.LedgerEntry) bool { return e.Time.Before(since) })
+			var rows []ui.UsageRow
+			for _, sum := range core.SummarizeUsage(recent, core.UsageGroup(by)) {
+				rows = append(rows, ui.UsageRow{
+					Key:          sum.Key,
+					Requests:     sum.Requests,
+					InputTokens:  sum.InputTokens + sum.CacheReadTokens + sum.CacheWriteTokens,
+					OutputTokens: sum.OutputTokens,
+					Cost:         formatter.Format(sum.CostUSD),
+				})
+			}
+			return ui.UsageReportMsg{By: by, Rows: rows}
+		}
+	}
+}
+
 // setupSessionResult contains everything produced by setupSession.
 type setupSessionResult struct {
 	session     *core.Session
 	tools       []provider.ToolDefinition
 	executor    *runtime.V8Executor
 	snapshotter *vfs.Snapshotter
+	journal     *core.Journal // nil if it could not be opened
 }
 
-// setupSession creates the core session with executor, tools, and event adapter.
+// cosmosDir is the project-local directory for audit logs, snapshots,
+// agent storage and the policy file.
+const cosmosDir = ".cosmos"
+
+// setupSession creates the core session with executor and tools, sending
+// its events to notifier. A resumed session keeps its ID, so its snapshots,
+// audit log and journal continue where they left off.
 func setupSession(
 	_ context.Context,
 	cfg config.Config,
 	llmProvider provider.Provider,
 	tracker *core.Tracker,
-	notifier *ui.Notifier,
+	notifier core.Notifier,
+	resumed *core.RecoveredSession,
 ) (*setupSessionResult, error) {
-	cosmosDir := ".cosmos" // Project-local directory
-	adapter := &coreNotifierAdapter{ui: notifier, cosmosDir: cosmosDir}
-
 	// Create audit logger with session ID
 	sessionID := uuid.New().String()
+	if resumed != nil {
+		sessionID = resumed.SessionID
+	}
 	auditLogger, err := policy.NewAuditLogger(sessionID, cosmosDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "cosmos: warning: audit logger init failed: %v\n", err)
@@ -284,15 +546,37 @@ func setupSession(
 		fmt.Fprintf(os.Stderr, "cosmos: warning: agent %s: %v\n", agentErr.Dir, agentErr.Err)
 	}
 
+	// Retry throttled and transient failures, and abort streams that stall,
+	// so a busy provider no longer ends the turn. Retries show in the chat.
+	// User price overrides apply on top of whatever the provider reports.
+	llmProvider = middleware.Chain(llmProvider,
+		pricetable.Overrides(priceOverrides(cfg.Pricing.Overrides)),
+		middleware.Retry(middleware.RetryConfig{
+			MaxRetries: cfg.ProviderRetries,
+			OnRetry: func(r middleware.RetryInfo) {
+				notifier.Send(core.ProviderRetryEvent{
+					Attempt:    r.Attempt,
+					MaxRetries: r.MaxRetries,
+					Delay:      r.Delay,
+					Reason:     r.Err.Error(),
+				})
+			},
+		}),
+		middleware.StallTimeout(middleware.StallConfig{
+			FirstToken: time.Duration(cfg.FirstTokenTimeout) * time.Second,
+			Idle:       time.Duration(cfg.StreamIdleTimeout) * time.Second,
+		}),
+	)
+
 	// Pass the same sessionID to both audit logger and session
 	session = core.NewSession(
 		sessionID,
 		llmProvider,
 		tracker,
-		adapter,
+		notifier,
 		cfg.DefaultModel,
 		"You are a helpful coding assistant with access to tools.",
-		4096, // MaxTokens
+		cfg.MaxTokens, // 0 derives the limit from the model
 		result.Executor,
 		result.Tools,
 		auditLogger,
@@ -308,22 +592,73 @@ func setupSession(
 		return changes
 	})
 
+	// Let branch switches restore the files changed on the branch left.
+	if snapshotter != nil {
+		session.SetFileRestorer(snapshotter.RestoreInteractions)
+	}
+
 	// Wire configurable permission timeout if set.
 	if cfg.PermissionTimeout > 0 {
 		session.SetPermissionTimeout(time.Duration(cfg.PermissionTimeout) * time.Second)
 	}
 
+	// Wire prompt caching (0 disables).
+	session.SetPromptCaching(cfg.PromptCacheTurns)
+
+	// Wire extended thinking (0 disables).
+	session.SetThinkingBudget(cfg.ThinkingBudget)
+
 	// Wire sessions directory for /restore completions.
 	session.SetSessionsDir(cfg.SessionsDir)
 
+	// Offer the delegate tool for sub-agents.
+	if cfg.Delegate.Enabled {
+		err := session.SetDelegation(core.Delegation{
+			Model:       cfg.Delegate.Model,
+			Tools:       cfg.Delegate.Tools,
+			MaxRequests: cfg.Delegate.MaxRequests,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("[delegate] tools in %s: %w", cfg.ConfigFilePath(), err)
+		}
+	}
+
+	// Journal first, so the repair of a turn cut off by the crash is kept.
+	journal := setupJournal(session, sessionID, resumed, cfg.DefaultModel)
+	if resumed != nil {
+		session.Resume(*resumed)
+	}
+
 	return &setupSessionResult{
 		session:     session,
 		tools:       result.Tools,
 		executor:    result.Executor,
 		snapshotter: snapshotter,
+		journal:     journal,
 	}, nil
 }
 
+// setupJournal opens the session's journal and records every history
+// change in it. Returns nil (no crash safety) if it cannot be opened.
+func setupJournal(session *core.Session, sessionID string, resumed *core.RecoveredSession, model string) *core.Journal {
+	journal, err := core.OpenJournal(journalDir, sessionID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cosmos: warning: session journal: %v\n", err)
+		return nil
+	}
+	if resumed != nil && resumed.Model != "" {
+		model = resumed.Model
+	}
+	workDir, _ := os.Getwd()
+	if err := journal.Start(sessionID, model, workDir); err != nil {
+		fmt.Fprintf(os.Stderr, "cosmos: warning: session journal: %v\n", err)
+		_ = journal.Close()
+		return nil
+	}
+	session.SetJournal(journal)
+	return journal
+}
+
 // configureUI sets up scaffold pages and status bar items.
 func configureUI(scaffold *ui.Scaffold, session *core.Session, tools []provider.ToolDefinition, model string, restoreFunc ui.RestoreFunc) error {
 	// Get current directory for status bar
@@ -3,6 +3,11 @@ package app
 import (
 	"context"
 	"cosmos/config"
+	"cosmos/providers/router"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -35,23 +40,38 @@ func TestSetupCurrencyFormatterUSD(t *testing.T) {
 }
 
 func TestSetupCurrencyFormatterNonUSD(t *testing.T) {
-	// Skip in CI/offline environments
-	if testing.Short() {
-		t.Skip("skipping network test in short mode")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"amount":1,"base":"USD","rates":{"EUR":0.92}}`))
+	}))
+	cfg := config.Config{
+		Currency:         "EUR",
+		CurrencyAPIURL:   ts.URL,
+		CurrencyCacheDir: t.TempDir(),
+		CurrencyCacheTTL: 24,
 	}
-
-	cfg := config.Config{Currency: "EUR"}
 	formatter, err := setupCurrencyFormatter(context.Background(), cfg)
 	if err != nil {
-		// Non-fatal in production (falls back to USD), so just log
-		t.Logf("currency fetch failed (may be expected in CI): %v", err)
-		return
+		t.Fatalf("setupCurrencyFormatter failed: %v", err)
 	}
-	if formatter == nil {
-		t.Fatal("expected non-nil formatter")
+	if formatter.Code != "EUR" || formatter.Rate != 0.92 {
+		t.Errorf("expected EUR at 0.92, got %s at %v", formatter.Code, formatter.Rate)
 	}
-	if formatter.Code != "EUR" {
-		t.Errorf("expected EUR, got %s", formatter.Code)
+
+	// Offline, the cached rate is used, even once it has expired.
+	ts.Close()
+	cfg.CurrencyCacheTTL = 0
+	formatter, err = setupCurrencyFormatter(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("setupCurrencyFormatter offline failed: %v", err)
+	}
+	if formatter.Rate != 0.92 {
+		t.Errorf("expected cached rate 0.92, got %v", formatter.Rate)
+	}
+
+	// With no cached rate there is nothing to fall back to.
+	cfg.CurrencyCacheDir = t.TempDir()
+	if _, err := setupCurrencyFormatter(context.Background(), cfg); err == nil {
+		t.Error("expected an error offline without a cached rate")
 	}
 }
 
@@ -67,7 +87,7 @@ func TestSetupProvider(t *testing.T) {
 		PricingCacheTTL: 24,
 	}
 
-	provider, err := setupProvider(context.Background(), cfg)
+	provider, err := setupProvider(context.Background(), cfg, nil)
 	if err != nil {
 		t.Fatalf("setupProvider failed: %v", err)
 	}
@@ -76,13 +96,78 @@ func TestSetupProvider(t *testing.T) {
 	}
 }
 
+func TestSetupProviderAnthropic(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	cfg := config.Config{Provider: "anthropic"}
+	if _, err := setupProvider(context.Background(), cfg, nil); err == nil {
+		t.Fatal("expected error when no Anthropic API key is configured")
+	}
+
+	// Environment variable is used when the config key is empty.
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	provider, err := setupProvider(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatalf("setupProvider failed: %v", err)
+	}
+	if provider == nil {
+		t.Fatal("expected non-nil provider")
+	}
+}
+
+func TestSetupProviderUnknown(t *testing.T) {
+	cfg := config.Config{Provider: "openai-ish"}
+	if _, err := setupProvider(context.Background(), cfg, nil); err == nil {
+		t.Fatal("expected error for unknown provider")
+	}
+}
+
+func TestSetupProviderRouted(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+
+	cfg := config.Config{
+		Provider:  "openai",
+		Routes:    map[string]string{"claude-": "anthropic"},
+		Fallbacks: map[string][]string{"claude-sonnet-4-20250514": {"qwen2.5-coder-32b"}},
+	}
+	prov, err := setupProvider(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatalf("setupProvider failed: %v", err)
+	}
+	r, ok := prov.(*router.Router)
+	if !ok {
+		t.Fatalf("provider = %T, want *router.Router", prov)
+	}
+	if got := r.Route("claude-sonnet-4-20250514"); got != "anthropic" {
+		t.Errorf("Route(claude) = %q, want anthropic", got)
+	}
+	if got := r.Route("qwen2.5-coder-32b"); got != "openai" {
+		t.Errorf("Route(qwen) = %q, want openai", got)
+	}
+
+	cfg.Routes["llama"] = "ollama"
+	if _, err := setupProvider(context.Background(), cfg, nil); err == nil {
+		t.Fatal("expected error for route to unknown provider")
+	}
+}
+
+func TestFormatServed(t *testing.T) {
+	if got := formatServed(router.Served{Backend: "bedrock", Model: "m"}); got != "⇄ bedrock" {
+		t.Errorf("primary = %q", got)
+	}
+	got := formatServed(router.Served{Backend: "openai", Model: "qwen2.5-coder-32b", Fallback: true})
+	if got != "⇄ openai · qwen2.5-coder-32b" {
+		t.Errorf("fallback = %q", got)
+	}
+}
+
 func TestBootstrap(t *testing.T) {
 	// Integration test: full bootstrap
 	// Skip if running in CI without AWS credentials
 	t.Skip("integration test, requires full environment")
 
 	ctx := context.Background()
-	app, err := Bootstrap(ctx)
+	app, err := Bootstrap(ctx, Options{})
 	if err != nil {
 		t.Fatalf("Bootstrap failed: %v", err)
 	}
@@ -114,3 +199,55 @@ func TestSetupTrackerNotNil(t *testing.T) {
 	// We need a real ui.Notifier for this test
 	t.Skip("requires real ui.Notifier, tested in integration test")
 }
+
+func TestSetupProviderOpenAI(t *testing.T) {
+	cfg := config.Config{
+		Provider:      "openai",
+		OpenAIBaseURL: "http://localhost:11434",
+		OpenAIModels: map[string]config.ModelConfig{
+			"llama3": {ContextWindow: 8192},
+		},
+	}
+	provider, err := setupProvider(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatalf("setupProvider failed: %v", err)
+	}
+	if provider == nil {
+		t.Fatal("expected non-nil provider")
+	}
+}
+
+func TestCassetteSettingsEnvOverride(t *testing.T) {
+	t.Setenv("COSMOS_CASSETTE_MODE", "")
+	t.Setenv("COSMOS_CASSETTE_PATH", "")
+
+	mode, path := cassetteSettings(config.Config{CassetteMode: "record"})
+	if mode != "record" || path != filepath.Join(".cosmos", "cassette.jsonl") {
+		t.Errorf("config only: got (%q, %q)", mode, path)
+	}
+
+	t.Setenv("COSMOS_CASSETTE_MODE", "replay")
+	t.Setenv("COSMOS_CASSETTE_PATH", "/tmp/bug-report.jsonl")
+	mode, path = cassetteSettings(config.Config{CassetteMode: "record", CassettePath: "x.jsonl"})
+	if mode != "replay" || path != "/tmp/bug-report.jsonl" {
+		t.Errorf("env override: got (%q, %q)", mode, path)
+	}
+}
+
+func TestSetupProviderReplayNeedsNoCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("COSMOS_CASSETTE_MODE", "replay")
+	t.Setenv("COSMOS_CASSETTE_PATH", path)
+
+	// An unknown provider would fail if it were constructed.
+	prov, err := setupProvider(context.Background(), config.Config{Provider: "none"}, nil)
+	if err != nil {
+		t.Fatalf("setupProvider: %v", err)
+	}
+	if prov == nil {
+		t.Fatal("expected non-nil provider")
+	}
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"bufio"
+	"cosmos/core"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// journalDir holds the journals of this project's sessions, next to their
+// snapshots and audit logs.
+var journalDir = filepath.Join(cosmosDir, "journal")
+
+// unfinishedSessions returns the sessions journaled in dir whose process is
+// no longer running, most recent first.
+func unfinishedSessions(dir string) []core.RecoveredSession {
+	sessions, err := core.FindJournals(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cosmos: warning: %v\n", err)
+		return nil
+	}
+	var unfinished []core.RecoveredSession
+	for _, r := range sessions {
+		if r.PID == 0 || !processAlive(r.PID) {
+			unfinished = append(unfinished, r)
+		}
+	}
+	return unfinished
+}
+
+// processAlive reports whether a process with the given PID is running.
+// Where that cannot be checked, it is assumed not to be.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// offerResume asks which of the unfinished sessions to resume and returns
+// it, or nil to start a new session. The sessions not resumed are saved to
+// sessionsDir, where /restore finds them, and their journals removed.
+func offerResume(sessions []core.RecoveredSession, sessionsDir string, in io.Reader, out io.Writer) *core.RecoveredSession {
+	if len(sessions) == 0 {
+		return nil
+	}
+	fmt.Fprintln(out, "cosmos: found sessions that did not exit cleanly:")
+	for i, r := range sessions {
+		fmt.Fprintf(out, "  %d) %s  %q  %d messages\n",
+			i+1, r.UpdatedAt.Local().Format("2006-01-02 15:04"), r.Description(), len(r.History))
+	}
+	if len(sessions) == 1 {
+		fmt.Fprint(out, "Resume it? [Y/n] ")
+	} else {
+		fmt.Fprintf(out, "Resume which? [1-%d, Enter for a new session] ", len(sessions))
+	}
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	choice := parseResumeChoice(strings.TrimSpace(answer), len(sessions))
+
+	var resumed *core.RecoveredSession
+	for i := range sessions {
+		if i == choice {
+			resumed = &sessions[i]
+			continue
+		}
+		if err := core.SaveRecoveredSession(sessions[i], sessionsDir); err != nil {
+			fmt.Fprintf(os.Stderr, "cosmos: warning: keeping journal %s: %v\n", sessions[i].Path, err)
+			continue
+		}
+		if err := os.Remove(sessions[i].Path); err != nil {
+			fmt.Fprintf(os.Stderr, "cosmos: warning: %v\n", err)
+		}
+	}
+	if resumed == nil {
+		fmt.Fprintln(out, "cosmos: saved them for /restore.")
+	}
+	return resumed
+}
+
+// parseResumeChoice returns the index of the session chosen by answer, or
+// -1 for none. With a single session, Enter or "y" resumes it.
+func parseResumeChoice(answer string, n int) int {
+	if n == 1 {
+		switch strings.ToLower(answer) {
+		case "", "y", "yes", "1":
+			return 0
+		}
+		return -1
+	}
+	i, err := strconv.Atoi(answer)
+	if err != nil || i < 1 || i > n {
+		return -1
+	}
+	return i - 1
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
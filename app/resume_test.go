@@ -0,0 +1,72 @@
+package app
+
+import (
+	"bytes"
+	"cosmos/core"
+	"cosmos/core/provider"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseResumeChoice(t *testing.T) {
+	tests := []struct {
+		answer string
+		n      int
+		want   int
+	}{
+		{"", 1, 0},
+		{"Y", 1, 0},
+		{"n", 1, -1},
+		{"", 3, -1},
+		{"2", 3, 1},
+		{"4", 3, -1},
+		{"x", 3, -1},
+	}
+	for _, tt := range tests {
+		if got := parseResumeChoice(tt.answer, tt.n); got != tt.want {
+			t.Errorf("parseResumeChoice(%q, %d) = %d, want %d", tt.answer, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestOfferResumeSavesTheOthers(t *testing.T) {
+	journals := t.TempDir()
+	var sessions []core.RecoveredSession
+	for i, id := range []string{"new", "old"} {
+		path := filepath.Join(journals, id+".jsonl")
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, core.RecoveredSession{
+			Path:      path,
+			SessionID: id,
+			WorkDir:   "/work/proj",
+			UpdatedAt: time.Date(2025, 6, 15, 9-i, 0, 0, 0, time.UTC),
+			History:   []provider.Message{{Role: provider.RoleUser, Content: "Prompt " + id}},
+		})
+	}
+
+	sessionsDir := t.TempDir()
+	var out bytes.Buffer
+	resumed := offerResume(sessions, sessionsDir, strings.NewReader("1\n"), &out)
+	if resumed == nil || resumed.SessionID != "new" {
+		t.Fatalf("resumed = %+v, want the first session", resumed)
+	}
+	if !strings.Contains(out.String(), `"Prompt old"`) || !strings.Contains(out.String(), "Resume which? [1-2") {
+		t.Errorf("prompt = %q", out.String())
+	}
+
+	if _, err := os.Stat(sessions[0].Path); err != nil {
+		t.Errorf("resumed journal: %v, want it kept", err)
+	}
+	if _, err := os.Stat(sessions[1].Path); !os.IsNotExist(err) {
+		t.Errorf("declined journal: %v, want it removed", err)
+	}
+	saved, err := core.ListSavedSessions(sessionsDir)
+	if err != nil || len(saved) != 1 || saved[0].Description != "Prompt old" {
+		t.Errorf("saved sessions = %+v, %v", saved, err)
+	}
+}
@@ -0,0 +1,370 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"cosmos/core"
+	"cosmos/core/provider"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Permission modes of `cosmos run`; any other value names an allowlist file.
+const (
+	permissionDeny       = "deny"        // refuse every permission that needs approval
+	permissionPolicyOnly = "policy-only" // grant only what the project policy allows
+)
+
+// runOptions configures `cosmos run`.
+type runOptions struct {
+	prompt     string
+	json       bool
+	model      string // "" for the configured default
+	permission core.PermissionResolver
+}
+
+// RunHeadless implements `cosmos run`: a single prompt, given with -p or on
+// stdin, run to completion without the TUI, e.g.
+//
+//	cosmos run -p "Fix the failing test" -permission-mode allow.txt -json
+//
+// The reply streams to stdout as plain text, or with -json as JSON lines of
+// the session's events. Nobody can answer permission prompts, so
+// -permission-mode decides them. It fails if the turn does.
+func RunHeadless(ctx context.Context, opts Options, args []string, stdin io.Reader, stdout io.Writer) error {
+	ropts, err := parseRunArgs(args, stdin)
+	if err != nil {
+		return err
+	}
+	cfg, warnings, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "cosmos: warning: %s\n", w)
+	}
+	if ropts.model != "" {
+		cfg.DefaultModel = ropts.model
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	formatter := displayCurrency(ctx, cfg, opts)
+	llmProvider, err := selectProvider(ctx, &cfg, opts, nil)
+	if err != nil {
+		return err
+	}
+	defer closeProvider(llmProvider)
+
+	out := newRunPrinter(stdout, os.Stderr, ropts.json)
+	tracker := core.NewTracker(out.cost, formatter)
+	setupBudget(tracker, cfg)
+
+	sr, err := setupSession(ctx, cfg, llmProvider, tracker, out, nil)
+	if err != nil {
+		return fmt.Errorf("initializing session: %w", err)
+	}
+	if sr.executor != nil {
+		defer sr.executor.Close()
+	}
+	ledger := setupLedger(tracker, cfg, sr.session.ID())
+	sr.session.SetPermissionResolver(ropts.permission)
+
+	runErr := sr.session.Process(ctx, ropts.prompt)
+	if runErr == nil {
+		runErr = out.halted()
+	}
+	if runErr == nil && ctx.Err() != nil {
+		runErr = fmt.Errorf("interrupted")
+	}
+	out.finish(sr.session.ID(), lastReply(sr.session.HistorySnapshot()), tracker.Snapshot(), runErr)
+
+	saveSession(sr.session, tracker, cfg, sr.journal, ledger)
+	return runErr
+}
+
+func parseRunArgs(args []string, stdin io.Reader) (runOptions, error) {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	prompt := fs.String("p", "", "the `prompt` to run; read from stdin if not set")
+	asJSON := fs.Bool("json", false, "print the session's events as JSON lines instead of the reply")
+	model := fs.String("model", "", "use this `model` instead of the configured default")
+	mode := fs.String("permission-mode", permissionPolicyOnly, "decide permission prompts by `mode`: deny, policy-only, or an allowlist file")
+	if err := fs.Parse(args); err != nil {
+		return runOptions{}, err
+	}
+	if fs.NArg() > 0 {
+		return runOptions{}, fmt.Errorf("unexpected argument %q (quote the prompt after -p)", fs.Arg(0))
+	}
+
+	text := *prompt
+	if text == "" {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return runOptions{}, fmt.Errorf("reading prompt: %w", err)
+		}
+		text = string(data)
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return runOptions{}, errors.New("no prompt: pass it with -p or on stdin")
+	}
+
+	resolver, err := permissionResolver(*mode)
+	if err != nil {
+		return runOptions{}, fmt.Errorf("-permission-mode: %w", err)
+	}
+	return runOptions{prompt: text, json: *asJSON, model: *model, permission: resolver}, nil
+}
+
+// permissionResolver decides permission prompts by mode. deny refuses them
+// all, even permissions granted earlier; policy-only grants those the
+// project policy allows; an allowlist file also grants the permissions it
+// lists.
+func permissionResolver(mode string) (core.PermissionResolver, error) {
+	switch mode {
+	case permissionDeny:
+		return func(string, string, bool) bool { return false }, nil
+	case permissionPolicyOnly:
+		return func(_, _ string, granted bool) bool { return granted }, nil
+	}
+	allowed, err := readAllowlist(mode)
+	if err != nil {
+		return nil, err
+	}
+	return func(agentName, permission string, granted bool) bool {
+		return granted || allowed[permission] || allowed[agentName+" "+permission]
+	}, nil
+}
+
+// readAllowlist reads a permission allowlist: one permission key per line,
+// as declared in agent manifests (e.g. fs:write:./src/**), optionally
+// preceded by the agent it is granted to. Blank lines and lines starting
+// with # are ignored.
+func readAllowlist(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("want deny, policy-only or an allowlist file: %w", err)
+	}
+	defer f.Close()
+
+	allowed := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("%s:%d: want [agent] permission", path, n)
+		}
+		allowed[strings.Join(fields, " ")] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return allowed, nil
+}
+
+// lastReply returns the text of the last reply in history.
+func lastReply(history []provider.Message) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		if m := history[i]; m.Role == provider.RoleAssistant && m.Content != "" {
+			return m.Content
+		}
+	}
+	return ""
+}
+
+// runEvent is one line of `cosmos run -json` output. Type selects which of
+// the other fields are set.
+type runEvent struct {
+	Type string `json:"type"` // token, toolUse, toolResult, fileChange, cost, retry, error, done
+
+	Text  string `json:"text,omitempty"`  // token, retry; done: the final reply
+	Error string `json:"error,omitempty"` // error; done: why the run failed
+
+	ToolCallID string          `json:"toolCallId,omitempty"` // toolUse, toolResult, fileChange
+	ToolName   string          `json:"toolName,omitempty"`   // toolUse, toolResult, fileChange
+	Input      json.RawMessage `json:"input,omitempty"`      // toolUse
+	Result     string          `json:"result,omitempty"`     // toolResult
+	IsError    bool            `json:"isError,omitempty"`    // toolResult
+
+	InteractionID string          `json:"interactionId,omitempty"` // fileChange
+	Changes       []runFileChange `json:"changes,omitempty"`       // fileChange
+
+	*runCost // cost, done
+
+	SessionID string `json:"sessionId,omitempty"` // done
+}
+
+type runFileChange struct {
+	Path      string `json:"path"`
+	Operation string `json:"operation"`
+	WasNew    bool   `json:"wasNew,omitempty"`
+}
+
+// runCost is the session's usage so far.
+type runCost struct {
+	InputTokens      int     `json:"inputTokens"`
+	OutputTokens     int     `json:"outputTokens"`
+	CacheReadTokens  int     `json:"cacheReadTokens,omitempty"`
+	CacheWriteTokens int     `json:"cacheWriteTokens,omitempty"`
+	CostUSD          float64 `json:"costUSD"`
+	Cost             string  `json:"cost"` // in the display currency
+}
+
+func newRunCost(snap core.CostSnapshot) *runCost {
+	return &runCost{
+		InputTokens:      snap.TotalInputTokens,
+		OutputTokens:     snap.TotalOutputTokens,
+		CacheReadTokens:  snap.TotalCacheReadTokens,
+		CacheWriteTokens: snap.TotalCacheWriteTokens,
+		CostUSD:          snap.TotalCost,
+		Cost:             snap.FormatCost(),
+	}
+}
+
+// runPrinter is the core.Notifier of `cosmos run`. As plain text, the reply
+// goes to stdout and tool activity, errors and the cost to stderr; as JSON,
+// every event goes to stdout.
+type runPrinter struct {
+	mu       sync.Mutex
+	out      io.Writer
+	log      io.Writer
+	json     *json.Encoder // nil for plain text
+	midLine  bool          // the reply printed so far does not end in a newline
+	exceeded *core.BudgetExceededEvent
+}
+
+func newRunPrinter(out, log io.Writer, asJSON bool) *runPrinter {
+	p := &runPrinter{out: out, log: log}
+	if asJSON {
+		p.json = json.NewEncoder(out)
+	}
+	return p
+}
+
+// Send prints an event of the session. Events the output does not cover,
+// such as context usage, are dropped.
+func (p *runPrinter) Send(msg any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := msg.(core.BudgetExceededEvent); ok {
+		p.exceeded = &e
+	}
+	if p.json != nil {
+		if ev, ok := jsonRunEvent(msg); ok {
+			_ = p.json.Encode(ev)
+		}
+		return
+	}
+
+	switch e := msg.(type) {
+	case core.TokenEvent:
+		if e.Text != "" {
+			fmt.Fprint(p.out, e.Text)
+			p.midLine = !strings.HasSuffix(e.Text, "\n")
+		}
+	case core.ToolUseEvent:
+		p.endLine()
+		fmt.Fprintf(p.log, "cosmos: running %s\n", e.ToolName)
+	case core.ToolResultEvent:
+		if e.IsError {
+			first, _, _ := strings.Cut(e.Result, "\n")
+			fmt.Fprintf(p.log, "cosmos: %s failed: %s\n", e.ToolName, first)
+		}
+	case core.FileChangeEvent:
+		for _, c := range e.Changes {
+			fmt.Fprintf(p.log, "cosmos: %s %s\n", c.Operation, c.Path)
+		}
+	case core.ProviderRetryEvent:
+		fmt.Fprintf(p.log, "cosmos: retrying in %s (attempt %d of %d): %s\n", e.Delay, e.Attempt, e.MaxRetries, e.Reason)
+	case core.ErrorEvent:
+		p.endLine()
+		fmt.Fprintf(p.log, "cosmos: %s\n", e.Error)
+	}
+}
+
+// jsonRunEvent converts a core event to its -json line.
+func jsonRunEvent(msg any) (runEvent, bool) {
+	switch e := msg.(type) {
+	case core.TokenEvent:
+		return runEvent{Type: "token", Text: e.Text}, true
+	case core.ToolUseEvent:
+		ev := runEvent{Type: "toolUse", ToolCallID: e.ToolCallID, ToolName: e.ToolName}
+		if json.Valid([]byte(e.Input)) {
+			ev.Input = json.RawMessage(e.Input)
+		}
+		return ev, true
+	case core.ToolResultEvent:
+		return runEvent{Type: "toolResult", ToolCallID: e.ToolCallID, ToolName: e.ToolName, Result: e.Result, IsError: e.IsError}, true
+	case core.FileChangeEvent:
+		changes := make([]runFileChange, len(e.Changes))
+		for i, c := range e.Changes {
+			changes[i] = runFileChange{Path: c.Path, Operation: c.Operation, WasNew: c.WasNew}
+		}
+		return runEvent{Type: "fileChange", ToolCallID: e.ToolCallID, ToolName: e.ToolName, InteractionID: e.InteractionID, Changes: changes}, true
+	case core.ProviderRetryEvent:
+		return runEvent{Type: "retry", Text: e.Reason}, true
+	case core.ErrorEvent:
+		return runEvent{Type: "error", Error: e.Error}, true
+	}
+	return runEvent{}, false
+}
+
+// cost is the tracker's update callback, reporting the spend after every
+// model request.
+func (p *runPrinter) cost(snap core.CostSnapshot) {
+	if p.json == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_ = p.json.Encode(runEvent{Type: "cost", runCost: newRunCost(snap)})
+}
+
+// halted returns an error if the turn was stopped by a used-up budget.
+func (p *runPrinter) halted() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e := p.exceeded; e != nil {
+		return fmt.Errorf("%s budget exceeded: $%.2f of $%.2f spent", e.Scope, e.Spent, e.Limit)
+	}
+	return nil
+}
+
+// finish ends the output: a done event with the final reply, or the reply's
+// last newline and the cost.
+func (p *runPrinter) finish(sessionID, reply string, snap core.CostSnapshot, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.json != nil {
+		ev := runEvent{Type: "done", Text: reply, SessionID: sessionID, runCost: newRunCost(snap)}
+		if err != nil {
+			ev.Error = err.Error()
+		}
+		_ = p.json.Encode(ev)
+		return
+	}
+	p.endLine()
+	fmt.Fprintf(p.log, "cosmos: %s tokens, %s\n", snap.FormatTokens(), snap.FormatCost())
+}
+
+// endLine ends a reply printed without a trailing newline. Caller must hold
+// p.mu.
+func (p *runPrinter) endLine() {
+	if p.midLine {
+		fmt.Fprintln(p.out)
+		p.midLine = false
+	}
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"bytes"
+	"cosmos/core"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRunArgs(t *testing.T) {
+	opts, err := parseRunArgs([]string{"-p", "Fix it", "-json", "-model", "m"}, strings.NewReader("ignored"))
+	if err != nil {
+		t.Fatalf("parseRunArgs: %v", err)
+	}
+	if opts.prompt != "Fix it" || !opts.json || opts.model != "m" {
+		t.Errorf("opts = %+v", opts)
+	}
+	if opts.permission("fs", "fs:write", false) || !opts.permission("fs", "fs:write", true) {
+		t.Error("default permission mode should be policy-only")
+	}
+
+	opts, err = parseRunArgs(nil, strings.NewReader("  From stdin\n"))
+	if err != nil || opts.prompt != "From stdin" {
+		t.Errorf("stdin prompt = %q, %v", opts.prompt, err)
+	}
+
+	if _, err := parseRunArgs(nil, strings.NewReader("\n")); err == nil {
+		t.Error("expected an error without a prompt")
+	}
+	if _, err := parseRunArgs([]string{"-p", "x", "-permission-mode", "no-such-file"}, nil); err == nil {
+		t.Error("expected an error for a missing allowlist")
+	}
+	if _, err := parseRunArgs([]string{"Fix", "it"}, nil); err == nil {
+		t.Error("expected an error for an unquoted prompt")
+	}
+}
+
+func TestPermissionResolverModes(t *testing.T) {
+	deny, _ := permissionResolver("deny")
+	if deny("fs", "fs:read", true) {
+		t.Error("deny granted a permission")
+	}
+
+	path := filepath.Join(t.TempDir(), "allow.txt")
+	list := "# CI permissions\nfs:write:./src/**\n\nweb net:http\n"
+	if err := os.WriteFile(path, []byte(list), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	allow, err := permissionResolver(path)
+	if err != nil {
+		t.Fatalf("permissionResolver: %v", err)
+	}
+	tests := []struct {
+		agent, permission string
+		granted, want     bool
+	}{
+		{"fs", "fs:write:./src/**", false, true},
+		{"other", "fs:write:./src/**", false, true},
+		{"web", "net:http", false, true},
+		{"other", "net:http", false, false},
+		{"fs", "fs:unlink", false, false},
+		{"fs", "fs:unlink", true, true},
+	}
+	for _, tt := range tests {
+		if got := allow(tt.agent, tt.permission, tt.granted); got != tt.want {
+			t.Errorf("allowlist(%s, %s, %v) = %v, want %v", tt.agent, tt.permission, tt.granted, got, tt.want)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("a b c\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := permissionResolver(path); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("bad line error = %v", err)
+	}
+}
+
+func TestRunPrinterText(t *testing.T) {
+	var out, log bytes.Buffer
+	p := newRunPrinter(&out, &log, false)
+	p.Send(core.TokenEvent{Text: "Let me look."})
+	p.Send(core.ToolUseEvent{ToolName: "read_file", Input: `{}`})
+	p.Send(core.ToolResultEvent{ToolName: "read_file", Result: "denied\nmore", IsError: true})
+	p.Send(core.TokenEvent{Text: "Done."})
+	p.Send(core.ContextUpdateEvent{Percentage: 10})
+	p.finish("id", "Done.", core.CostSnapshot{}, nil)
+
+	if out.String() != "Let me look.\nDone.\n" {
+		t.Errorf("stdout = %q", out.String())
+	}
+	if !strings.Contains(log.String(), "cosmos: running read_file\ncosmos: read_file failed: denied\n") {
+		t.Errorf("stderr = %q", log.String())
+	}
+}
+
+func TestRunPrinterJSON(t *testing.T) {
+	var out bytes.Buffer
+	p := newRunPrinter(&out, nil, true)
+	p.Send(core.TokenEvent{Text: "Hi"})
+	p.Send(core.ToolUseEvent{ToolCallID: "c1", ToolName: "write_file", Input: `{"path":"a.go"}`})
+	p.Send(core.FileChangeEvent{ToolCallID: "c1", InteractionID: "i1", Changes: []core.FileChange{{Path: "a.go", Operation: "write", WasNew: true}}})
+	p.Send(core.ThinkingEvent{Text: "hmm"})
+	p.cost(core.CostSnapshot{TotalInputTokens: 10, TotalCost: 0.5})
+	p.Send(core.BudgetExceededEvent{Scope: core.BudgetSession, Spent: 0.5, Limit: 0.4})
+	err := p.halted()
+	if err == nil {
+		t.Fatal("halted() = nil after BudgetExceededEvent")
+	}
+	p.finish("id", "Hi", core.CostSnapshot{}, err)
+
+	var events []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var ev map[string]any
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		events = append(events, ev)
+	}
+	var types []string
+	for _, ev := range events {
+		types = append(types, ev["type"].(string))
+	}
+	if got := strings.Join(types, " "); got != "token toolUse fileChange cost done" {
+		t.Fatalf("event types = %s", got)
+	}
+	if input := events[1]["input"].(map[string]any); input["path"] != "a.go" {
+		t.Errorf("toolUse input = %v", events[1]["input"])
+	}
+	if changes := events[2]["changes"].([]any); changes[0].(map[string]any)["wasNew"] != true {
+		t.Errorf("fileChange = %v", events[2])
+	}
+	if events[3]["costUSD"] != 0.5 || events[3]["inputTokens"] != 10.0 {
+		t.Errorf("cost = %v", events[3])
+	}
+	if done := events[4]; done["sessionId"] != "id" || done["text"] != "Hi" || !strings.Contains(done["error"].(string), "budget exceeded") {
+		t.Errorf("done = %v", done)
+	}
+}
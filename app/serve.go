@@ -0,0 +1,399 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"cosmos/core"
+	"cosmos/core/provider"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+)
+
+// RunServer implements `cosmos serve --stdio`: a session driven over
+// JSON-RPC 2.0 by an editor plugin, one message per line on stdin and
+// stdout. Methods:
+//
+//	session/submit      {"text"}           queue a prompt or slash command
+//	session/cancel                         cancel the turn in progress
+//	session/history                        {"messages"}: the conversation
+//	models/list                            {"models"}: the provider's models
+//	permission/respond  {"toolCallId", "allowed", "remember"}
+//	changelog/restore   {"interactionId"}  {"paths"}: the files restored
+//
+// Every core event is sent as an "event/<type>" notification, e.g.
+// event/TokenEvent with the event's fields as params, and the spend after
+// each model request as session/cost. The server exits at the end of stdin.
+func RunServer(ctx context.Context, opts Options, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	stdio := fs.Bool("stdio", false, "serve on stdin and stdout (the only transport)")
+	model := fs.String("model", "", "use this `model` instead of the configured default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*stdio {
+		return errors.New("no transport: pass --stdio")
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	cfg, warnings, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "cosmos: warning: %s\n", w)
+	}
+	if *model != "" {
+		cfg.DefaultModel = *model
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	formatter := displayCurrency(ctx, cfg, opts)
+	llmProvider, err := selectProvider(ctx, &cfg, opts, nil)
+	if err != nil {
+		return err
+	}
+	defer closeProvider(llmProvider)
+
+	srv := newRPCServer(stdout)
+	tracker := core.NewTracker(srv.cost, formatter)
+	setupBudget(tracker, cfg)
+
+	sr, err := setupSession(ctx, cfg, llmProvider, tracker, srv, nil)
+	if err != nil {
+		return fmt.Errorf("initializing session: %w", err)
+	}
+	if sr.executor != nil {
+		defer sr.executor.Close()
+	}
+	ledger := setupLedger(tracker, cfg, sr.session.ID())
+	srv.session = sr.session
+	srv.provider = llmProvider
+	if sr.snapshotter != nil {
+		srv.restore = sr.snapshotter.RestoreInteraction
+	}
+
+	sessionCtx, cancel := context.WithCancel(ctx)
+	sr.session.Start(sessionCtx)
+	err = srv.serve(ctx, stdin)
+	cancel()
+	sr.session.Stop()
+
+	saveSession(sr.session, tracker, cfg, sr.journal, ledger)
+	return err
+}
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000 // the method failed
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+func invalidParams(format string, args ...any) *rpcError {
+	return &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// rpcServer serves a session over JSON-RPC and is its core.Notifier.
+// Requests are handled one at a time in the order they arrive; the session
+// runs turns in its own goroutine, so none of them blocks for long.
+type rpcServer struct {
+	session  *core.Session
+	provider provider.Provider
+	restore  func(interactionID string) ([]string, error) // nil when snapshots are off
+
+	mu  sync.Mutex // serializes writes to out
+	out *json.Encoder
+
+	// pending are the permission prompts awaiting permission/respond, by
+	// tool call ID.
+	pendingMu sync.Mutex
+	pending   map[string]chan<- core.PermissionResponse
+}
+
+func newRPCServer(out io.Writer) *rpcServer {
+	return &rpcServer{
+		out:     json.NewEncoder(out),
+		pending: make(map[string]chan<- core.PermissionResponse),
+	}
+}
+
+// serve handles the requests read from in until it ends or ctx is done.
+func (s *rpcServer) serve(ctx context.Context, in io.Reader) error {
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			return err
+		case line := <-lines:
+			if reply := s.handleLine(ctx, line); reply != nil {
+				s.write(reply)
+			}
+		}
+	}
+}
+
+// handleLine handles a request or a batch of them and returns the reply to
+// write, or nil when there is none (notifications only).
+func (s *rpcServer) handleLine(ctx context.Context, line []byte) any {
+	line = bytes.TrimSpace(line)
+	if line[0] != '[' {
+		if resp := s.handleMessage(ctx, line); resp != nil {
+			return resp
+		}
+		return nil
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(line, &batch); err != nil {
+		return errorResponse(nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+	}
+	if len(batch) == 0 {
+		return errorResponse(nil, &rpcError{Code: rpcInvalidRequest, Message: "empty batch"})
+	}
+	var replies []*rpcResponse
+	for _, msg := range batch {
+		if resp := s.handleMessage(ctx, msg); resp != nil {
+			replies = append(replies, resp)
+		}
+	}
+	if len(replies) == 0 {
+		return nil
+	}
+	return replies
+}
+
+// handleMessage handles one request and returns its response, or nil for a
+// notification.
+func (s *rpcServer) handleMessage(ctx context.Context, msg []byte) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			return errorResponse(nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+		}
+		return errorResponse(nil, &rpcError{Code: rpcInvalidRequest, Message: err.Error()})
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, &rpcError{Code: rpcInvalidRequest, Message: `want "jsonrpc": "2.0" and a method`})
+	}
+
+	result, err := s.call(ctx, req.Method, req.Params)
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		var rerr *rpcError
+		if !errors.As(err, &rerr) {
+			rerr = &rpcError{Code: rpcServerError, Message: err.Error()}
+		}
+		return errorResponse(req.ID, rerr)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, &rpcError{Code: rpcServerError, Message: err.Error()})
+	}
+	return &rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: data}
+}
+
+func errorResponse(id json.RawMessage, err *rpcError) *rpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &rpcResponse{JSONRPC: "2.0", ID: id, Error: err}
+}
+
+// call runs a method and returns its result.
+func (s *rpcServer) call(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "session/submit":
+		var p struct {
+			Text string `json:"text"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Text == "" {
+			return nil, invalidParams("text is required")
+		}
+		s.session.SubmitMessage(p.Text)
+		return nil, nil
+
+	case "session/cancel":
+		s.session.CancelTurn()
+		return nil, nil
+
+	case "session/history":
+		return struct {
+			Messages []provider.Message `json:"messages"`
+		}{s.session.HistorySnapshot()}, nil
+
+	case "models/list":
+		models, err := s.provider.ListModels(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			Models []provider.ModelInfo `json:"models"`
+		}{models}, nil
+
+	case "permission/respond":
+		var p struct {
+			ToolCallID string `json:"toolCallId"`
+			Allowed    bool   `json:"allowed"`
+			Remember   bool   `json:"remember"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.respond(p.ToolCallID, core.PermissionResponse{Allowed: p.Allowed, Remember: p.Remember})
+
+	case "changelog/restore":
+		var p struct {
+			InteractionID string `json:"interactionId"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.InteractionID == "" {
+			return nil, invalidParams("interactionId is required")
+		}
+		if s.restore == nil {
+			return nil, errors.New("file snapshots are off")
+		}
+		paths, err := s.restore(p.InteractionID)
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			Paths []string `json:"paths"`
+		}{paths}, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method %q not found", method)}
+}
+
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return invalidParams("params are required")
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return invalidParams("%v", err)
+	}
+	return nil
+}
+
+// respond answers the permission prompt of a tool call.
+func (s *rpcServer) respond(toolCallID string, resp core.PermissionResponse) (err error) {
+	s.pendingMu.Lock()
+	ch, ok := s.pending[toolCallID]
+	delete(s.pending, toolCallID)
+	s.pendingMu.Unlock()
+	if !ok {
+		return invalidParams("no permission prompt pending for tool call %q", toolCallID)
+	}
+	// The core closes the channel once the prompt times out or its turn is
+	// cancelled; a response racing that is dropped.
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("permission response channel already closed (timeout race): %v", r)
+			err = fmt.Errorf("the permission prompt for tool call %q has expired", toolCallID)
+		}
+	}()
+	ch <- resp
+	return nil
+}
+
+// Send notifies the client of a core event.
+func (s *rpcServer) Send(msg any) {
+	switch e := msg.(type) {
+	case core.PermissionRequestEvent:
+		s.pendingMu.Lock()
+		s.pending[e.ToolCallID] = e.ResponseChan
+		s.pendingMu.Unlock()
+	case core.PermissionTimeoutEvent:
+		s.pendingMu.Lock()
+		delete(s.pending, e.ToolCallID)
+		s.pendingMu.Unlock()
+	}
+	s.write(rpcNotification{JSONRPC: "2.0", Method: "event/" + reflect.TypeOf(msg).Name(), Params: msg})
+}
+
+// cost is the tracker's update callback, reporting the spend after every
+// model request.
+func (s *rpcServer) cost(snap core.CostSnapshot) {
+	s.write(rpcNotification{JSONRPC: "2.0", Method: "session/cost", Params: newRunCost(snap)})
+}
+
+func (s *rpcServer) write(v any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.out.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "cosmos: warning: writing to the client: %v\n", err)
+	}
+}
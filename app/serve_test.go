@@ -0,0 +1,160 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"cosmos/core"
+	"cosmos/providers/fake"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe to write from the session goroutine
+// while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) lines() []map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var msgs []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var msg map[string]any
+		if json.Unmarshal([]byte(line), &msg) == nil {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+func newTestServer(t *testing.T, turns ...fake.Turn) (*rpcServer, *syncBuffer) {
+	t.Helper()
+	prov, err := fake.New(fake.Script{Turns: turns})
+	if err != nil {
+		t.Fatalf("fake.New: %v", err)
+	}
+	out := &syncBuffer{}
+	srv := newRPCServer(out)
+	srv.provider = prov
+	srv.session = core.NewSession("test-session", prov, core.NewTracker(nil, nil), srv, prov.DefaultModel(), "system", 1024, nil, nil, nil, nil)
+	return srv, out
+}
+
+func TestRPCServerSubmitStreamsEvents(t *testing.T) {
+	srv, out := newTestServer(t, fake.Turn{Deltas: []string{"All ", "done."}})
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"session/submit","params":{"text":"Hi"}}` + "\n")
+	srv.session.Start(t.Context())
+	defer srv.session.Stop()
+	if err := srv.serve(t.Context(), in); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(srv.session.HistorySnapshot()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("turn did not finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	srv.session.Stop()
+
+	msgs := out.lines()
+	if msgs[0]["id"] != 1.0 || msgs[0]["result"] != nil {
+		t.Errorf("submit response = %v", msgs[0])
+	}
+	var text strings.Builder
+	var completed bool
+	for _, msg := range msgs[1:] {
+		switch msg["method"] {
+		case "event/TokenEvent":
+			text.WriteString(msg["params"].(map[string]any)["Text"].(string))
+		case "event/CompletionEvent":
+			completed = true
+		}
+	}
+	if text.String() != "All done." || !completed {
+		t.Errorf("streamed %q, completed %v", text.String(), completed)
+	}
+
+	reply := srv.handleLine(t.Context(), []byte(`{"jsonrpc":"2.0","id":"h","method":"session/history"}`)).(*rpcResponse)
+	if !strings.Contains(string(reply.Result), `"Content":"All done."`) {
+		t.Errorf("history = %s", reply.Result)
+	}
+}
+
+func TestRPCServerPermissionRespond(t *testing.T) {
+	srv, out := newTestServer(t)
+	ch := make(chan core.PermissionResponse, 1)
+	srv.Send(core.PermissionRequestEvent{ToolCallID: "c1", ToolName: "write_file", Permission: "fs:write", ResponseChan: ch})
+
+	msgs := out.lines()
+	if len(msgs) != 1 || msgs[0]["method"] != "event/PermissionRequestEvent" {
+		t.Fatalf("notifications = %v", msgs)
+	}
+	if params := msgs[0]["params"].(map[string]any); params["ToolCallID"] != "c1" || params["Permission"] != "fs:write" {
+		t.Errorf("params = %v", params)
+	}
+
+	respond := []byte(`{"jsonrpc":"2.0","id":2,"method":"permission/respond","params":{"toolCallId":"c1","allowed":true,"remember":true}}`)
+	if reply := srv.handleLine(t.Context(), respond).(*rpcResponse); reply.Error != nil {
+		t.Fatalf("respond error = %+v", reply.Error)
+	}
+	if resp := <-ch; !resp.Allowed || !resp.Remember {
+		t.Errorf("response = %+v", resp)
+	}
+	if reply := srv.handleLine(t.Context(), respond).(*rpcResponse); reply.Error == nil || reply.Error.Code != rpcInvalidParams {
+		t.Errorf("second respond = %+v, want no prompt pending", reply.Error)
+	}
+
+	// A prompt the core gave up on can no longer be answered.
+	closed := make(chan core.PermissionResponse)
+	close(closed)
+	srv.Send(core.PermissionRequestEvent{ToolCallID: "c2", ResponseChan: closed})
+	late := []byte(`{"jsonrpc":"2.0","id":3,"method":"permission/respond","params":{"toolCallId":"c2"}}`)
+	if reply := srv.handleLine(t.Context(), late).(*rpcResponse); reply.Error == nil || !strings.Contains(reply.Error.Message, "expired") {
+		t.Errorf("late respond = %+v", reply.Error)
+	}
+}
+
+func TestRPCServerErrors(t *testing.T) {
+	srv, _ := newTestServer(t)
+	ctx := context.Background()
+	tests := []struct {
+		line string
+		code int
+	}{
+		{`{"jsonrpc":"2.0","id":1,"method":`, rpcParseError},
+		{`{"id":1,"method":"session/cancel"}`, rpcInvalidRequest},
+		{`{"jsonrpc":"2.0","id":1,"method":"session/nope"}`, rpcMethodNotFound},
+		{`{"jsonrpc":"2.0","id":1,"method":"session/submit","params":{"text":""}}`, rpcInvalidParams},
+		{`{"jsonrpc":"2.0","id":1,"method":"changelog/restore","params":{"interactionId":"i1"}}`, rpcServerError},
+		{`[]`, rpcInvalidRequest},
+	}
+	for _, tt := range tests {
+		reply, ok := srv.handleLine(ctx, []byte(tt.line)).(*rpcResponse)
+		if !ok || reply.Error == nil || reply.Error.Code != tt.code {
+			t.Errorf("%s: reply = %+v, want error %d", tt.line, reply, tt.code)
+		}
+	}
+
+	// Notifications get no reply, also within a batch.
+	if reply := srv.handleLine(ctx, []byte(`{"jsonrpc":"2.0","method":"session/cancel"}`)); reply != nil {
+		t.Errorf("notification reply = %v", reply)
+	}
+	batch := srv.handleLine(ctx, []byte(`[{"jsonrpc":"2.0","method":"session/cancel"},{"jsonrpc":"2.0","id":7,"method":"models/list"}]`))
+	replies, ok := batch.([]*rpcResponse)
+	if !ok || len(replies) != 1 || string(replies[0].ID) != "7" || !strings.Contains(string(replies[0].Result), fake.DefaultModelID) {
+		t.Errorf("batch reply = %v", batch)
+	}
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"cosmos/config"
+	"cosmos/core"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// usageOptions selects and groups the ledger entries `cosmos usage` reports.
+type usageOptions struct {
+	by      core.UsageGroup
+	since   time.Time // zero: no lower bound
+	until   time.Time // exclusive; zero: no upper bound
+	project string    // "" for all projects
+	json    bool
+}
+
+// RunUsage implements `cosmos usage`: spend from the usage ledger grouped
+// by day, project, model or source, e.g.
+//
+//	cosmos usage -by project -since 2025-06-01 -until 2025-06-30
+func RunUsage(args []string, stdout io.Writer) error {
+	opts, err := parseUsageArgs(args, time.Now())
+	if err != nil {
+		return err
+	}
+	cfg, warnings, err := config.Load()
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "cosmos: warning: %s\n", w)
+	}
+	entries, err := core.ReadLedger(cfg.UsageLedger)
+	if err != nil {
+		return err
+	}
+	return writeUsage(stdout, entries, opts)
+}
+
+func parseUsageArgs(args []string, now time.Time) (usageOptions, error) {
+	fs := flag.NewFlagSet("usage", flag.ContinueOnError)
+	by := fs.String("by", "day", "group by `dimension`: day, project, model or source")
+	since := fs.String("since", "", "count requests from this `date` (YYYY-MM-DD, local time)")
+	until := fs.String("until", "", "count requests up to and including this `date`")
+	days := fs.Int("days", 30, "count the last `n` days when -since is not set; 0 counts all")
+	project := fs.String("project", "", "count only requests made in this `dir`")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	if err := fs.Parse(args); err != nil {
+		return usageOptions{}, err
+	}
+	if fs.NArg() > 0 {
+		return usageOptions{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	opts := usageOptions{by: core.UsageGroup(*by), json: *asJSON}
+	if !slices.Contains(core.UsageGroups, opts.by) {
+		return usageOptions{}, fmt.Errorf("-by %q: want day, project, model or source", *by)
+	}
+	switch {
+	case *since != "":
+		t, err := time.ParseInLocation(time.DateOnly, *since, now.Location())
+		if err != nil {
+			return usageOptions{}, fmt.Errorf("-since: %w", err)
+		}
+		opts.since = t
+	case *days > 0:
+		y, m, d := now.Date()
+		opts.since = time.Date(y, m, d-*days+1, 0, 0, 0, 0, now.Location())
+	}
+	if *until != "" {
+		t, err := time.ParseInLocation(time.DateOnly, *until, now.Location())
+		if err != nil {
+			return usageOptions{}, fmt.Errorf("-until: %w", err)
+		}
+		opts.until = t.AddDate(0, 0, 1)
+	}
+	if *project != "" {
+		abs, err := filepath.Abs(*project)
+		if err != nil {
+			return usageOptions{}, fmt.Errorf("-project: %w", err)
+		}
+		opts.project = abs
+	}
+	return opts, nil
+}
+
+// writeUsage prints the entries selected by opts, summarized as a table
+// with a total row, or as JSON.
+func writeUsage(w io.Writer, entries []core.LedgerEntry, opts usageOptions) error {
+	selected := slices.DeleteFunc(slices.Clone(entries), func(e core.LedgerEntry) bool {
+		return (!opts.since.IsZero() && e.Time.Before(opts.since)) ||
+			(!opts.until.IsZero() && !e.Time.Before(opts.until)) ||
+			(opts.project != "" && e.ProjectDir != opts.project)
+	})
+	summaries := core.SummarizeUsage(selected, opts.by)
+
+	if opts.json {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(summaries)
+	}
+
+	if len(summaries) == 0 {
+		_, err := fmt.Fprintln(w, "No usage recorded for this period.")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tREQUESTS\tINPUT\tOUTPUT\tCACHE READ\tCACHE WRITE\tCOST (USD)\n", strings.ToUpper(string(opts.by)))
+	var total core.UsageSummary
+	for _, s := range summaries {
+		writeUsageRow(tw, s)
+		total.Requests += s.Requests
+		total.InputTokens += s.InputTokens
+		total.OutputTokens += s.OutputTokens
+		total.CacheReadTokens += s.CacheReadTokens
+		total.CacheWriteTokens += s.CacheWriteTokens
+		total.CostUSD += s.CostUSD
+	}
+	total.Key = "total"
+	writeUsageRow(tw, total)
+	return tw.Flush()
+}
+
+func writeUsageRow(w io.Writer, s core.UsageSummary) {
+	key := s.Key
+	if key == "" {
+		key = "(unknown)"
+	}
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%.4f\n",
+		key, s.Requests, s.InputTokens, s.OutputTokens, s.CacheReadTokens, s.CacheWriteTokens, s.CostUSD)
+}
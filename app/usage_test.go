@@ -0,0 +1,65 @@
+package app
+
+import (
+	"bytes"
+	"cosmos/core"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseUsageArgs(t *testing.T) {
+	now := time.Date(2025, 6, 15, 9, 0, 0, 0, time.UTC)
+
+	opts, err := parseUsageArgs(nil, now)
+	if err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	if opts.by != core.UsageByDay || !opts.since.Equal(time.Date(2025, 5, 17, 0, 0, 0, 0, time.UTC)) || !opts.until.IsZero() {
+		t.Errorf("defaults = %+v, want by day over the last 30 days", opts)
+	}
+
+	opts, err = parseUsageArgs([]string{"-by", "project", "-since", "2025-06-01", "-until", "2025-06-30"}, now)
+	if err != nil {
+		t.Fatalf("month: %v", err)
+	}
+	if !opts.since.Equal(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) || !opts.until.Equal(time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("month = %v..%v", opts.since, opts.until)
+	}
+
+	for _, args := range [][]string{{"-by", "week"}, {"-since", "June"}, {"extra"}} {
+		if _, err := parseUsageArgs(args, now); err == nil {
+			t.Errorf("parseUsageArgs(%q) should fail", args)
+		}
+	}
+}
+
+func TestWriteUsage(t *testing.T) {
+	at := func(d int) time.Time { return time.Date(2025, 6, d, 12, 0, 0, 0, time.UTC) }
+	entries := []core.LedgerEntry{
+		{Time: at(1), ProjectDir: "/repo", Model: "m", CostUSD: 1.5},
+		{Time: at(2), ProjectDir: "/repo", Model: "m", CostUSD: 2},
+		{Time: at(2), ProjectDir: "/other", Model: "m", CostUSD: 4},
+		{Time: at(3), ProjectDir: "/repo", Model: "m", CostUSD: 8}, // after -until
+	}
+	opts := usageOptions{
+		by:      core.UsageByProject,
+		until:   time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC),
+		project: "/repo",
+	}
+
+	var out bytes.Buffer
+	if err := writeUsage(&out, entries, opts); err != nil {
+		t.Fatalf("writeUsage: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "/repo") || !strings.HasSuffix(lines[2], "3.5000") {
+		t.Errorf("report:\n%s", out.String())
+	}
+
+	out.Reset()
+	opts.json = true
+	if err := writeUsage(&out, entries, opts); err != nil || !strings.Contains(out.String(), `"costUSD": 3.5`) {
+		t.Errorf("JSON report = %s, %v", out.String(), err)
+	}
+}
@@ -9,25 +9,176 @@ import (
 	"github.com/BurntSushi/toml"
 )
 
+// DefaultAnthropicModel is the model used when provider = "anthropic" and
+// default_model is not set (the Bedrock default ID is not valid there).
+const DefaultAnthropicModel = "claude-sonnet-4-20250514"
+
+// ModelConfig describes a model's context window and pricing for providers
+// whose APIs do not report them.
+type ModelConfig struct {
+	Name            string  `toml:"name"`
+	ContextWindow   int     `toml:"context_window"`
+	InputCostPer1M  float64 `toml:"input_cost_per_1m"`
+	OutputCostPer1M float64 `toml:"output_cost_per_1m"`
+}
+
+// ModelPrice is a model's price in USD per million tokens. Zero cache prices
+// bill cached tokens at the input price.
+type ModelPrice struct {
+	InputCostPer1M      float64 `toml:"input_cost_per_1m"`
+	OutputCostPer1M     float64 `toml:"output_cost_per_1m"`
+	CacheReadCostPer1M  float64 `toml:"cache_read_cost_per_1m"`
+	CacheWriteCostPer1M float64 `toml:"cache_write_cost_per_1m"`
+
+	// Requests with more than LongContextThreshold input tokens are billed
+	// at LongContext prices, set in a nested long_context table.
+	LongContextThreshold int         `toml:"long_context_threshold"`
+	LongContext          *ModelPrice `toml:"long_context"`
+}
+
+// PricingConfig is the [pricing] table.
+type PricingConfig struct {
+	// Overrides replaces the price reported for a model, keyed by model ID,
+	// for any provider. Use it for negotiated rates or models missing from
+	// the built-in price table:
+	//
+	//	[pricing.overrides."us.anthropic.claude-sonnet-4-20250514-v1:0"]
+	//	input_cost_per_1m = 2.7
+	//	output_cost_per_1m = 13.5
+	Overrides map[string]ModelPrice `toml:"overrides"`
+}
+
+// BudgetConfig is the [budget] table: spend limits in USD. Zero disables a
+// limit. Reaching one pauses the agent before its next model request until
+// the limit is raised with /budget.
+//
+//	[budget]
+//	session = 5.0   # this run
+//	daily = 20.0    # all runs today
+//	project = 100.0 # all runs in the working directory
+//	warn_at = [0.5, 0.8]
+type BudgetConfig struct {
+	Session float64   `toml:"session"`
+	Daily   float64   `toml:"daily"`
+	Project float64   `toml:"project"`
+	WarnAt  []float64 `toml:"warn_at"` // fractions of a limit that trigger a warning
+}
+
+// DelegateConfig is the [delegate] table: the sub-agents the model can hand
+// self-contained tasks to with the delegate tool.
+//
+//	[delegate]
+//	enabled = true
+//	model = "us.anthropic.claude-3-5-haiku-20241022-v1:0" # "" = default_model
+//	tools = ["readFile", "searchFiles"]                     # [] = all tools
+//	max_requests = 20                                       # per sub-agent; 0 = no limit
+type DelegateConfig struct {
+	Enabled     bool     `toml:"enabled"`
+	Model       string   `toml:"model"`
+	Tools       []string `toml:"tools"`
+	MaxRequests int      `toml:"max_requests"`
+}
+
 // Config holds all Cosmos configuration values.
 type Config struct {
+	// LLM backend: "bedrock" (default), "anthropic", or "openai".
+	Provider string `toml:"provider"`
+
 	AWSRegion    string `toml:"aws_region"`
 	AWSProfile   string `toml:"aws_profile"`
 	DefaultModel string `toml:"default_model"`
 
+	// Anthropic Messages API settings (provider = "anthropic").
+	// An empty API key falls back to the ANTHROPIC_API_KEY environment variable.
+	AnthropicAPIKey  string `toml:"anthropic_api_key"`
+	AnthropicBaseURL string `toml:"anthropic_base_url"`
+
+	// OpenAI-compatible chat completions settings (provider = "openai"),
+	// for llama.cpp, vLLM, Ollama and similar servers. The base URL is the
+	// server root without /v1. The API key is optional for local servers and
+	// falls back to the OPENAI_API_KEY environment variable.
+	OpenAIBaseURL string `toml:"openai_base_url"`
+	OpenAIAPIKey  string `toml:"openai_api_key"`
+	// Per-model metadata keyed by model ID, e.g. [openai_models."qwen2.5-coder"].
+	// Models not listed here are treated as free with an unknown context window.
+	OpenAIModels map[string]ModelConfig `toml:"openai_models"`
+
+	// Multi-provider routing. Routes maps model ID prefixes to a provider
+	// ("bedrock", "anthropic" or "openai"); the longest matching prefix wins
+	// and unmatched models use Provider. Fallbacks maps a model ID to the
+	// ordered models tried when it is throttled, overloaded or access is
+	// denied, each routed by its own prefix:
+	//
+	//	[routes]
+	//	"claude-" = "anthropic"
+	//	"qwen" = "openai"
+	//
+	//	[fallbacks]
+	//	"us.anthropic.claude-sonnet-4-20250514-v1:0" = ["claude-sonnet-4-20250514", "qwen2.5-coder-32b"]
+	Routes    map[string]string   `toml:"routes"`
+	Fallbacks map[string][]string `toml:"fallbacks"`
+
+	// Record/replay of provider traffic: "" (off), "record", or "replay".
+	// The COSMOS_CASSETTE_MODE and COSMOS_CASSETTE_PATH environment variables
+	// override these, so CI and bug repros need no config file edits.
+	CassetteMode string `toml:"cassette_mode"`
+	CassettePath string `toml:"cassette_path"`
+
 	CosmosDir   string `toml:"cosmos_dir"`
 	SessionsDir string `toml:"sessions_dir"`
 	AgentsDir   string `toml:"agents_dir"`
 
+	// Append-only JSON-lines record of every priced model request, read by
+	// `cosmos usage`, the cost breakdown and the daily and project budgets.
+	UsageLedger string `toml:"usage_ledger"`
+
 	// Pricing configuration
-	PricingCacheDir string `toml:"pricing_cache_dir"`
-	PricingCacheTTL int    `toml:"pricing_cache_ttl"`
-	PricingEnabled  bool   `toml:"pricing_enabled"`
+	PricingCacheDir string        `toml:"pricing_cache_dir"`
+	PricingCacheTTL int           `toml:"pricing_cache_ttl"`
+	PricingEnabled  bool          `toml:"pricing_enabled"`
+	Pricing         PricingConfig `toml:"pricing"`
+
+	Budget BudgetConfig `toml:"budget"`
+
+	Delegate DelegateConfig `toml:"delegate"`
 
 	// Display currency (ISO 4217 code). AWS pricing is always USD;
 	// this controls the display currency with conversion via Frankfurter API.
 	Currency string `toml:"currency"`
 
+	// Fetched exchange rates are cached for CurrencyCacheTTL hours and the
+	// last one is used while the currency API is unreachable.
+	// CurrencyAPIURL replaces the Frankfurter API, e.g. with a local
+	// stand-in for tests.
+	CurrencyCacheDir string `toml:"currency_cache_dir"`
+	CurrencyCacheTTL int    `toml:"currency_cache_ttl"`
+	CurrencyAPIURL   string `toml:"currency_api_url"`
+
+	// Prompt caching: number of trailing user turns marked as cache
+	// breakpoints in addition to the system prompt and tool definitions.
+	// 0 disables caching; values above 2 are capped (providers allow four
+	// breakpoints per request).
+	PromptCacheTurns int `toml:"prompt_cache_turns"`
+
+	// Extended thinking token budget for models that support reasoning
+	// (Claude 3.7 Sonnet and later). 0 disables thinking; the minimum
+	// accepted by providers is 1024.
+	ThinkingBudget int `toml:"thinking_budget"`
+
+	// Output token limit per model response. 0 derives it from the model's
+	// published maximum. Replies cut off at the limit are continued
+	// automatically.
+	MaxTokens int `toml:"max_tokens"`
+
+	// Provider resilience. Throttled or transiently failing requests are
+	// retried up to ProviderRetries times with jittered exponential backoff.
+	// A stream that sends nothing for FirstTokenTimeout seconds after the
+	// request, or StreamIdleTimeout seconds between chunks, is treated as
+	// stalled and retried. 0 disables the respective behavior.
+	ProviderRetries   int `toml:"provider_retries"`
+	FirstTokenTimeout int `toml:"first_token_timeout"`
+	StreamIdleTimeout int `toml:"stream_idle_timeout"`
+
 	// Permission timeout (seconds). How long to wait for user response to
 	// permission prompts before applying the default decision.
 	PermissionTimeout int `toml:"permission_timeout"`
@@ -50,21 +201,31 @@ func DefaultConfig() Config {
 	cosmosDir := filepath.Join(home, ".cosmos")
 
 	return Config{
-		AWSRegion:       "us-east-1",
-		AWSProfile:      "",
-		DefaultModel:    "us.anthropic.claude-3-5-sonnet-20241022-v2:0",
-		CosmosDir:       cosmosDir,
-		SessionsDir:     filepath.Join(cosmosDir, "sessions"),
-		AgentsDir:       filepath.Join(cosmosDir, "agents"),
-		PricingCacheDir: filepath.Join(cosmosDir, "cache", "pricing"),
+		Provider:          "bedrock",
+		AWSRegion:         "us-east-1",
+		AWSProfile:        "",
+		DefaultModel:      "us.anthropic.claude-3-5-sonnet-20241022-v2:0",
+		CosmosDir:         cosmosDir,
+		SessionsDir:       filepath.Join(cosmosDir, "sessions"),
+		AgentsDir:         filepath.Join(cosmosDir, "agents"),
+		UsageLedger:       filepath.Join(cosmosDir, "usage.jsonl"),
+		PricingCacheDir:   filepath.Join(cosmosDir, "cache", "pricing"),
 		PricingCacheTTL:   168, // 1 week in hours
 		PricingEnabled:    true,
 		Currency:          "USD",
-		PermissionTimeout: 30, // seconds
+		CurrencyCacheDir:  filepath.Join(cosmosDir, "cache", "currency"),
+		CurrencyCacheTTL:  24, // hours
+		Budget:            BudgetConfig{WarnAt: []float64{0.5, 0.8}},
+		Delegate:          DelegateConfig{Enabled: true, MaxRequests: 20},
+		PromptCacheTurns:  2,
+		ProviderRetries:   4,
+		FirstTokenTimeout: 120, // seconds
+		StreamIdleTimeout: 60,  // seconds
+		PermissionTimeout: 30,  // seconds
 		// AuditFile documents the pattern - actual files are per-session: audit-<session-id>.jsonl
-		AuditFile:       filepath.Join(".cosmos", "audit-{session-id}.jsonl"),
-		PolicyFile:      filepath.Join(".cosmos", "policy.json"),
-		MaxToolTimeout:  5 * time.Minute,
+		AuditFile:      filepath.Join(".cosmos", "audit-{session-id}.jsonl"),
+		PolicyFile:     filepath.Join(".cosmos", "policy.json"),
+		MaxToolTimeout: 5 * time.Minute,
 	}
 }
 
@@ -107,6 +268,18 @@ func LoadFrom(path string, defaults Config) (Config, []string, error) {
 		if !meta.IsDefined("pricing_cache_dir") {
 			cfg.PricingCacheDir = filepath.Join(cfg.CosmosDir, "cache", "pricing")
 		}
+		if !meta.IsDefined("currency_cache_dir") {
+			cfg.CurrencyCacheDir = filepath.Join(cfg.CosmosDir, "cache", "currency")
+		}
+		if !meta.IsDefined("usage_ledger") {
+			cfg.UsageLedger = filepath.Join(cfg.CosmosDir, "usage.jsonl")
+		}
+	}
+
+	// Bedrock model IDs are not valid on the Anthropic API; pick a native
+	// default unless the user chose a model explicitly.
+	if cfg.Provider == "anthropic" && !meta.IsDefined("default_model") {
+		cfg.DefaultModel = DefaultAnthropicModel
 	}
 
 	// Restore non-TOML fields from defaults.
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -19,9 +20,19 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.DefaultModel != "us.anthropic.claude-3-5-sonnet-20241022-v2:0" {
 		t.Errorf("DefaultModel = %q, want %q", cfg.DefaultModel, "us.anthropic.claude-3-5-sonnet-20241022-v2:0")
 	}
+	if cfg.Provider != "bedrock" {
+		t.Errorf("Provider = %q, want %q", cfg.Provider, "bedrock")
+	}
 	if cfg.MaxToolTimeout != 5*time.Minute {
 		t.Errorf("MaxToolTimeout = %v, want %v", cfg.MaxToolTimeout, 5*time.Minute)
 	}
+	if cfg.PromptCacheTurns != 2 {
+		t.Errorf("PromptCacheTurns = %d, want 2", cfg.PromptCacheTurns)
+	}
+	if cfg.ProviderRetries != 4 || cfg.FirstTokenTimeout != 120 || cfg.StreamIdleTimeout != 60 {
+		t.Errorf("resilience defaults = %d retries, %ds first token, %ds idle; want 4, 120, 60",
+			cfg.ProviderRetries, cfg.FirstTokenTimeout, cfg.StreamIdleTimeout)
+	}
 
 	// Sub-dirs should be children of CosmosDir.
 	if filepath.Dir(cfg.SessionsDir) != cfg.CosmosDir {
@@ -44,7 +55,7 @@ func TestLoadNoFile(t *testing.T) {
 	if len(warnings) != 0 {
 		t.Errorf("expected no warnings, got %v", warnings)
 	}
-	if cfg != defaults {
+	if !reflect.DeepEqual(cfg, defaults) {
 		t.Errorf("LoadFrom with missing file returned non-default config")
 	}
 }
@@ -175,6 +186,12 @@ func TestLoadCosmosDirOverride(t *testing.T) {
 	if cfg.AgentsDir != wantAgents {
 		t.Errorf("AgentsDir = %q, want %q", cfg.AgentsDir, wantAgents)
 	}
+	if want := filepath.Join(customDir, "usage.jsonl"); cfg.UsageLedger != want {
+		t.Errorf("UsageLedger = %q, want %q", cfg.UsageLedger, want)
+	}
+	if want := filepath.Join(customDir, "cache", "currency"); cfg.CurrencyCacheDir != want {
+		t.Errorf("CurrencyCacheDir = %q, want %q", cfg.CurrencyCacheDir, want)
+	}
 }
 
 func TestLoadExplicitSubDirs(t *testing.T) {
@@ -207,6 +224,180 @@ sessions_dir = "` + customSessions + `"
 	}
 }
 
+func TestLoadAnthropicProviderDefaultModel(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "config.toml")
+
+	content := `provider = "anthropic"
+anthropic_base_url = "http://localhost:8080"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, warnings, err := LoadFrom(path, testDefaults(tmp))
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+	if cfg.Provider != "anthropic" {
+		t.Errorf("Provider = %q, want %q", cfg.Provider, "anthropic")
+	}
+	if cfg.AnthropicBaseURL != "http://localhost:8080" {
+		t.Errorf("AnthropicBaseURL = %q, want %q", cfg.AnthropicBaseURL, "http://localhost:8080")
+	}
+	// Bedrock default model is replaced with a native Anthropic model ID.
+	if cfg.DefaultModel != DefaultAnthropicModel {
+		t.Errorf("DefaultModel = %q, want %q", cfg.DefaultModel, DefaultAnthropicModel)
+	}
+}
+
+func TestLoadAnthropicProviderExplicitModel(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "config.toml")
+
+	content := `provider = "anthropic"
+default_model = "claude-3-5-haiku-20241022"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := LoadFrom(path, testDefaults(tmp))
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+	if cfg.DefaultModel != "claude-3-5-haiku-20241022" {
+		t.Errorf("DefaultModel = %q, want %q", cfg.DefaultModel, "claude-3-5-haiku-20241022")
+	}
+}
+
+func TestLoadPromptCachingDisabled(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "config.toml")
+
+	if err := os.WriteFile(path, []byte("prompt_cache_turns = 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := LoadFrom(path, testDefaults(tmp))
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+	if cfg.PromptCacheTurns != 0 {
+		t.Errorf("PromptCacheTurns = %d, want 0", cfg.PromptCacheTurns)
+	}
+}
+
+func TestLoadOpenAIModels(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "config.toml")
+
+	content := `provider = "openai"
+openai_base_url = "http://gpu-box:8000"
+default_model = "qwen2.5-coder-32b"
+
+[openai_models."qwen2.5-coder-32b"]
+name = "Qwen 2.5 Coder 32B"
+context_window = 32768
+input_cost_per_1m = 0.1
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, warnings, err := LoadFrom(path, testDefaults(tmp))
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+	if cfg.OpenAIBaseURL != "http://gpu-box:8000" {
+		t.Errorf("OpenAIBaseURL = %q", cfg.OpenAIBaseURL)
+	}
+	m, ok := cfg.OpenAIModels["qwen2.5-coder-32b"]
+	if !ok {
+		t.Fatalf("model table not decoded: %v", cfg.OpenAIModels)
+	}
+	if m.Name != "Qwen 2.5 Coder 32B" || m.ContextWindow != 32768 || m.InputCostPer1M != 0.1 || m.OutputCostPer1M != 0 {
+		t.Errorf("model config = %+v", m)
+	}
+}
+
+func TestLoadRoutesAndFallbacks(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "config.toml")
+
+	content := `[routes]
+"claude-" = "anthropic"
+"qwen" = "openai"
+
+[fallbacks]
+"us.anthropic.claude-sonnet-4-20250514-v1:0" = ["claude-sonnet-4-20250514", "qwen2.5-coder-32b"]
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, warnings, err := LoadFrom(path, testDefaults(tmp))
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+	if cfg.Routes["claude-"] != "anthropic" || cfg.Routes["qwen"] != "openai" {
+		t.Errorf("Routes = %v", cfg.Routes)
+	}
+	chain := cfg.Fallbacks["us.anthropic.claude-sonnet-4-20250514-v1:0"]
+	if len(chain) != 2 || chain[0] != "claude-sonnet-4-20250514" || chain[1] != "qwen2.5-coder-32b" {
+		t.Errorf("Fallbacks = %v", cfg.Fallbacks)
+	}
+}
+
+func TestLoadPricingOverrides(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "config.toml")
+
+	content := `pricing_enabled = true
+
+[pricing.overrides."us.anthropic.claude-sonnet-4-20250514-v1:0"]
+input_cost_per_1m = 2.7
+output_cost_per_1m = 13.5
+cache_read_cost_per_1m = 0.27
+long_context_threshold = 200000
+
+[pricing.overrides."us.anthropic.claude-sonnet-4-20250514-v1:0".long_context]
+input_cost_per_1m = 5.4
+output_cost_per_1m = 20.25
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, warnings, err := LoadFrom(path, testDefaults(tmp))
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+	want := ModelPrice{
+		InputCostPer1M: 2.7, OutputCostPer1M: 13.5, CacheReadCostPer1M: 0.27,
+		LongContextThreshold: 200000,
+		LongContext:          &ModelPrice{InputCostPer1M: 5.4, OutputCostPer1M: 20.25},
+	}
+	if got := cfg.Pricing.Overrides["us.anthropic.claude-sonnet-4-20250514-v1:0"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("override = %+v, want %+v", got, want)
+	}
+	if !cfg.PricingEnabled {
+		t.Error("pricing_enabled should still load alongside [pricing]")
+	}
+}
+
 func TestEnsureDirs(t *testing.T) {
 	tmp := t.TempDir()
 	cfg := testDefaults(tmp)
@@ -267,15 +458,17 @@ func TestConfigFilePath(t *testing.T) {
 func testDefaults(tmpDir string) Config {
 	cosmosDir := filepath.Join(tmpDir, ".cosmos")
 	return Config{
-		AWSRegion:      "us-east-1",
-		AWSProfile:     "",
-		DefaultModel:   "us.anthropic.claude-3-5-sonnet-20241022-v2:0",
-		CosmosDir:      cosmosDir,
-		SessionsDir:    filepath.Join(cosmosDir, "sessions"),
-		AgentsDir:      filepath.Join(cosmosDir, "agents"),
-		AuditFile:      filepath.Join(".cosmos", "audit.jsonl"),
-		PolicyFile:     filepath.Join(".cosmos", "policy.json"),
-		MaxToolTimeout: 5 * time.Minute,
+		Provider:         "bedrock",
+		AWSRegion:        "us-east-1",
+		AWSProfile:       "",
+		DefaultModel:     "us.anthropic.claude-3-5-sonnet-20241022-v2:0",
+		CosmosDir:        cosmosDir,
+		SessionsDir:      filepath.Join(cosmosDir, "sessions"),
+		AgentsDir:        filepath.Join(cosmosDir, "agents"),
+		AuditFile:        filepath.Join(".cosmos", "audit.jsonl"),
+		PolicyFile:       filepath.Join(".cosmos", "policy.json"),
+		MaxToolTimeout:   5 * time.Minute,
+		PromptCacheTurns: 2,
 	}
 }
 
@@ -288,3 +481,57 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestLoadDelegate(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "config.toml")
+
+	content := `[delegate]
+model = "small-model"
+tools = ["readFile"]
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := testDefaults(tmp)
+	defaults.Delegate = DefaultConfig().Delegate
+	cfg, warnings, err := LoadFrom(path, defaults)
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+	want := DelegateConfig{Enabled: true, Model: "small-model", Tools: []string{"readFile"}, MaxRequests: 20}
+	if !reflect.DeepEqual(cfg.Delegate, want) {
+		t.Errorf("delegate = %+v, want %+v (defaults kept)", cfg.Delegate, want)
+	}
+}
+
+func TestLoadBudget(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "config.toml")
+
+	content := `[budget]
+session = 5.0
+daily = 20
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := testDefaults(tmp)
+	defaults.Budget = DefaultConfig().Budget
+	cfg, warnings, err := LoadFrom(path, defaults)
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+	want := BudgetConfig{Session: 5, Daily: 20, WarnAt: []float64{0.5, 0.8}}
+	if !reflect.DeepEqual(cfg.Budget, want) {
+		t.Errorf("budget = %+v, want %+v (default warn_at kept)", cfg.Budget, want)
+	}
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"cosmos/engine/manifest"
+	"cosmos/engine/policy"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// attachmentPattern matches @path references in a prompt. Only references
+// with a known media extension become attachments, so @mentions and
+// decorators in pasted code are left alone.
+var attachmentPattern = regexp.MustCompile(`(?:^|\s)@(\S+)`)
+
+// attachmentAgent is the policy identity for files attached from the prompt.
+// Overrides in policy.json under this name apply to attachments.
+const attachmentAgent = "user"
+
+// attachmentRules let the user attach project files freely and ask before
+// anything outside the working directory is sent to the provider.
+var attachmentRules = []manifest.PermissionRule{
+	mustPermissionRule("fs:read:./**", manifest.PermissionAllow),
+	mustPermissionRule("fs:read", manifest.PermissionRequestAlways),
+}
+
+func mustPermissionRule(raw string, mode manifest.PermissionMode) manifest.PermissionRule {
+	key, err := manifest.ParsePermissionKey(raw)
+	if err != nil {
+		panic(err)
+	}
+	return manifest.PermissionRule{Key: key, Mode: mode}
+}
+
+// resolveAttachments reads the images and documents referenced with @path in
+// text and returns them as content blocks. Each file must pass an fs:read
+// policy check first. Must be called from the loop goroutine, since it may
+// prompt for permission.
+func (s *Session) resolveAttachments(ctx context.Context, text string) ([]provider.ContentBlock, error) {
+	var blocks []provider.ContentBlock
+	seen := make(map[string]bool)
+	for _, match := range attachmentPattern.FindAllStringSubmatch(text, -1) {
+		// Sentence punctuation after a path is not part of it.
+		ref := strings.TrimRight(match[1], ".,;:!?)]}'\"")
+		mediaType, ok := provider.MediaTypes[strings.ToLower(filepath.Ext(ref))]
+		if !ok {
+			continue
+		}
+
+		path, err := resolveAttachmentPath(ref)
+		if err != nil {
+			return nil, fmt.Errorf("attach %s: %w", ref, err)
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		if err := s.checkAttachment(ctx, path); err != nil {
+			return nil, fmt.Errorf("attach %s: %w", ref, err)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("attach %s: %w", ref, err)
+		}
+		if info.Size() > provider.MaxMediaBytes {
+			return nil, fmt.Errorf("attach %s: file is %d bytes (limit %d)", ref, info.Size(), provider.MaxMediaBytes)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("attach %s: %w", ref, err)
+		}
+
+		blockType := provider.ContentDocument
+		if strings.HasPrefix(mediaType, "image/") {
+			blockType = provider.ContentImage
+		}
+		blocks = append(blocks, provider.ContentBlock{
+			Type:      blockType,
+			MediaType: mediaType,
+			Name:      filepath.Base(path),
+			Data:      data,
+		})
+	}
+	return blocks, nil
+}
+
+// resolveAttachmentPath expands ~ and returns the absolute, symlink-free path
+// so the policy check sees the file that will actually be read.
+func resolveAttachmentPath(ref string) (string, error) {
+	if ref == "~" || strings.HasPrefix(ref, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		ref = filepath.Join(home, strings.TrimPrefix(ref, "~"))
+	}
+	abs, err := filepath.Abs(ref)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// checkAttachment evaluates fs:read for path against attachmentRules and
+// prompts the user when the rules ask for it.
+func (s *Session) checkAttachment(ctx context.Context, path string) error {
+	if s.evaluator == nil {
+		return nil
+	}
+	key, err := manifest.ParsePermissionKey("fs:read:" + path)
+	if err != nil {
+		return err
+	}
+
+	decision := s.evaluator.Evaluate(attachmentAgent, key, attachmentRules)
+	switch decision.Effect {
+	case policy.EffectAllow:
+		return nil
+	case policy.EffectPromptOnce, policy.EffectPromptAlways:
+		// Prompt with the concrete path rather than the broad rule so the
+		// user sees which file is about to be sent.
+		rule := manifest.PermissionRule{Key: key, Mode: decision.MatchedRule.Mode}
+		pd := s.handlePermissionPrompt(ctx, "attach-"+uuid.NewString(), "attachment", attachmentAgent, rule, decision)
+		if !pd.allowed {
+			return fmt.Errorf("permission denied: %s", pd.reason)
+		}
+		return nil
+	default:
+		return fmt.Errorf("permission denied: %s", key.Raw)
+	}
+}
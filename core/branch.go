@@ -0,0 +1,368 @@
+package core
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Branch is one line of a conversation. Editing an earlier prompt or
+// retrying a reply forks a new branch off the active one, keeping the old
+// one to switch back to; Parent and ForkAt link the branches into a tree.
+type Branch struct {
+	ID     int    `json:"id"`
+	Parent int    `json:"parent"` // branch forked from; -1 for the first
+	ForkAt int    `json:"forkAt"` // leading messages shared with Parent
+	Label  string `json:"label"`  // the prompt the branch starts with
+
+	History []provider.Message `json:"history"`
+
+	// FileSteps are the tool steps in History that changed files.
+	FileSteps []FileStep `json:"fileSteps,omitempty"`
+}
+
+// FileStep is a tool step that changed files: the position in the history
+// of the assistant message that called the tools, and the interaction the
+// changes were snapshotted under.
+type FileStep struct {
+	At            int    `json:"at"`
+	InteractionID string `json:"interactionId"`
+}
+
+// FileRestorer restores the files changed in the given interactions to
+// their state before the first of them, and returns the restored paths.
+type FileRestorer func(interactionIDs []string) ([]string, error)
+
+// SetFileRestorer lets leaving a branch offer to restore the files changed
+// on it since the branch point, with /branch restore.
+// Must be called before Start().
+func (s *Session) SetFileRestorer(f FileRestorer) {
+	s.restoreFiles = f
+}
+
+// maxBranchLabel bounds the branch labels shown by /branch.
+const maxBranchLabel = 60
+
+// promptIndexes returns the history positions of the user's prompts, as
+// opposed to the user messages carrying tool results.
+func promptIndexes(history []provider.Message) []int {
+	var idx []int
+	for i, m := range history {
+		if m.Role == provider.RoleUser && len(m.ToolResults) == 0 {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// syncBranch copies the live history into the active branch, creating the
+// first branch if the conversation has not forked yet. Caller must hold
+// s.mu.
+func (s *Session) syncBranch() {
+	if len(s.branches) == 0 {
+		label := ""
+		if idx := promptIndexes(s.history); len(idx) > 0 {
+			label = s.history[idx[0]].Content
+		}
+		s.branches = []Branch{{ID: 0, Parent: -1, Label: label}}
+		s.activeBranch = 0
+	}
+	b := &s.branches[s.activeBranch]
+	b.History = slices.Clone(s.history)
+	b.FileSteps = slices.Clone(s.fileSteps)
+}
+
+// fork starts a new branch sharing the active branch's first at messages
+// and makes it active. It returns the interactions that changed files on
+// the branch left behind after the branch point.
+func (s *Session) fork(at int, label string) (Branch, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncBranch()
+	parent := s.branches[s.activeBranch]
+	undo := interactionsFrom(parent.FileSteps, at)
+
+	b := Branch{
+		ID:      len(s.branches),
+		Parent:  parent.ID,
+		ForkAt:  at,
+		Label:   label,
+		History: slices.Clone(parent.History[:at]),
+		FileSteps: slices.DeleteFunc(slices.Clone(parent.FileSteps), func(f FileStep) bool {
+			return f.At >= at
+		}),
+	}
+	s.branches = append(s.branches, b)
+	s.activeBranch = b.ID
+	s.replaceHistory(slices.Clone(b.History))
+	s.fileSteps = slices.Clone(b.FileSteps)
+	s.warned50 = false
+	return b, undo
+}
+
+// changedFiles reports whether a tool step may have changed files. A
+// sub-agent's changes are reported under its own tool calls, so a
+// completed delegate call counts as one.
+func (s *Session) changedFiles(execs []toolExecution) bool {
+	for _, exec := range execs {
+		if len(exec.fileChanges) > 0 {
+			return true
+		}
+		if s.delegation != nil && exec.toolCall.Name == DelegateToolName && !exec.result.IsError {
+			return true
+		}
+	}
+	return false
+}
+
+// branchMessages returns the prompts and the text of the replies in
+// history.
+func branchMessages(history []provider.Message) []BranchMessage {
+	var msgs []BranchMessage
+	for _, m := range history {
+		if m.Content == "" || len(m.ToolResults) > 0 {
+			continue
+		}
+		msgs = append(msgs, BranchMessage{User: m.Role == provider.RoleUser, Text: m.Content})
+	}
+	return msgs
+}
+
+// validBranches reports whether branches loaded from a saved session form
+// a tree with active among them.
+func validBranches(branches []Branch, active int) bool {
+	if active < 0 || active >= len(branches) {
+		return false
+	}
+	for i, b := range branches {
+		if b.ID != i || b.Parent < -1 || b.Parent >= i || b.ForkAt < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// interactionsFrom returns the interactions of the steps at or after
+// position at, latest first.
+func interactionsFrom(steps []FileStep, at int) []string {
+	var ids []string
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i].At >= at && !slices.Contains(ids, steps[i].InteractionID) {
+			ids = append(ids, steps[i].InteractionID)
+		}
+	}
+	return ids
+}
+
+// sharedPrefix returns the number of leading messages branches a and b
+// have in common: those before the point where their lines diverge.
+// Caller must hold s.mu.
+func (s *Session) sharedPrefix(a, b int) int {
+	limits := make(map[int]int)
+	limit := len(s.branches[a].History)
+	for id := a; id >= 0; id = s.branches[id].Parent {
+		limit = min(limit, len(s.branches[id].History))
+		limits[id] = limit
+		limit = min(limit, s.branches[id].ForkAt)
+	}
+	limit = len(s.branches[b].History)
+	for id := b; id >= 0; id = s.branches[id].Parent {
+		limit = min(limit, len(s.branches[id].History))
+		if l, ok := limits[id]; ok {
+			return min(l, limit)
+		}
+		limit = min(limit, s.branches[id].ForkAt)
+	}
+	return 0
+}
+
+// leaveBranch records the interactions to offer restoring after the active
+// branch was left, and announces the branch now active.
+func (s *Session) leaveBranch(event BranchSwitchedEvent, undo []string) {
+	s.haltedTurn = nil
+	s.pendingRestore = nil
+	if s.restoreFiles != nil && len(undo) > 0 {
+		s.pendingRestore = undo
+		event.FilesChanged = true
+	}
+	s.notifier.Send(event)
+}
+
+// handleEditCommand processes /edit [#<n>] <prompt>. It forks a branch
+// before the n-th prompt of the conversation (the last one by default)
+// and sends the new prompt in its place.
+func (s *Session) handleEditCommand(ctx context.Context, args string) error {
+	n := 0
+	if rest, ok := strings.CutPrefix(args, "#"); ok {
+		num, text, _ := strings.Cut(rest, " ")
+		v, err := strconv.Atoi(num)
+		if err != nil || v < 1 {
+			s.notifier.Send(ErrorEvent{Error: fmt.Sprintf("/edit: bad prompt number %q", "#"+num)})
+			return nil
+		}
+		n, args = v, strings.TrimSpace(text)
+	}
+	if args == "" {
+		s.notifier.Send(ErrorEvent{Error: "usage: /edit [#<n>] <prompt>"})
+		return nil
+	}
+
+	s.mu.Lock()
+	idx := promptIndexes(s.history)
+	s.mu.Unlock()
+	if len(idx) == 0 {
+		s.notifier.Send(ErrorEvent{Error: "/edit: no prompt to edit yet"})
+		return nil
+	}
+	if n == 0 {
+		n = len(idx)
+	}
+	if n > len(idx) {
+		s.notifier.Send(ErrorEvent{Error: fmt.Sprintf("/edit: there are only %d prompts", len(idx))})
+		return nil
+	}
+
+	// Check the prompt as processTurn would before forking, so a used-up
+	// budget or an unreadable attachment leaves the branches unchanged.
+	opts, thinkingBudget, err := s.turnSettings(TurnOptions{})
+	if err != nil {
+		return err
+	}
+	if s.checkBudget() {
+		return nil
+	}
+	blocks, err := s.resolveAttachments(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	b, undo := s.fork(idx[n-1], args)
+	s.leaveBranch(BranchSwitchedEvent{ID: b.ID, Parent: b.Parent, Label: b.Label, Created: true}, undo)
+	s.mu.Lock()
+	s.appendHistory(provider.Message{
+		Role:    provider.RoleUser,
+		Content: args,
+		Blocks:  blocks,
+	})
+	s.mu.Unlock()
+	return s.runTurn(ctx, opts, thinkingBudget)
+}
+
+// handleRetryCommand processes /retry. It forks a branch that keeps the
+// last prompt and asks the model for a new reply to it.
+func (s *Session) handleRetryCommand(ctx context.Context) error {
+	s.mu.Lock()
+	idx := promptIndexes(s.history)
+	s.mu.Unlock()
+	if len(idx) == 0 {
+		s.notifier.Send(ErrorEvent{Error: "/retry: no prompt to retry yet"})
+		return nil
+	}
+
+	opts, thinkingBudget, err := s.turnSettings(TurnOptions{})
+	if err != nil {
+		return err
+	}
+	if s.checkBudget() {
+		return nil
+	}
+
+	last := idx[len(idx)-1]
+	s.mu.Lock()
+	label := s.history[last].Content
+	s.mu.Unlock()
+	b, undo := s.fork(last+1, label)
+	s.leaveBranch(BranchSwitchedEvent{ID: b.ID, Parent: b.Parent, Label: b.Label, Created: true}, undo)
+	return s.runTurn(ctx, opts, thinkingBudget)
+}
+
+// handleBranchCommand processes /branch [<id>|restore]: without an
+// argument it lists the branches, with an ID it switches to that branch,
+// and "restore" restores the files changed on the branch last left.
+func (s *Session) handleBranchCommand(_ context.Context, args string) error {
+	switch args {
+	case "":
+		s.notifier.Send(BranchListEvent{Summary: s.branchSummary()})
+		return nil
+	case "restore":
+		return s.restoreBranchFiles()
+	}
+
+	id, err := strconv.Atoi(args)
+	s.mu.Lock()
+	if err != nil || id < 0 || id >= len(s.branches) {
+		s.mu.Unlock()
+		s.notifier.Send(ErrorEvent{Error: fmt.Sprintf("/branch: no branch %q (see /branch)", args)})
+		return nil
+	}
+	if id == s.activeBranch {
+		s.mu.Unlock()
+		s.notifier.Send(ErrorEvent{Error: fmt.Sprintf("/branch: already on branch %d", id)})
+		return nil
+	}
+	s.syncBranch()
+	shared := s.sharedPrefix(s.activeBranch, id)
+	undo := interactionsFrom(s.fileSteps, shared)
+	target := s.branches[id]
+	s.activeBranch = id
+	s.replaceHistory(slices.Clone(target.History))
+	s.fileSteps = slices.Clone(target.FileSteps)
+	s.warned50 = false
+	s.mu.Unlock()
+
+	s.leaveBranch(BranchSwitchedEvent{
+		ID:     target.ID,
+		Parent: target.Parent,
+		Label:  target.Label,
+		Replay: branchMessages(target.History[min(shared, len(target.History)):]),
+	}, undo)
+	return nil
+}
+
+// restoreBranchFiles restores the files changed on the branch last left
+// since its branch point.
+func (s *Session) restoreBranchFiles() error {
+	if len(s.pendingRestore) == 0 {
+		s.notifier.Send(ErrorEvent{Error: "/branch restore: no file changes to restore"})
+		return nil
+	}
+	paths, err := s.restoreFiles(s.pendingRestore)
+	s.pendingRestore = nil
+	if err != nil {
+		s.notifier.Send(ErrorEvent{Error: fmt.Sprintf("/branch restore: %v", err)})
+		return nil
+	}
+	s.notifier.Send(FilesRestoredEvent{Paths: paths})
+	return nil
+}
+
+// branchSummary renders the branch tree for /branch.
+func (s *Session) branchSummary() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.branches) == 0 {
+		return "No branches yet — /edit a prompt or /retry a reply to start one."
+	}
+	s.syncBranch()
+
+	var b strings.Builder
+	b.WriteString("Branches (switch with /branch <id>):")
+	for _, br := range s.branches {
+		marker := " "
+		if br.ID == s.activeBranch {
+			marker = "*"
+		}
+		label := br.Label
+		if runes := []rune(label); len(runes) > maxBranchLabel {
+			label = string(runes[:maxBranchLabel-3]) + "..."
+		}
+		fmt.Fprintf(&b, "\n%s %d  %q  %d messages", marker, br.ID, label, len(br.History))
+		if br.Parent >= 0 {
+			fmt.Fprintf(&b, ", from %d after message %d", br.Parent, br.ForkAt)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,230 @@
+package core
+
+import (
+	"cosmos/core/provider"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func lastBranchEvent(t *testing.T, notifier *mockNotifier) BranchSwitchedEvent {
+	t.Helper()
+	var last *BranchSwitchedEvent
+	for _, msg := range notifier.getMessages() {
+		if e, ok := msg.(BranchSwitchedEvent); ok {
+			last = &e
+		}
+	}
+	if last == nil {
+		t.Fatal("no BranchSwitchedEvent sent")
+	}
+	return *last
+}
+
+func TestRetryForksAndBranchSwitchesBack(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{
+		textChunks("First answer"),
+		textChunks("Second answer"),
+	}}
+	notifier := &mockNotifier{}
+	session := newTestSession(prov, &mockExecutor{}, notifier)
+
+	for _, text := range []string{"Question", "/retry"} {
+		if err := session.processUserMessage(t.Context(), text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+	if e := lastBranchEvent(t, notifier); !e.Created || e.ID != 1 || e.Parent != 0 {
+		t.Errorf("retry event = %+v, want branch 1 created from 0", e)
+	}
+	retried := prov.requests[1].Messages
+	if len(retried) != 1 || retried[0].Content != "Question" {
+		t.Errorf("retry request messages = %+v, want only the prompt", retried)
+	}
+	if h := session.HistorySnapshot(); len(h) != 2 || h[1].Content != "Second answer" {
+		t.Errorf("history on branch 1 = %+v", h)
+	}
+
+	if err := session.processUserMessage(t.Context(), "/branch 0"); err != nil {
+		t.Fatalf("/branch 0: %v", err)
+	}
+	if h := session.HistorySnapshot(); len(h) != 2 || h[1].Content != "First answer" {
+		t.Errorf("history on branch 0 = %+v", h)
+	}
+	e := lastBranchEvent(t, notifier)
+	if e.Created || e.ID != 0 || len(e.Replay) != 1 || e.Replay[0].Text != "First answer" {
+		t.Errorf("switch event = %+v, want the diverging reply replayed", e)
+	}
+
+	if err := session.processUserMessage(t.Context(), "/branch"); err != nil {
+		t.Fatalf("/branch: %v", err)
+	}
+	var summary string
+	for _, msg := range notifier.getMessages() {
+		if e, ok := msg.(BranchListEvent); ok {
+			summary = e.Summary
+		}
+	}
+	if !strings.Contains(summary, `* 0  "Question"`) || !strings.Contains(summary, "1  \"Question\"  2 messages, from 0 after message 1") {
+		t.Errorf("branch list = %q", summary)
+	}
+}
+
+func TestEditOffersToRestoreFiles(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{
+		textChunks("Hi"),
+		toolUseChunks("call_w", "write", `{}`),
+		textChunks("Written."),
+		textChunks("Different."),
+	}}
+	notifier := &mockNotifier{}
+	session := newTestSession(prov, &mockExecutor{results: map[string]string{"write": "ok"}}, notifier)
+	session.SetFileChangesFunc(func(toolCallID string) []FileChange {
+		if toolCallID == "call_w" {
+			return []FileChange{{Path: "a.go", Operation: "write"}}
+		}
+		return nil
+	})
+	var restored []string
+	session.SetFileRestorer(func(ids []string) ([]string, error) {
+		restored = ids
+		return []string{"a.go"}, nil
+	})
+
+	for _, text := range []string{"Hello", "Write a.go", "/edit #2 Write b.go", "/branch restore"} {
+		if err := session.processUserMessage(t.Context(), text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+
+	if e := lastBranchEvent(t, notifier); !e.Created || !e.FilesChanged || e.Label != "Write b.go" {
+		t.Errorf("edit event = %+v, want a new branch offering a restore", e)
+	}
+	h := session.HistorySnapshot()
+	if len(h) != 4 || h[2].Content != "Write b.go" || h[3].Content != "Different." {
+		t.Errorf("history after /edit = %+v", h)
+	}
+	if len(restored) != 1 || restored[0] == "" {
+		t.Errorf("restored interactions = %v, want the write step's", restored)
+	}
+	if !slices.ContainsFunc(notifier.getMessages(), func(m any) bool {
+		e, ok := m.(FilesRestoredEvent)
+		return ok && len(e.Paths) == 1
+	}) {
+		t.Error("expected a FilesRestoredEvent")
+	}
+
+	// Switching back to the original branch has nothing to restore, since
+	// the edited branch changed no files.
+	if err := session.processUserMessage(t.Context(), "/branch 0"); err != nil {
+		t.Fatalf("/branch 0: %v", err)
+	}
+	if e := lastBranchEvent(t, notifier); e.FilesChanged || len(e.Replay) != 2 {
+		t.Errorf("switch event = %+v, want the two replaced messages replayed", e)
+	}
+}
+
+func TestSaveSession_StoresBranches(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{
+		textChunks("One"),
+		textChunks("Two"),
+	}}
+	session := newTestSession(prov, &mockExecutor{}, &mockNotifier{})
+	for _, text := range []string{"Question", "/retry"} {
+		if err := session.processUserMessage(t.Context(), text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := SaveSession(session, nil, dir, "/work/proj"); err != nil {
+		t.Fatalf("SaveSession: %v", err)
+	}
+	sessions, err := ListSavedSessions(dir)
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("ListSavedSessions: %v, %v", sessions, err)
+	}
+
+	restored := newTestSession(&mockProvider{}, &mockExecutor{}, &mockNotifier{})
+	restored.SetSessionsDir(dir)
+	if err := restored.processUserMessage(t.Context(), "/restore "+sessions[0].Filename); err != nil {
+		t.Fatalf("/restore: %v", err)
+	}
+	if len(restored.branches) != 2 || restored.activeBranch != 1 {
+		t.Fatalf("restored %d branches, active %d; want 2, active 1", len(restored.branches), restored.activeBranch)
+	}
+	if err := restored.processUserMessage(t.Context(), "/branch 0"); err != nil {
+		t.Fatalf("/branch 0: %v", err)
+	}
+	if h := restored.HistorySnapshot(); len(h) != 2 || h[1].Content != "One" {
+		t.Errorf("restored branch 0 history = %+v", h)
+	}
+}
+
+func TestSharedPrefix(t *testing.T) {
+	msgs := func(n int) []provider.Message { return make([]provider.Message, n) }
+	s := &Session{branches: []Branch{
+		{ID: 0, Parent: -1, History: msgs(8)},
+		{ID: 1, Parent: 0, ForkAt: 3, History: msgs(5)},
+		{ID: 2, Parent: 1, ForkAt: 4, History: msgs(6)},
+		{ID: 3, Parent: 0, ForkAt: 6, History: msgs(7)},
+	}}
+	tests := []struct{ a, b, want int }{
+		{1, 0, 3},
+		{2, 1, 4},
+		{2, 0, 3},
+		{3, 2, 3},
+		{3, 0, 6},
+	}
+	for _, tt := range tests {
+		if got := s.sharedPrefix(tt.a, tt.b); got != tt.want {
+			t.Errorf("sharedPrefix(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := s.sharedPrefix(tt.b, tt.a); got != tt.want {
+			t.Errorf("sharedPrefix(%d, %d) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestEditChecksPromptBeforeForking(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{textChunks("Hi")}}
+	session := newTestSession(prov, &mockExecutor{}, &mockNotifier{})
+
+	if err := session.processUserMessage(t.Context(), "Hello"); err != nil {
+		t.Fatalf("Hello: %v", err)
+	}
+	if err := session.processUserMessage(t.Context(), "/edit Look at @missing.png"); err == nil {
+		t.Fatal("/edit with an unreadable attachment succeeded")
+	}
+	if len(session.branches) != 0 {
+		t.Errorf("branches = %+v, want none forked", session.branches)
+	}
+	if h := session.HistorySnapshot(); len(h) != 2 || h[0].Content != "Hello" {
+		t.Errorf("history = %+v, want it unchanged", h)
+	}
+}
+
+func TestCompactionDropsBranches(t *testing.T) {
+	long := strings.Repeat("A detailed message about the implementation. ", 20)
+	prov := &mockProvider{calls: [][]provider.StreamChunk{textChunks("Summary.")}}
+	notifier := &mockNotifier{}
+	session := newTestSession(prov, &mockExecutor{}, notifier)
+	for i := range 8 {
+		role := provider.RoleUser
+		if i%2 == 1 {
+			role = provider.RoleAssistant
+		}
+		session.history = append(session.history, provider.Message{Role: role, Content: long})
+	}
+	session.fork(len(session.history), "Retried")
+
+	if err := session.performCompaction(t.Context(), "manual"); err != nil {
+		t.Fatalf("performCompaction: %v", err)
+	}
+	if len(session.branches) != 0 {
+		t.Errorf("branches = %+v, want them dropped", session.branches)
+	}
+	if summary := session.branchSummary(); !strings.HasPrefix(summary, "No branches yet") {
+		t.Errorf("branch list = %q", summary)
+	}
+}
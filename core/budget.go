@@ -0,0 +1,250 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BudgetScope names what a spend limit covers.
+type BudgetScope string
+
+const (
+	BudgetSession BudgetScope = "session" // this process
+	BudgetDaily   BudgetScope = "daily"   // all sessions today, local time
+	BudgetProject BudgetScope = "project" // all sessions in the working directory
+)
+
+var budgetScopes = []BudgetScope{BudgetSession, BudgetDaily, BudgetProject}
+
+// Budget limits spend in USD. A zero limit is disabled.
+type Budget struct {
+	Session float64
+	Daily   float64
+	Project float64
+
+	// WarnAt lists fractions of a limit (e.g. 0.5, 0.8) at which a
+	// BudgetWarningEvent is sent, once per threshold.
+	WarnAt []float64
+}
+
+func (b *Budget) limit(scope BudgetScope) *float64 {
+	switch scope {
+	case BudgetSession:
+		return &b.Session
+	case BudgetDaily:
+		return &b.Daily
+	case BudgetProject:
+		return &b.Project
+	}
+	return nil
+}
+
+// PriorSpend is spend in USD from earlier sessions that counts toward the
+// daily and project limits.
+type PriorSpend struct {
+	Today   float64 // requests made today
+	Project float64 // requests made in the current working directory
+}
+
+// PriorSpendFrom sums the cost of the ledger entries made on now's day and
+// of those made in workDir.
+func PriorSpendFrom(entries []LedgerEntry, workDir string, now time.Time) PriorSpend {
+	var prior PriorSpend
+	today := dayOf(now)
+	for _, e := range entries {
+		if dayOf(e.Time.In(now.Location())) == today {
+			prior.Today += e.CostUSD
+		}
+		if workDir != "" && e.ProjectDir == workDir {
+			prior.Project += e.CostUSD
+		}
+	}
+	return prior
+}
+
+func dayOf(t time.Time) string { return t.Format(time.DateOnly) }
+
+// budgetState is the Tracker's budget accounting. Guarded by Tracker.mu.
+type budgetState struct {
+	limits     Budget
+	prior      PriorSpend
+	spent      float64                 // this session
+	spentToday float64                 // all sessions on day today
+	today      string                  // "" until SetBudget or the first Record
+	warned     map[BudgetScope]float64 // highest threshold warned per scope
+}
+
+// haltedTurn is a turn stopped by an exceeded budget, resumed by /budget.
+type haltedTurn struct {
+	opts           TurnOptions
+	thinkingBudget int
+}
+
+// SetBudget sets the spend limits and the prior spend counted toward them.
+func (t *Tracker) SetBudget(limits Budget, prior PriorSpend) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.budget.limits = limits
+	t.budget.prior = prior
+	t.budget.today = dayOf(t.clock())
+	t.budget.spentToday = prior.Today + t.budget.spent
+	t.budget.warned = make(map[BudgetScope]float64)
+}
+
+// SetBudgetLimit changes one limit, e.g. to raise it after it was reached.
+// A zero usd disables the limit.
+func (t *Tracker) SetBudgetLimit(scope BudgetScope, usd float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if l := t.budget.limits.limit(scope); l != nil {
+		*l = usd
+		delete(t.budget.warned, scope)
+	}
+}
+
+// rollover starts a new day's spend at midnight. Caller must hold Tracker.mu.
+func (b *budgetState) rollover(now time.Time) {
+	if day := dayOf(now); day != b.today {
+		if b.today != "" {
+			b.spentToday = 0
+			delete(b.warned, BudgetDaily)
+		}
+		b.today = day
+	}
+}
+
+// add counts a request's cost. Caller must hold Tracker.mu.
+func (b *budgetState) add(cost float64, now time.Time) {
+	b.rollover(now)
+	b.spent += cost
+	b.spentToday += cost
+}
+
+func (b *budgetState) spentIn(scope BudgetScope) float64 {
+	switch scope {
+	case BudgetDaily:
+		return b.spentToday
+	case BudgetProject:
+		return b.prior.Project + b.spent
+	default:
+		return b.spent
+	}
+}
+
+// CheckBudget compares spend with the limits. It returns the warning
+// thresholds crossed since the last check, and the first limit used up, or
+// nil if none is. The session calls it before every provider request and
+// halts the turn while a limit is exceeded.
+func (t *Tracker) CheckBudget() ([]BudgetWarningEvent, *BudgetExceededEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := &t.budget
+	b.rollover(t.clock())
+
+	var warnings []BudgetWarningEvent
+	var exceeded *BudgetExceededEvent
+	for _, scope := range budgetScopes {
+		limit := *b.limits.limit(scope)
+		if limit <= 0 {
+			continue
+		}
+		spent := b.spentIn(scope)
+		if spent >= limit {
+			if exceeded == nil {
+				exceeded = &BudgetExceededEvent{Scope: scope, Spent: spent, Limit: limit}
+			}
+			continue
+		}
+		crossed := 0.0
+		for _, th := range b.limits.WarnAt {
+			if spent >= th*limit && th > crossed {
+				crossed = th
+			}
+		}
+		if crossed > b.warned[scope] {
+			if b.warned == nil {
+				b.warned = make(map[BudgetScope]float64)
+			}
+			b.warned[scope] = crossed
+			warnings = append(warnings, BudgetWarningEvent{Scope: scope, Spent: spent, Limit: limit, Threshold: crossed})
+		}
+	}
+	return warnings, exceeded
+}
+
+// BudgetStatus renders spend against each limit, e.g. in reply to /budget.
+func (t *Tracker) BudgetStatus() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	parts := make([]string, len(budgetScopes))
+	for i, scope := range budgetScopes {
+		spent := t.budget.spentIn(scope)
+		if limit := *t.budget.limits.limit(scope); limit > 0 {
+			parts[i] = fmt.Sprintf("%s: $%.2f of $%.2f", scope, spent, limit)
+		} else {
+			parts[i] = fmt.Sprintf("%s: $%.2f (no limit)", scope, spent)
+		}
+	}
+	return "Budget — " + strings.Join(parts, " · ")
+}
+
+// parseBudgetArgs parses a /budget argument: a scope and a limit in USD, or
+// "off" to disable the limit.
+func parseBudgetArgs(args string) (BudgetScope, float64, error) {
+	fields := strings.Fields(args)
+	if len(fields) != 2 {
+		return "", 0, fmt.Errorf("usage: /budget [session|daily|project <usd>|off]")
+	}
+	scope := BudgetScope(fields[0])
+	if (&Budget{}).limit(scope) == nil {
+		return "", 0, fmt.Errorf("unknown budget %q (want session, daily or project)", fields[0])
+	}
+	if fields[1] == "off" {
+		return scope, 0, nil
+	}
+	usd, err := strconv.ParseFloat(strings.TrimPrefix(fields[1], "$"), 64)
+	if err != nil || usd <= 0 {
+		return "", 0, fmt.Errorf("expected a positive amount in USD or \"off\", got %q", fields[1])
+	}
+	return scope, usd, nil
+}
+
+// checkBudget sends budget warnings and reports whether a limit is used up,
+// in which case the user has been asked to raise it.
+func (s *Session) checkBudget() bool {
+	warnings, exceeded := s.tracker.CheckBudget()
+	for _, w := range warnings {
+		s.notifier.Send(w)
+	}
+	if exceeded != nil {
+		s.notifier.Send(*exceeded)
+		return true
+	}
+	return false
+}
+
+// handleBudgetCommand processes /budget [<scope> <usd>|off]. Raising a limit
+// resumes a turn halted by it.
+func (s *Session) handleBudgetCommand(ctx context.Context, args string) error {
+	if args == "" {
+		s.notifier.Send(BudgetStatusEvent{Summary: s.tracker.BudgetStatus()})
+		return nil
+	}
+	scope, usd, err := parseBudgetArgs(args)
+	if err != nil {
+		s.notifier.Send(ErrorEvent{Error: "/budget: " + err.Error()})
+		return nil
+	}
+	s.tracker.SetBudgetLimit(scope, usd)
+	s.notifier.Send(BudgetStatusEvent{Summary: s.tracker.BudgetStatus()})
+
+	halted := s.haltedTurn
+	if halted == nil {
+		return nil
+	}
+	s.haltedTurn = nil
+	return s.runTurn(ctx, halted.opts, halted.thinkingBudget)
+}
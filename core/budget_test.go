@@ -0,0 +1,97 @@
+package core
+
+import (
+	"cosmos/core/provider"
+	"testing"
+	"time"
+)
+
+func TestCheckBudgetWarnsOncePerThreshold(t *testing.T) {
+	tracker := NewTracker(nil, nil)
+	tracker.SetBudget(Budget{Session: 1, WarnAt: []float64{0.5, 0.8}}, PriorSpend{})
+	model := modelInfo("m", "M", 1_000_000, 0) // $1 per input token
+
+	thresholds := func() []float64 {
+		warnings, exceeded := tracker.CheckBudget()
+		if exceeded != nil {
+			t.Fatalf("exceeded = %+v", exceeded)
+		}
+		var got []float64
+		for _, w := range warnings {
+			got = append(got, w.Threshold)
+		}
+		return got
+	}
+
+	if got := thresholds(); got != nil {
+		t.Errorf("no spend: warnings at %v", got)
+	}
+	tracker.budget.spent = 0.6
+	if got := thresholds(); len(got) != 1 || got[0] != 0.5 {
+		t.Errorf("at 60%%: warnings at %v, want [0.5]", got)
+	}
+	if got := thresholds(); got != nil {
+		t.Errorf("repeated check: warnings at %v", got)
+	}
+	tracker.budget.spent = 0.9
+	if got := thresholds(); len(got) != 1 || got[0] != 0.8 {
+		t.Errorf("at 90%%: warnings at %v, want [0.8]", got)
+	}
+
+	tracker.Record(model, provider.Usage{InputTokens: 1}, SourcePrompt)
+	_, exceeded := tracker.CheckBudget()
+	if exceeded == nil || exceeded.Scope != BudgetSession || exceeded.Limit != 1 {
+		t.Fatalf("exceeded = %+v, want session limit", exceeded)
+	}
+
+	tracker.SetBudgetLimit(BudgetSession, 10)
+	if _, exceeded := tracker.CheckBudget(); exceeded != nil {
+		t.Errorf("still exceeded after raising the limit: %+v", exceeded)
+	}
+}
+
+func TestCheckBudgetDailyAndProject(t *testing.T) {
+	now := time.Date(2025, 6, 1, 23, 0, 0, 0, time.Local)
+	tracker := NewTracker(nil, nil)
+	tracker.clock = func() time.Time { return now }
+	tracker.SetBudget(Budget{Daily: 5, Project: 50}, PriorSpend{Today: 4.5, Project: 49})
+
+	tracker.Record(modelInfo("m", "M", 1_000_000, 0), provider.Usage{InputTokens: 1}, SourcePrompt)
+	_, exceeded := tracker.CheckBudget()
+	if exceeded == nil || exceeded.Scope != BudgetDaily || exceeded.Spent != 5.5 {
+		t.Fatalf("exceeded = %+v, want daily at 5.5", exceeded)
+	}
+
+	// After midnight only the project limit still counts earlier spend.
+	now = now.Add(2 * time.Hour)
+	_, exceeded = tracker.CheckBudget()
+	if exceeded == nil || exceeded.Scope != BudgetProject || exceeded.Spent != 50 {
+		t.Fatalf("exceeded = %+v, want project at 50", exceeded)
+	}
+}
+
+func TestPriorSpendFrom(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	entries := []LedgerEntry{
+		{ProjectDir: "/repo", Time: now.Add(-time.Hour), CostUSD: 1},
+		{ProjectDir: "/other", Time: now.Add(-2 * time.Hour), CostUSD: 2},
+		{ProjectDir: "/repo", Time: now.AddDate(0, 0, -1), CostUSD: 4},
+	}
+	if got, want := PriorSpendFrom(entries, "/repo", now), (PriorSpend{Today: 3, Project: 5}); got != want {
+		t.Errorf("PriorSpendFrom = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseBudgetArgs(t *testing.T) {
+	if scope, usd, err := parseBudgetArgs("daily $25"); err != nil || scope != BudgetDaily || usd != 25 {
+		t.Errorf("daily $25 = %q, %v, %v", scope, usd, err)
+	}
+	if scope, usd, err := parseBudgetArgs("project off"); err != nil || scope != BudgetProject || usd != 0 {
+		t.Errorf("project off = %q, %v, %v", scope, usd, err)
+	}
+	for _, args := range []string{"session", "weekly 5", "session -1", "session lots"} {
+		if _, _, err := parseBudgetArgs(args); err == nil {
+			t.Errorf("parseBudgetArgs(%q) should fail", args)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"strings"
+	"unicode"
+)
+
+const (
+	// defaultMaxTokens caps the output limit derived from a model's
+	// published maximum. Bedrock reserves throughput quota against
+	// max_tokens, so asking for 64K on every request throttles sooner;
+	// longer replies are continued instead.
+	defaultMaxTokens = 16384
+
+	// fallbackMaxTokens is the output limit for models whose maximum is
+	// unknown.
+	fallbackMaxTokens = 4096
+
+	// maxContinuations bounds how often one reply is continued after
+	// hitting the output limit.
+	maxContinuations = 4
+
+	stopMaxTokens = "max_tokens"
+)
+
+// outputBudget is the max_tokens of a turn's requests. It can grow up to
+// ceiling when a tool call does not fit.
+type outputBudget struct {
+	tokens  int
+	ceiling int
+}
+
+// outputBudget derives the turn's output limit: the configured maxTokens if
+// set, otherwise the model's maximum capped at defaultMaxTokens. Neither may
+// exceed the model's maximum.
+func (s *Session) outputBudget(ctx context.Context) outputBudget {
+	limit := 0
+	if info, err := s.getModelInfo(ctx); err == nil && info != nil {
+		limit = info.MaxOutputTokens
+	}
+	tokens := s.maxTokens
+	switch {
+	case tokens > 0:
+	case limit > 0:
+		tokens = min(limit, defaultMaxTokens)
+	default:
+		tokens = fallbackMaxTokens
+	}
+	if limit <= 0 {
+		return outputBudget{tokens: tokens, ceiling: tokens}
+	}
+	return outputBudget{tokens: min(tokens, limit), ceiling: limit}
+}
+
+// grow doubles the budget up to its ceiling. It reports false if the budget
+// is already at the ceiling.
+func (b *outputBudget) grow() bool {
+	if b.tokens >= b.ceiling {
+		return false
+	}
+	b.tokens = min(b.tokens*2, b.ceiling)
+	return true
+}
+
+// truncatedToolCall reports whether a response cut off at the output limit
+// ended inside a tool call, leaving its input JSON incomplete.
+func truncatedToolCall(pending *pendingToolCall, calls []provider.ToolCall) bool {
+	if pending != nil {
+		return true
+	}
+	for _, tc := range calls {
+		if _, ok := tc.Input["_raw"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// continuationPrefill is the cut-off reply sent back as the final assistant
+// message so the model resumes mid-reply. Providers reject a prefill that
+// ends in whitespace.
+func continuationPrefill(text string) string {
+	return strings.TrimRightFunc(text, unicode.IsSpace)
+}
+
+// signedReasoning keeps the reasoning blocks a provider accepts back in
+// history: signed or redacted ones. A block cut off before its signature
+// arrived would fail the provider's signature check.
+func signedReasoning(blocks []provider.ReasoningBlock) []provider.ReasoningBlock {
+	var signed []provider.ReasoningBlock
+	for _, r := range blocks {
+		if r.Signature != "" || r.Redacted != nil {
+			signed = append(signed, r)
+		}
+	}
+	return signed
+}
+
+// replayFilter passes on only the streamed text not yet shown to the user.
+// A retried reply repeats what the discarded attempt already streamed.
+type replayFilter struct {
+	shown int // bytes shown by earlier attempts
+	seen  int // bytes streamed by this attempt
+}
+
+// unseen returns the part of text past what was already shown.
+func (f *replayFilter) unseen(text string) string {
+	start := f.seen
+	f.seen += len(text)
+	switch {
+	case f.seen <= f.shown:
+		return ""
+	case start >= f.shown:
+		return text
+	default:
+		return text[f.shown-start:]
+	}
+}
+
+// retry starts another attempt at the same reply.
+func (f *replayFilter) retry() {
+	f.shown = max(f.shown, f.seen)
+	f.seen = 0
+}
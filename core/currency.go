@@ -7,7 +7,10 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 const defaultFrankfurterBaseURL = "https://api.frankfurter.app"
@@ -48,6 +51,8 @@ type CurrencyEngine struct {
 	baseURL    string
 	httpClient *http.Client
 	userAgent  string
+	cacheDir   string        // "" disables the rate cache
+	cacheTTL   time.Duration // how long a cached rate is used without refetching
 }
 
 // NewCurrencyEngine creates a CurrencyEngine using the default Frankfurter base URL.
@@ -72,6 +77,98 @@ func NewCurrencyEngineWithBaseURL(httpClient *http.Client, baseURL string) *Curr
 	return engine
 }
 
+// SetCache makes Rate keep fetched rates in dir and reuse them for ttl.
+func (e *CurrencyEngine) SetCache(dir string, ttl time.Duration) {
+	e.cacheDir = dir
+	e.cacheTTL = ttl
+}
+
+// ExchangeRate is a rate from one currency to another and when it was fetched.
+type ExchangeRate struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Rate      float64   `json:"rate"`
+	FetchedAt time.Time `json:"fetchedAt"`
+
+	// Stale is set when the rate is older than the cache TTL because the
+	// currency API could not be reached.
+	Stale bool `json:"-"`
+}
+
+// Rate returns the exchange rate from one currency to another: the cached
+// rate while it is younger than the cache TTL, and otherwise a freshly
+// fetched one, which is cached. If the API cannot be reached, the last
+// cached rate is returned, marked Stale; an error is returned only when no
+// rate was ever cached.
+func (e *CurrencyEngine) Rate(ctx context.Context, from, to string) (ExchangeRate, error) {
+	from = strings.ToUpper(strings.TrimSpace(from))
+	to = strings.ToUpper(strings.TrimSpace(to))
+	if from == to {
+		return ExchangeRate{From: from, To: to, Rate: 1.0, FetchedAt: time.Now().UTC()}, nil
+	}
+
+	cached, haveCached := e.readCachedRate(from, to)
+	if haveCached && time.Since(cached.FetchedAt) < e.cacheTTL {
+		return cached, nil
+	}
+
+	rate, err := e.FetchRate(ctx, from, to)
+	if err != nil {
+		if haveCached {
+			cached.Stale = true
+			return cached, nil
+		}
+		return ExchangeRate{}, err
+	}
+	fresh := ExchangeRate{From: from, To: to, Rate: rate, FetchedAt: time.Now().UTC()}
+	if err := e.writeCachedRate(fresh); err != nil {
+		fmt.Fprintf(os.Stderr, "cosmos: warning: currency cache: %v\n", err)
+	}
+	return fresh, nil
+}
+
+func (e *CurrencyEngine) cachePath(from, to string) string {
+	return filepath.Join(e.cacheDir, from+"-"+to+".json")
+}
+
+func (e *CurrencyEngine) readCachedRate(from, to string) (ExchangeRate, bool) {
+	if e.cacheDir == "" {
+		return ExchangeRate{}, false
+	}
+	data, err := os.ReadFile(e.cachePath(from, to))
+	if err != nil {
+		return ExchangeRate{}, false
+	}
+	var cached ExchangeRate
+	if err := json.Unmarshal(data, &cached); err != nil || cached.Rate <= 0 {
+		return ExchangeRate{}, false
+	}
+	return cached, true
+}
+
+// writeCachedRate stores a rate atomically (write to .tmp, then rename).
+func (e *CurrencyEngine) writeCachedRate(rate ExchangeRate) error {
+	if e.cacheDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(e.cacheDir, 0700); err != nil {
+		return fmt.Errorf("creating cache dir: %w", err)
+	}
+	data, err := json.MarshalIndent(rate, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling rate: %w", err)
+	}
+	path := e.cachePath(rate.From, rate.To)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("writing rate: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		_ = os.Remove(path + ".tmp")
+		return fmt.Errorf("renaming rate file: %w", err)
+	}
+	return nil
+}
+
 // FetchRate fetches the exchange rate from one currency to another.
 // Returns the rate as a float64 (e.g., 0.92 for USD→EUR).
 func (e *CurrencyEngine) FetchRate(ctx context.Context, from, to string) (float64, error) {
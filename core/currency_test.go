@@ -6,7 +6,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestFetchRate(t *testing.T) {
@@ -68,6 +70,48 @@ func TestFetchRateHTTPError(t *testing.T) {
 	}
 }
 
+func TestRateCachesAndFallsBack(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+	var offline atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if offline.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"amount":1,"base":"USD","rates":{"EUR":0.9}}`))
+	}))
+	defer ts.Close()
+
+	cacheDir := t.TempDir()
+	engine := NewCurrencyEngineWithBaseURL(ts.Client(), ts.URL)
+	engine.SetCache(cacheDir, time.Hour)
+
+	rate, err := engine.Rate(context.Background(), "usd", "eur")
+	if err != nil || rate.Rate != 0.9 || rate.Stale {
+		t.Fatalf("Rate() = %+v, %v; want fresh 0.9", rate, err)
+	}
+	// Within the TTL the cached rate is used without a request.
+	if rate, err := engine.Rate(context.Background(), "USD", "EUR"); err != nil || rate.Rate != 0.9 || hits.Load() != 1 {
+		t.Errorf("cached Rate() = %+v, %v after %d requests; want 0.9 after 1", rate, err, hits.Load())
+	}
+
+	// Once expired and offline, the last known rate is used.
+	offline.Store(true)
+	engine.SetCache(cacheDir, 0)
+	rate, err = engine.Rate(context.Background(), "USD", "EUR")
+	if err != nil || rate.Rate != 0.9 || !rate.Stale || rate.FetchedAt.IsZero() {
+		t.Errorf("offline Rate() = %+v, %v; want stale 0.9", rate, err)
+	}
+
+	// With nothing cached, the error surfaces.
+	if _, err := engine.Rate(context.Background(), "USD", "GBP"); err == nil {
+		t.Error("offline Rate() without a cached rate should fail")
+	}
+}
+
 func TestListSupportedCurrencies(t *testing.T) {
 	t.Parallel()
 
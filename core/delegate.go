@@ -0,0 +1,279 @@
+package core
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"cosmos/engine/manifest"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// DelegateToolName is the built-in tool through which the model hands a
+// self-contained task to a sub-agent.
+const DelegateToolName = "delegate"
+
+// SourceDelegate is the Tracker source of sub-agent requests.
+const SourceDelegate Source = "delegate"
+
+// Delegation configures the sub-agents the delegate tool spawns.
+type Delegation struct {
+	// Model the sub-agents run on, typically a smaller one; "" uses the
+	// parent's model.
+	Model string
+
+	// Tools the sub-agents may be given; empty allows all of the parent's.
+	// The model picks a subset of these per task.
+	Tools []string
+
+	// MaxRequests bounds the model requests of one sub-agent; 0 is unbounded.
+	MaxRequests int
+}
+
+const delegateSystemPrompt = `You are a sub-agent of a coding assistant. Complete the task you are given using your tools, without asking questions. Your final reply is the only thing the requesting agent sees, so make it a concise, complete answer: the findings, file paths and facts it needs, not a narrative of your steps.`
+
+// errRequestLimit ends a turn that reached Session.maxRequests.
+var errRequestLimit = errors.New("request limit reached")
+
+// SetDelegation offers the model the delegate tool, which runs a task in a
+// child session with its own history, a subset of the tools and a request
+// budget, and returns only the child's final answer. Child usage is tracked
+// under SourceDelegate; its file changes belong to the parent's interaction.
+// It fails if d.Tools names a tool the session does not have.
+// Must be called before Start().
+func (s *Session) SetDelegation(d Delegation) error {
+	for _, name := range d.Tools {
+		if name == DelegateToolName || !slices.ContainsFunc(s.tools, func(t provider.ToolDefinition) bool { return t.Name == name }) {
+			return fmt.Errorf("delegate: unknown tool %q", name)
+		}
+	}
+	s.delegation = &d
+	s.tools = append(slices.DeleteFunc(slices.Clone(s.tools), func(t provider.ToolDefinition) bool {
+		return t.Name == DelegateToolName
+	}), s.delegateTool())
+	return nil
+}
+
+// delegateTool describes the delegate tool, listing the tools a sub-agent
+// may be given.
+func (s *Session) delegateTool() provider.ToolDefinition {
+	names := s.delegableTools()
+	return provider.ToolDefinition{
+		Name: DelegateToolName,
+		Description: "Hand a self-contained task, such as exploring a large part of the codebase, to a sub-agent " +
+			"with its own context. Only its final answer is returned, which keeps this conversation small. " +
+			"The sub-agent cannot see this conversation, so state the task fully.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"task": map[string]any{
+					"type":        "string",
+					"description": "The task, with all the context the sub-agent needs.",
+				},
+				"tools": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string", "enum": names},
+					"description": "Tools the sub-agent may use. Defaults to all of them.",
+				},
+			},
+			"required": []string{"task"},
+		},
+	}
+}
+
+// delegableTools lists the names of the tools a sub-agent may be given.
+func (s *Session) delegableTools() []string {
+	var names []string
+	for _, t := range s.tools {
+		if t.Name == DelegateToolName {
+			continue // sub-agents do not delegate further
+		}
+		if len(s.delegation.Tools) == 0 || slices.Contains(s.delegation.Tools, t.Name) {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
+
+// runDelegate runs a delegate tool call in a child session and returns the
+// child's final answer.
+func (s *Session) runDelegate(ctx context.Context, tc provider.ToolCall, interactionID string) (string, error) {
+	task, _ := tc.Input["task"].(string)
+	if strings.TrimSpace(task) == "" {
+		return "", fmt.Errorf("delegate: task is required")
+	}
+	tools, err := s.delegateToolSet(tc.Input["tools"])
+	if err != nil {
+		return "", fmt.Errorf("delegate: %w", err)
+	}
+
+	model := s.delegation.Model
+	if model == "" {
+		model = s.model
+	}
+	var executor ToolExecutor
+	if s.executor != nil {
+		executor = &toolSubset{ToolExecutor: s.executor, tools: tools}
+	}
+	child := NewSession(uuid.New().String(), s.provider, s.tracker,
+		&delegateNotifier{parent: s.notifier}, model, delegateSystemPrompt, 0,
+		executor, tools, s.auditLogger, s.evaluator)
+	child.source = SourceDelegate
+	child.interactionID = interactionID
+	child.maxRequests = s.delegation.MaxRequests
+	child.permissionTimeout = s.permissionTimeout
+	child.resolvePermission = s.resolvePermission
+	child.getFileChanges = s.getFileChanges
+	child.cacheTurns = s.cacheTurns
+	if info := s.delegateModelInfo(ctx, model); info != nil {
+		child.cachedModelInfo = info
+		child.modelInfoOnce.Do(func() {})
+	}
+
+	child.history = []provider.Message{{Role: provider.RoleUser, Content: task}}
+	err = child.runTurn(ctx, TurnOptions{}, 0)
+	answer, answered := child.finalAnswer()
+	switch {
+	case errors.Is(err, errRequestLimit):
+		if !answered {
+			return "", fmt.Errorf("sub-agent stopped after %d model requests without a final answer", child.maxRequests)
+		}
+	case err != nil:
+		return "", fmt.Errorf("sub-agent failed: %w", err)
+	case ctx.Err() != nil:
+		return "", ctx.Err()
+	case !answered:
+		// A used-up budget halts the child before it answers.
+		return "", fmt.Errorf("sub-agent stopped before answering")
+	}
+	return answer, nil
+}
+
+// delegateModelInfo returns the info of the sub-agents' model, looked up
+// once by the parent rather than by every sub-agent. The model is fixed by
+// the Delegation, so one cached entry serves. Returns nil if not found.
+func (s *Session) delegateModelInfo(ctx context.Context, model string) *provider.ModelInfo {
+	if model == s.model {
+		info, _ := s.getModelInfo(ctx)
+		return info
+	}
+	s.mu.Lock()
+	info := s.delegateInfo
+	s.mu.Unlock()
+	if info == nil {
+		info, _ = s.lookupModelInfo(ctx, model)
+		s.mu.Lock()
+		s.delegateInfo = info
+		s.mu.Unlock()
+	}
+	return info
+}
+
+// delegateToolSet resolves the tools requested for a sub-agent; nil asks
+// for every delegable tool.
+func (s *Session) delegateToolSet(requested any) ([]provider.ToolDefinition, error) {
+	allowed := s.delegableTools()
+	names := allowed
+	if requested != nil {
+		list, ok := requested.([]any)
+		if !ok {
+			return nil, fmt.Errorf("tools must be a list of tool names")
+		}
+		names = nil
+		for _, v := range list {
+			name, _ := v.(string)
+			if !slices.Contains(allowed, name) {
+				return nil, fmt.Errorf("tool %q is not available to sub-agents (available: %s)", v, strings.Join(allowed, ", "))
+			}
+			names = append(names, name)
+		}
+	}
+
+	var tools []provider.ToolDefinition
+	for _, t := range s.tools {
+		if slices.Contains(names, t.Name) {
+			tools = append(tools, t)
+		}
+	}
+	return tools, nil
+}
+
+// finalAnswer returns the text of the last assistant message, if the
+// history ends with one that calls no tools.
+func (s *Session) finalAnswer() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n := len(s.history); n > 0 {
+		if last := s.history[n-1]; last.Role == provider.RoleAssistant && len(last.ToolCalls) == 0 {
+			return last.Content, true
+		}
+	}
+	return "", false
+}
+
+// delegateNotifier passes on the events of a sub-agent that concern the
+// user: its tool calls, permission prompts, file changes and spend. Its
+// streamed reply and context usage stay out of the parent's chat.
+type delegateNotifier struct {
+	parent Notifier
+}
+
+func (n *delegateNotifier) Send(msg any) {
+	switch msg.(type) {
+	case ToolUseEvent, ToolResultEvent, ToolExecutionEvent,
+		PermissionRequestEvent, PermissionTimeoutEvent, FileChangeEvent,
+		PricingMissingEvent, BudgetWarningEvent, BudgetExceededEvent:
+		n.parent.Send(msg)
+	}
+}
+
+// toolSubset restricts an executor to a sub-agent's tools. The optional
+// executor interfaces are passed through.
+type toolSubset struct {
+	ToolExecutor
+	tools []provider.ToolDefinition
+}
+
+func (t *toolSubset) allows(name string) error {
+	for _, def := range t.tools {
+		if def.Name == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("tool %s is not available to this sub-agent", name)
+}
+
+func (t *toolSubset) Execute(ctx context.Context, name string, input map[string]any) (string, error) {
+	if err := t.allows(name); err != nil {
+		return "", err
+	}
+	return t.ToolExecutor.Execute(ctx, name, input)
+}
+
+func (t *toolSubset) ExecuteContent(ctx context.Context, name string, input map[string]any) (string, []provider.ContentBlock, error) {
+	if err := t.allows(name); err != nil {
+		return "", nil, err
+	}
+	if ce, ok := t.ToolExecutor.(ContentToolExecutor); ok {
+		return ce.ExecuteContent(ctx, name, input)
+	}
+	result, err := t.ToolExecutor.Execute(ctx, name, input)
+	return result, nil, err
+}
+
+func (t *toolSubset) ToolPermissionRules(name string) (string, []manifest.PermissionRule, bool) {
+	if mp, ok := t.ToolExecutor.(ToolManifestProvider); ok {
+		return mp.ToolPermissionRules(name)
+	}
+	return "", nil, false
+}
+
+func (t *toolSubset) WithExecContext(ctx context.Context, interactionID, toolCallID string) context.Context {
+	if ec, ok := t.ToolExecutor.(ExecutionContexter); ok {
+		return ec.WithExecContext(ctx, interactionID, toolCallID)
+	}
+	return ctx
+}
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"strings"
+	"testing"
+)
+
+var delegateTestTools = []provider.ToolDefinition{{Name: "search"}, {Name: "write"}}
+
+func newDelegatingSession(prov *mockProvider, notifier Notifier, d Delegation) *Session {
+	exec := &mockExecutor{results: map[string]string{"search": "a.go:12", "write": "ok"}}
+	s := NewSession("parent", prov, NewTracker(nil, nil), notifier, "test-model", "system", 1024, exec, delegateTestTools, nil, nil)
+	if err := s.SetDelegation(d); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func TestDelegateReturnsOnlyFinalAnswer(t *testing.T) {
+	prov := &mockProvider{
+		calls: [][]provider.StreamChunk{
+			toolUseChunks("call_d", DelegateToolName, `{"task":"Find the parser","tools":["search"]}`),
+			toolUseChunks("call_s", "search", `{}`),
+			textChunks("The parser is in a.go."),
+			textChunks("Done."),
+		},
+		models: []provider.ModelInfo{
+			modelInfo("test-model", "Test", 3, 15),
+			modelInfo("small-model", "Small", 1, 5),
+		},
+	}
+	notifier := &mockNotifier{}
+	session := newDelegatingSession(prov, notifier, Delegation{Model: "small-model"})
+
+	if err := session.processUserMessage(t.Context(), "Where is the parser?"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+
+	if tools := prov.requests[0].Tools; len(tools) != 3 || tools[2].Name != DelegateToolName {
+		t.Errorf("parent tools = %+v, want the delegate tool added", tools)
+	}
+	child := prov.requests[1]
+	if child.Model != "small-model" || child.System != delegateSystemPrompt {
+		t.Errorf("child request model %q system %q", child.Model, child.System)
+	}
+	if len(child.Tools) != 1 || child.Tools[0].Name != "search" {
+		t.Errorf("child tools = %+v, want only search", child.Tools)
+	}
+	if len(child.Messages) != 1 || child.Messages[0].Content != "Find the parser" {
+		t.Errorf("child messages = %+v, want only the task", child.Messages)
+	}
+
+	history := session.HistorySnapshot()
+	if len(history) != 4 {
+		t.Fatalf("parent history has %d messages, want 4", len(history))
+	}
+	if r := history[2].ToolResults; len(r) != 1 || r[0].Content != "The parser is in a.go." || r[0].IsError {
+		t.Errorf("delegate result = %+v, want the child's final answer", r)
+	}
+
+	var sawChildTool bool
+	for _, msg := range notifier.getMessages() {
+		switch e := msg.(type) {
+		case TokenEvent:
+			if strings.Contains(e.Text, "parser is in") {
+				t.Error("the child's reply streamed into the parent chat")
+			}
+		case ToolUseEvent:
+			sawChildTool = sawChildTool || e.ToolName == "search"
+		}
+	}
+	if !sawChildTool {
+		t.Error("expected the child's tool call to be shown")
+	}
+
+	var delegateCost bool
+	for _, m := range session.tracker.Snapshot().Models {
+		for _, src := range m.Sources {
+			if src.Source == SourceDelegate {
+				delegateCost = m.ModelID == "small-model"
+			}
+		}
+	}
+	if !delegateCost {
+		t.Error("child usage should be tracked under SourceDelegate for small-model")
+	}
+}
+
+func TestDelegateRequestLimit(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{
+		toolUseChunks("call_d", DelegateToolName, `{"task":"Search forever"}`),
+		toolUseChunks("call_s", "search", `{}`),
+		textChunks("Gave up."),
+	}}
+	session := newDelegatingSession(prov, &mockNotifier{}, Delegation{MaxRequests: 1})
+
+	if err := session.processUserMessage(t.Context(), "Go"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+	r := session.HistorySnapshot()[2].ToolResults[0]
+	if !r.IsError || !strings.Contains(r.Content, "after 1 model requests") {
+		t.Errorf("delegate result = %+v, want the request limit error", r)
+	}
+}
+
+func TestDelegateToolRestrictions(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{
+		toolUseChunks("call_d", DelegateToolName, `{"task":"Edit it","tools":["write"]}`),
+		textChunks("Could not."),
+	}}
+	session := newDelegatingSession(prov, &mockNotifier{}, Delegation{Tools: []string{"search"}})
+
+	if err := session.processUserMessage(t.Context(), "Go"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+	r := session.HistorySnapshot()[2].ToolResults[0]
+	if !r.IsError || !strings.Contains(r.Content, `"write" is not available`) {
+		t.Errorf("delegate result = %+v, want write refused", r)
+	}
+
+	// The child cannot call tools it was not given.
+	subset := &toolSubset{ToolExecutor: &mockExecutor{}, tools: delegateTestTools[:1]}
+	if _, err := subset.Execute(t.Context(), "write", nil); err == nil {
+		t.Error("toolSubset ran a tool outside its set")
+	}
+}
+
+func TestDelegateFileChangesJoinParentInteraction(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{
+		{
+			{Event: provider.EventToolStart, ToolCallID: "call_w", ToolName: "write"},
+			{Event: provider.EventToolEnd},
+			{Event: provider.EventToolStart, ToolCallID: "call_d", ToolName: DelegateToolName},
+			{Event: provider.EventToolDelta, InputDelta: `{"task":"Write more"}`},
+			{Event: provider.EventToolEnd},
+			{Event: provider.EventMessageStop, StopReason: "tool_use"},
+		},
+		toolUseChunks("call_cw", "write", `{}`),
+		textChunks("Written."),
+		textChunks("Done."),
+	}}
+	notifier := &mockNotifier{}
+	session := newDelegatingSession(prov, notifier, Delegation{})
+	session.SetFileChangesFunc(func(toolCallID string) []FileChange {
+		if toolCallID == "call_d" {
+			return nil
+		}
+		return []FileChange{{Path: toolCallID + ".txt", Operation: "write"}}
+	})
+
+	if err := session.processUserMessage(t.Context(), "Go"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+	interactions := map[string]string{}
+	for _, msg := range notifier.getMessages() {
+		if e, ok := msg.(FileChangeEvent); ok {
+			interactions[e.ToolCallID] = e.InteractionID
+		}
+	}
+	if interactions["call_w"] == "" || interactions["call_cw"] != interactions["call_w"] {
+		t.Errorf("file change interactions = %v, want the child's under the parent's", interactions)
+	}
+}
+
+func TestSetDelegationRejectsUnknownTools(t *testing.T) {
+	s := NewSession("parent", &mockProvider{}, NewTracker(nil, nil), &mockNotifier{}, "test-model", "system", 1024, &mockExecutor{}, delegateTestTools, nil, nil)
+	for _, name := range []string{"serach", DelegateToolName} {
+		if err := s.SetDelegation(Delegation{Tools: []string{"search", name}}); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("SetDelegation with %q: err = %v, want it named", name, err)
+		}
+	}
+	if s.delegation != nil {
+		t.Error("a rejected delegation must not enable the delegate tool")
+	}
+}
+
+// listCountingProvider counts ListModels calls.
+type listCountingProvider struct {
+	*mockProvider
+	lists int
+}
+
+func (p *listCountingProvider) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
+	p.lists++
+	return p.mockProvider.ListModels(ctx)
+}
+
+func TestDelegateReusesModelInfo(t *testing.T) {
+	prov := &listCountingProvider{mockProvider: &mockProvider{
+		calls: [][]provider.StreamChunk{
+			toolUseChunks("call_1", DelegateToolName, `{"task":"One"}`),
+			textChunks("First."),
+			toolUseChunks("call_2", DelegateToolName, `{"task":"Two"}`),
+			textChunks("Second."),
+			textChunks("Done."),
+		},
+		models: []provider.ModelInfo{
+			modelInfo("test-model", "Test", 3, 15),
+			modelInfo("small-model", "Small", 1, 5),
+		},
+	}}
+	exec := &mockExecutor{}
+	s := NewSession("parent", prov, NewTracker(nil, nil), &mockNotifier{}, "test-model", "system", 1024, exec, delegateTestTools, nil, nil)
+	if err := s.SetDelegation(Delegation{Model: "small-model"}); err != nil {
+		t.Fatalf("SetDelegation: %v", err)
+	}
+
+	if err := s.processUserMessage(t.Context(), "Do both"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+	if prov.lists != 2 {
+		t.Errorf("ListModels called %d times, want once for each model", prov.lists)
+	}
+	if got := s.tracker.Snapshot().Models; len(got) != 2 {
+		t.Errorf("tracked models = %+v, want the sub-agents priced at their own model", got)
+	}
+}
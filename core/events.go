@@ -9,6 +9,10 @@ import "time"
 // TokenEvent carries a single token delta from LLM streaming.
 type TokenEvent struct{ Text string }
 
+// ThinkingEvent carries a reasoning delta streamed before or between
+// the visible response text.
+type ThinkingEvent struct{ Text string }
+
 // CompletionEvent signals the assistant message is complete.
 type CompletionEvent struct{}
 
@@ -85,11 +89,11 @@ type PermissionRequestEvent struct {
 	ToolCallID   string
 	ToolName     string
 	AgentName    string
-	Permission   string        // e.g. "fs:write:./src/**"
-	Description  string        // User-friendly description
-	Timeout      time.Duration // 0 = no timeout
-	DefaultAllow bool          // If timeout expires, grant or deny?
-	ResponseChan chan<- PermissionResponse
+	Permission   string                    // e.g. "fs:write:./src/**"
+	Description  string                    // User-friendly description
+	Timeout      time.Duration             // 0 = no timeout
+	DefaultAllow bool                      // If timeout expires, grant or deny?
+	ResponseChan chan<- PermissionResponse `json:"-"`
 }
 
 // PermissionResponse is the user's decision sent back via channel.
@@ -105,9 +109,55 @@ type PermissionTimeoutEvent struct {
 	Allowed    bool // Whether the default was to allow (from DefaultAllow)
 }
 
+// ProviderRetryEvent reports that a failed provider request is about to be
+// retried after Delay (throttling, overload, or a transient network error).
+type ProviderRetryEvent struct {
+	Attempt    int
+	MaxRetries int
+	Delay      time.Duration
+	Reason     string
+}
+
+// ResponseTruncatedEvent reports that a model response hit the MaxTokens
+// output limit. The reply is continued where it stopped, or, when the cut
+// fell inside a tool call or before any text, discarded and retried with
+// RetryMaxTokens.
+type ResponseTruncatedEvent struct {
+	MaxTokens      int
+	RetryMaxTokens int // 0 when the reply is continued
+}
+
+// PricingMissingEvent reports that ModelID has no known price, so its cost
+// is counted as zero. Sent once per model per session.
+type PricingMissingEvent struct{ ModelID string }
+
+// BudgetWarningEvent reports that spend in a budget scope crossed one of its
+// warning thresholds. Sent once per threshold.
+type BudgetWarningEvent struct {
+	Scope     BudgetScope
+	Spent     float64 // USD
+	Limit     float64 // USD
+	Threshold float64 // fraction of Limit
+}
+
+// BudgetExceededEvent reports that a budget is used up. The turn is halted
+// before its next provider request until the limit is raised with /budget.
+type BudgetExceededEvent struct {
+	Scope BudgetScope
+	Spent float64 // USD
+	Limit float64 // USD
+}
+
+// BudgetStatusEvent reports spend against the budget limits after /budget.
+type BudgetStatusEvent struct{ Summary string }
+
 // ModelChangedEvent signals that the active model has been changed via /model.
 type ModelChangedEvent struct{ ModelID string }
 
+// TurnOptionsEvent reports the session's tool choice and sampling settings
+// after /tool-choice, /temperature, /top-p or /stop.
+type TurnOptionsEvent struct{ Summary string }
+
 // HistoryClearedEvent signals that the conversation history was reset via /clear.
 type HistoryClearedEvent struct{}
 
@@ -119,11 +169,50 @@ type ContextInfoEvent struct {
 	ModelID    string
 }
 
+// PlanModeEvent signals plan mode was entered or left via /plan, /execute
+// or /clear. Executing is set when the plan is being carried out.
+type PlanModeEvent struct {
+	Active    bool
+	Executing bool
+}
+
+// BranchSwitchedEvent signals the active conversation branch changed via
+// /edit or /retry, which create a branch, or /branch <id>. Replay holds the
+// prompts and replies of the branch switched to after the point where it
+// diverges. FilesChanged is set when files changed on the branch left behind can be
+// restored with /branch restore.
+type BranchSwitchedEvent struct {
+	ID           int
+	Parent       int
+	Label        string
+	Created      bool
+	Replay       []BranchMessage
+	FilesChanged bool
+}
+
+// BranchMessage is a prompt or reply shown when switching branches.
+type BranchMessage struct {
+	User bool
+	Text string
+}
+
+// BranchListEvent lists the conversation branches in reply to /branch.
+type BranchListEvent struct{ Summary string }
+
+// FilesRestoredEvent signals /branch restore restored the files changed on
+// the branch last left.
+type FilesRestoredEvent struct{ Paths []string }
+
+// TurnCancelledEvent signals the turn in progress was stopped by
+// Session.CancelTurn. A CompletionEvent follows.
+type TurnCancelledEvent struct{}
+
 // SessionRestoredEvent signals a session was successfully restored from disk via /restore.
 type SessionRestoredEvent struct {
 	SessionID    string // ID of the restored session (for changelog replay)
 	Description  string
 	MessageCount int
+	Cost         string // the session's cost at the rate it was saved with
 }
 
 // FileChangeEvent signals files were modified by a tool execution.
@@ -0,0 +1,320 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"cosmos/core/provider"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalOp names a change recorded in a session journal.
+type JournalOp string
+
+const (
+	JournalStart   JournalOp = "start"   // a process took over the session
+	JournalAppend  JournalOp = "append"  // messages were appended to the history
+	JournalReplace JournalOp = "replace" // the history was replaced, e.g. by compaction
+	JournalModel   JournalOp = "model"   // the model was changed
+)
+
+// JournalEntry is one line of a session journal.
+type JournalEntry struct {
+	Op       JournalOp          `json:"op"`
+	Time     time.Time          `json:"time"`
+	Messages []provider.Message `json:"messages,omitempty"` // append, replace
+
+	SessionID string `json:"sessionId,omitempty"` // start
+	WorkDir   string `json:"workDir,omitempty"`   // start
+	PID       int    `json:"pid,omitempty"`       // start
+	Model     string `json:"model,omitempty"`     // start, model
+}
+
+// Journal records every change to a session's history as it happens, in a
+// JSON-lines file per session, so a session survives a crash or kill. The
+// file is removed once the session is saved on a clean exit; a journal
+// left behind marks an unfinished session that can be resumed.
+type Journal struct {
+	mu     sync.Mutex
+	file   *os.File
+	path   string
+	failed bool // a write failed; reported once
+}
+
+// OpenJournal opens the journal of a session in dir for appending,
+// continuing the existing one when a session is resumed.
+func OpenJournal(dir, sessionID string) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create journal directory: %w", err)
+	}
+	path := filepath.Join(dir, sessionID+".jsonl")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open session journal: %w", err)
+	}
+	return &Journal{file: file, path: path}, nil
+}
+
+// Start records that this process runs the session from now on.
+func (j *Journal) Start(sessionID, model, workDir string) error {
+	return j.Append(JournalEntry{
+		Op:        JournalStart,
+		SessionID: sessionID,
+		WorkDir:   workDir,
+		PID:       os.Getpid(),
+		Model:     model,
+	})
+}
+
+// Append writes an entry and syncs it to disk, so it survives a crash of
+// the machine as well as of the process.
+func (j *Journal) Append(entry JournalEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal journal entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return fmt.Errorf("session journal closed")
+	}
+	if _, err := j.file.Write(data); err != nil {
+		return fmt.Errorf("write journal entry: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("sync session journal: %w", err)
+	}
+	return nil
+}
+
+// record appends an entry and reports the first failure on stderr. The
+// session goes on without crash safety when its journal cannot be written.
+func (j *Journal) record(entry JournalEntry) {
+	err := j.Append(entry)
+	if err == nil {
+		return
+	}
+	j.mu.Lock()
+	report := !j.failed
+	j.failed = true
+	j.mu.Unlock()
+	if report {
+		fmt.Fprintf(os.Stderr, "cosmos: warning: session journal: %v\n", err)
+	}
+}
+
+// Close closes the journal file, leaving it to be resumed.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+// Remove closes and deletes the journal once the session was saved.
+func (j *Journal) Remove() error {
+	if err := j.Close(); err != nil {
+		return err
+	}
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove session journal: %w", err)
+	}
+	return nil
+}
+
+// RecoveredSession is an unfinished session replayed from its journal.
+type RecoveredSession struct {
+	Path      string // the journal file
+	SessionID string
+	Model     string
+	WorkDir   string
+	PID       int // of the process that last ran the session
+	StartedAt time.Time
+	UpdatedAt time.Time
+	History   []provider.Message
+}
+
+// Description returns the session's first prompt, shortened for display.
+func (r RecoveredSession) Description() string {
+	return describeHistory(r.History)
+}
+
+// ReadJournal replays the journal at path. A last line cut short by a
+// crash is skipped.
+func ReadJournal(path string) (RecoveredSession, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return RecoveredSession{}, fmt.Errorf("read session journal: %w", err)
+	}
+	defer f.Close()
+
+	r := RecoveredSession{Path: path}
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var entry JournalEntry
+			if json.Unmarshal(line, &entry) == nil {
+				r.apply(entry)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return RecoveredSession{}, fmt.Errorf("read session journal: %w", err)
+		}
+	}
+	if r.SessionID == "" {
+		r.SessionID = strings.TrimSuffix(filepath.Base(path), ".jsonl")
+	}
+	return r, nil
+}
+
+func (r *RecoveredSession) apply(e JournalEntry) {
+	if r.StartedAt.IsZero() {
+		r.StartedAt = e.Time
+	}
+	r.UpdatedAt = e.Time
+	switch e.Op {
+	case JournalStart:
+		r.SessionID, r.WorkDir, r.PID = e.SessionID, e.WorkDir, e.PID
+		if e.Model != "" {
+			r.Model = e.Model
+		}
+	case JournalAppend:
+		r.History = append(r.History, e.Messages...)
+	case JournalReplace:
+		r.History = append([]provider.Message{}, e.Messages...)
+	case JournalModel:
+		r.Model = e.Model
+	}
+}
+
+// FindJournals replays the journals in dir, most recently updated first.
+// Journals without history and unreadable ones are skipped. A missing dir
+// has none.
+func FindJournals(dir string) ([]RecoveredSession, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("list session journals: %w", err)
+	}
+	var sessions []RecoveredSession
+	for _, path := range paths {
+		r, err := ReadJournal(path)
+		if err != nil || len(r.History) == 0 {
+			continue
+		}
+		sessions = append(sessions, r)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// SetJournal records every history change in j from now on.
+// Must be called before Start().
+func (s *Session) SetJournal(j *Journal) {
+	s.journal = j
+}
+
+// Resume continues a recovered session: its history and model. A history
+// cut off mid-turn is completed as a cancelled turn would be, and the
+// repair journaled if SetJournal was called first. The SessionRestoredEvent
+// announcing the session is sent once it starts.
+// Must be called before Start().
+func (s *Session) Resume(r RecoveredSession) {
+	s.id = r.SessionID
+	history, repaired := completeInterruptedTurn(slices.Clone(r.History))
+	s.mu.Lock()
+	if repaired {
+		s.replaceHistory(history)
+	} else {
+		s.history = history
+	}
+	s.mu.Unlock()
+	if r.Model != "" {
+		s.model = r.Model
+	}
+	if !r.StartedAt.IsZero() {
+		s.createdAt = r.StartedAt
+	}
+	s.resumed = &SessionRestoredEvent{
+		SessionID:    r.SessionID,
+		Description:  r.Description(),
+		MessageCount: len(r.History),
+	}
+}
+
+// completeInterruptedTurn completes a history whose process was killed
+// mid-turn, so the next request is valid: tool calls left without results
+// are answered as interrupted, and a history ending on the user's side gets
+// a placeholder reply.
+func completeInterruptedTurn(history []provider.Message) ([]provider.Message, bool) {
+	n := len(history)
+	if n == 0 {
+		return history, false
+	}
+	repaired := false
+	if last := history[n-1]; last.Role == provider.RoleAssistant && len(last.ToolCalls) > 0 {
+		results := make([]provider.ToolResult, len(last.ToolCalls))
+		for i, tc := range last.ToolCalls {
+			results[i] = provider.ToolResult{
+				ToolUseID: tc.ID,
+				Content:   fmt.Sprintf("tool %s interrupted: the session ended before it finished", tc.Name),
+				IsError:   true,
+			}
+		}
+		history = append(history, provider.Message{Role: provider.RoleUser, ToolResults: results})
+		repaired = true
+	}
+	if history[len(history)-1].Role == provider.RoleUser {
+		history = append(history, provider.Message{Role: provider.RoleAssistant, Content: "(Interrupted)"})
+		repaired = true
+	}
+	return history, repaired
+}
+
+// appendHistory appends a message to the history and journals it.
+// Caller must hold s.mu.
+func (s *Session) appendHistory(msg provider.Message) {
+	s.history = append(s.history, msg)
+	if s.journal != nil {
+		s.journal.record(JournalEntry{Op: JournalAppend, Messages: []provider.Message{msg}})
+	}
+}
+
+// replaceHistory replaces the history and journals it.
+// Caller must hold s.mu.
+func (s *Session) replaceHistory(history []provider.Message) {
+	s.history = history
+	if s.journal != nil {
+		s.journal.record(JournalEntry{Op: JournalReplace, Messages: history})
+	}
+}
+
+// journalModel records a model change.
+func (s *Session) journalModel(model string) {
+	if s.journal != nil {
+		s.journal.record(JournalEntry{Op: JournalModel, Model: model})
+	}
+}
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"cosmos/core/provider"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournalReplaysHistoryChanges(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{
+		textChunks("First answer"),
+		textChunks("Second answer"),
+	}}
+	session := newTestSession(prov, &mockExecutor{}, &mockNotifier{})
+	dir := t.TempDir()
+	journal, err := OpenJournal(dir, session.ID())
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	defer journal.Close()
+	if err := journal.Start(session.ID(), "test-model", "/work/proj"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	session.SetJournal(journal)
+
+	for _, text := range []string{"Question", "/retry", "/model other-model"} {
+		if err := session.processUserMessage(t.Context(), text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+
+	r, err := ReadJournal(filepath.Join(dir, session.ID()+".jsonl"))
+	if err != nil {
+		t.Fatalf("ReadJournal: %v", err)
+	}
+	if r.SessionID != session.ID() || r.WorkDir != "/work/proj" || r.PID != os.Getpid() {
+		t.Errorf("recovered = %+v", r)
+	}
+	if r.Model != "other-model" {
+		t.Errorf("Model = %q, want other-model", r.Model)
+	}
+	want := session.HistorySnapshot()
+	if len(r.History) != len(want) || r.History[1].Content != "Second answer" {
+		t.Errorf("History = %+v, want %+v", r.History, want)
+	}
+}
+
+func TestReadJournal_SkipsTruncatedLine(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := OpenJournal(dir, "abc")
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	_ = journal.Append(JournalEntry{Op: JournalAppend, Messages: []provider.Message{{Role: provider.RoleUser, Content: "Hello"}}})
+	_ = journal.Close()
+
+	path := filepath.Join(dir, "abc.jsonl")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"op":"append","messages":[{"role":"assis`)
+	_ = f.Close()
+
+	sessions, err := FindJournals(dir)
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("FindJournals = %v, %v", sessions, err)
+	}
+	if r := sessions[0]; r.SessionID != "abc" || len(r.History) != 1 || r.Description() != "Hello" {
+		t.Errorf("recovered = %+v", r)
+	}
+}
+
+func TestResumeAnnouncesTheSession(t *testing.T) {
+	notifier := &mockNotifier{}
+	session := newTestSession(&mockProvider{}, &mockExecutor{}, notifier)
+	session.Resume(RecoveredSession{
+		SessionID: "old-id",
+		Model:     "other-model",
+		History: []provider.Message{
+			{Role: provider.RoleUser, Content: "Question"},
+			{Role: provider.RoleAssistant, Content: "Answer"},
+		},
+	})
+	if session.ID() != "old-id" || session.model != "other-model" || len(session.HistorySnapshot()) != 2 {
+		t.Fatalf("resumed session: id %q, model %q, %d messages", session.ID(), session.model, len(session.HistorySnapshot()))
+	}
+
+	session.Start(t.Context())
+	session.Stop()
+	msgs := notifier.getMessages()
+	if len(msgs) == 0 {
+		t.Fatal("no events sent")
+	}
+	if e, ok := msgs[0].(SessionRestoredEvent); !ok || e.SessionID != "old-id" || e.MessageCount != 2 {
+		t.Errorf("first event = %+v, want the SessionRestoredEvent", msgs[0])
+	}
+}
+
+func TestResumeCompletesTurnKilledDuringTools(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := OpenJournal(dir, "old-id")
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	defer journal.Close()
+	prov := &mockProvider{calls: [][]provider.StreamChunk{textChunks("Retried.")}}
+	session := newTestSession(prov, &mockExecutor{}, &mockNotifier{})
+	session.SetJournal(journal)
+	session.Resume(RecoveredSession{
+		SessionID: "old-id",
+		History: []provider.Message{
+			{Role: provider.RoleUser, Content: "Write a.go"},
+			{Role: provider.RoleAssistant, ToolCalls: []provider.ToolCall{{ID: "call_w", Name: "write"}}},
+		},
+	})
+
+	h := session.HistorySnapshot()
+	if len(h) != 4 {
+		t.Fatalf("history = %+v, want the tool call answered and a reply", h)
+	}
+	if r := h[2].ToolResults; len(r) != 1 || r[0].ToolUseID != "call_w" || !r[0].IsError || !strings.Contains(r[0].Content, "interrupted") {
+		t.Errorf("tool results = %+v", r)
+	}
+	if h[3].Role != provider.RoleAssistant || h[3].Content != "(Interrupted)" {
+		t.Errorf("last message = %+v", h[3])
+	}
+
+	r, err := ReadJournal(filepath.Join(dir, "old-id.jsonl"))
+	if err != nil || len(r.History) != 4 {
+		t.Errorf("journaled history = %+v, %v; want the repair kept", r.History, err)
+	}
+
+	if err := session.processUserMessage(t.Context(), "Try again"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+	if msgs := prov.requests[0].Messages; len(msgs) != 5 || msgs[3].Role != provider.RoleAssistant || msgs[4].Role != provider.RoleUser {
+		t.Errorf("first request after resume = %+v", msgs)
+	}
+}
+
+func TestResumeCompletesTurnKilledDuringStream(t *testing.T) {
+	session := newTestSession(&mockProvider{}, &mockExecutor{}, &mockNotifier{})
+	session.Resume(RecoveredSession{
+		SessionID: "old-id",
+		History: []provider.Message{
+			{Role: provider.RoleUser, Content: "Question"},
+			{Role: provider.RoleAssistant, Content: "Answer"},
+			{Role: provider.RoleUser, Content: "Follow-up"},
+		},
+	})
+	h := session.HistorySnapshot()
+	if len(h) != 4 || h[3].Role != provider.RoleAssistant || h[3].Content != "(Interrupted)" {
+		t.Errorf("history = %+v, want a placeholder reply", h)
+	}
+
+	// A complete history is left as it is.
+	complete := newTestSession(&mockProvider{}, &mockExecutor{}, &mockNotifier{})
+	complete.Resume(RecoveredSession{SessionID: "old-id", History: h[:2]})
+	if got := complete.HistorySnapshot(); len(got) != 2 {
+		t.Errorf("complete history = %+v", got)
+	}
+}
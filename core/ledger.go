@@ -0,0 +1,197 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LedgerEntry is one priced model request in the usage ledger.
+type LedgerEntry struct {
+	Time             time.Time `json:"time"`
+	SessionID        string    `json:"sessionId"`
+	ProjectDir       string    `json:"projectDir"`
+	Model            string    `json:"model"`
+	Source           Source    `json:"source"`
+	InputTokens      int       `json:"inputTokens"`
+	OutputTokens     int       `json:"outputTokens"`
+	CacheReadTokens  int       `json:"cacheReadTokens,omitempty"`
+	CacheWriteTokens int       `json:"cacheWriteTokens,omitempty"`
+	CostUSD          float64   `json:"costUSD"`
+}
+
+// Ledger appends every request the Tracker records to a JSON-lines file
+// shared by all sessions, so spend outlives the process.
+type Ledger struct {
+	mu         sync.Mutex
+	file       *os.File
+	sessionID  string
+	projectDir string
+	failed     bool // a write failed; reported once
+}
+
+// OpenLedger opens the ledger at path for appending entries of one session.
+func OpenLedger(path, sessionID, projectDir string) (*Ledger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create ledger directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open usage ledger: %w", err)
+	}
+	return &Ledger{file: file, sessionID: sessionID, projectDir: projectDir}, nil
+}
+
+// Append writes an entry, filling in the session and project. Each entry is
+// a single write to an O_APPEND file, so concurrent sessions do not
+// interleave lines.
+func (l *Ledger) Append(entry LedgerEntry) error {
+	entry.SessionID = l.sessionID
+	entry.ProjectDir = l.projectDir
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal ledger entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return fmt.Errorf("usage ledger closed")
+	}
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("write ledger entry: %w", err)
+	}
+	return nil
+}
+
+// record appends an entry and reports the first failure on stderr. Usage
+// is still tracked in memory when the ledger cannot be written.
+func (l *Ledger) record(entry LedgerEntry) {
+	err := l.Append(entry)
+	if err == nil {
+		return
+	}
+	l.mu.Lock()
+	report := !l.failed
+	l.failed = true
+	l.mu.Unlock()
+	if report {
+		fmt.Fprintf(os.Stderr, "cosmos: warning: usage ledger: %v\n", err)
+	}
+}
+
+// Close closes the ledger file.
+func (l *Ledger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// ReadLedger reads all entries of the ledger at path. A missing ledger is
+// empty. Lines that do not parse, such as one cut short by a crash, are
+// skipped.
+func ReadLedger(path string) ([]LedgerEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read usage ledger: %w", err)
+	}
+	defer f.Close()
+
+	var entries []LedgerEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry LedgerEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read usage ledger: %w", err)
+	}
+	return entries, nil
+}
+
+// UsageGroup is a dimension usage reports are grouped by.
+type UsageGroup string
+
+const (
+	UsageByDay     UsageGroup = "day"
+	UsageByProject UsageGroup = "project"
+	UsageByModel   UsageGroup = "model"
+	UsageBySource  UsageGroup = "source"
+)
+
+// UsageGroups lists the report dimensions in display order.
+var UsageGroups = []UsageGroup{UsageByDay, UsageByProject, UsageByModel, UsageBySource}
+
+// UsageSummary is spend for one key of a usage report, e.g. one day.
+type UsageSummary struct {
+	Key              string  `json:"key"`
+	Requests         int     `json:"requests"`
+	InputTokens      int     `json:"inputTokens"`
+	OutputTokens     int     `json:"outputTokens"`
+	CacheReadTokens  int     `json:"cacheReadTokens"`
+	CacheWriteTokens int     `json:"cacheWriteTokens"`
+	CostUSD          float64 `json:"costUSD"`
+}
+
+// SummarizeUsage groups entries by the given dimension. Days are listed
+// newest first in local time, other groups by descending cost.
+func SummarizeUsage(entries []LedgerEntry, by UsageGroup) []UsageSummary {
+	byKey := make(map[string]*UsageSummary)
+	for _, e := range entries {
+		var key string
+		switch by {
+		case UsageByDay:
+			key = dayOf(e.Time.Local())
+		case UsageByProject:
+			key = e.ProjectDir
+		case UsageByModel:
+			key = e.Model
+		case UsageBySource:
+			key = string(e.Source)
+		}
+		sum, ok := byKey[key]
+		if !ok {
+			sum = &UsageSummary{Key: key}
+			byKey[key] = sum
+		}
+		sum.Requests++
+		sum.InputTokens += e.InputTokens
+		sum.OutputTokens += e.OutputTokens
+		sum.CacheReadTokens += e.CacheReadTokens
+		sum.CacheWriteTokens += e.CacheWriteTokens
+		sum.CostUSD += e.CostUSD
+	}
+
+	summaries := make([]UsageSummary, 0, len(byKey))
+	for _, sum := range byKey {
+		summaries = append(summaries, *sum)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if by == UsageByDay {
+			return a.Key > b.Key
+		}
+		if a.CostUSD != b.CostUSD {
+			return a.CostUSD > b.CostUSD
+		}
+		return a.Key < b.Key
+	})
+	return summaries
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"cosmos/core/provider"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLedgerRecordsTrackerUsage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cosmos", "usage.jsonl")
+	ledger, err := OpenLedger(path, "sess-1", "/repo")
+	if err != nil {
+		t.Fatalf("OpenLedger: %v", err)
+	}
+	tracker := NewTracker(nil, nil)
+	tracker.SetLedger(ledger)
+
+	model := modelInfo("opus-4", "Claude Opus 4", 15.0, 75.0)
+	tracker.Record(model, provider.Usage{InputTokens: 1000, OutputTokens: 100, CacheReadTokens: 50}, SourcePrompt)
+	tracker.Record(model, provider.Usage{InputTokens: 10, OutputTokens: 10}, Source("code-analyzer.analyzeFile"))
+	if err := ledger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	entries, err := ReadLedger(path)
+	if err != nil {
+		t.Fatalf("ReadLedger: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries = %d, want 2", len(entries))
+	}
+	e := entries[0]
+	if e.SessionID != "sess-1" || e.ProjectDir != "/repo" || e.Model != "opus-4" || e.Source != SourcePrompt ||
+		e.InputTokens != 1000 || e.CacheReadTokens != 50 || e.Time.IsZero() {
+		t.Errorf("entry = %+v", e)
+	}
+	if diff := e.CostUSD + entries[1].CostUSD - tracker.Snapshot().TotalCost; diff > 1e-12 || diff < -1e-12 {
+		t.Errorf("ledger cost %f + %f != tracked %f", e.CostUSD, entries[1].CostUSD, tracker.Snapshot().TotalCost)
+	}
+}
+
+func TestReadLedgerSkipsTruncatedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	data := `{"model":"a","costUSD":1}
+{"model":"b","cos
+{"model":"c","costUSD":2}
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ReadLedger(path)
+	if err != nil || len(entries) != 2 || entries[1].Model != "c" {
+		t.Errorf("ReadLedger = %+v, %v", entries, err)
+	}
+
+	if entries, err := ReadLedger(filepath.Join(t.TempDir(), "missing.jsonl")); err != nil || entries != nil {
+		t.Errorf("missing ledger = %+v, %v; want empty", entries, err)
+	}
+}
+
+func TestSummarizeUsage(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 6, d, 12, 0, 0, 0, time.Local) }
+	entries := []LedgerEntry{
+		{Time: day(1), ProjectDir: "/a", Model: "m1", Source: SourcePrompt, InputTokens: 10, CostUSD: 1},
+		{Time: day(2), ProjectDir: "/b", Model: "m2", Source: SourcePrompt, InputTokens: 20, CostUSD: 5},
+		{Time: day(2), ProjectDir: "/a", Model: "m1", Source: "agent.tool", InputTokens: 30, CostUSD: 2},
+	}
+
+	days := SummarizeUsage(entries, UsageByDay)
+	if len(days) != 2 || days[0].Key != "2025-06-02" || days[0].Requests != 2 || days[0].CostUSD != 7 {
+		t.Errorf("by day = %+v", days)
+	}
+	projects := SummarizeUsage(entries, UsageByProject)
+	if len(projects) != 2 || projects[0].Key != "/b" || projects[1].InputTokens != 40 {
+		t.Errorf("by project = %+v, want /b first (highest cost)", projects)
+	}
+	sources := SummarizeUsage(entries, UsageBySource)
+	if len(sources) != 2 || sources[0].Key != "prompt" || sources[0].CostUSD != 6 {
+		t.Errorf("by source = %+v", sources)
+	}
+}
@@ -6,11 +6,13 @@ import (
 	"cosmos/engine/manifest"
 	"cosmos/engine/policy"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"runtime/debug"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -76,6 +78,13 @@ type ToolManifestProvider interface {
 	ToolPermissionRules(name string) (agentName string, rules []manifest.PermissionRule, ok bool)
 }
 
+// ContentToolExecutor is an optional interface for executors whose tools can
+// return images or documents alongside their text result. When implemented,
+// the core loop calls ExecuteContent instead of Execute.
+type ContentToolExecutor interface {
+	ExecuteContent(ctx context.Context, name string, input map[string]any) (string, []provider.ContentBlock, error)
+}
+
 // toolBatch groups consecutive tool calls by their read/write characteristics.
 // Read-only batches can be executed concurrently; write batches must be sequential.
 type toolBatch struct {
@@ -118,19 +127,79 @@ type Session struct {
 
 	model     string
 	systemMsg string
-	maxTokens int
+	maxTokens int // 0 = derive from the model's MaxOutputTokens
 
 	id                string              // UUID v4, generated at creation
 	auditLogger       *policy.AuditLogger // nil if audit disabled
 	evaluator         *policy.Evaluator   // nil if policy checks disabled; internally thread-safe
 	permissionTimeout time.Duration       // 0 = use defaultPermissionTimeout; configurable for tests
+	resolvePermission PermissionResolver  // nil = prompt the user; set via SetPermissionResolver
 
 	createdAt   time.Time // set at creation, immutable
 	sessionsDir string    // for /restore completions; set via SetSessionsDir
 
+	// cacheTurns is the number of trailing user turns marked as prompt-cache
+	// breakpoints; 0 disables prompt caching. Set via SetPromptCaching.
+	cacheTurns int
+
+	// thinkingBudget is the extended-thinking token budget sent with every
+	// request; 0 disables thinking. Set via SetThinkingBudget.
+	thinkingBudget int
+
+	// turnDefaults holds the tool choice and sampling settings applied to
+	// every turn unless overridden per turn. Changed by slash commands;
+	// accessed only from the loop goroutine.
+	turnDefaults TurnOptions
+
+	// haltedTurn is the turn stopped by a used-up budget, resumed when the
+	// limit is raised with /budget. Accessed only from the loop goroutine.
+	haltedTurn *haltedTurn
+
+	// planMode advertises only read-only tools and asks the model for a
+	// plan; pinnedPlan is the plan accepted with /execute, kept through
+	// compaction. Accessed only from the loop goroutine.
+	planMode   bool
+	pinnedPlan string
+
+	// delegation enables the delegate tool; nil when it is off. Set via
+	// SetDelegation.
+	delegation   *Delegation
+	delegateInfo *provider.ModelInfo // sub-agent model info; guarded by mu
+
+	// Set on sub-agents spawned by the delegate tool: the Tracker source
+	// of their requests, the parent interaction their tool calls belong
+	// to, and their request budget (0 = unbounded).
+	source        Source
+	interactionID string
+	maxRequests   int
+
+	// cancelTurn cancels the context of the turn in progress; nil between
+	// turns. Guarded by mu.
+	cancelTurn context.CancelFunc
+
+	// branches are the lines of the conversation, nil until it first forks;
+	// history is the live copy of branches[activeBranch], whose file-changing
+	// tool steps are fileSteps. Guarded by mu.
+	branches     []Branch
+	activeBranch int
+	fileSteps    []FileStep
+
+	// restoreFiles undoes file changes when a branch is left; nil when
+	// snapshots are off. Set via SetFileRestorer. pendingRestore lists the
+	// interactions /branch restore undoes; accessed only from the loop
+	// goroutine.
+	restoreFiles   FileRestorer
+	pendingRestore []string
+
+	// journal records history changes as they happen; nil when off. Set
+	// via SetJournal. resumed announces a session continued with Resume
+	// once the loop starts.
+	journal *Journal
+	resumed *SessionRestoredEvent
+
 	mu sync.Mutex
 	history      []provider.Message
-	userMsgChan  chan string
+	userMsgChan  chan userMessage
 	stopChan     chan struct{}
 	stopOnce     sync.Once
 	wg           sync.WaitGroup // Tracks in-flight operations (loop, message processing)
@@ -141,6 +210,10 @@ type Session struct {
 
 	warned50 bool // Track if 50% context warning already sent (reset after compaction)
 
+	unpricedWarned map[string]bool // model IDs already reported as having no price
+
+	cannotCount map[string]bool // model IDs the provider cannot count tokens for; guarded by mu
+
 	// recentPrompts tracks the last time each permission key was prompted.
 	// Used for rate-limiting permission prompts (5s window).
 	// Accessed only from the single-threaded loop goroutine — no mutex needed.
@@ -164,6 +237,18 @@ func (s *Session) SetPermissionTimeout(d time.Duration) {
 	s.permissionTimeout = d
 }
 
+// PermissionResolver decides a permission a tool would otherwise prompt the
+// user for, in sessions run without a UI. granted reports whether the policy
+// already allows it, through a remembered grant or an override.
+type PermissionResolver func(agentName, permission string, granted bool) bool
+
+// SetPermissionResolver decides permission prompts with r instead of sending
+// PermissionRequestEvent. Its decisions are not remembered in the policy.
+// Must be called before Start().
+func (s *Session) SetPermissionResolver(r PermissionResolver) {
+	s.resolvePermission = r
+}
+
 // SetSessionsDir sets the directory used for /restore tab completions.
 // Must be called before Start().
 func (s *Session) SetSessionsDir(dir string) {
@@ -177,6 +262,22 @@ func (s *Session) SetFileChangesFunc(f func(toolCallID string) []FileChange) {
 	s.getFileChanges = f
 }
 
+// SetPromptCaching enables prompt-cache breakpoints on the system prompt,
+// tool definitions and the last recentTurns user turns. Every iteration of
+// the tool loop resends the whole history, so caching the stable prefix
+// avoids paying full input price for it each time. Zero disables caching.
+// Providers accept at most four breakpoints per request, so recentTurns is
+// capped at maxCacheTurns. Must be called before Start().
+func (s *Session) SetPromptCaching(recentTurns int) {
+	s.cacheTurns = min(recentTurns, maxCacheTurns)
+}
+
+// SetThinkingBudget enables extended thinking with the given token budget
+// (0 disables it). Must be called before Start().
+func (s *Session) SetThinkingBudget(tokens int) {
+	s.thinkingBudget = tokens
+}
+
 // HistorySnapshot returns a thread-safe copy of the current conversation history.
 func (s *Session) HistorySnapshot() []provider.Message {
 	s.mu.Lock()
@@ -185,7 +286,8 @@ func (s *Session) HistorySnapshot() []provider.Message {
 }
 
 // Completions returns tab completion strings for the given input prefix.
-// Supports /model <id> and /restore <filename> completions.
+// Supports /model <id>, /tool-choice <choice> and /restore <filename>
+// completions.
 func (s *Session) Completions(prefix string) []string {
 	switch {
 	case strings.HasPrefix(prefix, "/model "):
@@ -201,6 +303,20 @@ func (s *Session) Completions(prefix string) []string {
 		}
 		return completions
 
+	case strings.HasPrefix(prefix, "/tool-choice "):
+		partial := strings.TrimPrefix(prefix, "/tool-choice ")
+		choices := []string{string(provider.ToolChoiceAuto), string(provider.ToolChoiceAny), string(provider.ToolChoiceNone)}
+		for _, t := range s.tools {
+			choices = append(choices, t.Name)
+		}
+		var completions []string
+		for _, c := range choices {
+			if strings.HasPrefix(c, partial) {
+				completions = append(completions, "/tool-choice "+c)
+			}
+		}
+		return completions
+
 	case strings.HasPrefix(prefix, "/restore "):
 		partial := strings.TrimPrefix(prefix, "/restore ")
 		if s.sessionsDir == "" {
@@ -249,16 +365,23 @@ func NewSession(
 		evaluator:     evaluator,
 		createdAt:     time.Now().UTC(),
 		history:       []provider.Message{},
-		userMsgChan:   make(chan string, 16), // Buffered for responsiveness
+		userMsgChan:   make(chan userMessage, 16), // Buffered for responsiveness
 		stopChan:      make(chan struct{}),
 		recentPrompts: make(map[string]time.Time),
+		source:        SourcePrompt,
 	}
 }
 
 // SubmitMessage queues a user message for processing
 func (s *Session) SubmitMessage(text string) {
+	s.SubmitMessageWithOptions(text, TurnOptions{})
+}
+
+// SubmitMessageWithOptions queues a user message whose turn uses opts in
+// place of the session's tool choice and sampling defaults.
+func (s *Session) SubmitMessageWithOptions(text string, opts TurnOptions) {
 	select {
-	case s.userMsgChan <- text:
+	case s.userMsgChan <- userMessage{text: text, opts: opts}:
 	case <-s.stopChan:
 		// Session stopped, drop message
 	}
@@ -302,6 +425,10 @@ func (s *Session) ID() string {
 // loop is the main goroutine that processes user messages
 func (s *Session) loop(ctx context.Context) {
 	defer s.wg.Done()
+	if s.resumed != nil {
+		s.notifier.Send(*s.resumed)
+		s.resumed = nil
+	}
 	for {
 		select {
 		case <-ctx.Done():
@@ -310,9 +437,19 @@ func (s *Session) loop(ctx context.Context) {
 		case <-s.stopChan:
 			s.drainPendingMessages()
 			return
-		case userText := <-s.userMsgChan:
+		case msg := <-s.userMsgChan:
 			s.wg.Add(1)
-			if err := s.processUserMessage(ctx, userText); err != nil {
+			// Each turn gets its own context so CancelTurn stops only it.
+			turnCtx, cancel := context.WithCancel(ctx)
+			s.mu.Lock()
+			s.cancelTurn = cancel
+			s.mu.Unlock()
+			err := s.processTurn(turnCtx, msg.text, msg.opts)
+			s.mu.Lock()
+			s.cancelTurn = nil
+			s.mu.Unlock()
+			cancel()
+			if err != nil {
 				// Send error to UI
 				s.notifier.Send(ErrorEvent{Error: err.Error()})
 			}
@@ -321,13 +458,32 @@ func (s *Session) loop(ctx context.Context) {
 	}
 }
 
+// Process runs a prompt or slash command to completion on the calling
+// goroutine, for sessions driven without Start, such as `cosmos run`. A
+// session must not be driven both ways.
+func (s *Session) Process(ctx context.Context, text string) error {
+	return s.processTurn(ctx, text, TurnOptions{})
+}
+
+// CancelTurn cancels the turn in progress, stopping the provider stream and
+// any running tools. The turn is recorded as far as it got. It does nothing
+// between turns.
+func (s *Session) CancelTurn() {
+	s.mu.Lock()
+	cancel := s.cancelTurn
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
 // drainPendingMessages reads and logs any messages left in userMsgChan
 // during shutdown so they are not silently lost.
 func (s *Session) drainPendingMessages() {
 	for {
 		select {
-		case userText := <-s.userMsgChan:
-			log.Printf("session: dropping pending message during shutdown: %q", userText)
+		case msg := <-s.userMsgChan:
+			log.Printf("session: dropping pending message during shutdown: %q", msg.text)
 		default:
 			return
 		}
@@ -345,6 +501,11 @@ type pendingToolCall struct {
 // It continues looping as long as the model requests tool use, and exits
 // when the model produces a final text response (end_turn).
 func (s *Session) processUserMessage(ctx context.Context, text string) error {
+	return s.processTurn(ctx, text, TurnOptions{})
+}
+
+// processTurn is processUserMessage with per-turn options.
+func (s *Session) processTurn(ctx context.Context, text string, opts TurnOptions) error {
 	// Dispatch known slash commands before adding to history.
 	// Unrecognized /-prefixed text is sent as a normal user message
 	// (users may paste paths or code starting with /).
@@ -352,17 +513,108 @@ func (s *Session) processUserMessage(ctx context.Context, text string) error {
 		return err
 	}
 
+	opts, thinkingBudget, err := s.turnSettings(opts)
+	if err != nil {
+		return err
+	}
+
+	// A used-up budget rejects the prompt before it enters history, so it
+	// can be resent once the limit is raised.
+	if s.checkBudget() {
+		return nil
+	}
+
+	// Read @path attachments before touching history, so a missing or
+	// denied file leaves the conversation unchanged and the user can resend.
+	blocks, err := s.resolveAttachments(ctx, text)
+	if err != nil {
+		return err
+	}
+
 	// Append user message to history
 	s.mu.Lock()
-	s.history = append(s.history, provider.Message{
+	s.appendHistory(provider.Message{
 		Role:    provider.RoleUser,
 		Content: text,
+		Blocks:  blocks,
 	})
 	s.mu.Unlock()
+	s.haltedTurn = nil
+
+	return s.runTurn(ctx, opts, thinkingBudget)
+}
+
+// turnSettings applies the session defaults to a turn's options and
+// returns them with the turn's thinking budget.
+func (s *Session) turnSettings(opts TurnOptions) (TurnOptions, int, error) {
+	opts = opts.withDefaults(s.turnDefaults)
+	if err := s.validateToolChoice(opts.ToolChoice); err != nil {
+		return opts, 0, fmt.Errorf("tool choice: %w", err)
+	}
+	// Providers reject extended thinking on requests that force a tool
+	// call, and a tool loop cannot switch thinking on halfway through.
+	thinkingBudget := s.thinkingBudget
+	if opts.ToolChoice.Forces() {
+		thinkingBudget = 0
+	}
+	// Nor do they accept sampling settings alongside extended thinking.
+	if thinkingBudget > 0 {
+		opts.Temperature, opts.TopP = nil, nil
+	}
+	return opts, thinkingBudget, nil
+}
 
+// runTurn sends the history to the model and runs the tools it calls until
+// it replies with text. A turn halted by the budget is resumed by calling
+// runTurn again once the limit is raised.
+func (s *Session) runTurn(ctx context.Context, opts TurnOptions, thinkingBudget int) error {
 	var autoCompactPending bool
+	budget := s.outputBudget(ctx)
+	toolsCalled := false
+
+	// Text and reasoning of a reply cut off at the output limit, sent back
+	// as a prefill so the next request continues it, then stitched with
+	// that request's reply.
+	var carriedText string
+	var carriedReasoning []provider.ReasoningBlock
+	continuations := 0
+	requests := 0
+	// Text and reasoning already streamed to the user by an attempt at the
+	// reply that was discarded and retried.
+	var shownText, shownThinking replayFilter
 
 	for {
+		if ctx.Err() != nil {
+			return s.finishCancelledTurn(carriedText, carriedReasoning)
+		}
+		if s.maxRequests > 0 && requests == s.maxRequests {
+			return errRequestLimit
+		}
+		requests++
+
+		// Stop before spending more once a budget is used up.
+		if s.checkBudget() {
+			s.mu.Lock()
+			if carriedText != "" {
+				// Keep the reply cut off at the output limit.
+				s.appendHistory(provider.Message{
+					Role:      provider.RoleAssistant,
+					Content:   carriedText,
+					Reasoning: carriedReasoning,
+				})
+			}
+			ended := len(s.history) == 0 || s.history[len(s.history)-1].Role == provider.RoleAssistant
+			s.mu.Unlock()
+			if !ended {
+				if toolsCalled && opts.ToolChoice.Forces() {
+					opts.ToolChoice = nil
+				}
+				s.haltedTurn = &haltedTurn{opts: opts, thinkingBudget: thinkingBudget}
+			}
+			s.notifier.Send(CompletionEvent{})
+			return nil
+		}
+
 		// Build request from current history
 		s.mu.Lock()
 		conversationCopy := append([]provider.Message{}, s.history...)
@@ -370,15 +622,41 @@ func (s *Session) processUserMessage(ctx context.Context, text string) error {
 
 		req := provider.Request{
 			Model:     s.model,
-			System:    s.systemMsg,
+			System:    s.turnSystem(),
 			Messages:  conversationCopy,
-			Tools:     s.tools,
-			MaxTokens: s.maxTokens,
+			Tools:     s.turnTools(),
+			MaxTokens: budget.tokens,
+
+			ThinkingBudget: thinkingBudget,
+			ToolChoice:     opts.ToolChoice,
+			Temperature:    opts.Temperature,
+			TopP:           opts.TopP,
+			StopSequences:  opts.StopSequences,
+		}
+		if toolsCalled && opts.ToolChoice.Forces() {
+			// The forced call was made; let the model finish the turn.
+			req.ToolChoice = nil
+		}
+		if s.cacheTurns > 0 {
+			req.CacheSystem = req.System != ""
+			req.CacheTools = len(req.Tools) > 0
+			markCachePoints(req.Messages, s.cacheTurns)
+		}
+		if carriedText != "" {
+			req.Messages = append(req.Messages, provider.Message{
+				Role:    provider.RoleAssistant,
+				Content: carriedText,
+			})
+			// Providers do not accept extended thinking on a prefilled reply.
+			req.ThinkingBudget = 0
 		}
 
 		// Send to provider
 		iter, err := s.provider.Send(ctx, req)
 		if err != nil {
+			if ctx.Err() != nil {
+				return s.finishCancelledTurn(carriedText, carriedReasoning)
+			}
 			return fmt.Errorf("provider send failed: %w", err)
 		}
 
@@ -386,8 +664,10 @@ func (s *Session) processUserMessage(ctx context.Context, text string) error {
 		var fullText strings.Builder
 		var toolCalls []provider.ToolCall
 		var pending *pendingToolCall
+		var reasoning reasoningAccumulator
 		var usage *provider.Usage
 		var stopReason string
+		var servedModel string
 
 		for {
 			chunk, err := iter.Next()
@@ -396,13 +676,31 @@ func (s *Session) processUserMessage(ctx context.Context, text string) error {
 			}
 			if err != nil {
 				_ = iter.Close()
+				if ctx.Err() != nil {
+					s.recordCancelledUsage(ctx, usage, servedModel)
+					return s.finishCancelledTurn(carriedText+fullText.String(), slices.Concat(carriedReasoning, reasoning.blocks))
+				}
 				return fmt.Errorf("stream error: %w", err)
 			}
 
 			switch chunk.Event {
 			case provider.EventTextDelta:
 				fullText.WriteString(chunk.Text)
-				s.notifier.Send(TokenEvent{Text: chunk.Text})
+				if text := shownText.unseen(chunk.Text); text != "" {
+					s.notifier.Send(TokenEvent{Text: text})
+				}
+
+			case provider.EventReasoningDelta:
+				reasoning.addText(chunk.Text)
+				if text := shownThinking.unseen(chunk.Text); text != "" {
+					s.notifier.Send(ThinkingEvent{Text: text})
+				}
+
+			case provider.EventReasoningSignature:
+				reasoning.sign(chunk.Signature)
+
+			case provider.EventReasoningRedacted:
+				reasoning.addRedacted(chunk.Redacted)
 
 			case provider.EventToolStart:
 				pending = &pendingToolCall{
@@ -434,21 +732,25 @@ func (s *Session) processUserMessage(ctx context.Context, text string) error {
 			case provider.EventMessageStop:
 				usage = chunk.Usage
 				stopReason = chunk.StopReason
+				servedModel = chunk.Model
 			}
 		}
 		_ = iter.Close()
+		if ctx.Err() != nil && stopReason == "" {
+			// Cancelled before the reply was complete; its tool calls, if
+			// any, are dropped unrun.
+			s.recordCancelledUsage(ctx, usage, servedModel)
+			return s.finishCancelledTurn(carriedText+fullText.String(), slices.Concat(carriedReasoning, reasoning.blocks))
+		}
 
 		// Record token usage
 		if usage != nil {
-			modelInfo, err := s.getModelInfo(ctx)
-			if err == nil && modelInfo != nil {
-				s.tracker.Record(*modelInfo, *usage, SourcePrompt)
-
+			if modelInfo := s.recordUsage(ctx, *usage, servedModel); modelInfo != nil {
 				// Monitor context usage — use THIS response's tokens
 				// (Bedrock reports full-conversation total per call)
 				pct := 0.0
 				if modelInfo.ContextWindow > 0 {
-					pct = float64(usage.InputTokens+usage.OutputTokens) / float64(modelInfo.ContextWindow) * 100.0
+					pct = float64(usage.TotalInputTokens()+usage.OutputTokens) / float64(modelInfo.ContextWindow) * 100.0
 				}
 
 				// Always update status bar with current percentage
@@ -483,22 +785,65 @@ func (s *Session) processUserMessage(ctx context.Context, text string) error {
 			}
 		}
 
-		// Check if this is a tool-use turn
-		if stopReason == "tool_use" && len(toolCalls) > 0 {
+		text := carriedText + fullText.String()
+		reasoningBlocks := slices.Concat(carriedReasoning, reasoning.blocks)
+
+		// A reply cut off at the output limit is continued where it
+		// stopped. A tool call cut off mid-input cannot be continued, nor
+		// can a reply cut off while the model was still thinking: without
+		// text there is no prefill, and the unsigned reasoning cannot be
+		// sent back. Both are discarded and retried with a larger limit.
+		if stopReason == stopMaxTokens {
+			prefill := continuationPrefill(text)
+			if truncatedToolCall(pending, toolCalls) || (prefill == "" && len(toolCalls) == 0) {
+				limit := budget.tokens
+				if !budget.grow() {
+					if prefill == "" && len(toolCalls) == 0 {
+						return fmt.Errorf("response cut off before any reply at the %d-token output limit", limit)
+					}
+					return fmt.Errorf("response cut off inside a tool call at the %d-token output limit", limit)
+				}
+				shownText.retry()
+				shownThinking.retry()
+				s.notifier.Send(ResponseTruncatedEvent{MaxTokens: limit, RetryMaxTokens: budget.tokens})
+				continue
+			}
+			if len(toolCalls) == 0 && continuations < maxContinuations {
+				continuations++
+				carriedText = prefill
+				carriedReasoning = signedReasoning(reasoningBlocks)
+				shownText, shownThinking = replayFilter{}, replayFilter{}
+				s.notifier.Send(ResponseTruncatedEvent{MaxTokens: budget.tokens})
+				continue
+			}
+		}
+		carriedText, carriedReasoning, continuations = "", nil, 0
+		shownText, shownThinking = replayFilter{}, replayFilter{}
+
+		// Check if this is a tool-use turn. Complete tool calls in a reply
+		// that hit the output limit are run as well.
+		if len(toolCalls) > 0 && (stopReason == "tool_use" || stopReason == stopMaxTokens) {
+			toolsCalled = true
+
 			// Append assistant message with text + tool calls
 			s.mu.Lock()
-			s.history = append(s.history, provider.Message{
+			s.appendHistory(provider.Message{
 				Role:      provider.RoleAssistant,
-				Content:   fullText.String(),
+				Content:   text,
 				ToolCalls: toolCalls,
+				Reasoning: reasoningBlocks,
 			})
+			stepAt := len(s.history) - 1
 			s.mu.Unlock()
 
 			// Phase 1: Preflight all tool calls sequentially (permission checks,
 			// UI notifications, input serialization). This runs on the single-
 			// threaded loop goroutine so recentPrompts and permission prompts
 			// are safe — no concurrent map access, no competing UI prompts.
-			interactionID := uuid.New().String()
+			interactionID := s.interactionID
+			if interactionID == "" {
+				interactionID = uuid.New().String()
+			}
 			allExecutions := make([]toolExecution, len(toolCalls))
 			for i, tc := range toolCalls {
 				allExecutions[i] = s.preflightToolCall(ctx, tc)
@@ -584,12 +929,20 @@ func (s *Session) processUserMessage(ctx context.Context, text string) error {
 
 			// Append tool results as a user message (Bedrock convention)
 			s.mu.Lock()
-			s.history = append(s.history, provider.Message{
+			s.appendHistory(provider.Message{
 				Role:        provider.RoleUser,
 				ToolResults: toolResults,
 			})
+			if s.changedFiles(allExecutions) {
+				s.fileSteps = append(s.fileSteps, FileStep{At: stepAt, InteractionID: interactionID})
+			}
 			s.mu.Unlock()
 
+			// Every tool call has a result, so a cancelled turn can end here.
+			if ctx.Err() != nil {
+				return s.finishCancelledTurn("", nil)
+			}
+
 			// Signal completion of this turn, then loop for next LLM call
 			s.notifier.Send(CompletionEvent{})
 			continue
@@ -597,13 +950,14 @@ func (s *Session) processUserMessage(ctx context.Context, text string) error {
 
 		// Final text response — append and break out of tool loop
 		s.mu.Lock()
-		content := fullText.String()
+		content := text
 		if content == "" {
 			content = "(No response)"
 		}
-		s.history = append(s.history, provider.Message{
-			Role:    provider.RoleAssistant,
-			Content: content,
+		s.appendHistory(provider.Message{
+			Role:      provider.RoleAssistant,
+			Content:   content,
+			Reasoning: reasoningBlocks,
 		})
 		s.mu.Unlock()
 
@@ -620,8 +974,10 @@ func (s *Session) processUserMessage(ctx context.Context, text string) error {
 			modelInfo, err := s.getModelInfo(ctx)
 			if err == nil && modelInfo != nil && modelInfo.ContextWindow > 0 {
 				s.mu.Lock()
-				newPct := float64(s.estimateTokenCount(s.history)) / float64(modelInfo.ContextWindow) * 100.0
+				history := append([]provider.Message{}, s.history...)
 				s.mu.Unlock()
+				tokens := localTokenCount(s.systemMsg, s.tools, history)
+				newPct := float64(tokens) / float64(modelInfo.ContextWindow) * 100.0
 				s.notifier.Send(ContextUpdateEvent{
 					Percentage: newPct,
 					ModelID:    s.model,
@@ -633,16 +989,79 @@ func (s *Session) processUserMessage(ctx context.Context, text string) error {
 	return nil
 }
 
-// stripRegionalPrefix removes a Bedrock regional prefix (e.g. "us.", "eu.", "ap.")
-// from a model ID, returning the base model ID.
-func stripRegionalPrefix(modelID string) string {
-	prefixes := []string{"us.", "eu.", "ap."}
-	for _, p := range prefixes {
-		if after, found := strings.CutPrefix(modelID, p); found {
-			return after
+// finishCancelledTurn ends a turn stopped by CancelTurn. The reply streamed
+// so far is kept, with its signed reasoning only, as providers reject
+// unsigned reasoning. If the turn ended on the user's side, a placeholder
+// reply is added so roles still alternate on the next request.
+func (s *Session) finishCancelledTurn(text string, reasoning []provider.ReasoningBlock) error {
+	signed := signedReasoning(reasoning)
+
+	s.mu.Lock()
+	if n := len(s.history); n > 0 && s.history[n-1].Role == provider.RoleUser {
+		if text == "" {
+			text = "(Cancelled)"
+		}
+		s.appendHistory(provider.Message{
+			Role:      provider.RoleAssistant,
+			Content:   text,
+			Reasoning: signed,
+		})
+	}
+	s.mu.Unlock()
+
+	s.notifier.Send(TurnCancelledEvent{})
+	s.notifier.Send(CompletionEvent{})
+	return nil
+}
+
+// reasoningAccumulator collects streamed reasoning into blocks. Text deltas
+// extend the open block until a signature closes it; redacted blocks arrive
+// whole.
+type reasoningAccumulator struct {
+	blocks []provider.ReasoningBlock
+	open   bool
+}
+
+func (r *reasoningAccumulator) addText(text string) {
+	if !r.open {
+		r.blocks = append(r.blocks, provider.ReasoningBlock{})
+		r.open = true
+	}
+	r.blocks[len(r.blocks)-1].Text += text
+}
+
+func (r *reasoningAccumulator) sign(signature string) {
+	if !r.open {
+		// Signature without text (possible when the provider omits the
+		// thinking summary); keep it so the block round-trips.
+		r.blocks = append(r.blocks, provider.ReasoningBlock{})
+	}
+	r.blocks[len(r.blocks)-1].Signature = signature
+	r.open = false
+}
+
+func (r *reasoningAccumulator) addRedacted(data []byte) {
+	r.blocks = append(r.blocks, provider.ReasoningBlock{Redacted: data})
+	r.open = false
+}
+
+// maxCacheTurns leaves room for the system and tool breakpoints within the
+// four-breakpoint limit shared by Bedrock and the Anthropic API.
+const maxCacheTurns = 2
+
+// markCachePoints sets CachePoint on the last n user messages in msgs.
+// msgs must be a copy of the history: the flags are per-request and must
+// not leak into stored history, where the breakpoints would pile up.
+// Marking more than the newest turn keeps the previous request's cached
+// prefix addressable, so the next tool iteration reads it instead of
+// writing it again.
+func markCachePoints(msgs []provider.Message, n int) {
+	for i := len(msgs) - 1; i >= 0 && n > 0; i-- {
+		if msgs[i].Role == provider.RoleUser {
+			msgs[i].CachePoint = true
+			n--
 		}
 	}
-	return modelID
 }
 
 // getModelInfo retrieves model info for pricing, caching the result after the
@@ -657,14 +1076,7 @@ func (s *Session) getModelInfo(ctx context.Context) (*provider.ModelInfo, error)
 			return
 		}
 
-		baseModel := stripRegionalPrefix(s.model)
-		for _, m := range models {
-			if m.ID == s.model || m.ID == baseModel {
-				info := m
-				s.cachedModelInfo = &info
-				return
-			}
-		}
+		s.cachedModelInfo = findModel(models, s.model)
 	})
 	if fetchErr != nil {
 		// Reset Once so next call retries on transient errors
@@ -674,6 +1086,108 @@ func (s *Session) getModelInfo(ctx context.Context) (*provider.ModelInfo, error)
 	return s.cachedModelInfo, nil
 }
 
+// recordUsage adds a reply's usage to the tracker, priced at the rates of
+// the model that served it. It returns that model's info, or nil if the
+// usage could not be priced.
+func (s *Session) recordUsage(ctx context.Context, usage provider.Usage, servedModel string) *provider.ModelInfo {
+	modelInfo, err := s.getModelInfo(ctx)
+	if servedModel != "" && servedModel != s.model {
+		// A fallback model answered: price the turn at its rates.
+		modelInfo, err = s.lookupModelInfo(ctx, servedModel)
+	}
+	if err != nil {
+		return nil
+	}
+	modelID := s.model
+	if servedModel != "" {
+		modelID = servedModel
+	}
+	s.warnIfUnpriced(modelID, modelInfo)
+	if modelInfo != nil {
+		s.tracker.Record(*modelInfo, usage, s.source)
+	}
+	return modelInfo
+}
+
+// recordCancelledUsage records the usage of a reply cut short by CancelTurn,
+// if it arrived: the tokens were billed all the same.
+func (s *Session) recordCancelledUsage(ctx context.Context, usage *provider.Usage, servedModel string) {
+	if usage != nil {
+		s.recordUsage(context.WithoutCancel(ctx), *usage, servedModel)
+	}
+}
+
+// warnIfUnpriced reports, once per model, that a model has no known price
+// and its cost is counted as zero.
+func (s *Session) warnIfUnpriced(modelID string, info *provider.ModelInfo) {
+	if info != nil && (info.InputCostPer1M != 0 || info.OutputCostPer1M != 0) {
+		return
+	}
+	s.mu.Lock()
+	warned := s.unpricedWarned[modelID]
+	if !warned {
+		if s.unpricedWarned == nil {
+			s.unpricedWarned = make(map[string]bool)
+		}
+		s.unpricedWarned[modelID] = true
+	}
+	s.mu.Unlock()
+	if !warned {
+		s.notifier.Send(PricingMissingEvent{ModelID: modelID})
+	}
+}
+
+// lookupModelInfo returns info for a model other than the session's, e.g.
+// one that served a turn as a routing fallback. The model list prefetched for
+// completions is consulted before asking the provider. Returns nil if not
+// found (non-fatal).
+func (s *Session) lookupModelInfo(ctx context.Context, modelID string) (*provider.ModelInfo, error) {
+	s.mu.Lock()
+	models := s.cachedModels
+	s.mu.Unlock()
+	if len(models) == 0 {
+		var err error
+		if models, err = s.provider.ListModels(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return findModel(models, modelID), nil
+}
+
+// findModel returns the entry for modelID in models, or nil. Providers list
+// every invocable ID, including Bedrock inference profiles, with the
+// metadata of the model behind it; a profile missing from the list (e.g. one
+// the account cannot enumerate) falls back to its base foundation model.
+func findModel(models []provider.ModelInfo, modelID string) *provider.ModelInfo {
+	baseModel := stripRegionalPrefix(modelID)
+	var base *provider.ModelInfo
+	for _, m := range models {
+		switch m.ID {
+		case modelID:
+			info := m
+			return &info
+		case baseModel:
+			if base == nil {
+				info := m
+				base = &info
+			}
+		}
+	}
+	return base
+}
+
+// stripRegionalPrefix removes a Bedrock inference profile prefix (e.g.
+// "us.", "eu.", "global.") from a model ID, returning the base model ID.
+func stripRegionalPrefix(modelID string) string {
+	prefixes := []string{"us.", "eu.", "ap.", "apac.", "global."}
+	for _, p := range prefixes {
+		if after, found := strings.CutPrefix(modelID, p); found {
+			return after
+		}
+	}
+	return modelID
+}
+
 // handleCommand dispatches a known slash command to its handler.
 // Returns (true, err) if the text was a recognized command, (false, nil) otherwise.
 // Unrecognized /-prefixed text should be treated as a normal user message.
@@ -696,6 +1210,26 @@ func (s *Session) handleCommand(ctx context.Context, text string) (bool, error)
 		return true, s.handleContextCommand(ctx)
 	case "/restore":
 		return true, s.handleRestoreCommand(ctx, args)
+	case "/tool-choice":
+		return true, s.handleToolChoiceCommand(args)
+	case "/temperature":
+		return true, s.handleSamplingCommand(verb, args, &s.turnDefaults.Temperature, 2)
+	case "/top-p":
+		return true, s.handleSamplingCommand(verb, args, &s.turnDefaults.TopP, 1)
+	case "/stop":
+		return true, s.handleStopCommand(args)
+	case "/budget":
+		return true, s.handleBudgetCommand(ctx, args)
+	case "/plan":
+		return true, s.handlePlanCommand(ctx, args)
+	case "/execute":
+		return true, s.handleExecuteCommand(ctx, args)
+	case "/edit":
+		return true, s.handleEditCommand(ctx, args)
+	case "/retry":
+		return true, s.handleRetryCommand(ctx)
+	case "/branch":
+		return true, s.handleBranchCommand(ctx, args)
 	default:
 		return false, nil
 	}
@@ -717,6 +1251,7 @@ func (s *Session) handleModelCommand(_ context.Context, args string) error {
 	s.cachedModelInfo = nil
 	s.modelInfoOnce = sync.Once{}
 	s.mu.Unlock()
+	s.journalModel(args)
 
 	s.notifier.Send(ModelChangedEvent{ModelID: args})
 	return nil
@@ -725,9 +1260,17 @@ func (s *Session) handleModelCommand(_ context.Context, args string) error {
 // handleClearCommand processes the /clear user command.
 func (s *Session) handleClearCommand(_ context.Context) error {
 	s.mu.Lock()
-	s.history = []provider.Message{}
+	s.replaceHistory([]provider.Message{})
 	s.warned50 = false
+	s.branches, s.activeBranch, s.fileSteps = nil, 0, nil
 	s.mu.Unlock()
+	s.haltedTurn = nil
+	s.pinnedPlan = ""
+	s.pendingRestore = nil
+	if s.planMode {
+		s.planMode = false
+		s.notifier.Send(PlanModeEvent{Active: false})
+	}
 
 	s.notifier.Send(HistoryClearedEvent{})
 	return nil
@@ -738,9 +1281,10 @@ func (s *Session) handleContextCommand(ctx context.Context) error {
 	modelInfo, _ := s.getModelInfo(ctx)
 
 	s.mu.Lock()
-	estimated := s.estimateTokenCount(s.history)
+	history := append([]provider.Message{}, s.history...)
 	modelID := s.model
 	s.mu.Unlock()
+	estimated, _ := s.estimateTokenCount(ctx, history)
 
 	total := 0
 	pct := 0.0
@@ -776,19 +1320,31 @@ func (s *Session) handleRestoreCommand(_ context.Context, args string) error {
 	}
 
 	s.mu.Lock()
-	s.history = saved.History
+	s.replaceHistory(saved.History)
 	if saved.Model != "" {
 		s.model = saved.Model
 		s.cachedModelInfo = nil
 		s.modelInfoOnce = sync.Once{}
 	}
 	s.warned50 = false
+	s.branches, s.activeBranch, s.fileSteps = nil, 0, nil
+	if validBranches(saved.Branches, saved.Branch) {
+		s.branches, s.activeBranch = saved.Branches, saved.Branch
+		s.fileSteps = slices.Clone(saved.Branches[saved.Branch].FileSteps)
+	}
 	s.mu.Unlock()
+	s.haltedTurn = nil
+	s.pinnedPlan = ""
+	s.pendingRestore = nil
+	if saved.Model != "" {
+		s.journalModel(saved.Model)
+	}
 
 	s.notifier.Send(SessionRestoredEvent{
 		SessionID:    saved.SessionID,
 		Description:  saved.Description,
 		MessageCount: len(saved.History),
+		Cost:         saved.Usage.FormatCost(),
 	})
 	if saved.Model != "" {
 		s.notifier.Send(ModelChangedEvent{ModelID: saved.Model})
@@ -809,11 +1365,12 @@ func (s *Session) performCompaction(ctx context.Context, mode string) error {
 		return err
 	}
 
-	// 2. Estimate old token count (character-based, same unit as newTokenCount)
-	oldTokens := s.estimateTokenCount(s.history)
-
+	oldHistory := append([]provider.Message{}, s.history...)
 	s.mu.Unlock()
 
+	// 2. Count old tokens (same unit as newTokenCount, see step 5)
+	oldTokens, oldExact := s.estimateTokenCount(ctx, oldHistory)
+
 	// Notify UI (after validation, before work begins)
 	s.notifier.Send(CompactionStartEvent{Mode: mode})
 
@@ -833,7 +1390,12 @@ func (s *Session) performCompaction(ctx context.Context, mode string) error {
 
 	// 5. Estimate token count for new history
 	s.notifier.Send(CompactionProgressEvent{Stage: "estimating_tokens"})
-	newTokenCount := s.estimateTokenCount(newHistory)
+	newTokenCount, newExact := s.estimateTokenCount(ctx, newHistory)
+	if oldExact != newExact {
+		// One provider count failed: compare local estimates instead.
+		oldTokens = localTokenCount(s.systemMsg, s.tools, oldHistory)
+		newTokenCount = localTokenCount(s.systemMsg, s.tools, newHistory)
+	}
 
 	// 6. Validate compaction achieved reduction
 	if newTokenCount >= oldTokens {
@@ -851,7 +1413,15 @@ func (s *Session) performCompaction(ctx context.Context, mode string) error {
 
 	// 7. Commit changes (point of no return)
 	s.mu.Lock()
-	s.history = newHistory
+	// Steps in the summarized part move to its start.
+	shift := len(s.history) - len(newHistory)
+	for i := range s.fileSteps {
+		s.fileSteps[i].At = max(s.fileSteps[i].At-shift, 0)
+	}
+	// Branches index into the histories before compaction; the compacted
+	// conversation goes on as the only one.
+	s.branches, s.activeBranch = nil, 0
+	s.replaceHistory(newHistory)
 	s.warned50 = false // Reset warning flag for fresh warnings
 	s.mu.Unlock()
 
@@ -899,7 +1469,7 @@ func (s *Session) generateSummary(ctx context.Context) (string, error) {
 	}
 
 	// Build summarization request
-	targetTokens := int(float64(s.estimateTokenCount(historyToSummarize)) * compactionTargetRatio * 1.5) // 1.5x target for safety
+	targetTokens := int(float64(localTokenCount("", nil, historyToSummarize)) * compactionTargetRatio * 1.5) // 1.5x target for safety
 
 	summaryPrompt := fmt.Sprintf(compactionPromptTemplate, conversationText.String())
 
@@ -949,6 +1519,13 @@ func (s *Session) buildCompactedHistory(summary string) []provider.Message {
 		Content: "**[Conversation Summary]**\n\n" + summary,
 	}
 
+	// Keep the plan being executed, unless it is among the recent messages.
+	if s.pinnedPlan != "" && !slices.ContainsFunc(recentMessages, func(m provider.Message) bool {
+		return m.Role == provider.RoleAssistant && m.Content == s.pinnedPlan
+	}) {
+		summaryMsg.Content += "\n\n**[Plan being executed]**\n\n" + s.pinnedPlan
+	}
+
 	newHistory := []provider.Message{summaryMsg}
 	newHistory = append(newHistory, recentMessages...)
 
@@ -995,6 +1572,12 @@ func (s *Session) checkPermission(ctx context.Context, toolCallID, toolName stri
 		return permissionDecision{allowed: true}
 	}
 
+	// The delegate tool needs no permission of its own; the sub-agent's
+	// tool calls are checked as they are made.
+	if s.delegation != nil && toolName == DelegateToolName {
+		return permissionDecision{allowed: true}
+	}
+
 	// Look up the tool's manifest rules via the executor.
 	// If the executor doesn't implement ToolManifestProvider (e.g., stub),
 	// fall through to default-deny.
@@ -1029,6 +1612,13 @@ func (s *Session) checkPermission(ctx context.Context, toolCallID, toolName stri
 
 		decision := s.evaluator.Evaluate(agentName, rule.Key, rules)
 
+		if s.resolvePermission != nil && decision.Effect != policy.EffectDeny {
+			if !s.resolvePermission(agentName, rule.Key.Raw, decision.Effect == policy.EffectAllow) {
+				return permissionDecision{allowed: false, reason: fmt.Sprintf("permission not granted: %s", rule.Key.Raw)}
+			}
+			continue
+		}
+
 		switch decision.Effect {
 		case policy.EffectAllow:
 			continue // already granted (persisted grant or policy override)
@@ -1148,6 +1738,22 @@ func (s *Session) preflightToolCall(ctx context.Context, tc provider.ToolCall) t
 		Input:      exec.inputJSON,
 	})
 
+	if ctx.Err() != nil {
+		exec.result = cancelledToolResult(tc, ctx.Err())
+		return exec
+	}
+
+	// Refuse write tools the model calls in plan mode, e.g. ones it
+	// remembers from before the plan started.
+	if s.planMode && s.isWriteTool(tc.Name) {
+		exec.result = provider.ToolResult{
+			ToolUseID: tc.ID,
+			Content:   fmt.Sprintf("%s is not available in plan mode; reply with the plan instead", tc.Name),
+			IsError:   true,
+		}
+		return exec
+	}
+
 	// Check permission before execution (accesses recentPrompts — not thread-safe)
 	permDecision := s.checkPermission(ctx, tc.ID, tc.Name, tc.Input)
 	if !permDecision.allowed {
@@ -1179,14 +1785,28 @@ func (s *Session) executeTool(ctx context.Context, exec *toolExecution, interact
 		execCtx = ec.WithExecContext(ctx, interactionID, exec.toolCall.ID)
 	}
 
-	result, execErr := s.executor.Execute(execCtx, exec.toolCall.Name, exec.toolCall.Input)
+	var result string
+	var blocks []provider.ContentBlock
+	var execErr error
+	if s.delegation != nil && exec.toolCall.Name == DelegateToolName {
+		result, execErr = s.runDelegate(ctx, exec.toolCall, interactionID)
+	} else if ce, ok := s.executor.(ContentToolExecutor); ok {
+		result, blocks, execErr = ce.ExecuteContent(execCtx, exec.toolCall.Name, exec.toolCall.Input)
+	} else {
+		result, execErr = s.executor.Execute(execCtx, exec.toolCall.Name, exec.toolCall.Input)
+	}
 	exec.result = provider.ToolResult{
 		ToolUseID: exec.toolCall.ID,
 		Content:   result,
+		Blocks:    blocks,
 	}
 	if execErr != nil {
 		exec.result.Content = execErr.Error()
+		exec.result.Blocks = nil
 		exec.result.IsError = true
+		if ctx.Err() != nil {
+			exec.result.Content = cancelledToolResult(exec.toolCall, ctx.Err()).Content
+		}
 	}
 
 	// Retrieve file changes for this tool call.
@@ -1228,11 +1848,7 @@ func (s *Session) executeBatchConcurrent(ctx context.Context, execs []toolExecut
 		case sem <- struct{}{}:
 		case <-ctx.Done():
 			wg.Done()
-			execs[i].result = provider.ToolResult{
-				ToolUseID: execs[i].toolCall.ID,
-				Content:   fmt.Sprintf("tool %s cancelled: %v", execs[i].toolCall.Name, ctx.Err()),
-				IsError:   true,
-			}
+			execs[i].result = cancelledToolResult(execs[i].toolCall, ctx.Err())
 			continue
 		}
 		go func(idx int) {
@@ -1262,16 +1878,36 @@ func (s *Session) executeBatchSequential(ctx context.Context, execs []toolExecut
 		if execs[i].result.ToolUseID != "" {
 			continue // Already resolved (denied/no executor)
 		}
+		if ctx.Err() != nil {
+			execs[i].result = cancelledToolResult(execs[i].toolCall, ctx.Err())
+			continue
+		}
 		s.executeTool(ctx, &execs[i], interactionID)
 	}
 }
 
+// cancelledToolResult answers a tool call that was stopped or never run
+// because its turn was cancelled.
+func cancelledToolResult(tc provider.ToolCall, err error) provider.ToolResult {
+	return provider.ToolResult{
+		ToolUseID: tc.ID,
+		Content:   fmt.Sprintf("tool %s cancelled: %v", tc.Name, err),
+		IsError:   true,
+	}
+}
+
 // isWriteTool checks if a tool has write permissions based on its manifest rules.
 // A tool is considered a "write" tool if it declares any of:
 //   - fs:write (non-deny mode)
 //   - docker:* (non-deny mode)
 // All other tools (including pure functions with no permissions) are read-only.
 func (s *Session) isWriteTool(toolName string) bool {
+	// Sub-agents may write, and run one at a time so their permission
+	// prompts do not interleave.
+	if s.delegation != nil && toolName == DelegateToolName {
+		return true
+	}
+
 	mp, ok := s.executor.(ToolManifestProvider)
 	if !ok {
 		return false // No manifest provider = stub mode = treat as read-only
@@ -1346,32 +1982,44 @@ func (s *Session) batchToolsByPermissions(toolCalls []provider.ToolCall) []toolB
 	return batches
 }
 
-// estimateTokenCount estimates token count using character heuristic.
-// Claude averages ~1.3 characters per token. We use 1.2 to be conservative.
-func (s *Session) estimateTokenCount(messages []provider.Message) int {
-	totalChars := 0
-
-	// Count system message if present
-	totalChars += len(s.systemMsg)
-
-	// Count all message content
-	for _, msg := range messages {
-		totalChars += len(msg.Content)
-
-		// Tool calls/results also contribute
-		for _, tc := range msg.ToolCalls {
-			totalChars += len(tc.Name) + 50 // Name + metadata overhead
-			inputJSON, _ := json.Marshal(tc.Input)
-			totalChars += len(inputJSON)
+// estimateTokenCount returns the input tokens a request with messages would
+// use. Providers implementing provider.TokenCounter count exactly; otherwise,
+// or when counting fails, the local tokenizer estimate is used. exact reports
+// which one was used, since the two should not be compared. A model the
+// provider cannot count for is remembered, so it is asked only once. Used by
+// /context and compaction only, as counting is a network request. Must not
+// be called with s.mu held.
+func (s *Session) estimateTokenCount(ctx context.Context, messages []provider.Message) (tokens int, exact bool) {
+	s.mu.Lock()
+	model := s.model
+	cannotCount := s.cannotCount[model]
+	s.mu.Unlock()
+	if tc, ok := s.provider.(provider.TokenCounter); ok && !cannotCount && len(messages) > 0 {
+		n, err := tc.CountTokens(ctx, provider.Request{
+			Model:    model,
+			System:   s.systemMsg,
+			Messages: messages,
+			Tools:    s.tools,
+		})
+		if err == nil {
+			return n, true
 		}
-		for _, tr := range msg.ToolResults {
-			totalChars += len(tr.Content) + 50 // Content + metadata
+		if countUnsupported(err) {
+			s.mu.Lock()
+			if s.cannotCount == nil {
+				s.cannotCount = make(map[string]bool)
+			}
+			s.cannotCount[model] = true
+			s.mu.Unlock()
 		}
 	}
+	return localTokenCount(s.systemMsg, s.tools, messages), false
+}
 
-	// Convert chars to tokens (1.2 chars/token is conservative)
-	estimatedTokens := int(float64(totalChars) / 1.2)
-
-	// Add 10% buffer for special tokens and formatting
-	return int(float64(estimatedTokens) * 1.1)
+// countUnsupported reports whether a CountTokens error will recur for the
+// same model, as opposed to a transient failure worth retrying next time.
+func countUnsupported(err error) bool {
+	return errors.Is(err, errors.ErrUnsupported) ||
+		errors.Is(err, provider.ErrAccessDenied) ||
+		errors.Is(err, provider.ErrModelNotFound)
 }
@@ -5,10 +5,14 @@ import (
 	"cosmos/core/provider"
 	"cosmos/engine/manifest"
 	"cosmos/engine/policy"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -36,15 +40,17 @@ func (it *mockStreamIterator) Close() error { return nil }
 
 // mockProvider returns a sequence of stream iterators, one per Send call.
 type mockProvider struct {
-	calls  [][]provider.StreamChunk // one chunk sequence per call
-	idx    int
-	mu     sync.Mutex
-	models []provider.ModelInfo // models to return from ListModels
+	calls    [][]provider.StreamChunk // one chunk sequence per call
+	idx      int
+	mu       sync.Mutex
+	models   []provider.ModelInfo // models to return from ListModels
+	requests []provider.Request   // requests received, in order
 }
 
-func (p *mockProvider) Send(_ context.Context, _ provider.Request) (provider.StreamIterator, error) {
+func (p *mockProvider) Send(_ context.Context, req provider.Request) (provider.StreamIterator, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.requests = append(p.requests, req)
 	if p.idx >= len(p.calls) {
 		return nil, fmt.Errorf("unexpected Send call #%d", p.idx+1)
 	}
@@ -491,6 +497,209 @@ func TestMultiRoundToolUse(t *testing.T) {
 	}
 }
 
+func TestPromptCachingMarksRecentTurns(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{
+		toolUseChunks("t1", "get_weather", `{"location":"Rome"}`),
+		toolUseChunks("t2", "read_file", `{"path":"/tmp/b.txt"}`),
+		textChunks("All done."),
+	}}
+	executor := &mockExecutor{results: map[string]string{"get_weather": "sunny", "read_file": "data"}}
+	session := newTestSession(prov, executor, &mockNotifier{})
+	session.SetPromptCaching(5) // capped at maxCacheTurns
+
+	if err := session.processUserMessage(context.Background(), "do everything"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(prov.requests) != 3 {
+		t.Fatalf("requests = %d, want 3", len(prov.requests))
+	}
+	last := prov.requests[2]
+	if !last.CacheSystem {
+		t.Error("expected CacheSystem on request")
+	}
+	// History: user, assistant, user(tr), assistant, user(tr) — the last two user turns are marked.
+	var marked []int
+	for i, m := range last.Messages {
+		if m.CachePoint {
+			marked = append(marked, i)
+		}
+	}
+	if len(marked) != 2 || marked[0] != 2 || marked[1] != 4 {
+		t.Errorf("cache points at %v, want [2 4]", marked)
+	}
+
+	// Breakpoints are per-request and must not leak into stored history.
+	for i, m := range session.history {
+		if m.CachePoint {
+			t.Errorf("history[%d] has CachePoint set", i)
+		}
+	}
+}
+
+func TestPromptCachingDisabledByDefault(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{textChunks("Hi")}}
+	session := newTestSession(prov, &mockExecutor{}, &mockNotifier{})
+
+	if err := session.processUserMessage(context.Background(), "Hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := prov.requests[0]
+	if req.CacheSystem || req.CacheTools || req.Messages[0].CachePoint {
+		t.Errorf("caching should be off without SetPromptCaching: %+v", req)
+	}
+}
+
+func TestPromptAttachment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shot.png")
+	if err := os.WriteFile(path, []byte("\x89PNG fake"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	prov := &mockProvider{calls: [][]provider.StreamChunk{textChunks("A screenshot.")}}
+	session := newTestSession(prov, &mockExecutor{}, &mockNotifier{})
+
+	text := "what is in @" + path + "? cc @alice"
+	if err := session.processUserMessage(context.Background(), text); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msg := prov.requests[0].Messages[0]
+	if msg.Content != text {
+		t.Errorf("content = %q, want prompt unchanged", msg.Content)
+	}
+	if len(msg.Blocks) != 1 {
+		t.Fatalf("blocks = %d, want 1 (@alice is not a file)", len(msg.Blocks))
+	}
+	b := msg.Blocks[0]
+	if b.Type != provider.ContentImage || b.MediaType != "image/png" || b.Name != "shot.png" || string(b.Data) != "\x89PNG fake" {
+		t.Errorf("block = %+v", b)
+	}
+}
+
+func TestPromptAttachmentDeniedByPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.4"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	policyPath := filepath.Join(t.TempDir(), "policy.json")
+	pf := policy.PolicyFile{Version: 1, Overrides: map[string]map[string]policy.PolicyEntry{
+		attachmentAgent: {"fs:read:" + resolved: {Effect: "deny", Reason: "override"}},
+	}}
+	data, _ := json.Marshal(pf)
+	if err := os.WriteFile(policyPath, data, 0o600); err != nil {
+		t.Fatalf("write policy: %v", err)
+	}
+	eval, err := policy.NewEvaluator(policyPath)
+	if err != nil {
+		t.Fatalf("NewEvaluator: %v", err)
+	}
+
+	prov := &mockProvider{}
+	session := NewSession("test-session-id", prov, NewTracker(nil, nil), &mockNotifier{}, "test-model", "system", 1024, &mockExecutor{}, nil, nil, eval)
+
+	err = session.processUserMessage(context.Background(), "summarize @"+path)
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("err = %v, want permission denied", err)
+	}
+	if len(prov.requests) != 0 || len(session.history) != 0 {
+		t.Errorf("denied attachment must not reach the provider or history")
+	}
+}
+
+// contentExecutor returns a fixed image block with every successful result.
+type contentExecutor struct {
+	mockExecutor
+	block provider.ContentBlock
+}
+
+func (e *contentExecutor) ExecuteContent(ctx context.Context, name string, input map[string]any) (string, []provider.ContentBlock, error) {
+	result, err := e.Execute(ctx, name, input)
+	if err != nil {
+		return "", nil, err
+	}
+	return result, []provider.ContentBlock{e.block}, nil
+}
+
+func TestToolResultContentBlocks(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{
+		toolUseChunks("t1", "screenshot", `{}`),
+		textChunks("I see a button."),
+	}}
+	executor := &contentExecutor{
+		mockExecutor: mockExecutor{results: map[string]string{"screenshot": `"[image ui.png attached]"`}},
+		block:        provider.ContentBlock{Type: provider.ContentImage, MediaType: "image/png", Name: "ui.png", Data: []byte("png")},
+	}
+	session := newTestSession(prov, executor, &mockNotifier{})
+
+	if err := session.processUserMessage(context.Background(), "look at the UI"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tr := prov.requests[1].Messages[2].ToolResults[0]
+	if tr.Content != `"[image ui.png attached]"` || len(tr.Blocks) != 1 || tr.Blocks[0].Name != "ui.png" {
+		t.Errorf("tool result = %+v", tr)
+	}
+}
+
+func TestReasoningPreservedInHistory(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{
+		{
+			{Event: provider.EventReasoningDelta, Text: "Need the "},
+			{Event: provider.EventReasoningDelta, Text: "weather."},
+			{Event: provider.EventReasoningSignature, Signature: "sig-1"},
+			{Event: provider.EventReasoningRedacted, Redacted: []byte{0x01, 0x02}},
+			{Event: provider.EventToolStart, ToolCallID: "t1", ToolName: "get_weather"},
+			{Event: provider.EventToolDelta, InputDelta: `{"location":"Rome"}`},
+			{Event: provider.EventToolEnd},
+			{Event: provider.EventMessageStop, StopReason: "tool_use"},
+		},
+		textChunks("Sunny."),
+	}}
+	notifier := &mockNotifier{}
+	executor := &mockExecutor{results: map[string]string{"get_weather": "sunny"}}
+	session := newTestSession(prov, executor, notifier)
+	session.SetThinkingBudget(2048)
+
+	if err := session.processUserMessage(context.Background(), "weather?"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []provider.ReasoningBlock{
+		{Text: "Need the weather.", Signature: "sig-1"},
+		{Redacted: []byte{0x01, 0x02}},
+	}
+	if !reflect.DeepEqual(session.history[1].Reasoning, want) {
+		t.Errorf("reasoning = %+v, want %+v", session.history[1].Reasoning, want)
+	}
+	if session.history[3].Reasoning != nil {
+		t.Errorf("final answer had no reasoning, got %+v", session.history[3].Reasoning)
+	}
+
+	// The second request carries the signed reasoning back with the tool call.
+	second := prov.requests[1]
+	if second.ThinkingBudget != 2048 {
+		t.Errorf("ThinkingBudget = %d, want 2048", second.ThinkingBudget)
+	}
+	if !reflect.DeepEqual(second.Messages[1].Reasoning, want) {
+		t.Errorf("resent reasoning = %+v", second.Messages[1].Reasoning)
+	}
+
+	var thinking strings.Builder
+	for _, m := range notifier.getMessages() {
+		if e, ok := m.(ThinkingEvent); ok {
+			thinking.WriteString(e.Text)
+		}
+	}
+	if thinking.String() != "Need the weather." {
+		t.Errorf("ThinkingEvent text = %q", thinking.String())
+	}
+}
+
 func TestDoubleStopNoPanic(t *testing.T) {
 	prov := &mockProvider{calls: [][]provider.StreamChunk{
 		textChunks("Hello"),
@@ -558,6 +767,7 @@ func TestStripRegionalPrefix(t *testing.T) {
 		{"us.anthropic.claude-3-5-sonnet-20241022-v2:0", "anthropic.claude-3-5-sonnet-20241022-v2:0"},
 		{"eu.anthropic.claude-3-5-sonnet-20241022-v2:0", "anthropic.claude-3-5-sonnet-20241022-v2:0"},
 		{"ap.anthropic.claude-3-5-sonnet-20241022-v2:0", "anthropic.claude-3-5-sonnet-20241022-v2:0"},
+		{"global.anthropic.claude-sonnet-4-20250514-v1:0", "anthropic.claude-sonnet-4-20250514-v1:0"},
 		{"anthropic.claude-3-5-sonnet-20241022-v2:0", "anthropic.claude-3-5-sonnet-20241022-v2:0"},
 		{"custom-model", "custom-model"},
 	}
@@ -569,11 +779,29 @@ func TestStripRegionalPrefix(t *testing.T) {
 	}
 }
 
+func TestGetModelInfoFallsBackToBaseModel(t *testing.T) {
+	listCallCount := 0
+	prov := &countingMockProvider{
+		models:    []provider.ModelInfo{{ID: "anthropic.claude-sonnet-4-20250514-v1:0", ContextWindow: 200_000}},
+		callCount: &listCallCount,
+	}
+	session := NewSession("test-session-id", prov, NewTracker(nil, nil), &mockNotifier{}, "global.anthropic.claude-sonnet-4-20250514-v1:0", "system", 1024, &mockExecutor{}, nil, nil, nil)
+
+	info, err := session.getModelInfo(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info == nil || info.ContextWindow != 200_000 {
+		t.Errorf("info = %+v, want the base model's entry", info)
+	}
+}
+
 func TestGetModelInfoCaching(t *testing.T) {
 	listCallCount := 0
 	prov := &countingMockProvider{
 		models: []provider.ModelInfo{
 			{ID: "anthropic.claude-3-5-sonnet-20241022-v2:0"},
+			{ID: "us.anthropic.claude-3-5-sonnet-20241022-v2:0", Name: "US Claude 3.5 Sonnet v2"},
 		},
 		callCount: &listCallCount,
 	}
@@ -588,8 +816,8 @@ func TestGetModelInfoCaching(t *testing.T) {
 	if info1 == nil {
 		t.Fatal("expected non-nil model info")
 	}
-	if info1.ID != "anthropic.claude-3-5-sonnet-20241022-v2:0" {
-		t.Errorf("model ID = %q, want base ID", info1.ID)
+	if info1.ID != "us.anthropic.claude-3-5-sonnet-20241022-v2:0" {
+		t.Errorf("model ID = %q, want the inference profile's entry", info1.ID)
 	}
 
 	// Second call — should use cache, not call ListModels again
@@ -693,6 +921,29 @@ func TestContextWarning50Percent(t *testing.T) {
 	}
 }
 
+func TestFallbackModelPricing(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{{
+		{Event: provider.EventTextDelta, Text: "from the fallback"},
+		{Event: provider.EventMessageStop, StopReason: "end_turn", Model: "fallback-model",
+			Usage: &provider.Usage{InputTokens: 1_000_000}},
+	}}}
+	prov.models = []provider.ModelInfo{
+		{ID: "test-model", InputCostPer1M: 1.0},
+		{ID: "fallback-model", InputCostPer1M: 3.0},
+	}
+
+	tracker := NewTracker(nil, nil)
+	session := NewSession("test-session-id", prov, tracker, &mockNotifier{}, "test-model", "system", 1024, &mockExecutor{}, nil, nil, nil)
+	if err := session.processUserMessage(context.Background(), "hi"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+
+	snap := tracker.Snapshot()
+	if snap.TotalCost != 3.0 {
+		t.Errorf("TotalCost = %v, want 3.0 (fallback model rates)", snap.TotalCost)
+	}
+}
+
 func TestContextAutoCompactAt90Percent(t *testing.T) {
 	// Setup: Model with 1000 token context window
 	// Response with 900 total tokens (90%)
@@ -1841,6 +2092,89 @@ func TestHandleContextCommand(t *testing.T) {
 	}
 }
 
+// countingProvider adds exact token counting to mockProvider.
+type countingProvider struct {
+	*mockProvider
+	tokens int
+	err    error
+	counts []provider.Request
+}
+
+func (p *countingProvider) CountTokens(_ context.Context, req provider.Request) (int, error) {
+	p.counts = append(p.counts, req)
+	return p.tokens, p.err
+}
+
+func TestHandleContextCommandUsesTokenCounter(t *testing.T) {
+	prov := &countingProvider{
+		mockProvider: &mockProvider{
+			calls:  [][]provider.StreamChunk{textChunks("Hi")},
+			models: []provider.ModelInfo{{ID: "test-model", ContextWindow: 100000}},
+		},
+		tokens: 25000,
+	}
+	notifier := &mockNotifier{}
+	session := newTestSession(prov, nil, notifier)
+
+	if err := session.processUserMessage(context.Background(), "Hello"); err != nil {
+		t.Fatalf("processUserMessage failed: %v", err)
+	}
+	if err := session.processUserMessage(context.Background(), "/context"); err != nil {
+		t.Fatalf("/context failed: %v", err)
+	}
+
+	if len(prov.counts) != 1 || prov.counts[0].Model != "test-model" || len(prov.counts[0].Messages) != 2 {
+		t.Fatalf("count requests = %+v, want one for the 2-message history", prov.counts)
+	}
+	var info *ContextInfoEvent
+	for _, m := range notifier.getMessages() {
+		if ev, ok := m.(ContextInfoEvent); ok {
+			info = &ev
+		}
+	}
+	if info == nil || info.Used != 25000 || info.Percentage != 25.0 {
+		t.Errorf("ContextInfoEvent = %+v, want the provider count (25000, 25%%)", info)
+	}
+}
+
+func TestEstimateTokenCountFallsBackToLocal(t *testing.T) {
+	prov := &countingProvider{mockProvider: &mockProvider{}, err: errors.New("count unavailable")}
+	session := newTestSession(prov, nil, &mockNotifier{})
+	history := []provider.Message{{Role: provider.RoleUser, Content: "Hello there"}}
+
+	tokens, exact := session.estimateTokenCount(context.Background(), history)
+	if exact {
+		t.Error("exact = true after the provider failed to count")
+	}
+	if want := localTokenCount("system", nil, history); tokens != want {
+		t.Errorf("tokens = %d, want local estimate %d", tokens, want)
+	}
+}
+
+func TestEstimateTokenCountRemembersUnsupportedModel(t *testing.T) {
+	prov := &countingProvider{
+		mockProvider: &mockProvider{calls: [][]provider.StreamChunk{textChunks("Hi")}},
+		err:          fmt.Errorf("router: %w", errors.ErrUnsupported),
+	}
+	session := newTestSession(prov, nil, &mockNotifier{})
+	ctx := context.Background()
+
+	if err := session.processUserMessage(ctx, "Hello"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+	if len(prov.counts) != 0 {
+		t.Errorf("a turn counted tokens %d times, want none", len(prov.counts))
+	}
+	for range 2 {
+		if err := session.processUserMessage(ctx, "/context"); err != nil {
+			t.Fatalf("/context: %v", err)
+		}
+	}
+	if len(prov.counts) != 1 {
+		t.Errorf("CountTokens called %d times, want once for an unsupported model", len(prov.counts))
+	}
+}
+
 func TestHandleRestoreCommand_NotConfigured(t *testing.T) {
 	notifier := &mockNotifier{}
 	session := newTestSession(&mockProvider{}, nil, notifier)
@@ -2040,3 +2374,677 @@ func TestCompletions_Restore(t *testing.T) {
 		t.Errorf("expected nil for unknown prefix, got %v", completions)
 	}
 }
+
+func TestForcedToolChoiceAppliesToFirstRequestOnly(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{
+		toolUseChunks("t1", "extract", `{}`),
+		textChunks("done"),
+	}}
+	exec := &mockExecutor{results: map[string]string{"extract": `{"ok":true}`}}
+	session := NewSession("test-session-id", prov, NewTracker(nil, nil), &mockNotifier{}, "test-model", "system", 1024, exec,
+		[]provider.ToolDefinition{{Name: "extract"}}, nil, nil)
+	session.SetThinkingBudget(2048)
+
+	temp := 0.0
+	err := session.processTurn(context.Background(), "pull out the fields", TurnOptions{
+		ToolChoice:  &provider.ToolChoice{Mode: provider.ToolChoiceTool, Name: "extract"},
+		Temperature: &temp,
+	})
+	if err != nil {
+		t.Fatalf("processTurn: %v", err)
+	}
+
+	if len(prov.requests) != 2 {
+		t.Fatalf("requests = %d, want 2", len(prov.requests))
+	}
+	first, second := prov.requests[0], prov.requests[1]
+	if first.ToolChoice == nil || first.ToolChoice.Name != "extract" {
+		t.Errorf("first request tool choice = %+v, want extract", first.ToolChoice)
+	}
+	if second.ToolChoice != nil {
+		t.Errorf("follow-up request tool choice = %+v, want nil", second.ToolChoice)
+	}
+	if first.ThinkingBudget != 0 || second.ThinkingBudget != 0 {
+		t.Error("thinking must be off for a turn that forces a tool call")
+	}
+	if second.Temperature == nil || *second.Temperature != 0 {
+		t.Error("sampling options should apply to every request of the turn")
+	}
+}
+
+func TestToolChoiceUnknownTool(t *testing.T) {
+	prov := &mockProvider{}
+	session := newTestSession(prov, &mockExecutor{}, &mockNotifier{})
+
+	err := session.processTurn(context.Background(), "hi", TurnOptions{
+		ToolChoice: &provider.ToolChoice{Mode: provider.ToolChoiceTool, Name: "missing"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("err = %v, want unknown tool error", err)
+	}
+	if len(prov.requests) != 0 || len(session.HistorySnapshot()) != 0 {
+		t.Error("an invalid tool choice must not reach the provider or history")
+	}
+}
+
+func TestSamplingSlashCommands(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{textChunks("a"), textChunks("b")}}
+	notifier := &mockNotifier{}
+	session := newTestSession(prov, &mockExecutor{}, notifier)
+	ctx := context.Background()
+
+	for _, cmd := range []string{"/temperature 0.3", "/top-p 0.8", `/stop END "\n\n"`, "/tool-choice none"} {
+		if err := session.processUserMessage(ctx, cmd); err != nil {
+			t.Fatalf("%s: %v", cmd, err)
+		}
+	}
+	if err := session.processUserMessage(ctx, "hello"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+	req := prov.requests[0]
+	if req.Temperature == nil || *req.Temperature != 0.3 || req.TopP == nil || *req.TopP != 0.8 {
+		t.Errorf("sampling = %v / %v", req.Temperature, req.TopP)
+	}
+	if !reflect.DeepEqual(req.StopSequences, []string{"END", "\n\n"}) {
+		t.Errorf("stop sequences = %q", req.StopSequences)
+	}
+	if req.ToolChoice == nil || req.ToolChoice.Mode != provider.ToolChoiceNone {
+		t.Errorf("tool choice = %+v, want none", req.ToolChoice)
+	}
+
+	// Per-turn options override the defaults for that turn only.
+	temp := 1.0
+	if err := session.processTurn(ctx, "again", TurnOptions{Temperature: &temp}); err != nil {
+		t.Fatalf("processTurn: %v", err)
+	}
+	if got := prov.requests[1].Temperature; got == nil || *got != 1.0 {
+		t.Errorf("per-turn temperature = %v, want 1.0", got)
+	}
+	if session.turnDefaults.Temperature == nil || *session.turnDefaults.Temperature != 0.3 {
+		t.Error("per-turn options must not change the session defaults")
+	}
+
+	var summaries []string
+	for _, m := range notifier.getMessages() {
+		if ev, ok := m.(TurnOptionsEvent); ok {
+			summaries = append(summaries, ev.Summary)
+		}
+	}
+	want := `tool choice: none · temperature: 0.3 · top-p: 0.8 · stop: "END" "\n\n"`
+	if len(summaries) != 4 || summaries[3] != want {
+		t.Errorf("summaries = %q, want last %q", summaries, want)
+	}
+}
+
+func TestSamplingSlashCommandErrors(t *testing.T) {
+	notifier := &mockNotifier{}
+	session := newTestSession(&mockProvider{}, &mockExecutor{}, notifier)
+
+	for _, cmd := range []string{"/temperature hot", "/top-p 1.5", "/tool-choice nosuchtool", `/stop "unterminated`} {
+		if err := session.processUserMessage(context.Background(), cmd); err != nil {
+			t.Fatalf("%s: %v", cmd, err)
+		}
+	}
+	var errs int
+	for _, m := range notifier.getMessages() {
+		if _, ok := m.(ErrorEvent); ok {
+			errs++
+		}
+	}
+	if errs != 4 {
+		t.Errorf("ErrorEvents = %d, want 4", errs)
+	}
+	if session.turnDefaults.Temperature != nil || session.turnDefaults.TopP != nil || session.turnDefaults.ToolChoice != nil {
+		t.Errorf("invalid commands changed defaults: %+v", session.turnDefaults)
+	}
+}
+
+func TestSamplingRefusedWithThinking(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{textChunks("a")}}
+	notifier := &mockNotifier{}
+	session := newTestSession(prov, &mockExecutor{}, notifier)
+	session.SetThinkingBudget(512)
+	ctx := context.Background()
+
+	if err := session.processUserMessage(ctx, "/temperature 0.3"); err != nil {
+		t.Fatalf("/temperature: %v", err)
+	}
+	var refusal string
+	for _, m := range notifier.getMessages() {
+		if ev, ok := m.(ErrorEvent); ok {
+			refusal = ev.Error
+		}
+	}
+	if !strings.Contains(refusal, "extended thinking") || session.turnDefaults.Temperature != nil {
+		t.Errorf("refusal = %q, temperature = %v", refusal, session.turnDefaults.Temperature)
+	}
+
+	// Per-turn sampling settings are left out while thinking is on.
+	temp, topP := 1.0, 0.9
+	if err := session.processTurn(ctx, "hello", TurnOptions{Temperature: &temp, TopP: &topP}); err != nil {
+		t.Fatalf("processTurn: %v", err)
+	}
+	if req := prov.requests[0]; req.ThinkingBudget != 512 || req.Temperature != nil || req.TopP != nil {
+		t.Errorf("request: thinking %d, temperature %v, top-p %v", req.ThinkingBudget, req.Temperature, req.TopP)
+	}
+}
+
+// truncated marks a chunk sequence as cut off at the output limit.
+func truncated(chunks []provider.StreamChunk) []provider.StreamChunk {
+	chunks[len(chunks)-1].StopReason = "max_tokens"
+	return chunks
+}
+
+func TestMaxTokensContinuesReply(t *testing.T) {
+	prov := &mockProvider{
+		calls: [][]provider.StreamChunk{
+			truncated(textChunks("The answer is ")),
+			textChunks(" forty-two."),
+		},
+		models: []provider.ModelInfo{{ID: "test-model", ContextWindow: 200_000, MaxOutputTokens: 64_000}},
+	}
+	notifier := &mockNotifier{}
+	session := NewSession("test-session-id", prov, NewTracker(nil, nil), notifier, "test-model", "system", 0, &mockExecutor{}, nil, nil, nil)
+	session.SetThinkingBudget(2048)
+
+	if err := session.processUserMessage(context.Background(), "question"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+
+	if len(prov.requests) != 2 {
+		t.Fatalf("requests = %d, want 2", len(prov.requests))
+	}
+	if got := prov.requests[0].MaxTokens; got != defaultMaxTokens {
+		t.Errorf("MaxTokens = %d, want the model maximum capped at %d", got, defaultMaxTokens)
+	}
+	cont := prov.requests[1]
+	prefill := cont.Messages[len(cont.Messages)-1]
+	if prefill.Role != provider.RoleAssistant || prefill.Content != "The answer is" {
+		t.Errorf("prefill = %+v, want the cut-off reply without trailing space", prefill)
+	}
+	if cont.ThinkingBudget != 0 {
+		t.Error("thinking must be off for a prefilled continuation")
+	}
+
+	history := session.HistorySnapshot()
+	if len(history) != 2 || history[1].Content != "The answer is forty-two." {
+		t.Errorf("history = %+v, want one stitched assistant reply", history)
+	}
+	var events []ResponseTruncatedEvent
+	for _, m := range notifier.getMessages() {
+		if ev, ok := m.(ResponseTruncatedEvent); ok {
+			events = append(events, ev)
+		}
+	}
+	if len(events) != 1 || events[0] != (ResponseTruncatedEvent{MaxTokens: defaultMaxTokens}) {
+		t.Errorf("events = %+v", events)
+	}
+}
+
+func TestMaxTokensRetriesTruncatedToolCall(t *testing.T) {
+	prov := &mockProvider{
+		calls: [][]provider.StreamChunk{
+			truncated(toolUseChunks("t1", "writeFile", `{"path":"a.go","content":"pack`)),
+			toolUseChunks("t2", "writeFile", `{"path":"a.go","content":"package a"}`),
+			textChunks("Done."),
+		},
+		models: []provider.ModelInfo{{ID: "test-model", MaxOutputTokens: 8192}},
+	}
+	notifier := &mockNotifier{}
+	exec := &mockExecutor{results: map[string]string{"writeFile": "ok"}}
+	session := newTestSession(prov, exec, notifier) // maxTokens 1024
+
+	if err := session.processUserMessage(context.Background(), "write it"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+
+	if len(prov.requests) != 3 {
+		t.Fatalf("requests = %d, want 3", len(prov.requests))
+	}
+	if got := prov.requests[1].MaxTokens; got != 2048 {
+		t.Errorf("retry MaxTokens = %d, want 2048", got)
+	}
+	if got := prov.requests[2].MaxTokens; got != 2048 {
+		t.Errorf("later requests keep the larger limit: MaxTokens = %d", got)
+	}
+	for _, msg := range session.HistorySnapshot() {
+		for _, tc := range msg.ToolCalls {
+			if tc.ID == "t1" {
+				t.Error("truncated tool call must not reach history")
+			}
+		}
+	}
+}
+
+func TestMaxTokensToolCallAtCeiling(t *testing.T) {
+	prov := &mockProvider{
+		calls:  [][]provider.StreamChunk{truncated(toolUseChunks("t1", "writeFile", `{"path":`))},
+		models: []provider.ModelInfo{{ID: "test-model", MaxOutputTokens: 1024}},
+	}
+	session := newTestSession(prov, &mockExecutor{}, &mockNotifier{})
+
+	err := session.processUserMessage(context.Background(), "write it")
+	if err == nil || !strings.Contains(err.Error(), "1024-token output limit") {
+		t.Fatalf("err = %v, want output limit error", err)
+	}
+}
+
+func TestMaxTokensRetriesReplyCutOffWhileThinking(t *testing.T) {
+	prov := &mockProvider{
+		calls: [][]provider.StreamChunk{
+			truncated([]provider.StreamChunk{
+				{Event: provider.EventReasoningDelta, Text: "Let me think"},
+				{Event: provider.EventMessageStop, Usage: &provider.Usage{InputTokens: 10, OutputTokens: 1024}},
+			}),
+			{
+				{Event: provider.EventReasoningDelta, Text: "Let me think it through."},
+				{Event: provider.EventReasoningSignature, Signature: "sig"},
+				{Event: provider.EventTextDelta, Text: "Done."},
+				{Event: provider.EventMessageStop, StopReason: "end_turn", Usage: &provider.Usage{InputTokens: 10, OutputTokens: 5}},
+			},
+		},
+		models: []provider.ModelInfo{{ID: "test-model", MaxOutputTokens: 8192}},
+	}
+	notifier := &mockNotifier{}
+	session := newTestSession(prov, &mockExecutor{}, notifier)
+	session.SetThinkingBudget(512)
+
+	if err := session.processUserMessage(context.Background(), "question"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+
+	if len(prov.requests) != 2 {
+		t.Fatalf("requests = %d, want 2", len(prov.requests))
+	}
+	retry := prov.requests[1]
+	if retry.MaxTokens != 2048 || retry.ThinkingBudget != 512 || len(retry.Messages) != 1 {
+		t.Errorf("retry = MaxTokens %d, ThinkingBudget %d, %d messages; want a fresh attempt with a larger limit",
+			retry.MaxTokens, retry.ThinkingBudget, len(retry.Messages))
+	}
+	history := session.HistorySnapshot()
+	if len(history) != 2 || history[1].Content != "Done." {
+		t.Fatalf("history = %+v", history)
+	}
+	if r := history[1].Reasoning; len(r) != 1 || r[0].Signature != "sig" {
+		t.Errorf("reasoning = %+v, want only the signed block", r)
+	}
+	var thinking string
+	for _, m := range notifier.getMessages() {
+		if ev, ok := m.(ThinkingEvent); ok {
+			thinking += ev.Text
+		}
+	}
+	if thinking != "Let me think it through." {
+		t.Errorf("thinking shown = %q, want the retry's new text only", thinking)
+	}
+}
+
+func TestMaxTokensToolCallRetryShowsTextOnce(t *testing.T) {
+	prov := &mockProvider{
+		calls: [][]provider.StreamChunk{
+			truncated(slices.Concat(
+				[]provider.StreamChunk{{Event: provider.EventTextDelta, Text: "Writing the file."}},
+				toolUseChunks("t1", "writeFile", `{"path":"a.go","content":"pack`)[:2],
+				[]provider.StreamChunk{{Event: provider.EventMessageStop}},
+			)),
+			slices.Concat(
+				[]provider.StreamChunk{{Event: provider.EventTextDelta, Text: "Writing the file. Then done."}},
+				toolUseChunks("t2", "writeFile", `{"path":"a.go","content":"package a"}`),
+			),
+			textChunks("Done."),
+		},
+		models: []provider.ModelInfo{{ID: "test-model", MaxOutputTokens: 8192}},
+	}
+	notifier := &mockNotifier{}
+	exec := &mockExecutor{results: map[string]string{"writeFile": "ok"}}
+	session := newTestSession(prov, exec, notifier)
+
+	if err := session.processUserMessage(context.Background(), "write it"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+
+	var shown string
+	for _, m := range notifier.getMessages() {
+		if ev, ok := m.(TokenEvent); ok {
+			shown += ev.Text
+		}
+	}
+	if shown != "Writing the file. Then done.Done." {
+		t.Errorf("text shown = %q, want the retried text once", shown)
+	}
+	if h := session.HistorySnapshot(); h[1].Content != "Writing the file. Then done." {
+		t.Errorf("history = %+v", h)
+	}
+}
+
+func TestPricingMissingWarnsOnce(t *testing.T) {
+	prov := &mockProvider{
+		calls:  [][]provider.StreamChunk{textChunks("a"), textChunks("b")},
+		models: []provider.ModelInfo{{ID: "test-model", ContextWindow: 1000}},
+	}
+	notifier := &mockNotifier{}
+	session := newTestSession(prov, &mockExecutor{}, notifier)
+
+	for _, prompt := range []string{"one", "two"} {
+		if err := session.processUserMessage(context.Background(), prompt); err != nil {
+			t.Fatalf("processUserMessage: %v", err)
+		}
+	}
+	var warnings []PricingMissingEvent
+	for _, m := range notifier.getMessages() {
+		if ev, ok := m.(PricingMissingEvent); ok {
+			warnings = append(warnings, ev)
+		}
+	}
+	if len(warnings) != 1 || warnings[0].ModelID != "test-model" {
+		t.Errorf("warnings = %+v, want one for test-model", warnings)
+	}
+}
+
+func TestBudgetHaltsTurnUntilRaised(t *testing.T) {
+	prov := &mockProvider{
+		calls: [][]provider.StreamChunk{
+			toolUseChunks("t1", "readFile", `{}`),
+			toolUseChunks("t2", "readFile", `{}`),
+			textChunks("done"),
+		},
+		// $2 per request: 10 input tokens at $0.10 plus 5 output at $0.20.
+		models: []provider.ModelInfo{{ID: "test-model", InputCostPer1M: 100_000, OutputCostPer1M: 200_000}},
+	}
+	notifier := &mockNotifier{}
+	session := newTestSession(prov, &mockExecutor{results: map[string]string{"readFile": "ok"}}, notifier)
+	session.tracker.SetBudget(Budget{Session: 3, WarnAt: []float64{0.5}}, PriorSpend{})
+
+	if err := session.processUserMessage(context.Background(), "read twice"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+	if len(prov.requests) != 2 || session.haltedTurn == nil {
+		t.Fatalf("requests = %d, halted = %v; want 2 requests then a halt", len(prov.requests), session.haltedTurn != nil)
+	}
+	var warned, exceeded int
+	for _, m := range notifier.getMessages() {
+		switch m.(type) {
+		case BudgetWarningEvent:
+			warned++
+		case BudgetExceededEvent:
+			exceeded++
+		}
+	}
+	if warned != 1 || exceeded != 1 {
+		t.Errorf("warnings = %d, exceeded = %d; want 1 and 1", warned, exceeded)
+	}
+
+	// New prompts are refused without entering history.
+	historyLen := len(session.HistorySnapshot())
+	if err := session.processUserMessage(context.Background(), "more"); err != nil {
+		t.Fatalf("processUserMessage: %v", err)
+	}
+	if len(session.HistorySnapshot()) != historyLen || len(prov.requests) != 2 {
+		t.Error("prompt sent while over budget")
+	}
+
+	if err := session.processUserMessage(context.Background(), "/budget session 10"); err != nil {
+		t.Fatalf("/budget: %v", err)
+	}
+	history := session.HistorySnapshot()
+	if len(prov.requests) != 3 || history[len(history)-1].Content != "done" || session.haltedTurn != nil {
+		t.Errorf("turn not resumed: %d requests, last message %+v", len(prov.requests), history[len(history)-1])
+	}
+}
+
+// stallingProvider streams text, then blocks until the request context is
+// cancelled. onStall runs when it starts blocking. Later calls are served
+// by next.
+type stallingProvider struct {
+	text    string
+	onStall func()
+	next    *mockProvider
+	stalled bool
+}
+
+func (p *stallingProvider) Send(ctx context.Context, req provider.Request) (provider.StreamIterator, error) {
+	if p.stalled {
+		return p.next.Send(ctx, req)
+	}
+	p.stalled = true
+	return &stallingIterator{ctx: ctx, text: p.text, onStall: p.onStall}, nil
+}
+
+func (p *stallingProvider) ListModels(context.Context) ([]provider.ModelInfo, error) {
+	return nil, nil
+}
+
+type stallingIterator struct {
+	ctx     context.Context
+	text    string
+	onStall func()
+	sent    bool
+}
+
+func (it *stallingIterator) Next() (provider.StreamChunk, error) {
+	if !it.sent && it.text != "" {
+		it.sent = true
+		return provider.StreamChunk{Event: provider.EventTextDelta, Text: it.text}, nil
+	}
+	if it.onStall != nil {
+		it.onStall()
+	}
+	<-it.ctx.Done()
+	return provider.StreamChunk{}, it.ctx.Err()
+}
+
+func (it *stallingIterator) Close() error { return nil }
+
+func TestCancelledStreamKeepsPartialReply(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	prov := &stallingProvider{
+		text:    "Partial answ",
+		onStall: cancel,
+		next:    &mockProvider{calls: [][]provider.StreamChunk{textChunks("Fine.")}},
+	}
+	notifier := &mockNotifier{}
+	session := newTestSession(prov, nil, notifier)
+
+	if err := session.processUserMessage(ctx, "Explain"); err != nil {
+		t.Fatalf("cancelled turn returned error: %v", err)
+	}
+	history := session.HistorySnapshot()
+	if len(history) != 2 || history[1].Role != provider.RoleAssistant || history[1].Content != "Partial answ" {
+		t.Fatalf("history = %+v, want the prompt and the partial reply", history)
+	}
+	cancelled := false
+	for _, msg := range notifier.getMessages() {
+		if _, ok := msg.(TurnCancelledEvent); ok {
+			cancelled = true
+		}
+	}
+	if !cancelled {
+		t.Error("expected a TurnCancelledEvent")
+	}
+
+	// The next turn sends a valid, alternating history.
+	if err := session.processUserMessage(t.Context(), "Go on"); err != nil {
+		t.Fatalf("next turn: %v", err)
+	}
+	if got := prov.next.requests[0].Messages; len(got) != 3 || got[2].Content != "Go on" {
+		t.Errorf("next request messages = %+v", got)
+	}
+}
+
+// cancelAfterStopProvider streams a reply up to its stop chunk, usage
+// included, then is cancelled before the stream ends.
+type cancelAfterStopProvider struct {
+	mockProvider
+	cancel context.CancelFunc
+}
+
+func (p *cancelAfterStopProvider) Send(ctx context.Context, req provider.Request) (provider.StreamIterator, error) {
+	iter, err := p.mockProvider.Send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &cancelAfterStopIterator{StreamIterator: iter, ctx: ctx, cancel: p.cancel}, nil
+}
+
+type cancelAfterStopIterator struct {
+	provider.StreamIterator
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (it *cancelAfterStopIterator) Next() (provider.StreamChunk, error) {
+	chunk, err := it.StreamIterator.Next()
+	if err == io.EOF {
+		it.cancel()
+		return provider.StreamChunk{}, it.ctx.Err()
+	}
+	return chunk, err
+}
+
+func TestCancelledTurnRecordsUsage(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	prov := &cancelAfterStopProvider{
+		mockProvider: mockProvider{
+			calls:  [][]provider.StreamChunk{textChunks("Partial")},
+			models: []provider.ModelInfo{{ID: "test-model", InputCostPer1M: 3, OutputCostPer1M: 15}},
+		},
+		cancel: cancel,
+	}
+	tracker := NewTracker(nil, nil)
+	session := NewSession("test-session-id", prov, tracker, &mockNotifier{}, "test-model", "system", 1024, &mockExecutor{}, nil, nil, nil)
+
+	if err := session.processUserMessage(ctx, "Explain"); err != nil {
+		t.Fatalf("cancelled turn returned error: %v", err)
+	}
+	snap := tracker.Snapshot()
+	if snap.TotalInputTokens != 10 || snap.TotalOutputTokens != 5 || snap.TotalCost == 0 {
+		t.Errorf("snapshot = %+v, want the cancelled reply's usage", snap)
+	}
+}
+
+// cancellingExecutor cancels the turn from inside a tool, as pressing Esc
+// while it runs would.
+type cancellingExecutor struct{ cancel context.CancelFunc }
+
+func (e *cancellingExecutor) Execute(ctx context.Context, _ string, _ map[string]any) (string, error) {
+	e.cancel()
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestCancelledToolsGetResults(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	chunks := []provider.StreamChunk{
+		{Event: provider.EventToolStart, ToolCallID: "call_1", ToolName: "slow"},
+		{Event: provider.EventToolEnd},
+		{Event: provider.EventToolStart, ToolCallID: "call_2", ToolName: "slow"},
+		{Event: provider.EventToolEnd},
+		{Event: provider.EventMessageStop, StopReason: "tool_use"},
+	}
+	prov := &mockProvider{calls: [][]provider.StreamChunk{chunks}}
+	session := newTestSession(prov, &cancellingExecutor{cancel: cancel}, &mockNotifier{})
+
+	if err := session.processUserMessage(ctx, "Run it"); err != nil {
+		t.Fatalf("cancelled turn returned error: %v", err)
+	}
+	if len(prov.requests) != 1 {
+		t.Errorf("provider called %d times after cancellation, want 1", len(prov.requests))
+	}
+
+	history := session.HistorySnapshot()
+	if len(history) != 4 {
+		t.Fatalf("history has %d messages, want 4: %+v", len(history), history)
+	}
+	results := history[2].ToolResults
+	if len(results) != 2 {
+		t.Fatalf("tool results = %+v, want one per call", results)
+	}
+	for _, r := range results {
+		if !r.IsError || !strings.Contains(r.Content, "cancelled") {
+			t.Errorf("result for %s = %+v, want a cancelled error", r.ToolUseID, r)
+		}
+	}
+	if last := history[3]; last.Role != provider.RoleAssistant || last.Content != "(Cancelled)" {
+		t.Errorf("last message = %+v, want the cancelled placeholder reply", last)
+	}
+}
+
+func TestCancelTurnStopsOnlyTheCurrentTurn(t *testing.T) {
+	stalled := make(chan struct{})
+	prov := &stallingProvider{
+		onStall: func() { close(stalled) },
+		next:    &mockProvider{calls: [][]provider.StreamChunk{textChunks("Fine.")}},
+	}
+	notifier := &mockNotifier{}
+	session := newTestSession(prov, nil, notifier)
+	session.Start(t.Context())
+	defer session.Stop()
+
+	session.CancelTurn() // no turn yet: no-op
+	session.SubmitMessage("First")
+	<-stalled
+	session.CancelTurn()
+	session.SubmitMessage("Second")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(session.HistorySnapshot()) < 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("turns did not finish: %+v", session.HistorySnapshot())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := session.HistorySnapshot()[3].Content; got != "Fine." {
+		t.Errorf("second turn reply = %q, want %q", got, "Fine.")
+	}
+}
+
+func TestPermissionResolverDecidesPrompts(t *testing.T) {
+	policyPath := filepath.Join(t.TempDir(), "policy.json")
+	eval, err := policy.NewEvaluator(policyPath)
+	if err != nil {
+		t.Fatalf("NewEvaluator: %v", err)
+	}
+	exec := &mockManifestExecutor{manifests: map[string]manifestEntry{
+		"write_file": {agentName: "fs", rules: []manifest.PermissionRule{
+			{Key: mustParsePermissionKey("fs:write"), Mode: manifest.PermissionRequestOnce},
+		}},
+		"fetch": {agentName: "web", rules: []manifest.PermissionRule{
+			{Key: mustParsePermissionKey("net:http"), Mode: manifest.PermissionRequestOnce},
+		}},
+	}}
+	prov := &mockProvider{calls: [][]provider.StreamChunk{
+		toolUseChunks("call_w", "write_file", `{}`),
+		toolUseChunks("call_f", "fetch", `{}`),
+		textChunks("Done."),
+	}}
+	notifier := &mockNotifier{}
+	session := NewSession("test-session-id", prov, NewTracker(nil, nil), notifier, "test-model", "system", 1024, exec, nil, nil, eval)
+	var asked []string
+	session.SetPermissionResolver(func(agentName, permission string, granted bool) bool {
+		asked = append(asked, agentName+" "+permission)
+		return !granted && permission == "fs:write"
+	})
+
+	if err := session.Process(context.Background(), "Write and fetch"); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+
+	history := session.HistorySnapshot()
+	if r := history[2].ToolResults[0]; r.IsError {
+		t.Errorf("write_file result = %+v, want it allowed", r)
+	}
+	if r := history[4].ToolResults[0]; !r.IsError || !strings.Contains(r.Content, "permission not granted: net:http") {
+		t.Errorf("fetch result = %+v, want it refused", r)
+	}
+	if !reflect.DeepEqual(asked, []string{"fs fs:write", "web net:http"}) {
+		t.Errorf("resolver asked about %v", asked)
+	}
+	for _, msg := range notifier.getMessages() {
+		if _, ok := msg.(PermissionRequestEvent); ok {
+			t.Error("resolved permission was also prompted for")
+		}
+	}
+	if _, err := os.Stat(policyPath); !os.IsNotExist(err) {
+		t.Errorf("policy file written (%v), want resolver decisions not remembered", err)
+	}
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"cosmos/core/provider"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TurnOptions adjusts the model requests of one turn: tool choice and
+// sampling. Unset fields fall back to the session defaults, which are
+// changed with /tool-choice, /temperature, /top-p and /stop.
+type TurnOptions struct {
+	// ToolChoice applies to the first request of the turn only. A forced
+	// choice (any or a specific tool) would otherwise make every follow-up
+	// request call a tool again and the turn would never end.
+	ToolChoice *provider.ToolChoice
+
+	Temperature   *float64
+	TopP          *float64
+	StopSequences []string
+}
+
+// userMessage is a queued prompt with its per-turn options.
+type userMessage struct {
+	text string
+	opts TurnOptions
+}
+
+// withDefaults fills unset fields of o from defaults.
+func (o TurnOptions) withDefaults(defaults TurnOptions) TurnOptions {
+	if o.ToolChoice == nil {
+		o.ToolChoice = defaults.ToolChoice
+	}
+	if o.Temperature == nil {
+		o.Temperature = defaults.Temperature
+	}
+	if o.TopP == nil {
+		o.TopP = defaults.TopP
+	}
+	if o.StopSequences == nil {
+		o.StopSequences = defaults.StopSequences
+	}
+	return o
+}
+
+// String renders the options for display, e.g. in reply to /temperature.
+func (o TurnOptions) String() string {
+	choice := string(provider.ToolChoiceAuto)
+	if o.ToolChoice != nil {
+		choice = string(o.ToolChoice.Mode)
+		if o.ToolChoice.Mode == provider.ToolChoiceTool {
+			choice = o.ToolChoice.Name
+		}
+	}
+	stops := "none"
+	if len(o.StopSequences) > 0 {
+		quoted := make([]string, len(o.StopSequences))
+		for i, s := range o.StopSequences {
+			quoted[i] = strconv.Quote(s)
+		}
+		stops = strings.Join(quoted, " ")
+	}
+	return fmt.Sprintf("tool choice: %s · temperature: %s · top-p: %s · stop: %s",
+		choice, formatSampling(o.Temperature), formatSampling(o.TopP), stops)
+}
+
+func formatSampling(v *float64) string {
+	if v == nil {
+		return "default"
+	}
+	return strconv.FormatFloat(*v, 'g', -1, 64)
+}
+
+// validateToolChoice checks that a specific tool choice names a tool
+// available this turn.
+func (s *Session) validateToolChoice(c *provider.ToolChoice) error {
+	if c == nil || c.Mode != provider.ToolChoiceTool {
+		return nil
+	}
+	if !slices.ContainsFunc(s.turnTools(), func(t provider.ToolDefinition) bool { return t.Name == c.Name }) {
+		return fmt.Errorf("unknown tool %q", c.Name)
+	}
+	return nil
+}
+
+// parseToolChoice parses a /tool-choice argument: auto, any, none, or a
+// tool name.
+func parseToolChoice(arg string) *provider.ToolChoice {
+	switch mode := provider.ToolChoiceMode(arg); mode {
+	case provider.ToolChoiceAuto:
+		return nil
+	case provider.ToolChoiceAny, provider.ToolChoiceNone:
+		return &provider.ToolChoice{Mode: mode}
+	default:
+		return &provider.ToolChoice{Mode: provider.ToolChoiceTool, Name: arg}
+	}
+}
+
+// parseSampling parses a /temperature or /top-p argument in [0, limit];
+// "default" clears the setting.
+func parseSampling(arg string, limit float64) (*float64, error) {
+	if arg == "default" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(arg, 64)
+	if err != nil || v < 0 || v > limit {
+		return nil, fmt.Errorf("expected a number between 0 and %g, or \"default\"", limit)
+	}
+	return &v, nil
+}
+
+// parseStopSequences parses a /stop argument: whitespace-separated
+// sequences, each optionally a Go-quoted string so it can hold spaces or
+// escapes like "\n\n". "clear" removes all stop sequences.
+func parseStopSequences(args string) ([]string, error) {
+	if args == "clear" {
+		return nil, nil
+	}
+	var seqs []string
+	for rest := strings.TrimSpace(args); rest != ""; rest = strings.TrimSpace(rest) {
+		if rest[0] != '"' {
+			field, tail, _ := strings.Cut(rest, " ")
+			seqs = append(seqs, field)
+			rest = tail
+			continue
+		}
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return nil, fmt.Errorf("bad quoted stop sequence: %s", rest)
+		}
+		seq, _ := strconv.Unquote(quoted)
+		if seq == "" {
+			return nil, fmt.Errorf("empty stop sequence")
+		}
+		seqs = append(seqs, seq)
+		rest = rest[len(quoted):]
+	}
+	return seqs, nil
+}
+
+// handleToolChoiceCommand processes /tool-choice [auto|any|none|<tool>].
+func (s *Session) handleToolChoiceCommand(args string) error {
+	if args != "" {
+		choice := parseToolChoice(args)
+		if err := s.validateToolChoice(choice); err != nil {
+			s.notifier.Send(ErrorEvent{Error: fmt.Sprintf("/tool-choice: %v (want auto, any, none or a tool name)", err)})
+			return nil
+		}
+		s.turnDefaults.ToolChoice = choice
+	}
+	s.notifier.Send(TurnOptionsEvent{Summary: s.turnDefaults.String()})
+	return nil
+}
+
+// handleSamplingCommand processes /temperature and /top-p: with an
+// argument it sets *target, without one it shows the current settings.
+func (s *Session) handleSamplingCommand(verb, args string, target **float64, limit float64) error {
+	if args != "" {
+		v, err := parseSampling(args, limit)
+		if err != nil {
+			s.notifier.Send(ErrorEvent{Error: fmt.Sprintf("%s: %v", verb, err)})
+			return nil
+		}
+		if v != nil && s.thinkingBudget > 0 {
+			s.notifier.Send(ErrorEvent{Error: fmt.Sprintf("%s: not supported with extended thinking on (thinking_budget = %d)", verb, s.thinkingBudget)})
+			return nil
+		}
+		*target = v
+	}
+	s.notifier.Send(TurnOptionsEvent{Summary: s.turnDefaults.String()})
+	return nil
+}
+
+// handleStopCommand processes /stop [<seq>...|clear].
+func (s *Session) handleStopCommand(args string) error {
+	if args != "" {
+		seqs, err := parseStopSequences(args)
+		if err != nil {
+			s.notifier.Send(ErrorEvent{Error: "/stop: " + err.Error()})
+			return nil
+		}
+		s.turnDefaults.StopSequences = seqs
+	}
+	s.notifier.Send(TurnOptionsEvent{Summary: s.turnDefaults.String()})
+	return nil
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"cosmos/core/provider"
+	"reflect"
+	"testing"
+)
+
+func TestParseToolChoice(t *testing.T) {
+	if got := parseToolChoice("auto"); got != nil {
+		t.Errorf("auto = %+v, want nil", got)
+	}
+	if got := parseToolChoice("any"); got == nil || got.Mode != provider.ToolChoiceAny {
+		t.Errorf("any = %+v", got)
+	}
+	if got := parseToolChoice("readFile"); got == nil || got.Mode != provider.ToolChoiceTool || got.Name != "readFile" {
+		t.Errorf("readFile = %+v", got)
+	}
+}
+
+func TestParseSampling(t *testing.T) {
+	if v, err := parseSampling("0.7", 1); err != nil || *v != 0.7 {
+		t.Errorf("0.7 = %v, %v", v, err)
+	}
+	if v, err := parseSampling("default", 1); err != nil || v != nil {
+		t.Errorf("default = %v, %v; want nil", v, err)
+	}
+	for _, arg := range []string{"-0.1", "1.01", "warm"} {
+		if _, err := parseSampling(arg, 1); err == nil {
+			t.Errorf("parseSampling(%q) should fail", arg)
+		}
+	}
+}
+
+func TestParseStopSequences(t *testing.T) {
+	tests := []struct {
+		args    string
+		want    []string
+		wantErr bool
+	}{
+		{"END", []string{"END"}, false},
+		{`"\n\n" Human:`, []string{"\n\n", "Human:"}, false},
+		{`"two words"`, []string{"two words"}, false},
+		{"clear", nil, false},
+		{`"open`, nil, true},
+		{`""`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseStopSequences(tt.args)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseStopSequences(%q) = %q, %v; want %q", tt.args, got, err, tt.want)
+		}
+	}
+}
+
+func TestTurnOptionsWithDefaults(t *testing.T) {
+	low, high := 0.2, 0.9
+	defaults := TurnOptions{
+		ToolChoice:    &provider.ToolChoice{Mode: provider.ToolChoiceNone},
+		Temperature:   &low,
+		StopSequences: []string{"END"},
+	}
+	got := TurnOptions{Temperature: &high}.withDefaults(defaults)
+	if *got.Temperature != high || got.ToolChoice.Mode != provider.ToolChoiceNone || got.TopP != nil {
+		t.Errorf("withDefaults = %+v", got)
+	}
+	if want := `tool choice: none · temperature: 0.9 · top-p: default · stop: "END"`; got.String() != want {
+		t.Errorf("String() = %q, want %q", got.String(), want)
+	}
+}
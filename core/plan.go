@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"fmt"
+	"strings"
+)
+
+const planModePrompt = `You are in plan mode. Only read-only tools are available: investigate the code as much as you need, but do not try to change anything. Reply with a numbered, step-by-step plan of the changes you intend to make — the files you will create, edit or delete and what changes in each — so the user can review it before you carry it out.`
+
+// executePrompt is the user message that starts executing an accepted plan.
+const executePrompt = "Execute the plan above."
+
+// turnTools returns the tools advertised to the model: all of them, or in
+// plan mode only those isWriteTool classifies as read-only.
+func (s *Session) turnTools() []provider.ToolDefinition {
+	if !s.planMode {
+		return s.tools
+	}
+	var tools []provider.ToolDefinition
+	for _, t := range s.tools {
+		if !s.isWriteTool(t.Name) {
+			tools = append(tools, t)
+		}
+	}
+	return tools
+}
+
+// turnSystem returns the system prompt, extended with the planning
+// instructions in plan mode.
+func (s *Session) turnSystem() string {
+	if !s.planMode {
+		return s.systemMsg
+	}
+	if s.systemMsg == "" {
+		return planModePrompt
+	}
+	return s.systemMsg + "\n\n" + planModePrompt
+}
+
+// handlePlanCommand processes /plan [off|<prompt>]. It switches to plan
+// mode, where the model sees only read-only tools and is asked for a plan,
+// and sends the prompt if one is given. "off" leaves plan mode without
+// executing.
+func (s *Session) handlePlanCommand(ctx context.Context, args string) error {
+	if args == "off" {
+		if s.planMode {
+			s.planMode = false
+			s.notifier.Send(PlanModeEvent{Active: false})
+		}
+		return nil
+	}
+	if !s.planMode {
+		s.planMode = true
+		s.notifier.Send(PlanModeEvent{Active: true})
+	}
+	if args == "" {
+		return nil
+	}
+	return s.processTurn(ctx, args, TurnOptions{})
+}
+
+// handleExecuteCommand processes /execute [<instructions>]. It leaves plan
+// mode, pins the model's last reply as the plan so compaction keeps it,
+// and asks the model to carry it out with all tools.
+func (s *Session) handleExecuteCommand(ctx context.Context, args string) error {
+	if !s.planMode {
+		s.notifier.Send(ErrorEvent{Error: "/execute: not in plan mode (start one with /plan)"})
+		return nil
+	}
+	plan, ok := s.finalAnswer()
+	if !ok || strings.TrimSpace(plan) == "" {
+		s.notifier.Send(ErrorEvent{Error: "/execute: no plan yet — ask for one first"})
+		return nil
+	}
+
+	s.planMode = false
+	s.pinnedPlan = plan
+	s.notifier.Send(PlanModeEvent{Active: false, Executing: true})
+
+	text := executePrompt
+	if args != "" {
+		text = fmt.Sprintf("%s %s", executePrompt, args)
+	}
+	return s.processTurn(ctx, text, TurnOptions{})
+}
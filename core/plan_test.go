@@ -0,0 +1,126 @@
+package core
+
+import (
+	"cosmos/core/provider"
+	"cosmos/engine/manifest"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var planTestTools = []provider.ToolDefinition{{Name: "read_file"}, {Name: "write_file"}, {Name: "delete_file"}}
+
+func newPlanningSession(prov *mockProvider, notifier Notifier) *Session {
+	exec := &mockManifestExecutor{manifests: map[string]manifestEntry{
+		"read_file": {agentName: "fs", rules: []manifest.PermissionRule{
+			{Key: mustParsePermissionKey("fs:read"), Mode: manifest.PermissionAllow},
+		}},
+		"write_file": {agentName: "fs", rules: []manifest.PermissionRule{
+			{Key: mustParsePermissionKey("fs:write"), Mode: manifest.PermissionAllow},
+		}},
+		"delete_file": {agentName: "fs", rules: []manifest.PermissionRule{
+			{Key: mustParsePermissionKey("fs:unlink"), Mode: manifest.PermissionAllow},
+		}},
+	}}
+	return NewSession("test-session-id", prov, NewTracker(nil, nil), notifier, "test-model", "system", 1024, exec, planTestTools, nil, nil)
+}
+
+func toolNames(tools []provider.ToolDefinition) []string {
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		names[i] = t.Name
+	}
+	return names
+}
+
+func TestPlanModeAdvertisesOnlyReadOnlyTools(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{
+		toolUseChunks("call_r", "read_file", `{}`),
+		toolUseChunks("call_w", "write_file", `{}`),
+		textChunks("1. Edit main.go"),
+	}}
+	notifier := &mockNotifier{}
+	session := newPlanningSession(prov, notifier)
+
+	if err := session.processUserMessage(t.Context(), "/plan Rename the flag"); err != nil {
+		t.Fatalf("/plan: %v", err)
+	}
+
+	for i, req := range prov.requests {
+		if got := toolNames(req.Tools); len(got) != 1 || got[0] != "read_file" {
+			t.Errorf("request %d tools = %v, want only read_file", i, got)
+		}
+		if !strings.HasPrefix(req.System, "system\n\n") || !strings.Contains(req.System, "plan mode") {
+			t.Errorf("request %d system = %q, want the plan-mode prompt appended", i, req.System)
+		}
+	}
+
+	history := session.HistorySnapshot()
+	if r := history[2].ToolResults[0]; r.IsError {
+		t.Errorf("read_file result = %+v, want it to run", r)
+	}
+	if r := history[4].ToolResults[0]; !r.IsError || !strings.Contains(r.Content, "not available in plan mode") {
+		t.Errorf("write_file result = %+v, want it refused", r)
+	}
+
+	var entered bool
+	for _, msg := range notifier.getMessages() {
+		if e, ok := msg.(PlanModeEvent); ok {
+			entered = e.Active
+		}
+	}
+	if !entered {
+		t.Error("expected a PlanModeEvent entering plan mode")
+	}
+}
+
+func TestExecutePinsThePlan(t *testing.T) {
+	prov := &mockProvider{calls: [][]provider.StreamChunk{
+		textChunks("1. Edit main.go"),
+		toolUseChunks("call_w", "write_file", `{}`),
+		textChunks("Done."),
+	}}
+	notifier := &mockNotifier{}
+	session := newPlanningSession(prov, notifier)
+
+	if err := session.processUserMessage(t.Context(), "/execute"); err != nil {
+		t.Fatalf("/execute: %v", err)
+	}
+	if len(prov.requests) != 0 {
+		t.Fatal("/execute outside plan mode sent a request")
+	}
+
+	for _, text := range []string{"/plan", "Rename the flag", "/execute keep the old name as an alias"} {
+		if err := session.processUserMessage(t.Context(), text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+
+	if session.planMode || session.pinnedPlan != "1. Edit main.go" {
+		t.Errorf("planMode = %v, pinnedPlan = %q after /execute", session.planMode, session.pinnedPlan)
+	}
+	exec := prov.requests[1]
+	if got := toolNames(exec.Tools); len(got) != len(planTestTools) {
+		t.Errorf("execute tools = %v, want all of them", got)
+	}
+	if exec.System != "system" {
+		t.Errorf("execute system = %q, want the plain prompt", exec.System)
+	}
+	if last := exec.Messages[len(exec.Messages)-1]; last.Content != executePrompt+" keep the old name as an alias" {
+		t.Errorf("execute message = %q", last.Content)
+	}
+	if r := session.HistorySnapshot()[4].ToolResults[0]; r.IsError {
+		t.Errorf("write_file result = %+v, want it to run after /execute", r)
+	}
+
+	// Compaction keeps the plan once it falls out of the recent messages.
+	for i := range 4 {
+		session.history = append(session.history,
+			provider.Message{Role: provider.RoleUser, Content: fmt.Sprintf("Message %d", i)},
+			provider.Message{Role: provider.RoleAssistant, Content: "ok"})
+	}
+	compacted := session.buildCompactedHistory("Summary...")
+	if !strings.Contains(compacted[0].Content, "1. Edit main.go") {
+		t.Errorf("summary message = %q, want the pinned plan", compacted[0].Content)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Source identifies what triggered the LLM call.
@@ -17,37 +18,48 @@ const SourcePrompt Source = "prompt"
 
 // SourceUsage holds token counts and cost for one source within a model.
 type SourceUsage struct {
-	Source       Source
-	InputTokens  int
-	OutputTokens int
-	Cost         float64
+	Source           Source
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+	Cost             float64
 }
 
 // ModelUsage holds cumulative token counts and cost for one model.
 type ModelUsage struct {
-	ModelID         string
-	ModelName       string
-	InputTokens     int
-	OutputTokens    int
-	Cost            float64
-	InputCostPer1M  float64
-	OutputCostPer1M float64
-	ContextWindow   int // Context window size for percentage calculation
-	Sources         []SourceUsage
+	ModelID             string
+	ModelName           string
+	InputTokens         int
+	OutputTokens        int
+	CacheReadTokens     int
+	CacheWriteTokens    int
+	Cost                float64
+	InputCostPer1M      float64
+	OutputCostPer1M     float64
+	CacheReadCostPer1M  float64 // effective rate (falls back to InputCostPer1M)
+	CacheWriteCostPer1M float64 // effective rate (falls back to InputCostPer1M)
+	ContextWindow       int     // Context window size for percentage calculation
+	Sources             []SourceUsage
 }
 
 // CostSnapshot is a point-in-time, deep-copied view of all accumulated usage.
 type CostSnapshot struct {
-	TotalInputTokens  int
-	TotalOutputTokens int
-	TotalCost         float64
-	Models            []ModelUsage
-	formatter         *CurrencyFormatter // set by Tracker during snapshot
+	TotalInputTokens      int
+	TotalOutputTokens     int
+	TotalCacheReadTokens  int
+	TotalCacheWriteTokens int
+	TotalCost             float64
+	Models                []ModelUsage
+	formatter             *CurrencyFormatter // set by Tracker during snapshot
 }
 
 type sourceAccum struct {
-	inputTokens  int
-	outputTokens int
+	inputTokens      int
+	outputTokens     int
+	cacheReadTokens  int
+	cacheWriteTokens int
+	cost             float64 // priced per request, as rates vary with size and tier
 }
 
 type modelAccum struct {
@@ -61,6 +73,9 @@ type Tracker struct {
 	models    map[string]*modelAccum // keyed by ModelInfo.ID
 	onUpdate  func(CostSnapshot)     // optional callback, nil-safe
 	formatter *CurrencyFormatter     // nil-safe, defaults to USD
+	budget    budgetState
+	ledger    *Ledger          // nil-safe; set via SetLedger
+	clock     func() time.Time // time.Now; replaced in tests
 }
 
 // NewTracker creates a new cost tracker. The onUpdate callback, if non-nil,
@@ -71,11 +86,22 @@ func NewTracker(onUpdate func(CostSnapshot), formatter *CurrencyFormatter) *Trac
 		models:    make(map[string]*modelAccum),
 		onUpdate:  onUpdate,
 		formatter: formatter,
+		clock:     time.Now,
 	}
 }
 
+// SetLedger persists every subsequent Record to ledger.
+func (t *Tracker) SetLedger(ledger *Ledger) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ledger = ledger
+}
+
 // Record accumulates token usage for the given model and source,
 // then invokes the onUpdate callback (if set) with a fresh snapshot.
+// The usage is priced at the rates that apply to this request: its service
+// tier's, or long-context rates if its input exceeds the model's threshold.
+// It is appended to the ledger, if one is set.
 func (t *Tracker) Record(model provider.ModelInfo, usage provider.Usage, source Source) {
 	t.mu.Lock()
 
@@ -96,13 +122,33 @@ func (t *Tracker) Record(model provider.ModelInfo, usage provider.Usage, source
 
 	sa.inputTokens += usage.InputTokens
 	sa.outputTokens += usage.OutputTokens
+	sa.cacheReadTokens += usage.CacheReadTokens
+	sa.cacheWriteTokens += usage.CacheWriteTokens
+	cost := usageCost(ratesFor(model, usage), usage)
+	sa.cost += cost
+	now := t.clock()
+	t.budget.add(cost, now)
 
 	var snap CostSnapshot
 	if t.onUpdate != nil {
 		snap = t.snapshotLocked()
 	}
+	ledger := t.ledger
 	t.mu.Unlock()
 
+	if ledger != nil {
+		ledger.record(LedgerEntry{
+			Time:             now.UTC(),
+			Model:            model.ID,
+			Source:           source,
+			InputTokens:      usage.InputTokens,
+			OutputTokens:     usage.OutputTokens,
+			CacheReadTokens:  usage.CacheReadTokens,
+			CacheWriteTokens: usage.CacheWriteTokens,
+			CostUSD:          cost,
+		})
+	}
+
 	if t.onUpdate != nil {
 		t.onUpdate(snap)
 	}
@@ -127,25 +173,28 @@ func (t *Tracker) snapshotLocked() CostSnapshot {
 		mu.InputCostPer1M = ma.info.InputCostPer1M
 		mu.OutputCostPer1M = ma.info.OutputCostPer1M
 		mu.ContextWindow = ma.info.ContextWindow
+		mu.CacheReadCostPer1M, mu.CacheWriteCostPer1M = cacheRates(standardRates(ma.info))
 
 		for src, sa := range ma.sources {
-			srcCost := float64(sa.inputTokens)*ma.info.InputCostPer1M/1_000_000 +
-				float64(sa.outputTokens)*ma.info.OutputCostPer1M/1_000_000
 			mu.Sources = append(mu.Sources, SourceUsage{
-				Source:       src,
-				InputTokens:  sa.inputTokens,
-				OutputTokens: sa.outputTokens,
-				Cost:         srcCost,
+				Source:           src,
+				InputTokens:      sa.inputTokens,
+				OutputTokens:     sa.outputTokens,
+				CacheReadTokens:  sa.cacheReadTokens,
+				CacheWriteTokens: sa.cacheWriteTokens,
+				Cost:             sa.cost,
 			})
 			mu.InputTokens += sa.inputTokens
 			mu.OutputTokens += sa.outputTokens
+			mu.CacheReadTokens += sa.cacheReadTokens
+			mu.CacheWriteTokens += sa.cacheWriteTokens
+			mu.Cost += sa.cost
 		}
 
-		mu.Cost = float64(mu.InputTokens)*ma.info.InputCostPer1M/1_000_000 +
-			float64(mu.OutputTokens)*ma.info.OutputCostPer1M/1_000_000
-
 		snap.TotalInputTokens += mu.InputTokens
 		snap.TotalOutputTokens += mu.OutputTokens
+		snap.TotalCacheReadTokens += mu.CacheReadTokens
+		snap.TotalCacheWriteTokens += mu.CacheWriteTokens
 		snap.TotalCost += mu.Cost
 		snap.Models = append(snap.Models, mu)
 	}
@@ -153,6 +202,53 @@ func (t *Tracker) snapshotLocked() CostSnapshot {
 	return snap
 }
 
+// standardRates returns the model's standard-tier, short-context rates.
+func standardRates(info provider.ModelInfo) provider.Rates {
+	return provider.Rates{
+		InputCostPer1M:      info.InputCostPer1M,
+		OutputCostPer1M:     info.OutputCostPer1M,
+		CacheReadCostPer1M:  info.CacheReadCostPer1M,
+		CacheWriteCostPer1M: info.CacheWriteCostPer1M,
+	}
+}
+
+// ratesFor returns the rates one request is billed at: those of the service
+// tier that served it if the model prices that tier, otherwise long-context
+// rates when its input exceeds the model's threshold, otherwise the
+// standard rates.
+func ratesFor(info provider.ModelInfo, usage provider.Usage) provider.Rates {
+	if rates, ok := info.Tiers[usage.ServiceTier]; ok && usage.ServiceTier != "" {
+		return rates
+	}
+	if info.LongContext != nil && info.LongContextThreshold > 0 && usage.TotalInputTokens() > info.LongContextThreshold {
+		return *info.LongContext
+	}
+	return standardRates(info)
+}
+
+// usageCost prices one request's usage at rates.
+func usageCost(rates provider.Rates, usage provider.Usage) float64 {
+	read, write := cacheRates(rates)
+	return float64(usage.InputTokens)*rates.InputCostPer1M/1_000_000 +
+		float64(usage.OutputTokens)*rates.OutputCostPer1M/1_000_000 +
+		float64(usage.CacheReadTokens)*read/1_000_000 +
+		float64(usage.CacheWriteTokens)*write/1_000_000
+}
+
+// cacheRates returns the per-1M prices for prompt-cache reads and writes.
+// Models without cache pricing bill cached tokens at the plain input rate,
+// which over- rather than under-reports cost.
+func cacheRates(rates provider.Rates) (read, write float64) {
+	read, write = rates.CacheReadCostPer1M, rates.CacheWriteCostPer1M
+	if read == 0 {
+		read = rates.InputCostPer1M
+	}
+	if write == 0 {
+		write = rates.InputCostPer1M
+	}
+	return read, write
+}
+
 // formatCount formats a token count with K/M abbreviations.
 // Rules: 0–999 as-is, 1K–999K with one decimal (drop .0), 1M+ same pattern.
 // Guard: if rounding would produce "1000.0K", display "1M" instead.
@@ -178,8 +274,14 @@ func formatCount(n int) string {
 }
 
 // FormatTokens formats the total token counts as "▲<input> ▼<output>".
+// When prompt caching was used, cached input is appended as "↺<read+write>"
+// so the status bar shows how much of the prompt was served from cache.
 func (s CostSnapshot) FormatTokens() string {
-	return fmt.Sprintf("▲%s ▼%s", formatCount(s.TotalInputTokens), formatCount(s.TotalOutputTokens))
+	out := fmt.Sprintf("▲%s ▼%s", formatCount(s.TotalInputTokens), formatCount(s.TotalOutputTokens))
+	if cached := s.TotalCacheReadTokens + s.TotalCacheWriteTokens; cached > 0 {
+		out += " ↺" + formatCount(cached)
+	}
+	return out
 }
 
 // FormatCost formats the total cost in the configured display currency.
@@ -36,6 +36,18 @@ func TestFormatTokens(t *testing.T) {
 	}
 }
 
+func TestFormatTokensWithCache(t *testing.T) {
+	snap := CostSnapshot{
+		TotalInputTokens:      1200,
+		TotalOutputTokens:     800,
+		TotalCacheReadTokens:  40_000,
+		TotalCacheWriteTokens: 2_000,
+	}
+	if got, want := snap.FormatTokens(), "▲1.2K ▼800 ↺42K"; got != want {
+		t.Errorf("FormatTokens = %q, want %q", got, want)
+	}
+}
+
 func TestFormatCost(t *testing.T) {
 	tests := []struct {
 		cost float64
@@ -100,6 +112,85 @@ func TestRecordSingleModel(t *testing.T) {
 	}
 }
 
+func TestRecordCachedTokens(t *testing.T) {
+	tracker := NewTracker(nil, nil)
+
+	model := modelInfo("sonnet-4", "Claude Sonnet 4", 3.0, 15.0)
+	model.CacheReadCostPer1M = 0.3
+	model.CacheWriteCostPer1M = 3.75
+	tracker.Record(model, provider.Usage{
+		InputTokens: 1000, OutputTokens: 100, CacheReadTokens: 100_000, CacheWriteTokens: 10_000,
+	}, SourcePrompt)
+
+	snap := tracker.Snapshot()
+	if snap.TotalCacheReadTokens != 100_000 || snap.TotalCacheWriteTokens != 10_000 {
+		t.Errorf("cache totals = %d/%d, want 100000/10000", snap.TotalCacheReadTokens, snap.TotalCacheWriteTokens)
+	}
+
+	// 1000*3 + 100*15 + 100000*0.3 + 10000*3.75 = 3000 + 1500 + 30000 + 37500 = 72000 per 1M
+	wantCost := 0.072
+	if diff := snap.TotalCost - wantCost; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("TotalCost = %f, want %f", snap.TotalCost, wantCost)
+	}
+	if src := snap.Models[0].Sources[0]; src.CacheReadTokens != 100_000 || src.Cost != snap.TotalCost {
+		t.Errorf("source usage = %+v", src)
+	}
+}
+
+func TestRecordCachedTokensWithoutCachePricing(t *testing.T) {
+	tracker := NewTracker(nil, nil)
+
+	// No cache prices: cached tokens are billed at the input rate.
+	model := modelInfo("custom", "Custom", 2.0, 0)
+	tracker.Record(model, provider.Usage{CacheReadTokens: 500_000, CacheWriteTokens: 500_000}, SourcePrompt)
+
+	snap := tracker.Snapshot()
+	if diff := snap.TotalCost - 2.0; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("TotalCost = %f, want 2.0", snap.TotalCost)
+	}
+	if m := snap.Models[0]; m.CacheReadCostPer1M != 2.0 || m.CacheWriteCostPer1M != 2.0 {
+		t.Errorf("effective cache rates = %f/%f, want 2.0/2.0", m.CacheReadCostPer1M, m.CacheWriteCostPer1M)
+	}
+}
+
+func TestRecordLongContextRates(t *testing.T) {
+	tracker := NewTracker(nil, nil)
+
+	model := modelInfo("sonnet-4", "Claude Sonnet 4", 3.0, 15.0)
+	model.LongContextThreshold = 200_000
+	model.LongContext = &provider.Rates{InputCostPer1M: 6.0, OutputCostPer1M: 22.5, CacheReadCostPer1M: 0.6}
+
+	// Exactly at the threshold: standard rates. 200000*3 + 1000*15 = 615000 per 1M.
+	tracker.Record(model, provider.Usage{InputTokens: 200_000, OutputTokens: 1000}, SourcePrompt)
+	// Above it, counting cached input: the whole request at long-context
+	// rates. 1000*6 + 1000*22.5 + 300000*0.6 = 208500 per 1M.
+	tracker.Record(model, provider.Usage{InputTokens: 1000, OutputTokens: 1000, CacheReadTokens: 300_000}, SourcePrompt)
+
+	snap := tracker.Snapshot()
+	wantCost := 0.615 + 0.2085
+	if diff := snap.TotalCost - wantCost; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("TotalCost = %f, want %f", snap.TotalCost, wantCost)
+	}
+	if m := snap.Models[0]; m.InputCostPer1M != 3.0 || m.CacheReadCostPer1M != 3.0 {
+		t.Errorf("displayed rates = %f/%f, want standard 3.0/3.0", m.InputCostPer1M, m.CacheReadCostPer1M)
+	}
+}
+
+func TestRecordServiceTierRates(t *testing.T) {
+	tracker := NewTracker(nil, nil)
+
+	model := modelInfo("sonnet-4", "Claude Sonnet 4", 3.0, 15.0)
+	model.Tiers = map[string]provider.Rates{"flex": {InputCostPer1M: 1.5, OutputCostPer1M: 7.5}}
+
+	tracker.Record(model, provider.Usage{InputTokens: 1_000_000, ServiceTier: "flex"}, SourcePrompt)
+	// A tier the model has no prices for falls back to the standard rates.
+	tracker.Record(model, provider.Usage{InputTokens: 1_000_000, ServiceTier: "priority"}, SourcePrompt)
+
+	if got := tracker.Snapshot().TotalCost; got != 4.5 {
+		t.Errorf("TotalCost = %f, want 4.5 (1.5 flex + 3.0 standard)", got)
+	}
+}
+
 func TestRecordMultipleModels(t *testing.T) {
 	tracker := NewTracker(nil, nil)
 
@@ -31,6 +31,29 @@ type Message struct {
 	Content     string
 	ToolCalls   []ToolCall
 	ToolResults []ToolResult
+
+	// Blocks holds attachments (images, documents) sent ahead of Content,
+	// e.g. files the user referenced with @path in the prompt.
+	Blocks []ContentBlock `json:",omitempty"`
+
+	// Reasoning holds the model's thinking blocks for an assistant turn.
+	// They must be sent back unchanged (signatures included) on later
+	// requests, or providers reject multi-turn tool use with thinking enabled.
+	Reasoning []ReasoningBlock `json:",omitempty"`
+
+	// CachePoint marks a prompt-cache breakpoint after this message: the
+	// conversation prefix up to and including it may be cached by providers
+	// that support prompt caching. Ignored by providers that do not.
+	CachePoint bool `json:",omitempty"`
+}
+
+// ReasoningBlock is one block of extended-thinking output. A block is either
+// readable text with a signature, or Redacted content that the provider
+// encrypted and only the provider can read.
+type ReasoningBlock struct {
+	Text      string `json:",omitempty"`
+	Signature string `json:",omitempty"`
+	Redacted  []byte `json:",omitempty"`
 }
 
 // ToolCall represents the LLM requesting a tool invocation.
@@ -45,8 +68,46 @@ type ToolResult struct {
 	ToolUseID string
 	Content   string
 	IsError   bool
+
+	// Blocks carries non-text output, such as images read by the tool.
+	// Sent after Content.
+	Blocks []ContentBlock `json:",omitempty"`
 }
 
+// ContentType identifies the kind of a ContentBlock.
+type ContentType string
+
+const (
+	ContentText     ContentType = "text"
+	ContentImage    ContentType = "image"
+	ContentDocument ContentType = "document"
+)
+
+// ContentBlock is one typed piece of message content. Text blocks use Text;
+// image and document blocks carry raw bytes in Data with their MediaType.
+type ContentBlock struct {
+	Type      ContentType
+	Text      string `json:",omitempty"`
+	MediaType string `json:",omitempty"` // e.g. "image/png", "application/pdf"
+	Name      string `json:",omitempty"` // file name; required by some providers for documents
+	Data      []byte `json:",omitempty"`
+}
+
+// MediaTypes maps lower-case file extensions to the image and document media
+// types providers accept in ContentBlocks.
+var MediaTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".pdf":  "application/pdf",
+}
+
+// MaxMediaBytes is the largest image or document accepted as a ContentBlock.
+// Providers reject larger payloads, so callers check before reading.
+const MaxMediaBytes = 5 << 20
+
 // ToolDefinition describes a tool the LLM can invoke.
 // InputSchema is a JSON Schema object built from manifest function params.
 type ToolDefinition struct {
@@ -64,33 +125,86 @@ const (
 	EventToolDelta                      // Partial tool input JSON
 	EventToolEnd                        // Tool invocation block complete
 	EventMessageStop                    // Response finished
+
+	EventReasoningDelta     // Partial reasoning text
+	EventReasoningSignature // Signature closing the current reasoning block
+	EventReasoningRedacted  // Complete redacted reasoning block
 )
 
 // StreamChunk is one unit of streamed LLM output.
 // Fields are relevant per event type; others are zero-valued.
 type StreamChunk struct {
 	Event      StreamEvent
-	Text       string // EventTextDelta
+	Text       string // EventTextDelta, EventReasoningDelta
 	ToolCallID string // EventToolStart
 	ToolName   string // EventToolStart
 	InputDelta string // EventToolDelta: partial JSON fragment
 	StopReason string // EventMessageStop: "end_turn", "tool_use"
 	Usage      *Usage // Set on EventMessageStop
+
+	// Model is set on EventMessageStop when a different model than the
+	// requested one produced the response (e.g. a routing fallback).
+	Model string `json:",omitempty"`
+
+	Signature string `json:",omitempty"` // EventReasoningSignature
+	Redacted  []byte `json:",omitempty"` // EventReasoningRedacted
 }
 
 // Usage holds token counts from a single LLM response.
+// InputTokens counts only uncached input; prompt-cache reads and writes are
+// reported separately because they are billed at different rates.
 type Usage struct {
-	InputTokens  int
-	OutputTokens int
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int `json:",omitempty"`
+	CacheWriteTokens int `json:",omitempty"`
+
+	// ServiceTier is the tier that served the request when the provider
+	// reports one other than standard, e.g. "priority", "flex" or "batch".
+	ServiceTier string `json:",omitempty"`
+}
+
+// TotalInputTokens returns all input tokens processed for the request,
+// cached or not. This is the figure that counts against the context window.
+func (u Usage) TotalInputTokens() int {
+	return u.InputTokens + u.CacheReadTokens + u.CacheWriteTokens
 }
 
 // ModelInfo describes a model's metadata and pricing.
 type ModelInfo struct {
-	ID              string  // Provider-specific model identifier
-	Name            string  // Human-readable display name
+	ID              string // Provider-specific model identifier
+	Name            string // Human-readable display name
 	ContextWindow   int
 	InputCostPer1M  float64
 	OutputCostPer1M float64
+
+	// MaxOutputTokens is the most the model can generate in one response.
+	// Zero means unknown.
+	MaxOutputTokens int `json:",omitempty"`
+
+	// Prompt-cache pricing. Zero means unknown; the tracker then bills
+	// cached tokens at InputCostPer1M.
+	CacheReadCostPer1M  float64 `json:",omitempty"`
+	CacheWriteCostPer1M float64 `json:",omitempty"`
+
+	// Long-context pricing. A request whose total input exceeds
+	// LongContextThreshold tokens is billed entirely at LongContext rates
+	// (e.g. Claude Sonnet 4 above 200K). Zero means no long-context pricing.
+	LongContextThreshold int    `json:",omitempty"`
+	LongContext          *Rates `json:",omitempty"`
+
+	// Tiers holds rates for service tiers other than standard, keyed by
+	// the name reported in Usage.ServiceTier ("priority", "flex", "batch").
+	Tiers map[string]Rates `json:",omitempty"`
+}
+
+// Rates are prices in USD per million tokens. Zero cache rates bill cached
+// tokens at InputCostPer1M.
+type Rates struct {
+	InputCostPer1M      float64
+	OutputCostPer1M     float64
+	CacheReadCostPer1M  float64 `json:",omitempty"`
+	CacheWriteCostPer1M float64 `json:",omitempty"`
 }
 
 // Request bundles everything sent to the LLM for one round-trip.
@@ -100,6 +214,44 @@ type Request struct {
 	Messages  []Message
 	Tools     []ToolDefinition
 	MaxTokens int
+
+	// Prompt-cache breakpoints after the system prompt and after the tool
+	// definitions. Per-turn breakpoints are set with Message.CachePoint.
+	CacheSystem bool `json:",omitempty"`
+	CacheTools  bool `json:",omitempty"`
+
+	// ThinkingBudget enables extended thinking with the given token budget;
+	// 0 disables it. Providers raise MaxTokens above the budget if needed.
+	ThinkingBudget int `json:",omitempty"`
+
+	// ToolChoice constrains tool use; nil leaves the decision to the model.
+	ToolChoice *ToolChoice `json:",omitempty"`
+
+	// Sampling parameters. nil or empty uses the provider's default.
+	Temperature   *float64 `json:",omitempty"`
+	TopP          *float64 `json:",omitempty"`
+	StopSequences []string `json:",omitempty"`
+}
+
+// ToolChoiceMode selects how the model may use tools.
+type ToolChoiceMode string
+
+const (
+	ToolChoiceAuto ToolChoiceMode = "auto" // Model decides (the default)
+	ToolChoiceAny  ToolChoiceMode = "any"  // Model must call some tool
+	ToolChoiceNone ToolChoiceMode = "none" // Model must not call tools
+	ToolChoiceTool ToolChoiceMode = "tool" // Model must call the tool named Name
+)
+
+// ToolChoice constrains which tools the model calls in its response.
+type ToolChoice struct {
+	Mode ToolChoiceMode
+	Name string `json:",omitempty"` // ToolChoiceTool only
+}
+
+// Forces reports whether the choice requires the model to call a tool.
+func (c *ToolChoice) Forces() bool {
+	return c != nil && (c.Mode == ToolChoiceAny || c.Mode == ToolChoiceTool)
 }
 
 // StreamIterator provides token-by-token iteration over a streamed response.
@@ -115,6 +267,14 @@ type Provider interface {
 	ListModels(ctx context.Context) ([]ModelInfo, error)
 }
 
+// TokenCounter is implemented by providers that can count the input tokens
+// of a request exactly, without running it. Callers check for it with a type
+// assertion. Providers that wrap another provider implement it by delegation
+// and return errors.ErrUnsupported when the wrapped one cannot count.
+type TokenCounter interface {
+	CountTokens(ctx context.Context, req Request) (int, error)
+}
+
 // PricingConfig holds provider-agnostic settings for dynamic pricing.
 // Passed to provider constructors to decouple providers from the application config.
 type PricingConfig struct {
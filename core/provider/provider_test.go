@@ -153,3 +153,13 @@ func TestStreamChunkPerEvent(t *testing.T) {
 		t.Errorf("Usage: got input=%d output=%d", stop.Usage.InputTokens, stop.Usage.OutputTokens)
 	}
 }
+
+func TestUsageTotalInputTokens(t *testing.T) {
+	u := Usage{InputTokens: 100, OutputTokens: 50, CacheReadTokens: 2000, CacheWriteTokens: 300}
+	if got := u.TotalInputTokens(); got != 2400 {
+		t.Errorf("TotalInputTokens() = %d, want 2400", got)
+	}
+	if got := (Usage{InputTokens: 7}).TotalInputTokens(); got != 7 {
+		t.Errorf("TotalInputTokens() without cache = %d, want 7", got)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -22,13 +23,34 @@ type SavedSession struct {
 	Description string             `json:"description"` // first user msg, ≤100 chars
 	History     []provider.Message `json:"history"`
 	Usage       SavedUsage         `json:"usage"`
+
+	// Branches of the conversation, if it forked; History is the active
+	// branch, Branches[Branch].
+	Branches []Branch `json:"branches,omitempty"`
+	Branch   int      `json:"branch,omitempty"`
 }
 
 // SavedUsage holds token/cost totals for a saved session.
 type SavedUsage struct {
-	InputTokens  int     `json:"inputTokens"`
-	OutputTokens int     `json:"outputTokens"`
-	TotalCostUSD float64 `json:"totalCostUSD"`
+	InputTokens      int     `json:"inputTokens"`
+	OutputTokens     int     `json:"outputTokens"`
+	CacheReadTokens  int     `json:"cacheReadTokens,omitempty"`
+	CacheWriteTokens int     `json:"cacheWriteTokens,omitempty"`
+	TotalCostUSD     float64 `json:"totalCostUSD"`
+
+	// Display currency and its rate from USD when the session was saved,
+	// so its cost renders the same later whatever the rate is then.
+	Currency     string  `json:"currency,omitempty"`
+	ExchangeRate float64 `json:"exchangeRate,omitempty"`
+}
+
+// FormatCost formats the total cost in the currency and at the rate the
+// session was saved with. Sessions saved without a rate render in USD.
+func (u SavedUsage) FormatCost() string {
+	if u.Currency == "" || u.ExchangeRate <= 0 {
+		return DefaultCurrencyFormatter().Format(u.TotalCostUSD)
+	}
+	return NewCurrencyFormatter(u.Currency, CurrencySymbol(u.Currency), u.ExchangeRate).Format(u.TotalCostUSD)
 }
 
 // SessionInfo is a lightweight summary of a saved session (history not loaded).
@@ -48,31 +70,31 @@ func SaveSession(s *Session, tracker *Tracker, sessionsDir, workDir string) erro
 	model := s.model
 	sessionID := s.id
 	createdAt := s.createdAt
+	var branches []Branch
+	if len(s.branches) > 0 {
+		s.syncBranch()
+		branches = slices.Clone(s.branches)
+	}
+	activeBranch := s.activeBranch
 	s.mu.Unlock()
 
 	if len(history) == 0 {
 		return nil // Nothing to save
 	}
 
-	// Build description from first user message (≤100 runes)
-	desc := ""
-	for _, msg := range history {
-		if msg.Role == provider.RoleUser && msg.Content != "" {
-			desc = msg.Content
-			if runes := []rune(desc); len(runes) > 100 {
-				desc = string(runes[:97]) + "..."
-			}
-			break
-		}
-	}
-
 	var usage SavedUsage
 	if tracker != nil {
 		snap := tracker.Snapshot()
 		usage = SavedUsage{
-			InputTokens:  snap.TotalInputTokens,
-			OutputTokens: snap.TotalOutputTokens,
-			TotalCostUSD: snap.TotalCost,
+			InputTokens:      snap.TotalInputTokens,
+			OutputTokens:     snap.TotalOutputTokens,
+			CacheReadTokens:  snap.TotalCacheReadTokens,
+			CacheWriteTokens: snap.TotalCacheWriteTokens,
+			TotalCostUSD:     snap.TotalCost,
+		}
+		if f := snap.formatter; f != nil {
+			usage.Currency = f.Code
+			usage.ExchangeRate = f.Rate
 		}
 	}
 
@@ -83,13 +105,50 @@ func SaveSession(s *Session, tracker *Tracker, sessionsDir, workDir string) erro
 		WorkDir:     workDir,
 		CreatedAt:   createdAt,
 		SavedAt:     time.Now().UTC(),
-		Description: desc,
+		Description: describeHistory(history),
 		History:     history,
 		Usage:       usage,
+		Branches:    branches,
+		Branch:      activeBranch,
+	}
+	return writeSavedSession(saved, sessionsDir)
+}
+
+// SaveRecoveredSession persists an unfinished session recovered from its
+// journal, e.g. one the user chose not to resume, so /restore can load it.
+func SaveRecoveredSession(r RecoveredSession, sessionsDir string) error {
+	return writeSavedSession(SavedSession{
+		Version:     1,
+		SessionID:   r.SessionID,
+		Model:       r.Model,
+		WorkDir:     r.WorkDir,
+		CreatedAt:   r.StartedAt,
+		SavedAt:     r.UpdatedAt,
+		Description: r.Description(),
+		History:     r.History,
+	}, sessionsDir)
+}
+
+// describeHistory returns the first user message, ≤100 runes, as the
+// description of a saved session.
+func describeHistory(history []provider.Message) string {
+	for _, msg := range history {
+		if msg.Role == provider.RoleUser && msg.Content != "" {
+			desc := msg.Content
+			if runes := []rune(desc); len(runes) > 100 {
+				desc = string(runes[:97]) + "..."
+			}
+			return desc
+		}
 	}
+	return ""
+}
 
+// writeSavedSession writes saved to sessionsDir, named after its work
+// directory and save time.
+func writeSavedSession(saved SavedSession, sessionsDir string) error {
 	// Filename: <base(workDir)>-<timestamp>.json
-	base := filepath.Base(workDir)
+	base := filepath.Base(saved.WorkDir)
 	if base == "" || base == "." {
 		base = "cosmos"
 	}
@@ -73,6 +73,36 @@ func TestSaveAndLoadSession_RoundTrip(t *testing.T) {
 	}
 }
 
+func TestSaveSession_StoresExchangeRate(t *testing.T) {
+	dir := t.TempDir()
+	prov := &mockProvider{calls: [][]provider.StreamChunk{textChunks("Reply!")}}
+	session := newTestSession(prov, nil, &mockNotifier{})
+	if err := session.processUserMessage(t.Context(), "Hello"); err != nil {
+		t.Fatalf("processUserMessage failed: %v", err)
+	}
+
+	tracker := NewTracker(nil, NewCurrencyFormatter("EUR", "€", 0.5))
+	tracker.Record(modelInfo("m", "M", 1_000_000, 0), provider.Usage{InputTokens: 3}, SourcePrompt)
+	if err := SaveSession(session, tracker, dir, "/projects/cosmos"); err != nil {
+		t.Fatalf("SaveSession failed: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	loaded, err := LoadSavedSession(dir, entries[0].Name())
+	if err != nil {
+		t.Fatalf("LoadSavedSession failed: %v", err)
+	}
+	if loaded.Usage.Currency != "EUR" || loaded.Usage.ExchangeRate != 0.5 {
+		t.Errorf("saved currency = %q at %v, want EUR at 0.5", loaded.Usage.Currency, loaded.Usage.ExchangeRate)
+	}
+	// Rendered at the saved rate, not today's.
+	if got := loaded.Usage.FormatCost(); got != "€ 1.50" {
+		t.Errorf("FormatCost() = %q, want %q", got, "€ 1.50")
+	}
+	if got := (SavedUsage{TotalCostUSD: 3}).FormatCost(); got != "$ 3.00" {
+		t.Errorf("FormatCost() without a rate = %q, want USD", got)
+	}
+}
+
 func TestSaveSession_DescriptionTruncation(t *testing.T) {
 	dir := t.TempDir()
 	prov := &mockProvider{calls: [][]provider.StreamChunk{textChunks("OK")}}
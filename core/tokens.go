@@ -0,0 +1,177 @@
+package core
+
+import (
+	"cosmos/core/provider"
+	"encoding/json"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Per-item overheads of the local token estimate. Providers wrap every
+// message, tool call and tool result in role and structure tokens that the
+// text itself does not show.
+const (
+	messageOverheadTokens = 4
+	toolOverheadTokens    = 12
+	// Claude bills an image at up to about 1,600 tokens; without decoding
+	// dimensions the maximum is the safe figure.
+	imageTokens = 1600
+	// PDFs are sent as extracted text plus a rendered image per page, at
+	// very roughly 40 tokens per KB of file.
+	documentTokensPerKB = 40
+)
+
+// localTokenCount estimates the input tokens of a request without asking the
+// provider. It approximates a BPE tokenizer by splitting text the way
+// GPT-style pre-tokenizers do, then charging each piece by its kind and
+// length (see countTextTokens).
+func localTokenCount(system string, tools []provider.ToolDefinition, messages []provider.Message) int {
+	total := countTextTokens(system)
+	for _, t := range tools {
+		schema, _ := json.Marshal(t.InputSchema)
+		total += toolOverheadTokens + countTextTokens(t.Name) + countTextTokens(t.Description) + countTextTokens(string(schema))
+	}
+	for _, msg := range messages {
+		total += messageOverheadTokens + countTextTokens(msg.Content) + countBlockTokens(msg.Blocks)
+		for _, r := range msg.Reasoning {
+			total += countTextTokens(r.Text) + len(r.Redacted)/4
+		}
+		for _, tc := range msg.ToolCalls {
+			input, _ := json.Marshal(tc.Input)
+			total += toolOverheadTokens + countTextTokens(tc.Name) + countTextTokens(string(input))
+		}
+		for _, tr := range msg.ToolResults {
+			total += toolOverheadTokens + countTextTokens(tr.Content) + countBlockTokens(tr.Blocks)
+		}
+	}
+	return total
+}
+
+func countBlockTokens(blocks []provider.ContentBlock) int {
+	total := 0
+	for _, b := range blocks {
+		switch b.Type {
+		case provider.ContentImage:
+			total += imageTokens
+		case provider.ContentDocument:
+			total += max(1, len(b.Data)/1024) * documentTokensPerKB
+		default:
+			total += countTextTokens(b.Text)
+		}
+	}
+	return total
+}
+
+// runeClass groups runes the way BPE vocabularies tend to merge them.
+type runeClass int
+
+const (
+	classSpace runeClass = iota
+	classNewline
+	classLetter
+	classDigit
+	classWide // CJK and similar scripts: about one token per character
+	classSymbol
+)
+
+func classify(r rune) runeClass {
+	switch {
+	case r == '\n' || r == '\r':
+		return classNewline
+	case unicode.IsSpace(r):
+		return classSpace
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+		return classWide
+	case unicode.IsLetter(r) || r == '_':
+		return classLetter
+	case unicode.IsDigit(r):
+		return classDigit
+	default:
+		return classSymbol
+	}
+}
+
+// countTextTokens estimates the tokens in s. Text is split into runs of one
+// rune class; identifiers are further split at case changes, so getUserName
+// costs three tokens like it does in real vocabularies. Per run:
+//
+//   - letters: one token per six runes of each word part (ASCII); runes
+//     outside ASCII cost one token per two, as they merge poorly
+//   - digits: one token per three, as vocabularies store up to 3-digit groups
+//   - symbols: one token per two, since operators like := and ); merge
+//   - a single space before a word is part of that word's token; other
+//     whitespace costs one token per four runes, newlines one per run
+//   - CJK: one token per rune
+func countTextTokens(s string) int {
+	total := 0
+	for len(s) > 0 {
+		r, _ := utf8.DecodeRuneInString(s)
+		class := classify(r)
+		end := runEnd(s, class)
+		run := s[:end]
+		s = s[end:]
+
+		switch class {
+		case classSpace:
+			if run == " " && len(s) > 0 {
+				continue // merged into the following token
+			}
+			total += ceilDiv(utf8.RuneCountInString(run), 4)
+		case classNewline:
+			total++
+		case classLetter:
+			total += countWordTokens(run)
+		case classDigit:
+			total += ceilDiv(len(run), 3)
+		case classWide:
+			total += utf8.RuneCountInString(run)
+		default:
+			total += ceilDiv(utf8.RuneCountInString(run), 2)
+		}
+	}
+	return total
+}
+
+// runEnd returns the byte length of the run of class at the start of s.
+func runEnd(s string, class runeClass) int {
+	for i, r := range s {
+		if classify(r) != class {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// countWordTokens charges a letter run per camelCase or snake_case part.
+func countWordTokens(word string) int {
+	total := 0
+	partLen, nonASCII := 0, 0
+	flush := func() {
+		if partLen > 0 {
+			total += ceilDiv(partLen-nonASCII, 6) + ceilDiv(nonASCII, 2)
+		}
+		partLen, nonASCII = 0, 0
+	}
+	prevLower := false
+	for _, r := range word {
+		if r == '_' {
+			flush()
+			prevLower = false
+			continue
+		}
+		if prevLower && unicode.IsUpper(r) {
+			flush()
+		}
+		partLen++
+		if r >= utf8.RuneSelf {
+			nonASCII++
+		}
+		prevLower = unicode.IsLower(r)
+	}
+	flush()
+	return max(total, 1)
+}
+
+func ceilDiv(n, d int) int {
+	return (n + d - 1) / d
+}
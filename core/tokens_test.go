@@ -0,0 +1,57 @@
+package core
+
+import (
+	"cosmos/core/provider"
+	"strings"
+	"testing"
+)
+
+func TestCountTextTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"The quick brown fox jumps over the lazy dog.", 10},
+		{"getUserName", 3},
+		{"snake_case_name", 3},
+		{"2025", 2},
+		{"x := a + b", 5},
+		{"\n\n", 1},
+		{"        return", 3}, // indentation: 8 spaces = 2 tokens
+		{"日本語", 3},
+	}
+	for _, tt := range tests {
+		if got := countTextTokens(tt.text); got != tt.want {
+			t.Errorf("countTextTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCountTextTokensCode(t *testing.T) {
+	// Code should land near the 3-4 characters per token real tokenizers
+	// produce, well below the old chars/1.2 heuristic.
+	code := strings.Repeat("func (s *Session) handleContextCommand(ctx context.Context) error {\n\treturn nil\n}\n", 20)
+	tokens := countTextTokens(code)
+	if ratio := float64(len(code)) / float64(tokens); ratio < 2.5 || ratio > 5 {
+		t.Errorf("chars per token = %.2f (%d tokens for %d chars), want 2.5-5", ratio, tokens, len(code))
+	}
+}
+
+func TestLocalTokenCount(t *testing.T) {
+	base := localTokenCount("system", nil, []provider.Message{{Role: provider.RoleUser, Content: "hello"}})
+
+	withImage := localTokenCount("system", nil, []provider.Message{{
+		Role:    provider.RoleUser,
+		Content: "hello",
+		Blocks:  []provider.ContentBlock{{Type: provider.ContentImage, MediaType: "image/png", Data: []byte{1}}},
+	}})
+	if withImage-base != imageTokens {
+		t.Errorf("image adds %d tokens, want %d", withImage-base, imageTokens)
+	}
+
+	tools := []provider.ToolDefinition{{Name: "readFile", Description: "Read a file", InputSchema: map[string]any{"type": "object"}}}
+	if withTools := localTokenCount("system", tools, []provider.Message{{Role: provider.RoleUser, Content: "hello"}}); withTools <= base {
+		t.Errorf("tool definitions not counted: %d <= %d", withTools, base)
+	}
+}
@@ -1,16 +1,20 @@
 package runtime
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 
+	"cosmos/core/provider"
+
 	v8 "rogchap.com/v8go"
 )
 
-// injectFsAPI registers fs.read, fs.write, fs.list, fs.stat, fs.unlink
-// on the global template. Each callback captures ctx for permission checks.
+// injectFsAPI registers fs.read, fs.readMedia, fs.write, fs.list, fs.stat,
+// fs.unlink on the global template. Each callback captures ctx for permission checks.
 func injectFsAPI(iso *v8.Isolate, global *v8.ObjectTemplate, ctx *ToolContext) error {
 	fs := v8.NewObjectTemplate(iso)
 
@@ -47,6 +51,62 @@ func injectFsAPI(iso *v8.Isolate, global *v8.ObjectTemplate, ctx *ToolContext) e
 		return fmt.Errorf("set fs.read: %w", err)
 	}
 
+	// fs.readMedia(path) → {__media, mediaType, name, data}
+	// Returned as-is (or nested) from a tool, the object reaches the model as
+	// an image or document block instead of text; see ExecuteContent.
+	readMediaFn := v8.NewFunctionTemplate(iso, func(info *v8.FunctionCallbackInfo) *v8.Value {
+		v8ctx := info.Context()
+		v8iso := v8ctx.Isolate()
+
+		path, err := argString(info, 0)
+		if err != nil {
+			return throwJSError(v8iso, v8ctx, "fs.readMedia: "+err.Error())
+		}
+		path, err = canonicalizePath(path)
+		if err != nil {
+			return throwJSError(v8iso, v8ctx, fmt.Sprintf("fs.readMedia: resolve path: %s", err))
+		}
+
+		mediaType, ok := provider.MediaTypes[strings.ToLower(filepath.Ext(path))]
+		if !ok {
+			return throwJSError(v8iso, v8ctx, fmt.Sprintf("fs.readMedia: unsupported file type: %s", path))
+		}
+
+		if err := checkPermission(ctx, "fs:read:"+path); err != nil {
+			return throwJSError(v8iso, v8ctx, err.Error())
+		}
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			return throwJSError(v8iso, v8ctx, fmt.Sprintf("fs.readMedia: %s", err))
+		}
+		if fi.Size() > provider.MaxMediaBytes {
+			return throwJSError(v8iso, v8ctx, fmt.Sprintf("fs.readMedia: %s is %d bytes (limit %d)", path, fi.Size(), provider.MaxMediaBytes))
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return throwJSError(v8iso, v8ctx, fmt.Sprintf("fs.readMedia: %s", err))
+		}
+
+		kind := provider.ContentDocument
+		if strings.HasPrefix(mediaType, "image/") {
+			kind = provider.ContentImage
+		}
+		val, err := toJSObject(v8iso, v8ctx, map[string]any{
+			mediaMarker: string(kind),
+			"mediaType": mediaType,
+			"name":      filepath.Base(path),
+			"data":      base64.StdEncoding.EncodeToString(data),
+		})
+		if err != nil {
+			return throwJSError(v8iso, v8ctx, fmt.Sprintf("fs.readMedia: create value: %s", err))
+		}
+		return val
+	})
+	if err := fs.Set("readMedia", readMediaFn, v8.ReadOnly); err != nil {
+		return fmt.Errorf("set fs.readMedia: %w", err)
+	}
+
 	// fs.write(path, content) → undefined
 	writeFn := v8.NewFunctionTemplate(iso, func(info *v8.FunctionCallbackInfo) *v8.Value {
 		v8ctx := info.Context()
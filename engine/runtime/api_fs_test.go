@@ -8,6 +8,7 @@ import (
 	"strings"
 	"testing"
 
+	"cosmos/core/provider"
 	"cosmos/engine/manifest"
 )
 
@@ -330,3 +331,46 @@ func TestFsUnlink(t *testing.T) {
 		t.Error("file should have been deleted")
 	}
 }
+
+func TestFsReadMedia(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "shot.png")
+	if err := os.WriteFile(testFile, []byte("\x89PNG fake"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	e, _ := fsTestExecutor(t, "readImage", tmpDir)
+
+	result, blocks, err := e.ExecuteContent(context.Background(), "readImage", map[string]any{
+		"path":    testFile,
+		"caption": "<login>",
+	})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if result != `{"caption":"<login>","image":"[image shot.png attached]"}` {
+		t.Errorf("result = %s", result)
+	}
+	if len(blocks) != 1 {
+		t.Fatalf("blocks = %d, want 1", len(blocks))
+	}
+	b := blocks[0]
+	if b.Type != provider.ContentImage || b.MediaType != "image/png" || b.Name != "shot.png" || string(b.Data) != "\x89PNG fake" {
+		t.Errorf("block = %+v", b)
+	}
+}
+
+func TestFsReadMedia_UnsupportedType(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "notes.txt")
+	if err := os.WriteFile(testFile, []byte("text"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	e, _ := fsTestExecutor(t, "readImage", tmpDir)
+
+	_, _, err := e.ExecuteContent(context.Background(), "readImage", map[string]any{"path": testFile})
+	if err == nil || !strings.Contains(err.Error(), "unsupported file type") {
+		t.Errorf("err = %v, want unsupported file type", err)
+	}
+}
@@ -0,0 +1,103 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cosmos/core/provider"
+)
+
+// mediaMarker is the key fs.readMedia sets on the objects it returns. Tool
+// results are scanned for it so media reach the model as content blocks
+// rather than as kilobytes of base64 text.
+const mediaMarker = "__media"
+
+// ExecuteContent runs a tool like Execute and lifts any fs.readMedia objects
+// out of its JSON result into content blocks. Each lifted object is replaced
+// in the text by a short placeholder. Satisfies core.ContentToolExecutor.
+func (e *V8Executor) ExecuteContent(ctx context.Context, name string, input map[string]any) (string, []provider.ContentBlock, error) {
+	result, err := e.Execute(ctx, name, input)
+	if err != nil {
+		return "", nil, err
+	}
+	text, blocks := extractMedia(result)
+	return text, blocks, nil
+}
+
+// extractMedia returns result unchanged when it holds no media objects.
+func extractMedia(result string) (string, []provider.ContentBlock) {
+	if !strings.Contains(result, mediaMarker) {
+		return result, nil
+	}
+	var value any
+	if err := json.Unmarshal([]byte(result), &value); err != nil {
+		return result, nil
+	}
+
+	var blocks []provider.ContentBlock
+	value = replaceMedia(value, &blocks)
+	if len(blocks) == 0 {
+		return result, nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return result, nil
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), blocks
+}
+
+// replaceMedia walks a decoded JSON value depth-first, in key order so block
+// order is deterministic, and swaps media objects for placeholders.
+func replaceMedia(value any, blocks *[]provider.ContentBlock) any {
+	switch v := value.(type) {
+	case map[string]any:
+		if block, ok := mediaBlock(v); ok {
+			*blocks = append(*blocks, block)
+			return fmt.Sprintf("[%s %s attached]", block.Type, block.Name)
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v[k] = replaceMedia(v[k], blocks)
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = replaceMedia(v[i], blocks)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+func mediaBlock(obj map[string]any) (provider.ContentBlock, bool) {
+	kind, _ := obj[mediaMarker].(string)
+	if kind != string(provider.ContentImage) && kind != string(provider.ContentDocument) {
+		return provider.ContentBlock{}, false
+	}
+	mediaType, _ := obj["mediaType"].(string)
+	encoded, _ := obj["data"].(string)
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if mediaType == "" || err != nil {
+		return provider.ContentBlock{}, false
+	}
+	name, _ := obj["name"].(string)
+	return provider.ContentBlock{
+		Type:      provider.ContentType(kind),
+		MediaType: mediaType,
+		Name:      name,
+		Data:      data,
+	}, true
+}
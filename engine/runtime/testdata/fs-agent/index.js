@@ -19,3 +19,7 @@ function deleteFile(input) {
   fs.unlink(input.path);
   return { ok: true };
 }
+
+function readImage(input) {
+  return { caption: input.caption, image: fs.readMedia(input.path) };
+}
@@ -187,10 +187,37 @@ func (s *Snapshotter) RestoreInteraction(interactionID string) ([]string, error)
 	if len(matching) == 0 {
 		return nil, fmt.Errorf("no snapshots found for interaction %s", interactionID)
 	}
+	return s.restoreRecords(matching)
+}
+
+// RestoreInteractions restores all files changed in any of the given
+// interactions to their state before the first of them modified it, e.g.
+// to undo the changes made on an abandoned conversation branch.
+// Interactions without snapshots are skipped. Returns the list of restored
+// file paths.
+func (s *Snapshotter) RestoreInteractions(interactionIDs []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make(map[string]bool, len(interactionIDs))
+	for _, id := range interactionIDs {
+		ids[id] = true
+	}
+	var matching []SnapshotRecord
+	for _, rec := range s.records {
+		if ids[rec.InteractionID] {
+			matching = append(matching, rec)
+		}
+	}
+	return s.restoreRecords(matching)
+}
 
+// restoreRecords restores each path to its earliest snapshot among records.
+// Caller must hold s.mu.
+func (s *Snapshotter) restoreRecords(matching []SnapshotRecord) ([]string, error) {
 	// Sort by timestamp ascending so that the first snapshot per path
 	// represents the original state before any modifications.
-	sort.Slice(matching, func(i, j int) bool {
+	sort.SliceStable(matching, func(i, j int) bool {
 		return matching[i].Timestamp.Before(matching[j].Timestamp)
 	})
 
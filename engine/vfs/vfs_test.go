@@ -184,6 +184,49 @@ func TestRestoreInteraction(t *testing.T) {
 	}
 }
 
+func TestRestoreInteractions(t *testing.T) {
+	dir := t.TempDir()
+	cosmosDir := filepath.Join(dir, ".cosmos")
+	workDir := filepath.Join(dir, "work")
+	mustMkdir(t, workDir)
+
+	edited := filepath.Join(workDir, "edited.txt")
+	created := filepath.Join(workDir, "created.txt")
+	mustWrite(t, edited, "original")
+
+	snap, err := NewSnapshotter(cosmosDir, "session-8")
+	if err != nil {
+		t.Fatalf("NewSnapshotter: %v", err)
+	}
+
+	if _, err := snap.Snapshot(edited, "write", "agent", "i1", "tc1"); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	mustWrite(t, edited, "first edit")
+	if _, err := snap.Snapshot(edited, "write", "agent", "i2", "tc2"); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	mustWrite(t, edited, "second edit")
+	if _, err := snap.Snapshot(created, "write", "agent", "i2", "tc3"); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	mustWrite(t, created, "new")
+
+	restored, err := snap.RestoreInteractions([]string{"i2", "i1", "unknown"})
+	if err != nil {
+		t.Fatalf("RestoreInteractions: %v", err)
+	}
+	if len(restored) != 2 {
+		t.Fatalf("expected 2 restored files, got %v", restored)
+	}
+	if data, _ := os.ReadFile(edited); string(data) != "original" {
+		t.Errorf("expected 'original', got %q", string(data))
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Error("expected the new file to be deleted after restore")
+	}
+}
+
 func TestRestoreNewFile(t *testing.T) {
 	dir := t.TempDir()
 	cosmosDir := filepath.Join(dir, ".cosmos")
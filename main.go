@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"cosmos/app"
+	"flag"
 	"fmt"
 	"os"
 )
@@ -10,16 +11,52 @@ import (
 const version = "0.2.0"
 
 func main() {
-	// Handle --version flag
-	if len(os.Args) > 1 && (os.Args[1] == "--version" || os.Args[1] == "-v") {
+	var (
+		showVersion   bool
+		offlineScript string
+	)
+	flag.BoolVar(&showVersion, "version", false, "print version and exit")
+	flag.BoolVar(&showVersion, "v", false, "print version and exit (shorthand)")
+	flag.StringVar(&offlineScript, "offline-script", "", "run against a scripted fake model from this JSON or YAML `file` (no network or credentials)")
+	flag.Parse()
+
+	if showVersion {
 		fmt.Println(version)
 		os.Exit(0)
 	}
 
+	if flag.NArg() > 0 {
+		switch cmd := flag.Arg(0); cmd {
+		case "usage":
+			if err := app.RunUsage(flag.Args()[1:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "cosmos usage: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "run":
+			err := app.RunHeadless(context.Background(), app.Options{OfflineScript: offlineScript}, flag.Args()[1:], os.Stdin, os.Stdout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "cosmos run: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "serve":
+			err := app.RunServer(context.Background(), app.Options{OfflineScript: offlineScript}, flag.Args()[1:], os.Stdin, os.Stdout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "cosmos serve: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		default:
+			fmt.Fprintf(os.Stderr, "cosmos: unknown command %q\n", cmd)
+			os.Exit(2)
+		}
+	}
+
 	ctx := context.Background()
 
 	// Bootstrap application
-	application, err := app.Bootstrap(ctx)
+	application, err := app.Bootstrap(ctx, app.Options{OfflineScript: offlineScript})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "cosmos: %v\n", err)
 		os.Exit(1)
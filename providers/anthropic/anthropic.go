@@ -0,0 +1,299 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"cosmos/core/provider"
+	"cosmos/providers/pricetable"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	defaultBaseURL = "https://api.anthropic.com"
+	apiVersion     = "2023-06-01"
+)
+
+// knownModels holds static metadata for Claude models on the Anthropic API.
+// The /v1/models endpoint does not return context windows or pricing,
+// so we maintain a static table for known models. Prices are filled in from
+// the embedded pricetable.
+var knownModels = map[string]provider.ModelInfo{
+	"claude-3-haiku-20240307": {
+		ID: "claude-3-haiku-20240307", Name: "Claude 3 Haiku",
+		ContextWindow: 200_000, MaxOutputTokens: 4096,
+	},
+	"claude-3-opus-20240229": {
+		ID: "claude-3-opus-20240229", Name: "Claude 3 Opus",
+		ContextWindow: 200_000, MaxOutputTokens: 4096,
+	},
+	"claude-3-5-sonnet-20240620": {
+		ID: "claude-3-5-sonnet-20240620", Name: "Claude 3.5 Sonnet",
+		ContextWindow: 200_000, MaxOutputTokens: 8192,
+	},
+	"claude-3-5-sonnet-20241022": {
+		ID: "claude-3-5-sonnet-20241022", Name: "Claude 3.5 Sonnet v2",
+		ContextWindow: 200_000, MaxOutputTokens: 8192,
+	},
+	"claude-3-5-haiku-20241022": {
+		ID: "claude-3-5-haiku-20241022", Name: "Claude 3.5 Haiku",
+		ContextWindow: 200_000, MaxOutputTokens: 8192,
+	},
+	"claude-3-7-sonnet-20250219": {
+		ID: "claude-3-7-sonnet-20250219", Name: "Claude 3.7 Sonnet",
+		ContextWindow: 200_000, MaxOutputTokens: 64000,
+	},
+	"claude-sonnet-4-20250514": {
+		ID: "claude-sonnet-4-20250514", Name: "Claude Sonnet 4",
+		ContextWindow: 200_000, MaxOutputTokens: 64000,
+	},
+	"claude-opus-4-20250514": {
+		ID: "claude-opus-4-20250514", Name: "Claude Opus 4",
+		ContextWindow: 200_000, MaxOutputTokens: 32000,
+	},
+	"claude-opus-4-1-20250805": {
+		ID: "claude-opus-4-1-20250805", Name: "Claude Opus 4.1",
+		ContextWindow: 200_000, MaxOutputTokens: 32000,
+	},
+	"claude-sonnet-4-5-20250929": {
+		ID: "claude-sonnet-4-5-20250929", Name: "Claude Sonnet 4.5",
+		ContextWindow: 200_000, MaxOutputTokens: 64000,
+	},
+	"claude-haiku-4-5-20251001": {
+		ID: "claude-haiku-4-5-20251001", Name: "Claude Haiku 4.5",
+		ContextWindow: 200_000, MaxOutputTokens: 64000,
+	},
+	"claude-opus-4-5-20251101": {
+		ID: "claude-opus-4-5-20251101", Name: "Claude Opus 4.5",
+		ContextWindow: 200_000, MaxOutputTokens: 64000,
+	},
+}
+
+// Anthropic implements Provider using the Anthropic Messages API.
+type Anthropic struct {
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewAnthropic creates an Anthropic provider authenticated with apiKey.
+// If baseURL is empty, the public API endpoint is used; a custom base URL
+// is useful for proxies and for testing with httptest.
+func NewAnthropic(apiKey, baseURL string, httpClient *http.Client) (*Anthropic, error) {
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return nil, fmt.Errorf("anthropic: API key is required (set anthropic_api_key or ANTHROPIC_API_KEY)")
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	base := defaultBaseURL
+	if trimmed := strings.TrimSpace(baseURL); trimmed != "" {
+		base = strings.TrimRight(trimmed, "/")
+	}
+	return &Anthropic{
+		apiKey:     apiKey,
+		baseURL:    base,
+		httpClient: httpClient,
+	}, nil
+}
+
+// Send starts a streaming conversation with the model specified in req.
+func (a *Anthropic) Send(ctx context.Context, req provider.Request) (provider.StreamIterator, error) {
+	body, err := buildMessagesRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("building request: %w", err)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("encoding request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: %w", err)
+	}
+	a.setHeaders(httpReq)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := a.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, classifyResponse(resp)
+	}
+
+	return newAnthropicIterator(resp.Body), nil
+}
+
+// CountTokens returns the input tokens req would use, as counted by the
+// Anthropic token counting endpoint. Satisfies provider.TokenCounter.
+func (a *Anthropic) CountTokens(ctx context.Context, req provider.Request) (int, error) {
+	body, err := buildCountTokensRequest(req)
+	if err != nil {
+		return 0, fmt.Errorf("building request: %w", err)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return 0, fmt.Errorf("encoding request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/v1/messages/count_tokens", bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("anthropic: %w", err)
+	}
+	a.setHeaders(httpReq)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := a.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("anthropic: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return 0, classifyResponse(resp)
+	}
+
+	var out struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return 0, fmt.Errorf("anthropic: decode response: %w", err)
+	}
+	return out.InputTokens, nil
+}
+
+// ListModels returns available models from the Anthropic API,
+// enriched with static pricing metadata where known.
+func (a *Anthropic) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
+	var models []provider.ModelInfo
+	afterID := ""
+	for {
+		params := url.Values{}
+		params.Set("limit", "100")
+		if afterID != "" {
+			params.Set("after_id", afterID)
+		}
+
+		var page struct {
+			Data []struct {
+				ID          string `json:"id"`
+				DisplayName string `json:"display_name"`
+			} `json:"data"`
+			HasMore bool   `json:"has_more"`
+			LastID  string `json:"last_id"`
+		}
+		if err := a.getJSON(ctx, a.baseURL+"/v1/models?"+params.Encode(), &page); err != nil {
+			return nil, err
+		}
+
+		for _, m := range page.Data {
+			info := provider.ModelInfo{ID: m.ID, Name: m.DisplayName}
+			if known, ok := knownModels[m.ID]; ok {
+				info = known
+			}
+			pricetable.Fill(&info)
+			models = append(models, info)
+		}
+
+		if !page.HasMore || page.LastID == "" {
+			break
+		}
+		afterID = page.LastID
+	}
+	return models, nil
+}
+
+func (a *Anthropic) setHeaders(req *http.Request) {
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("anthropic-version", apiVersion)
+}
+
+func (a *Anthropic) getJSON(ctx context.Context, endpoint string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("anthropic: %w", err)
+	}
+	a.setHeaders(req)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("anthropic: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return classifyResponse(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("anthropic: decode response: %w", err)
+	}
+	return nil
+}
+
+// apiError is the error object returned in non-2xx bodies and SSE error events.
+type apiError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// classifyResponse reads an error response body and maps it to a provider sentinel.
+func classifyResponse(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var body struct {
+		Error apiError `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil || body.Error.Message == "" {
+		body.Error.Message = strings.TrimSpace(string(raw))
+		if body.Error.Message == "" {
+			body.Error.Message = resp.Status
+		}
+	}
+	return classifyErr(resp.StatusCode, body.Error)
+}
+
+// classifyErr wraps Anthropic API errors into provider-level sentinels.
+// The error type takes precedence; the HTTP status is used when the type is
+// missing or unrecognized (status is 0 for errors received mid-stream).
+func classifyErr(status int, e apiError) error {
+	switch e.Type {
+	case "rate_limit_error":
+		return fmt.Errorf("%w: %s", provider.ErrThrottled, e.Message)
+	case "authentication_error", "permission_error":
+		return fmt.Errorf("%w: %s", provider.ErrAccessDenied, e.Message)
+	case "not_found_error":
+		return fmt.Errorf("%w: %s", provider.ErrModelNotFound, e.Message)
+	case "overloaded_error":
+		return fmt.Errorf("%w: %s", provider.ErrModelNotReady, e.Message)
+	case "invalid_request_error":
+		return fmt.Errorf("anthropic validation: %s", e.Message)
+	}
+
+	switch status {
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s", provider.ErrThrottled, e.Message)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: %s", provider.ErrAccessDenied, e.Message)
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", provider.ErrModelNotFound, e.Message)
+	case 529, http.StatusServiceUnavailable:
+		return fmt.Errorf("%w: %s", provider.ErrModelNotReady, e.Message)
+	}
+
+	if status != 0 {
+		return fmt.Errorf("anthropic: status %d: %s", status, e.Message)
+	}
+	return fmt.Errorf("anthropic: %s", e.Message)
+}
+
+// Compile-time check that Anthropic implements provider.Provider
+var _ provider.Provider = (*Anthropic)(nil)
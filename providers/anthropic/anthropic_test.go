@@ -0,0 +1,736 @@
+package anthropic
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"cosmos/providers/pricetable"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Compile-time checks: Anthropic satisfies Provider and TokenCounter.
+var (
+	_ provider.Provider     = (*Anthropic)(nil)
+	_ provider.TokenCounter = (*Anthropic)(nil)
+)
+
+// sseBody renders events as a server-sent event stream.
+func sseBody(events ...string) string {
+	var b strings.Builder
+	for _, e := range events {
+		var probe struct {
+			Type string `json:"type"`
+		}
+		_ = json.Unmarshal([]byte(e), &probe)
+		b.WriteString("event: " + probe.Type + "\n")
+		b.WriteString("data: " + e + "\n\n")
+	}
+	return b.String()
+}
+
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *Anthropic {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	a, err := NewAnthropic("test-key", srv.URL, srv.Client())
+	if err != nil {
+		t.Fatalf("NewAnthropic: %v", err)
+	}
+	return a
+}
+
+func collect(t *testing.T, it provider.StreamIterator) []provider.StreamChunk {
+	t.Helper()
+	var chunks []provider.StreamChunk
+	for {
+		chunk, err := it.Next()
+		if err == io.EOF {
+			return chunks
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+// --- Constructor tests ---
+
+func TestNewAnthropicRequiresKey(t *testing.T) {
+	if _, err := NewAnthropic("  ", "", nil); err == nil {
+		t.Fatal("expected error for empty API key")
+	}
+}
+
+func TestNewAnthropicBaseURL(t *testing.T) {
+	a, err := NewAnthropic("k", "", nil)
+	if err != nil {
+		t.Fatalf("NewAnthropic: %v", err)
+	}
+	if a.baseURL != defaultBaseURL {
+		t.Errorf("baseURL = %q, want %q", a.baseURL, defaultBaseURL)
+	}
+
+	a, err = NewAnthropic("k", "http://proxy.local/", nil)
+	if err != nil {
+		t.Fatalf("NewAnthropic: %v", err)
+	}
+	if a.baseURL != "http://proxy.local" {
+		t.Errorf("baseURL = %q, want trailing slash trimmed", a.baseURL)
+	}
+}
+
+// --- Request conversion tests ---
+
+func TestBuildMessagesRequest(t *testing.T) {
+	req := provider.Request{
+		Model:  "claude-sonnet-4-20250514",
+		System: "be brief",
+		Messages: []provider.Message{
+			{Role: provider.RoleUser, Content: "Hello"},
+			{
+				Role:    provider.RoleAssistant,
+				Content: "Reading.",
+				ToolCalls: []provider.ToolCall{
+					{ID: "tc1", Name: "readFile", Input: map[string]any{"path": "/tmp/x"}},
+					{ID: "tc2", Name: "listAll"},
+				},
+			},
+			{
+				Role: provider.RoleUser,
+				ToolResults: []provider.ToolResult{
+					{ToolUseID: "tc1", Content: "data"},
+					{ToolUseID: "tc2", Content: "boom", IsError: true},
+				},
+			},
+		},
+		Tools: []provider.ToolDefinition{
+			{Name: "readFile", Description: "reads", InputSchema: map[string]any{"type": "object"}},
+		},
+	}
+
+	out, err := buildMessagesRequest(req)
+	if err != nil {
+		t.Fatalf("buildMessagesRequest: %v", err)
+	}
+	if out.MaxTokens != defaultMaxTokens {
+		t.Errorf("MaxTokens = %d, want default %d", out.MaxTokens, defaultMaxTokens)
+	}
+	if !out.Stream {
+		t.Error("Stream should be true")
+	}
+
+	raw, err := json.Marshal(out)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded struct {
+		System   string `json:"system"`
+		Messages []struct {
+			Role    string           `json:"role"`
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+		Tools []map[string]any `json:"tools"`
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if decoded.System != "be brief" {
+		t.Errorf("system = %q", decoded.System)
+	}
+	if len(decoded.Messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(decoded.Messages))
+	}
+
+	asst := decoded.Messages[1].Content
+	if len(asst) != 3 {
+		t.Fatalf("assistant: expected 3 blocks, got %d", len(asst))
+	}
+	if asst[1]["type"] != "tool_use" || asst[1]["id"] != "tc1" || asst[1]["name"] != "readFile" {
+		t.Errorf("assistant block 1: %v", asst[1])
+	}
+	// No-arg tool calls must still send an input object.
+	if input, ok := asst[2]["input"].(map[string]any); !ok || len(input) != 0 {
+		t.Errorf("assistant block 2 input: got %v, want empty object", asst[2]["input"])
+	}
+
+	results := decoded.Messages[2].Content
+	if len(results) != 2 {
+		t.Fatalf("tool results: expected 2 blocks, got %d", len(results))
+	}
+	if results[0]["type"] != "tool_result" || results[0]["tool_use_id"] != "tc1" {
+		t.Errorf("result 0: %v", results[0])
+	}
+	if _, ok := results[0]["is_error"]; ok {
+		t.Error("result 0: is_error should be omitted for success")
+	}
+	if results[1]["is_error"] != true {
+		t.Errorf("result 1: is_error = %v, want true", results[1]["is_error"])
+	}
+
+	if len(decoded.Tools) != 1 || decoded.Tools[0]["input_schema"] == nil {
+		t.Errorf("tools: %v", decoded.Tools)
+	}
+}
+
+func TestBuildMessagesRequestEmptyMessage(t *testing.T) {
+	_, err := buildMessagesRequest(provider.Request{
+		Messages: []provider.Message{{Role: provider.RoleUser}},
+	})
+	if err == nil {
+		t.Fatal("expected error for empty message")
+	}
+}
+
+func TestBuildMessagesRequestUnknownRole(t *testing.T) {
+	_, err := buildMessagesRequest(provider.Request{
+		Messages: []provider.Message{{Role: provider.Role("system"), Content: "x"}},
+	})
+	if err == nil {
+		t.Fatal("expected error for unknown role")
+	}
+}
+
+func TestBuildMessagesRequestContentBlocks(t *testing.T) {
+	out, err := buildMessagesRequest(provider.Request{
+		Messages: []provider.Message{
+			{
+				Role:    provider.RoleUser,
+				Content: "What is this?",
+				Blocks:  []provider.ContentBlock{{Type: provider.ContentImage, MediaType: "image/png", Data: []byte("png")}},
+			},
+			{
+				Role: provider.RoleUser,
+				ToolResults: []provider.ToolResult{{
+					ToolUseID: "tc1",
+					Content:   "read",
+					Blocks:    []provider.ContentBlock{{Type: provider.ContentDocument, MediaType: "application/pdf", Data: []byte("pdf")}},
+				}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("buildMessagesRequest: %v", err)
+	}
+
+	raw, err := json.Marshal(out)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded struct {
+		Messages []struct {
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	prompt := decoded.Messages[0].Content
+	if len(prompt) != 2 || prompt[0]["type"] != "image" || prompt[1]["type"] != "text" {
+		t.Fatalf("prompt blocks = %v, want image then text", prompt)
+	}
+	wantSource := map[string]any{"type": "base64", "media_type": "image/png", "data": "cG5n"}
+	if !reflect.DeepEqual(prompt[0]["source"], wantSource) {
+		t.Errorf("image source = %v, want %v", prompt[0]["source"], wantSource)
+	}
+
+	content, ok := decoded.Messages[1].Content[0]["content"].([]any)
+	if !ok || len(content) != 2 {
+		t.Fatalf("tool result content = %v, want text and document blocks", decoded.Messages[1].Content[0]["content"])
+	}
+	if doc, _ := content[1].(map[string]any); doc["type"] != "document" {
+		t.Errorf("tool result block 1 = %v, want document", content[1])
+	}
+}
+
+func TestBuildMessagesRequestCacheControl(t *testing.T) {
+	out, err := buildMessagesRequest(provider.Request{
+		System:      "be brief",
+		CacheSystem: true,
+		CacheTools:  true,
+		Messages: []provider.Message{
+			{Role: provider.RoleUser, Content: "Hello", CachePoint: true},
+			{Role: provider.RoleAssistant, Content: "Hi"},
+		},
+		Tools: []provider.ToolDefinition{{Name: "a"}, {Name: "b"}},
+	})
+	if err != nil {
+		t.Fatalf("buildMessagesRequest: %v", err)
+	}
+
+	raw, _ := json.Marshal(out)
+	var decoded struct {
+		System   []map[string]any `json:"system"`
+		Messages []struct {
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+		Tools []map[string]any `json:"tools"`
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	ephemeral := map[string]any{"type": "ephemeral"}
+	if len(decoded.System) != 1 || decoded.System[0]["text"] != "be brief" ||
+		!reflect.DeepEqual(decoded.System[0]["cache_control"], ephemeral) {
+		t.Errorf("system = %v, want one cached text block", decoded.System)
+	}
+	if _, ok := decoded.Tools[0]["cache_control"]; ok {
+		t.Error("only the last tool should carry cache_control")
+	}
+	if !reflect.DeepEqual(decoded.Tools[1]["cache_control"], ephemeral) {
+		t.Errorf("last tool = %v", decoded.Tools[1])
+	}
+	if !reflect.DeepEqual(decoded.Messages[0].Content[0]["cache_control"], ephemeral) {
+		t.Errorf("marked message = %v", decoded.Messages[0].Content)
+	}
+	if _, ok := decoded.Messages[1].Content[0]["cache_control"]; ok {
+		t.Error("unmarked message should not carry cache_control")
+	}
+}
+
+func TestBuildMessagesRequestThinking(t *testing.T) {
+	out, err := buildMessagesRequest(provider.Request{
+		MaxTokens:      1024,
+		ThinkingBudget: 2048,
+		Messages: []provider.Message{
+			{Role: provider.RoleUser, Content: "Hello"},
+			{
+				Role:      provider.RoleAssistant,
+				Content:   "Hi",
+				Reasoning: []provider.ReasoningBlock{{Text: "greet", Signature: "sig"}, {Redacted: []byte("opaque")}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("buildMessagesRequest: %v", err)
+	}
+	if out.Thinking == nil || out.Thinking.BudgetTokens != 2048 || out.Thinking.Type != "enabled" {
+		t.Errorf("thinking = %+v", out.Thinking)
+	}
+	if out.MaxTokens != 3072 {
+		t.Errorf("MaxTokens = %d, want 3072 (raised above the budget)", out.MaxTokens)
+	}
+
+	blocks := out.Messages[1].Content
+	if len(blocks) != 3 {
+		t.Fatalf("assistant: expected 3 blocks, got %+v", blocks)
+	}
+	if blocks[0].Type != "thinking" || blocks[0].Thinking != "greet" || blocks[0].Signature != "sig" {
+		t.Errorf("block 0 = %+v", blocks[0])
+	}
+	if blocks[1].Type != "redacted_thinking" || blocks[1].Data != "opaque" {
+		t.Errorf("block 1 = %+v", blocks[1])
+	}
+	if blocks[2].Type != "text" {
+		t.Errorf("block 2 = %+v", blocks[2])
+	}
+}
+
+func TestBuildMessagesRequestToolChoiceAndSampling(t *testing.T) {
+	temp, topP := 0.0, 0.5
+	req := provider.Request{
+		Messages:      []provider.Message{{Role: provider.RoleUser, Content: "Hello"}},
+		Tools:         []provider.ToolDefinition{{Name: "extract"}},
+		ToolChoice:    &provider.ToolChoice{Mode: provider.ToolChoiceTool, Name: "extract"},
+		Temperature:   &temp,
+		TopP:          &topP,
+		StopSequences: []string{"END"},
+	}
+	out, err := buildMessagesRequest(req)
+	if err != nil {
+		t.Fatalf("buildMessagesRequest: %v", err)
+	}
+	raw, _ := json.Marshal(out)
+	for _, want := range []string{
+		`"tool_choice":{"type":"tool","name":"extract"}`,
+		`"temperature":0`, // explicit zero must be sent
+		`"top_p":0.5`,
+		`"stop_sequences":["END"]`,
+	} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("request missing %s: %s", want, raw)
+		}
+	}
+
+	req.ToolChoice = &provider.ToolChoice{Mode: provider.ToolChoiceNone}
+	if out, err = buildMessagesRequest(req); err != nil || out.ToolChoice.Type != "none" {
+		t.Errorf("none: tool_choice = %+v, %v", out.ToolChoice, err)
+	}
+
+	req.Tools = nil
+	req.ToolChoice = &provider.ToolChoice{Mode: provider.ToolChoiceAny}
+	if _, err := buildMessagesRequest(req); err == nil {
+		t.Error("expected error forcing a tool call without tools")
+	}
+}
+
+// --- Streaming tests ---
+
+func TestSendStreamsTextAndToolUse(t *testing.T) {
+	var gotHeaders http.Header
+	var gotBody map[string]any
+	a := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders = r.Header.Clone()
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, sseBody(
+			`{"type":"message_start","message":{"usage":{"input_tokens":25,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"readFile","input":{}}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"/tmp\"}"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":42}}`,
+			`{"type":"message_stop"}`,
+		))
+	})
+
+	it, err := a.Send(context.Background(), provider.Request{
+		Model:     "claude-sonnet-4-20250514",
+		Messages:  []provider.Message{{Role: provider.RoleUser, Content: "hi"}},
+		MaxTokens: 1024,
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	defer func() { _ = it.Close() }()
+
+	if gotHeaders.Get("x-api-key") != "test-key" {
+		t.Errorf("x-api-key = %q", gotHeaders.Get("x-api-key"))
+	}
+	if gotHeaders.Get("anthropic-version") != apiVersion {
+		t.Errorf("anthropic-version = %q", gotHeaders.Get("anthropic-version"))
+	}
+	if gotBody["stream"] != true || gotBody["max_tokens"] != float64(1024) {
+		t.Errorf("request body: %v", gotBody)
+	}
+
+	chunks := collect(t, it)
+	wantEvents := []provider.StreamEvent{
+		provider.EventTextDelta,
+		provider.EventTextDelta,
+		provider.EventToolStart,
+		provider.EventToolDelta,
+		provider.EventToolDelta,
+		provider.EventToolEnd,
+		provider.EventMessageStop,
+	}
+	if len(chunks) != len(wantEvents) {
+		t.Fatalf("got %d chunks, want %d: %+v", len(chunks), len(wantEvents), chunks)
+	}
+	for i, want := range wantEvents {
+		if chunks[i].Event != want {
+			t.Errorf("chunk %d: event = %d, want %d", i, chunks[i].Event, want)
+		}
+	}
+
+	if chunks[0].Text+chunks[1].Text != "Hello world" {
+		t.Errorf("text = %q", chunks[0].Text+chunks[1].Text)
+	}
+	if chunks[2].ToolCallID != "toolu_1" || chunks[2].ToolName != "readFile" {
+		t.Errorf("tool start: %+v", chunks[2])
+	}
+	if chunks[3].InputDelta+chunks[4].InputDelta != `{"path":"/tmp"}` {
+		t.Errorf("tool input = %q", chunks[3].InputDelta+chunks[4].InputDelta)
+	}
+
+	stop := chunks[6]
+	if stop.StopReason != "tool_use" {
+		t.Errorf("StopReason = %q, want tool_use", stop.StopReason)
+	}
+	if stop.Usage == nil {
+		t.Fatal("expected usage on message stop")
+	}
+	if stop.Usage.InputTokens != 25 || stop.Usage.OutputTokens != 42 {
+		t.Errorf("usage = %+v, want {25 42}", *stop.Usage)
+	}
+}
+
+func TestSendCacheUsage(t *testing.T) {
+	a := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, sseBody(
+			`{"type":"message_start","message":{"usage":{"input_tokens":12,"output_tokens":1,"cache_creation_input_tokens":300,"cache_read_input_tokens":9000,"service_tier":"priority"}}}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+			`{"type":"message_stop"}`,
+		))
+	})
+
+	it, err := a.Send(context.Background(), provider.Request{
+		Messages: []provider.Message{{Role: provider.RoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	defer func() { _ = it.Close() }()
+
+	chunks := collect(t, it)
+	want := provider.Usage{InputTokens: 12, OutputTokens: 7, CacheReadTokens: 9000, CacheWriteTokens: 300, ServiceTier: "priority"}
+	if len(chunks) != 1 || chunks[0].Usage == nil || *chunks[0].Usage != want {
+		t.Fatalf("chunks = %+v, want stop with usage %+v", chunks, want)
+	}
+}
+
+func TestSendStreamsThinking(t *testing.T) {
+	a := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, sseBody(
+			`{"type":"message_start","message":{"usage":{"input_tokens":5,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Consider"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig=="}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"redacted_thinking","data":"ENC"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"content_block_start","index":2,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"Done"}}`,
+			`{"type":"content_block_stop","index":2}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":9}}`,
+			`{"type":"message_stop"}`,
+		))
+	})
+
+	it, err := a.Send(context.Background(), provider.Request{
+		Messages: []provider.Message{{Role: provider.RoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	defer func() { _ = it.Close() }()
+
+	chunks := collect(t, it)
+	if len(chunks) != 5 {
+		t.Fatalf("got %d chunks, want 5: %+v", len(chunks), chunks)
+	}
+	if chunks[0].Event != provider.EventReasoningDelta || chunks[0].Text != "Consider" {
+		t.Errorf("chunk 0 = %+v", chunks[0])
+	}
+	if chunks[1].Event != provider.EventReasoningSignature || chunks[1].Signature != "sig==" {
+		t.Errorf("chunk 1 = %+v", chunks[1])
+	}
+	if chunks[2].Event != provider.EventReasoningRedacted || string(chunks[2].Redacted) != "ENC" {
+		t.Errorf("chunk 2 = %+v", chunks[2])
+	}
+	if chunks[3].Event != provider.EventTextDelta || chunks[4].Event != provider.EventMessageStop {
+		t.Errorf("tail = %+v", chunks[3:])
+	}
+}
+
+func TestSendStreamErrorEvent(t *testing.T) {
+	a := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, sseBody(
+			`{"type":"message_start","message":{"usage":{"input_tokens":5,"output_tokens":0}}}`,
+			`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+		))
+	})
+
+	it, err := a.Send(context.Background(), provider.Request{
+		Messages: []provider.Message{{Role: provider.RoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	defer func() { _ = it.Close() }()
+
+	_, err = it.Next()
+	if !errors.Is(err, provider.ErrModelNotReady) {
+		t.Fatalf("expected ErrModelNotReady, got %v", err)
+	}
+	// Iterator is finished after an error.
+	if _, err := it.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF after error, got %v", err)
+	}
+}
+
+func TestSendTruncatedStream(t *testing.T) {
+	a := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, sseBody(
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"partial"}}`,
+		))
+	})
+
+	it, err := a.Send(context.Background(), provider.Request{
+		Messages: []provider.Message{{Role: provider.RoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	defer func() { _ = it.Close() }()
+
+	if _, err := it.Next(); err != nil {
+		t.Fatalf("first Next: %v", err)
+	}
+	if _, err := it.Next(); err == nil || err == io.EOF {
+		t.Fatalf("expected truncation error, got %v", err)
+	}
+}
+
+func TestSendHTTPErrors(t *testing.T) {
+	cases := []struct {
+		status  int
+		errType string
+		want    error
+	}{
+		{http.StatusTooManyRequests, "rate_limit_error", provider.ErrThrottled},
+		{http.StatusUnauthorized, "authentication_error", provider.ErrAccessDenied},
+		{http.StatusForbidden, "permission_error", provider.ErrAccessDenied},
+		{http.StatusNotFound, "not_found_error", provider.ErrModelNotFound},
+		{529, "overloaded_error", provider.ErrModelNotReady},
+		{http.StatusTooManyRequests, "", provider.ErrThrottled}, // status fallback
+	}
+
+	for _, tc := range cases {
+		a := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"type":  "error",
+				"error": map[string]string{"type": tc.errType, "message": "nope"},
+			})
+		})
+
+		_, err := a.Send(context.Background(), provider.Request{
+			Messages: []provider.Message{{Role: provider.RoleUser, Content: "hi"}},
+		})
+		if !errors.Is(err, tc.want) {
+			t.Errorf("status %d type %q: got %v, want %v", tc.status, tc.errType, err, tc.want)
+		}
+	}
+}
+
+func TestSendBadRequest(t *testing.T) {
+	a := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens: too large"}}`)
+	})
+
+	_, err := a.Send(context.Background(), provider.Request{
+		Messages: []provider.Message{{Role: provider.RoleUser, Content: "hi"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "max_tokens: too large") {
+		t.Fatalf("expected validation message in error, got %v", err)
+	}
+}
+
+// --- ListModels tests ---
+
+func TestListModelsPaginatesAndEnriches(t *testing.T) {
+	var calls int
+	a := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/v1/models" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		if r.URL.Query().Get("after_id") == "" {
+			_, _ = io.WriteString(w, `{"data":[{"id":"claude-sonnet-4-20250514","display_name":"Claude Sonnet 4"}],"has_more":true,"last_id":"claude-sonnet-4-20250514"}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"data":[{"id":"claude-future-1","display_name":"Claude Future"}],"has_more":false,"last_id":"claude-future-1"}`)
+	})
+
+	models, err := a.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 page requests, got %d", calls)
+	}
+	if len(models) != 2 {
+		t.Fatalf("expected 2 models, got %d", len(models))
+	}
+
+	// Known model gets static pricing.
+	if models[0].InputCostPer1M != 3.0 || models[0].ContextWindow != 200_000 {
+		t.Errorf("known model not enriched: %+v", models[0])
+	}
+	// Unknown model keeps the API display name without pricing.
+	if models[1].Name != "Claude Future" || models[1].InputCostPer1M != 0 {
+		t.Errorf("unknown model: %+v", models[1])
+	}
+}
+
+func TestListModelsAccessDenied(t *testing.T) {
+	a := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = io.WriteString(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+	})
+
+	_, err := a.ListModels(context.Background())
+	if !errors.Is(err, provider.ErrAccessDenied) {
+		t.Fatalf("expected ErrAccessDenied, got %v", err)
+	}
+}
+
+func TestCountTokens(t *testing.T) {
+	a := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages/count_tokens" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		for _, field := range []string{"max_tokens", "stream"} {
+			if _, ok := body[field]; ok {
+				t.Errorf("count request must not carry %q", field)
+			}
+		}
+		if body["system"] != "be brief" || body["model"] != "claude-sonnet-4-20250514" {
+			t.Errorf("body = %v", body)
+		}
+		_, _ = io.WriteString(w, `{"input_tokens":1234}`)
+	})
+
+	n, err := a.CountTokens(context.Background(), provider.Request{
+		Model:     "claude-sonnet-4-20250514",
+		System:    "be brief",
+		Messages:  []provider.Message{{Role: provider.RoleUser, Content: "hi"}},
+		MaxTokens: 1024,
+	})
+	if err != nil {
+		t.Fatalf("CountTokens: %v", err)
+	}
+	if n != 1234 {
+		t.Errorf("CountTokens = %d, want 1234", n)
+	}
+}
+
+func TestCountTokensThrottled(t *testing.T) {
+	a := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = io.WriteString(w, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)
+	})
+
+	_, err := a.CountTokens(context.Background(), provider.Request{
+		Model:    "claude-sonnet-4-20250514",
+		Messages: []provider.Message{{Role: provider.RoleUser, Content: "hi"}},
+	})
+	if !errors.Is(err, provider.ErrThrottled) {
+		t.Fatalf("expected ErrThrottled, got %v", err)
+	}
+}
+
+func TestKnownModelsPriced(t *testing.T) {
+	for id := range knownModels {
+		if _, ok := pricetable.Lookup(id); !ok {
+			t.Errorf("%s has no entry in the embedded price table", id)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package anthropic
+
+import (
+	"cosmos/core/provider"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+const defaultMaxTokens = 4096
+
+// messagesRequest is the JSON body of a POST /v1/messages call.
+type messagesRequest struct {
+	Model     string `json:"model"`
+	MaxTokens int    `json:"max_tokens"`
+	// System is a plain string, or a []contentBlock when it carries a
+	// cache breakpoint.
+	System   any          `json:"system,omitempty"`
+	Messages []apiMessage `json:"messages"`
+	Tools    []apiTool    `json:"tools,omitempty"`
+	Thinking *thinking    `json:"thinking,omitempty"`
+	Stream   bool         `json:"stream"`
+
+	ToolChoice    *toolChoice `json:"tool_choice,omitempty"`
+	Temperature   *float64    `json:"temperature,omitempty"`
+	TopP          *float64    `json:"top_p,omitempty"`
+	StopSequences []string    `json:"stop_sequences,omitempty"`
+}
+
+// toolChoice constrains tool use: type is "auto", "any", "none" or "tool".
+type toolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// countTokensRequest is the JSON body of a POST /v1/messages/count_tokens
+// call: a messages request without the generation settings.
+type countTokensRequest struct {
+	Model    string       `json:"model"`
+	System   any          `json:"system,omitempty"`
+	Messages []apiMessage `json:"messages"`
+	Tools    []apiTool    `json:"tools,omitempty"`
+	Thinking *thinking    `json:"thinking,omitempty"`
+}
+
+// thinking enables extended thinking for a request.
+type thinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// cacheControl marks the end of a cacheable prompt prefix.
+type cacheControl struct {
+	Type string `json:"type"`
+}
+
+var ephemeral = &cacheControl{Type: "ephemeral"}
+
+type apiMessage struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+// contentBlock is a union of the text, image, document, thinking, tool_use,
+// and tool_result block shapes.
+// Unused fields are omitted so each block serializes to its documented form.
+type contentBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// image, document
+	Source *mediaSource `json:"source,omitempty"`
+
+	// thinking, redacted_thinking
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result. Content is a string, or a []contentBlock when the
+	// result carries images or documents.
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   any    `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+
+	CacheControl *cacheControl `json:"cache_control,omitempty"`
+}
+
+// mediaSource is inline base64 data for an image or document block.
+type mediaSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type apiTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+
+	CacheControl *cacheControl `json:"cache_control,omitempty"`
+}
+
+func buildMessagesRequest(req provider.Request) (*messagesRequest, error) {
+	msgs, err := toAPIMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+
+	out := &messagesRequest{
+		Model:     req.Model,
+		MaxTokens: maxTokens,
+		Messages:  msgs,
+		Stream:    true,
+
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		StopSequences: req.StopSequences,
+	}
+	if req.ThinkingBudget > 0 {
+		// The thinking budget counts toward max_tokens, which must exceed it.
+		if out.MaxTokens <= req.ThinkingBudget {
+			out.MaxTokens += req.ThinkingBudget
+		}
+		out.Thinking = &thinking{Type: "enabled", BudgetTokens: req.ThinkingBudget}
+	}
+	if req.System != "" {
+		out.System = req.System
+		if req.CacheSystem {
+			out.System = []contentBlock{{Type: "text", Text: req.System, CacheControl: ephemeral}}
+		}
+	}
+
+	for _, t := range req.Tools {
+		schema := t.InputSchema
+		if schema == nil {
+			schema = map[string]any{"type": "object"}
+		}
+		out.Tools = append(out.Tools, apiTool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: schema,
+		})
+	}
+	if req.CacheTools && len(out.Tools) > 0 {
+		out.Tools[len(out.Tools)-1].CacheControl = ephemeral
+	}
+
+	if c := req.ToolChoice; c != nil {
+		if c.Forces() && len(out.Tools) == 0 {
+			return nil, fmt.Errorf("tool choice %q requires tool definitions", c.Mode)
+		}
+		switch c.Mode {
+		case provider.ToolChoiceAuto, provider.ToolChoiceAny, provider.ToolChoiceNone:
+			// The API's mode names match ours.
+			out.ToolChoice = &toolChoice{Type: string(c.Mode)}
+		case provider.ToolChoiceTool:
+			if c.Name == "" {
+				return nil, fmt.Errorf("tool choice %q requires a tool name", c.Mode)
+			}
+			out.ToolChoice = &toolChoice{Type: "tool", Name: c.Name}
+		default:
+			return nil, fmt.Errorf("unknown tool choice %q", c.Mode)
+		}
+	}
+
+	return out, nil
+}
+
+func buildCountTokensRequest(req provider.Request) (*countTokensRequest, error) {
+	body, err := buildMessagesRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return &countTokensRequest{
+		Model:    body.Model,
+		System:   body.System,
+		Messages: body.Messages,
+		Tools:    body.Tools,
+		Thinking: body.Thinking,
+	}, nil
+}
+
+func toAPIMessages(msgs []provider.Message) ([]apiMessage, error) {
+	out := make([]apiMessage, 0, len(msgs))
+	for _, m := range msgs {
+		am, err := toAPIMessage(m)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, am)
+	}
+	return out, nil
+}
+
+func toAPIMessage(m provider.Message) (apiMessage, error) {
+	role, err := toAPIRole(m.Role)
+	if err != nil {
+		return apiMessage{}, err
+	}
+
+	msg := apiMessage{Role: role}
+
+	// Thinking blocks lead an assistant turn, exactly as they were streamed.
+	for _, r := range m.Reasoning {
+		if len(r.Redacted) > 0 {
+			msg.Content = append(msg.Content, contentBlock{Type: "redacted_thinking", Data: string(r.Redacted)})
+			continue
+		}
+		msg.Content = append(msg.Content, contentBlock{Type: "thinking", Thinking: r.Text, Signature: r.Signature})
+	}
+
+	// Tool results must lead a user message, ahead of any text.
+	for _, tr := range m.ToolResults {
+		block := contentBlock{
+			Type:      "tool_result",
+			ToolUseID: tr.ToolUseID,
+			Content:   tr.Content,
+			IsError:   tr.IsError,
+		}
+		if len(tr.Blocks) > 0 {
+			var content []contentBlock
+			if tr.Content != "" {
+				content = append(content, contentBlock{Type: "text", Text: tr.Content})
+			}
+			for _, b := range tr.Blocks {
+				cb, err := toAPIContent(b)
+				if err != nil {
+					return apiMessage{}, err
+				}
+				content = append(content, cb)
+			}
+			block.Content = content
+		}
+		msg.Content = append(msg.Content, block)
+	}
+
+	for _, b := range m.Blocks {
+		cb, err := toAPIContent(b)
+		if err != nil {
+			return apiMessage{}, err
+		}
+		msg.Content = append(msg.Content, cb)
+	}
+
+	if m.Content != "" {
+		msg.Content = append(msg.Content, contentBlock{Type: "text", Text: m.Content})
+	}
+
+	for _, tc := range m.ToolCalls {
+		input := []byte("{}") // The API requires an object even for no-arg tools.
+		if len(tc.Input) > 0 {
+			input, err = json.Marshal(tc.Input)
+			if err != nil {
+				return apiMessage{}, fmt.Errorf("encoding input for tool %s: %w", tc.Name, err)
+			}
+		}
+		msg.Content = append(msg.Content, contentBlock{
+			Type:  "tool_use",
+			ID:    tc.ID,
+			Name:  tc.Name,
+			Input: input,
+		})
+	}
+
+	if len(msg.Content) == 0 {
+		return apiMessage{}, fmt.Errorf("message with role %q has no content (need text, tool calls, or tool results)", m.Role)
+	}
+	if m.CachePoint {
+		msg.Content[len(msg.Content)-1].CacheControl = ephemeral
+	}
+
+	return msg, nil
+}
+
+func toAPIContent(b provider.ContentBlock) (contentBlock, error) {
+	switch b.Type {
+	case provider.ContentText:
+		return contentBlock{Type: "text", Text: b.Text}, nil
+	case provider.ContentImage, provider.ContentDocument:
+		return contentBlock{
+			Type: string(b.Type),
+			Source: &mediaSource{
+				Type:      "base64",
+				MediaType: b.MediaType,
+				Data:      base64.StdEncoding.EncodeToString(b.Data),
+			},
+		}, nil
+	default:
+		return contentBlock{}, fmt.Errorf("unsupported content block type %q", b.Type)
+	}
+}
+
+func toAPIRole(r provider.Role) (string, error) {
+	switch r {
+	case provider.RoleUser, provider.RoleAssistant:
+		return string(r), nil
+	default:
+		return "", fmt.Errorf("unknown message role: %q", r)
+	}
+}
@@ -0,0 +1,243 @@
+package anthropic
+
+import (
+	"bufio"
+	"cosmos/core/provider"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type blockKind int
+
+const (
+	blockText blockKind = iota
+	blockTool
+)
+
+// maxEventSize bounds a single SSE data line. Tool input deltas are small,
+// but a generous limit avoids spurious bufio.ErrTooLong on large text deltas.
+const maxEventSize = 1 << 20
+
+// streamEvent is the union of all Messages API SSE payloads we consume.
+type streamEvent struct {
+	Type string `json:"type"`
+
+	// message_start
+	Message *struct {
+		Usage apiUsage `json:"usage"`
+	} `json:"message,omitempty"`
+
+	// content_block_start
+	ContentBlock *struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Data string `json:"data"` // redacted_thinking
+	} `json:"content_block,omitempty"`
+
+	// content_block_delta, message_delta
+	Delta *struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		Signature   string `json:"signature"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta,omitempty"`
+
+	// message_delta
+	Usage *apiUsage `json:"usage,omitempty"`
+
+	// error
+	Error *apiError `json:"error,omitempty"`
+}
+
+type apiUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+
+	ServiceTier string `json:"service_tier"`
+}
+
+// anthropicIterator reads server-sent events from a Messages API response
+// and translates them into provider stream chunks.
+type anthropicIterator struct {
+	body       io.ReadCloser
+	scanner    *bufio.Scanner
+	block      blockKind
+	usage      provider.Usage
+	stopReason string
+	done       bool
+}
+
+func newAnthropicIterator(body io.ReadCloser) *anthropicIterator {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+	return &anthropicIterator{body: body, scanner: scanner}
+}
+
+func (it *anthropicIterator) Next() (provider.StreamChunk, error) {
+	for {
+		if it.done {
+			return provider.StreamChunk{}, io.EOF
+		}
+
+		data, err := it.nextData()
+		if err != nil {
+			it.done = true
+			if err == io.EOF {
+				return provider.StreamChunk{}, fmt.Errorf("anthropic stream: connection closed before message_stop")
+			}
+			return provider.StreamChunk{}, fmt.Errorf("anthropic stream: %w", err)
+		}
+
+		var event streamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			it.done = true
+			return provider.StreamChunk{}, fmt.Errorf("anthropic stream: decode event: %w", err)
+		}
+
+		chunk, ok, err := it.translate(event)
+		if err != nil {
+			it.done = true
+			return provider.StreamChunk{}, fmt.Errorf("anthropic stream: %w", err)
+		}
+		if ok {
+			return chunk, nil
+		}
+	}
+}
+
+func (it *anthropicIterator) Close() error {
+	it.done = true
+	return it.body.Close()
+}
+
+// nextData returns the payload of the next SSE event. Multi-line data fields
+// are joined with newlines per the SSE spec; event names, ids and comments are
+// ignored because every payload carries its own "type" field.
+func (it *anthropicIterator) nextData() (string, error) {
+	var data []string
+	for it.scanner.Scan() {
+		line := it.scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				return strings.Join(data, "\n"), nil
+			}
+			continue
+		}
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(value, " "))
+		}
+	}
+	if err := it.scanner.Err(); err != nil {
+		return "", err
+	}
+	// Tolerate a final event without a trailing blank line.
+	if len(data) > 0 {
+		return strings.Join(data, "\n"), nil
+	}
+	return "", io.EOF
+}
+
+func (it *anthropicIterator) translate(event streamEvent) (provider.StreamChunk, bool, error) {
+	switch event.Type {
+	case "message_start":
+		if event.Message != nil {
+			it.usage.InputTokens = event.Message.Usage.InputTokens
+			it.usage.OutputTokens = event.Message.Usage.OutputTokens
+			it.usage.CacheReadTokens = event.Message.Usage.CacheReadInputTokens
+			it.usage.CacheWriteTokens = event.Message.Usage.CacheCreationInputTokens
+			if tier := event.Message.Usage.ServiceTier; tier != "standard" {
+				it.usage.ServiceTier = tier
+			}
+		}
+		return provider.StreamChunk{}, false, nil
+
+	case "content_block_start":
+		if event.ContentBlock != nil && event.ContentBlock.Type == "tool_use" {
+			it.block = blockTool
+			return provider.StreamChunk{
+				Event:      provider.EventToolStart,
+				ToolCallID: event.ContentBlock.ID,
+				ToolName:   event.ContentBlock.Name,
+			}, true, nil
+		}
+		it.block = blockText
+		if event.ContentBlock != nil && event.ContentBlock.Type == "redacted_thinking" {
+			return provider.StreamChunk{
+				Event:    provider.EventReasoningRedacted,
+				Redacted: []byte(event.ContentBlock.Data),
+			}, true, nil
+		}
+		return provider.StreamChunk{}, false, nil
+
+	case "content_block_delta":
+		if event.Delta == nil {
+			return provider.StreamChunk{}, false, nil
+		}
+		switch event.Delta.Type {
+		case "text_delta":
+			return provider.StreamChunk{
+				Event: provider.EventTextDelta,
+				Text:  event.Delta.Text,
+			}, true, nil
+		case "input_json_delta":
+			return provider.StreamChunk{
+				Event:      provider.EventToolDelta,
+				InputDelta: event.Delta.PartialJSON,
+			}, true, nil
+		case "thinking_delta":
+			return provider.StreamChunk{
+				Event: provider.EventReasoningDelta,
+				Text:  event.Delta.Thinking,
+			}, true, nil
+		case "signature_delta":
+			return provider.StreamChunk{
+				Event:     provider.EventReasoningSignature,
+				Signature: event.Delta.Signature,
+			}, true, nil
+		}
+		return provider.StreamChunk{}, false, nil
+
+	case "content_block_stop":
+		if it.block == blockTool {
+			it.block = blockText
+			return provider.StreamChunk{Event: provider.EventToolEnd}, true, nil
+		}
+		return provider.StreamChunk{}, false, nil
+
+	case "message_delta":
+		if event.Delta != nil && event.Delta.StopReason != "" {
+			it.stopReason = event.Delta.StopReason
+		}
+		// message_delta usage is cumulative for output tokens.
+		if event.Usage != nil {
+			it.usage.OutputTokens = event.Usage.OutputTokens
+		}
+		return provider.StreamChunk{}, false, nil
+
+	case "message_stop":
+		it.done = true
+		usage := it.usage
+		return provider.StreamChunk{
+			Event:      provider.EventMessageStop,
+			StopReason: it.stopReason,
+			Usage:      &usage,
+		}, true, nil
+
+	case "error":
+		if event.Error == nil {
+			return provider.StreamChunk{}, false, fmt.Errorf("unknown stream error")
+		}
+		return provider.StreamChunk{}, false, classifyErr(0, *event.Error)
+
+	default:
+		// ping and future event types.
+		return provider.StreamChunk{}, false, nil
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -19,39 +20,56 @@ import (
 
 // knownModels holds static metadata for Claude models on Bedrock.
 // The ListFoundationModels API does not return context windows or pricing,
-// so we maintain a static table for known models.
+// so we maintain a static table for known models. Prices are filled in from
+// the embedded pricetable.
 var knownModels = map[string]provider.ModelInfo{
 	"anthropic.claude-3-haiku-20240307-v1:0": {
 		ID: "anthropic.claude-3-haiku-20240307-v1:0", Name: "Claude 3 Haiku",
-		ContextWindow: 200_000, InputCostPer1M: 0.25, OutputCostPer1M: 1.25,
+		ContextWindow: 200_000, MaxOutputTokens: 4096,
 	},
 	"anthropic.claude-3-sonnet-20240229-v1:0": {
 		ID: "anthropic.claude-3-sonnet-20240229-v1:0", Name: "Claude 3 Sonnet",
-		ContextWindow: 200_000, InputCostPer1M: 3.0, OutputCostPer1M: 15.0,
+		ContextWindow: 200_000, MaxOutputTokens: 4096,
 	},
 	"anthropic.claude-3-opus-20240229-v1:0": {
 		ID: "anthropic.claude-3-opus-20240229-v1:0", Name: "Claude 3 Opus",
-		ContextWindow: 200_000, InputCostPer1M: 15.0, OutputCostPer1M: 75.0,
+		ContextWindow: 200_000, MaxOutputTokens: 4096,
 	},
 	"anthropic.claude-3-5-sonnet-20240620-v1:0": {
 		ID: "anthropic.claude-3-5-sonnet-20240620-v1:0", Name: "Claude 3.5 Sonnet",
-		ContextWindow: 200_000, InputCostPer1M: 3.0, OutputCostPer1M: 15.0,
+		ContextWindow: 200_000, MaxOutputTokens: 8192,
 	},
 	"anthropic.claude-3-5-sonnet-20241022-v2:0": {
 		ID: "anthropic.claude-3-5-sonnet-20241022-v2:0", Name: "Claude 3.5 Sonnet v2",
-		ContextWindow: 200_000, InputCostPer1M: 3.0, OutputCostPer1M: 15.0,
+		ContextWindow: 200_000, MaxOutputTokens: 8192,
 	},
 	"anthropic.claude-3-5-haiku-20241022-v1:0": {
 		ID: "anthropic.claude-3-5-haiku-20241022-v1:0", Name: "Claude 3.5 Haiku",
-		ContextWindow: 200_000, InputCostPer1M: 1.0, OutputCostPer1M: 5.0,
+		ContextWindow: 200_000, MaxOutputTokens: 8192,
 	},
 	"anthropic.claude-sonnet-4-20250514-v1:0": {
 		ID: "anthropic.claude-sonnet-4-20250514-v1:0", Name: "Claude Sonnet 4",
-		ContextWindow: 200_000, InputCostPer1M: 3.0, OutputCostPer1M: 15.0,
+		ContextWindow: 200_000, MaxOutputTokens: 64000,
 	},
 	"anthropic.claude-opus-4-20250514-v1:0": {
 		ID: "anthropic.claude-opus-4-20250514-v1:0", Name: "Claude Opus 4",
-		ContextWindow: 200_000, InputCostPer1M: 15.0, OutputCostPer1M: 75.0,
+		ContextWindow: 200_000, MaxOutputTokens: 32000,
+	},
+	"anthropic.claude-opus-4-1-20250805-v1:0": {
+		ID: "anthropic.claude-opus-4-1-20250805-v1:0", Name: "Claude Opus 4.1",
+		ContextWindow: 200_000, MaxOutputTokens: 32000,
+	},
+	"anthropic.claude-sonnet-4-5-20250929-v1:0": {
+		ID: "anthropic.claude-sonnet-4-5-20250929-v1:0", Name: "Claude Sonnet 4.5",
+		ContextWindow: 200_000, MaxOutputTokens: 64000,
+	},
+	"anthropic.claude-haiku-4-5-20251001-v1:0": {
+		ID: "anthropic.claude-haiku-4-5-20251001-v1:0", Name: "Claude Haiku 4.5",
+		ContextWindow: 200_000, MaxOutputTokens: 64000,
+	},
+	"anthropic.claude-opus-4-5-20251101-v1:0": {
+		ID: "anthropic.claude-opus-4-5-20251101-v1:0", Name: "Claude Opus 4.5",
+		ContextWindow: 200_000, MaxOutputTokens: 64000,
 	},
 }
 
@@ -59,6 +77,8 @@ var knownModels = map[string]provider.ModelInfo{
 // Defined as an interface for testability.
 type modelLister interface {
 	ListFoundationModels(ctx context.Context, params *bedrock.ListFoundationModelsInput, optFns ...func(*bedrock.Options)) (*bedrock.ListFoundationModelsOutput, error)
+	ListInferenceProfiles(ctx context.Context, params *bedrock.ListInferenceProfilesInput, optFns ...func(*bedrock.Options)) (*bedrock.ListInferenceProfilesOutput, error)
+	ListProvisionedModelThroughputs(ctx context.Context, params *bedrock.ListProvisionedModelThroughputsInput, optFns ...func(*bedrock.Options)) (*bedrock.ListProvisionedModelThroughputsOutput, error)
 }
 
 // Bedrock implements Provider using AWS Bedrock's ConverseStream API.
@@ -69,6 +89,9 @@ type Bedrock struct {
 	dynamicPricing map[string]provider.ModelInfo // populated lazily from AWS Pricing API
 	region         string
 	pricingCfg     provider.PricingConfig
+
+	mu         sync.Mutex
+	baseModels map[string]string // profile or provisioned model ID → foundation model ID
 }
 
 // NewBedrock creates a Bedrock provider configured for the given AWS region.
@@ -132,50 +155,80 @@ func (b *Bedrock) Send(ctx context.Context, req provider.Request) (provider.Stre
 	}, nil
 }
 
-// ListModels returns available Anthropic models from the Bedrock catalog,
-// enriched with pricing metadata (dynamic or static fallback).
+// CountTokens returns the input tokens req would use, as counted by the
+// Bedrock CountTokens API. Satisfies provider.TokenCounter.
+func (b *Bedrock) CountTokens(ctx context.Context, req provider.Request) (int, error) {
+	req.Model = b.baseModel(req.Model)
+	input, err := buildCountTokensInput(req)
+	if err != nil {
+		return 0, fmt.Errorf("building request: %w", err)
+	}
+
+	out, err := b.runtime.CountTokens(ctx, input)
+	if err != nil {
+		return 0, classifyErr(err)
+	}
+	return int(aws.ToInt32(out.InputTokens)), nil
+}
+
+// ListModels returns the Anthropic foundation models in the Bedrock catalog,
+// then the inference profiles and provisioned throughputs that run them,
+// enriched with pricing metadata (dynamic or static fallback). Profiles and
+// provisioned models carry their foundation model's metadata. Each source is
+// best-effort: accounts may only be allowed to list some of them, so an
+// error is returned only if all fail.
 func (b *Bedrock) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
 	// Lazy pricing fetch on first call
 	if b.pricingEngine != nil && b.dynamicPricing == nil {
 		_ = b.refreshPricing(ctx) // Non-fatal, ignore errors
 	}
 
+	var models []provider.ModelInfo
+	var errs []error
+
 	// Fetch catalog from Bedrock
 	out, err := b.catalog.ListFoundationModels(ctx, &bedrock.ListFoundationModelsInput{
 		ByProvider: aws.String("Anthropic"),
 	})
 	if err != nil {
-		return nil, classifyErr(err)
-	}
-
-	// Enrich with pricing: dynamic → static → none
-	var models []provider.ModelInfo
-	for _, summary := range out.ModelSummaries {
-		if !isUsableModel(summary) {
-			continue
+		errs = append(errs, classifyErr(err))
+	} else {
+		for _, summary := range out.ModelSummaries {
+			if isUsableModel(summary) {
+				models = append(models, b.foundationModelInfo(aws.ToString(summary.ModelId), aws.ToString(summary.ModelName)))
+			}
 		}
+	}
 
-		id := aws.ToString(summary.ModelId)
-
-		// Priority 1: Dynamic pricing from cache
-		if info, ok := b.dynamicPricing[id]; ok {
+	baseModels := make(map[string]string)
+	add := func(info provider.ModelInfo, base string, ok bool) {
+		if ok {
 			models = append(models, info)
-			continue
+			baseModels[info.ID] = base
 		}
-
-		// Priority 2: Static pricing from knownModels
-		if known, ok := knownModels[id]; ok {
-			models = append(models, known)
-			continue
+	}
+	if profiles, err := b.listInferenceProfiles(ctx); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, p := range profiles {
+			add(b.profileModelInfo(p))
 		}
+	}
+	if provisioned, err := b.listProvisionedModels(ctx); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, p := range provisioned {
+			add(b.provisionedModelInfo(p))
+		}
+	}
 
-		// Priority 3: No pricing data
-		models = append(models, provider.ModelInfo{
-			ID:   id,
-			Name: aws.ToString(summary.ModelName),
-		})
+	if len(errs) == 3 {
+		return nil, errors.Join(errs...)
 	}
 
+	b.mu.Lock()
+	b.baseModels = baseModels
+	b.mu.Unlock()
 	return models, nil
 }
 
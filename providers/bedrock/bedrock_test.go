@@ -5,6 +5,7 @@ import (
 	"cosmos/core/provider"
 	"errors"
 	"io"
+	"strconv"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -14,8 +15,11 @@ import (
 	"github.com/aws/smithy-go"
 )
 
-// Compile-time check: Bedrock satisfies Provider.
-var _ provider.Provider = (*Bedrock)(nil)
+// Compile-time checks: Bedrock satisfies Provider and TokenCounter.
+var (
+	_ provider.Provider     = (*Bedrock)(nil)
+	_ provider.TokenCounter = (*Bedrock)(nil)
+)
 
 // --- Role conversion tests ---
 
@@ -74,7 +78,7 @@ func TestToBedrockMessages(t *testing.T) {
 		},
 	}
 
-	out, err := toBedrockMessages(msgs)
+	out, err := toBedrockMessages(msgs, false)
 	if err != nil {
 		t.Fatalf("toBedrockMessages: %v", err)
 	}
@@ -152,11 +156,78 @@ func TestToBedrockMessageEmpty(t *testing.T) {
 	}
 }
 
+func TestToBedrockMessageContentBlocks(t *testing.T) {
+	msg, err := toBedrockMessage(provider.Message{
+		Role:    provider.RoleUser,
+		Content: "Compare these",
+		Blocks: []provider.ContentBlock{
+			{Type: provider.ContentImage, MediaType: "image/png", Name: "a.png", Data: []byte("png")},
+			{Type: provider.ContentDocument, MediaType: "application/pdf", Name: "Q3 report (final).v2.pdf", Data: []byte("pdf")},
+		},
+		ToolResults: []provider.ToolResult{{
+			ToolUseID: "tc1",
+			Content:   "captured",
+			Blocks:    []provider.ContentBlock{{Type: provider.ContentImage, MediaType: "image/jpeg", Data: []byte("jpg")}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("toBedrockMessage: %v", err)
+	}
+	// Attachments precede the prompt text; tool results come last.
+	if len(msg.Content) != 4 {
+		t.Fatalf("content blocks = %d, want 4", len(msg.Content))
+	}
+
+	img, ok := msg.Content[0].(*brtypes.ContentBlockMemberImage)
+	if !ok {
+		t.Fatalf("block 0 = %T, want image", msg.Content[0])
+	}
+	if img.Value.Format != brtypes.ImageFormatPng {
+		t.Errorf("image format = %q", img.Value.Format)
+	}
+	if src, ok := img.Value.Source.(*brtypes.ImageSourceMemberBytes); !ok || string(src.Value) != "png" {
+		t.Errorf("image source = %#v", img.Value.Source)
+	}
+
+	doc, ok := msg.Content[1].(*brtypes.ContentBlockMemberDocument)
+	if !ok {
+		t.Fatalf("block 1 = %T, want document", msg.Content[1])
+	}
+	if doc.Value.Format != brtypes.DocumentFormatPdf || aws.ToString(doc.Value.Name) != "Q3 report (final) v2" {
+		t.Errorf("document = format %q name %q", doc.Value.Format, aws.ToString(doc.Value.Name))
+	}
+
+	if _, ok := msg.Content[2].(*brtypes.ContentBlockMemberText); !ok {
+		t.Errorf("block 2 = %T, want text", msg.Content[2])
+	}
+
+	tr, ok := msg.Content[3].(*brtypes.ContentBlockMemberToolResult)
+	if !ok {
+		t.Fatalf("block 3 = %T, want tool result", msg.Content[3])
+	}
+	if len(tr.Value.Content) != 2 {
+		t.Fatalf("tool result content = %d, want 2", len(tr.Value.Content))
+	}
+	if trImg, ok := tr.Value.Content[1].(*brtypes.ToolResultContentBlockMemberImage); !ok || trImg.Value.Format != brtypes.ImageFormatJpeg {
+		t.Errorf("tool result block 1 = %#v, want jpeg image", tr.Value.Content[1])
+	}
+}
+
+func TestToBedrockMessageUnsupportedMediaType(t *testing.T) {
+	_, err := toBedrockMessage(provider.Message{
+		Role:   provider.RoleUser,
+		Blocks: []provider.ContentBlock{{Type: provider.ContentImage, MediaType: "image/tiff", Data: []byte("x")}},
+	})
+	if err == nil {
+		t.Fatal("expected error for unsupported image type, got nil")
+	}
+}
+
 func TestToBedrockMessagesPropagatesToBedrockMessageError(t *testing.T) {
 	_, err := toBedrockMessages([]provider.Message{
 		{Role: provider.RoleUser, Content: "ok"},
 		{Role: provider.Role("bad"), Content: "nope"},
-	})
+	}, false)
 	if err == nil {
 		t.Fatal("expected error from bad role in second message")
 	}
@@ -255,6 +326,220 @@ func TestBuildConverseStreamInputDefaults(t *testing.T) {
 	}
 }
 
+func TestBuildConverseStreamInputToolChoiceAndSampling(t *testing.T) {
+	temp, topP := 0.2, 0.9
+	tools := []provider.ToolDefinition{{Name: "extract", InputSchema: map[string]any{"type": "object"}}}
+	req := provider.Request{
+		Model:         "anthropic.claude-sonnet-4-20250514-v1:0",
+		Messages:      []provider.Message{{Role: provider.RoleUser, Content: "Hi"}},
+		Tools:         tools,
+		ToolChoice:    &provider.ToolChoice{Mode: provider.ToolChoiceTool, Name: "extract"},
+		Temperature:   &temp,
+		TopP:          &topP,
+		StopSequences: []string{"END"},
+	}
+
+	input, err := buildConverseStreamInput(req)
+	if err != nil {
+		t.Fatalf("buildConverseStreamInput: %v", err)
+	}
+	ic := input.InferenceConfig
+	if aws.ToFloat32(ic.Temperature) != 0.2 || aws.ToFloat32(ic.TopP) != 0.9 || len(ic.StopSequences) != 1 {
+		t.Errorf("inference config = %+v", ic)
+	}
+	choice, ok := input.ToolConfig.ToolChoice.(*brtypes.ToolChoiceMemberTool)
+	if !ok || aws.ToString(choice.Value.Name) != "extract" {
+		t.Errorf("tool choice = %#v, want specific tool extract", input.ToolConfig.ToolChoice)
+	}
+
+	req.ToolChoice = &provider.ToolChoice{Mode: provider.ToolChoiceAny}
+	if input, _ = buildConverseStreamInput(req); input.ToolConfig.ToolChoice == nil {
+		t.Error("any: expected ToolChoiceMemberAny")
+	} else if _, ok := input.ToolConfig.ToolChoice.(*brtypes.ToolChoiceMemberAny); !ok {
+		t.Errorf("any: got %T", input.ToolConfig.ToolChoice)
+	}
+
+	// Defaults leave sampling unset so the model's own defaults apply.
+	req.ToolChoice, req.Temperature, req.TopP = nil, nil, nil
+	input, _ = buildConverseStreamInput(req)
+	if input.InferenceConfig.Temperature != nil || input.InferenceConfig.TopP != nil || input.ToolConfig.ToolChoice != nil {
+		t.Errorf("unset options leaked into request: %+v", input.InferenceConfig)
+	}
+}
+
+func TestBuildConverseStreamInputToolChoiceNone(t *testing.T) {
+	tools := []provider.ToolDefinition{{Name: "extract", InputSchema: map[string]any{"type": "object"}}}
+	req := provider.Request{
+		Model:      "anthropic.claude-sonnet-4-20250514-v1:0",
+		Messages:   []provider.Message{{Role: provider.RoleUser, Content: "Hi"}},
+		Tools:      tools,
+		ToolChoice: &provider.ToolChoice{Mode: provider.ToolChoiceNone},
+	}
+	input, err := buildConverseStreamInput(req)
+	if err != nil {
+		t.Fatalf("buildConverseStreamInput: %v", err)
+	}
+	if input.ToolConfig != nil {
+		t.Error("none: tool definitions should be omitted")
+	}
+
+	// With tool use in history Bedrock requires the tool config.
+	req.Messages = append(req.Messages,
+		provider.Message{Role: provider.RoleAssistant, ToolCalls: []provider.ToolCall{{ID: "t1", Name: "extract", Input: map[string]any{}}}},
+		provider.Message{Role: provider.RoleUser, ToolResults: []provider.ToolResult{{ToolUseID: "t1", Content: "ok"}}},
+	)
+	if input, _ = buildConverseStreamInput(req); input.ToolConfig == nil {
+		t.Error("none with tool history: tool config must be kept")
+	}
+
+	req.Tools = nil
+	req.ToolChoice = &provider.ToolChoice{Mode: provider.ToolChoiceAny}
+	if _, err := buildConverseStreamInput(req); err == nil {
+		t.Error("expected error forcing a tool call without tools")
+	}
+}
+
+func TestBuildCountTokensInput(t *testing.T) {
+	req := provider.Request{
+		Model:     "us.anthropic.claude-sonnet-4-20250514-v1:0",
+		System:    "You are helpful.",
+		MaxTokens: 2048,
+		Messages:  []provider.Message{{Role: provider.RoleUser, Content: "Hi"}},
+		Tools:     []provider.ToolDefinition{{Name: "read", InputSchema: map[string]any{"type": "object"}}},
+	}
+
+	input, err := buildCountTokensInput(req)
+	if err != nil {
+		t.Fatalf("buildCountTokensInput: %v", err)
+	}
+	if got := aws.ToString(input.ModelId); got != "anthropic.claude-sonnet-4-20250514-v1:0" {
+		t.Errorf("model: got %q, want the foundation model ID", got)
+	}
+	converse, ok := input.Input.(*brtypes.CountTokensInputMemberConverse)
+	if !ok {
+		t.Fatalf("expected CountTokensInputMemberConverse, got %T", input.Input)
+	}
+	if len(converse.Value.Messages) != 1 || len(converse.Value.System) == 0 || converse.Value.ToolConfig == nil {
+		t.Errorf("converse request incomplete: %+v", converse.Value)
+	}
+}
+
+
+func TestFoundationModelID(t *testing.T) {
+	for id, want := range map[string]string{
+		"us.anthropic.claude-sonnet-4-20250514-v1:0":  "anthropic.claude-sonnet-4-20250514-v1:0",
+		"apac.anthropic.claude-3-haiku-20240307-v1:0": "anthropic.claude-3-haiku-20240307-v1:0",
+		"anthropic.claude-3-haiku-20240307-v1:0":      "anthropic.claude-3-haiku-20240307-v1:0",
+		"amazon.nova-pro-v1:0":                        "amazon.nova-pro-v1:0",
+	} {
+		if got := foundationModelID(id); got != want {
+			t.Errorf("foundationModelID(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestBuildConverseStreamInputCachePoints(t *testing.T) {
+	req := provider.Request{
+		Model:       "us.anthropic.claude-sonnet-4-20250514-v1:0",
+		System:      "You are helpful.",
+		CacheSystem: true,
+		CacheTools:  true,
+		Tools:       []provider.ToolDefinition{{Name: "read", InputSchema: map[string]any{"type": "object"}}},
+		Messages: []provider.Message{
+			{Role: provider.RoleUser, Content: "Hi", CachePoint: true},
+			{Role: provider.RoleAssistant, Content: "Hello"},
+		},
+	}
+
+	input, err := buildConverseStreamInput(req)
+	if err != nil {
+		t.Fatalf("buildConverseStreamInput: %v", err)
+	}
+
+	if len(input.System) != 2 {
+		t.Fatalf("expected system text + cache point, got %d blocks", len(input.System))
+	}
+	if _, ok := input.System[1].(*brtypes.SystemContentBlockMemberCachePoint); !ok {
+		t.Errorf("system[1]: got %T, want cache point", input.System[1])
+	}
+	tools := input.ToolConfig.Tools
+	if _, ok := tools[len(tools)-1].(*brtypes.ToolMemberCachePoint); !ok || len(tools) != 2 {
+		t.Errorf("tools: got %d entries, last %T; want tool + cache point", len(tools), tools[len(tools)-1])
+	}
+	user := input.Messages[0].Content
+	if _, ok := user[len(user)-1].(*brtypes.ContentBlockMemberCachePoint); !ok {
+		t.Errorf("marked user message should end with a cache point, got %T", user[len(user)-1])
+	}
+	if n := len(input.Messages[1].Content); n != 1 {
+		t.Errorf("unmarked message: got %d blocks, want 1", n)
+	}
+}
+
+func TestBuildConverseStreamInputCachePointsUnsupportedModel(t *testing.T) {
+	req := provider.Request{
+		Model:       "anthropic.claude-3-haiku-20240307-v1:0",
+		System:      "You are helpful.",
+		CacheSystem: true,
+		Messages:    []provider.Message{{Role: provider.RoleUser, Content: "Hi", CachePoint: true}},
+	}
+
+	input, err := buildConverseStreamInput(req)
+	if err != nil {
+		t.Fatalf("buildConverseStreamInput: %v", err)
+	}
+	if len(input.System) != 1 || len(input.Messages[0].Content) != 1 {
+		t.Errorf("cache points must be dropped for models without caching: system %d, content %d",
+			len(input.System), len(input.Messages[0].Content))
+	}
+}
+
+func TestBuildConverseStreamInputThinking(t *testing.T) {
+	req := provider.Request{
+		Model:          "us.anthropic.claude-sonnet-4-20250514-v1:0",
+		MaxTokens:      1024,
+		ThinkingBudget: 2048,
+		Messages: []provider.Message{
+			{Role: provider.RoleUser, Content: "Hi"},
+			{
+				Role:      provider.RoleAssistant,
+				Content:   "Checking.",
+				Reasoning: []provider.ReasoningBlock{{Text: "hmm", Signature: "sig"}, {Redacted: []byte("x")}},
+			},
+		},
+	}
+
+	input, err := buildConverseStreamInput(req)
+	if err != nil {
+		t.Fatalf("buildConverseStreamInput: %v", err)
+	}
+
+	if got := aws.ToInt32(input.InferenceConfig.MaxTokens); got != 3072 {
+		t.Errorf("max tokens: got %d, want 3072 (raised above the budget)", got)
+	}
+	raw, err := input.AdditionalModelRequestFields.MarshalSmithyDocument()
+	if err != nil {
+		t.Fatalf("marshal additional fields: %v", err)
+	}
+	if string(raw) != `{"thinking":{"budget_tokens":2048,"type":"enabled"}}` {
+		t.Errorf("additional fields: got %s", raw)
+	}
+
+	content := input.Messages[1].Content
+	if len(content) != 3 {
+		t.Fatalf("assistant: expected 2 reasoning blocks + text, got %d", len(content))
+	}
+	text, ok := content[0].(*brtypes.ContentBlockMemberReasoningContent).Value.(*brtypes.ReasoningContentBlockMemberReasoningText)
+	if !ok || aws.ToString(text.Value.Text) != "hmm" || aws.ToString(text.Value.Signature) != "sig" {
+		t.Errorf("block 0: got %+v", content[0])
+	}
+	if _, ok := content[1].(*brtypes.ContentBlockMemberReasoningContent).Value.(*brtypes.ReasoningContentBlockMemberRedactedContent); !ok {
+		t.Errorf("block 1: expected redacted reasoning, got %+v", content[1])
+	}
+	if _, ok := content[2].(*brtypes.ContentBlockMemberText); !ok {
+		t.Errorf("block 2: expected text after reasoning, got %T", content[2])
+	}
+}
+
 func TestBuildConverseStreamInputBadRole(t *testing.T) {
 	req := provider.Request{
 		Model:    "model",
@@ -317,8 +602,11 @@ func TestClassifyErr(t *testing.T) {
 // --- ListModels tests ---
 
 type stubCatalog struct {
-	summaries []bedrocktypes.FoundationModelSummary
-	err       error
+	summaries   []bedrocktypes.FoundationModelSummary
+	profiles    []bedrocktypes.InferenceProfileSummary
+	provisioned []bedrocktypes.ProvisionedModelSummary
+	err         error // returned by every call
+	profileErr  error // returned by the profile and provisioned calls only
 }
 
 func (s *stubCatalog) ListFoundationModels(_ context.Context, _ *bedrock.ListFoundationModelsInput, _ ...func(*bedrock.Options)) (*bedrock.ListFoundationModelsOutput, error) {
@@ -328,6 +616,44 @@ func (s *stubCatalog) ListFoundationModels(_ context.Context, _ *bedrock.ListFou
 	return &bedrock.ListFoundationModelsOutput{ModelSummaries: s.summaries}, nil
 }
 
+// ListInferenceProfiles returns one profile per page to exercise pagination.
+func (s *stubCatalog) ListInferenceProfiles(_ context.Context, in *bedrock.ListInferenceProfilesInput, _ ...func(*bedrock.Options)) (*bedrock.ListInferenceProfilesOutput, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.profileErr != nil {
+		return nil, s.profileErr
+	}
+	var matching []bedrocktypes.InferenceProfileSummary
+	for _, p := range s.profiles {
+		if p.Type == in.TypeEquals {
+			matching = append(matching, p)
+		}
+	}
+	page := 0
+	if in.NextToken != nil {
+		page, _ = strconv.Atoi(*in.NextToken)
+	}
+	out := &bedrock.ListInferenceProfilesOutput{}
+	if page < len(matching) {
+		out.InferenceProfileSummaries = matching[page : page+1]
+	}
+	if page+1 < len(matching) {
+		out.NextToken = aws.String(strconv.Itoa(page + 1))
+	}
+	return out, nil
+}
+
+func (s *stubCatalog) ListProvisionedModelThroughputs(_ context.Context, _ *bedrock.ListProvisionedModelThroughputsInput, _ ...func(*bedrock.Options)) (*bedrock.ListProvisionedModelThroughputsOutput, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.profileErr != nil {
+		return nil, s.profileErr
+	}
+	return &bedrock.ListProvisionedModelThroughputsOutput{ProvisionedModelSummaries: s.provisioned}, nil
+}
+
 func TestListModelsFiltersAndEnriches(t *testing.T) {
 	catalog := &stubCatalog{
 		summaries: []bedrocktypes.FoundationModelSummary{
@@ -413,6 +739,105 @@ func TestListModelsAPIError(t *testing.T) {
 	}
 }
 
+func TestListModelsProfilesAndProvisioned(t *testing.T) {
+	const (
+		sonnet    = "anthropic.claude-sonnet-4-20250514-v1:0"
+		sonnetArn = "arn:aws:bedrock:us-east-1::foundation-model/" + sonnet
+		appArn    = "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/abc123"
+		ptArn     = "arn:aws:bedrock:us-east-1:123456789012:provisioned-model/xyz789"
+	)
+	active := bedrocktypes.InferenceProfileStatusActive
+	catalog := &stubCatalog{
+		profiles: []bedrocktypes.InferenceProfileSummary{
+			{
+				InferenceProfileId:   aws.String("us." + sonnet),
+				InferenceProfileArn:  aws.String("arn:aws:bedrock:us-east-1:123456789012:inference-profile/us." + sonnet),
+				InferenceProfileName: aws.String("US Claude Sonnet 4"),
+				Type:                 bedrocktypes.InferenceProfileTypeSystemDefined,
+				Status:               active,
+				Models: []bedrocktypes.InferenceProfileModel{
+					{ModelArn: aws.String(sonnetArn)},
+					{ModelArn: aws.String("arn:aws:bedrock:us-west-2::foundation-model/" + sonnet)},
+				},
+			},
+			{
+				InferenceProfileId:   aws.String("abc123"),
+				InferenceProfileArn:  aws.String(appArn),
+				InferenceProfileName: aws.String("team-sonnet"),
+				Type:                 bedrocktypes.InferenceProfileTypeApplication,
+				Status:               active,
+				Models:               []bedrocktypes.InferenceProfileModel{{ModelArn: aws.String(sonnetArn)}},
+			},
+			{
+				// Not an Anthropic model — skipped.
+				InferenceProfileId: aws.String("us.meta.llama3-3-70b-instruct-v1:0"),
+				Type:               bedrocktypes.InferenceProfileTypeSystemDefined,
+				Status:             active,
+				Models: []bedrocktypes.InferenceProfileModel{
+					{ModelArn: aws.String("arn:aws:bedrock:us-east-1::foundation-model/meta.llama3-3-70b-instruct-v1:0")},
+				},
+			},
+		},
+		provisioned: []bedrocktypes.ProvisionedModelSummary{{
+			ProvisionedModelArn:  aws.String(ptArn),
+			ProvisionedModelName: aws.String("sonnet-pt"),
+			FoundationModelArn:   aws.String(sonnetArn),
+		}},
+	}
+
+	b := &Bedrock{catalog: catalog}
+	models, err := b.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+
+	byID := make(map[string]provider.ModelInfo)
+	for _, m := range models {
+		byID[m.ID] = m
+	}
+	if len(models) != 3 {
+		t.Fatalf("expected 3 models, got %d: %+v", len(models), models)
+	}
+
+	system := byID["us."+sonnet]
+	if system.Name != "US Claude Sonnet 4" || system.InputCostPer1M != 3.0 || system.ContextWindow != 200_000 {
+		t.Errorf("system profile = %+v, want the foundation model's pricing", system)
+	}
+	app, ok := byID[appArn]
+	if !ok || app.Name != "team-sonnet" || app.OutputCostPer1M != 15.0 || app.MaxOutputTokens != 64000 {
+		t.Errorf("application profile = %+v, want listed by ARN with foundation metadata", app)
+	}
+	pt := byID[ptArn]
+	if pt.Name != "sonnet-pt" || pt.ContextWindow != 200_000 || pt.InputCostPer1M != 0 {
+		t.Errorf("provisioned = %+v, want context window and no token pricing", pt)
+	}
+
+	for _, id := range []string{appArn, ptArn, "us." + sonnet, "eu." + sonnet} {
+		if got := b.baseModel(id); got != sonnet {
+			t.Errorf("baseModel(%q) = %q, want %q", id, got, sonnet)
+		}
+	}
+}
+
+func TestListModelsProfilesDenied(t *testing.T) {
+	catalog := &stubCatalog{
+		summaries: []bedrocktypes.FoundationModelSummary{{
+			ModelId:                    aws.String("anthropic.claude-3-haiku-20240307-v1:0"),
+			ResponseStreamingSupported: aws.Bool(true),
+			OutputModalities:           []bedrocktypes.ModelModality{bedrocktypes.ModelModalityText},
+		}},
+		profileErr: &stubAPIError{code: "AccessDeniedException", message: "no"},
+	}
+	b := &Bedrock{catalog: catalog}
+	models, err := b.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v; want foundation models despite denied profile listing", err)
+	}
+	if len(models) != 1 {
+		t.Errorf("expected 1 model, got %+v", models)
+	}
+}
+
 // --- Iterator tests ---
 
 type fakeStream struct {
@@ -500,6 +925,87 @@ func TestIteratorTextStream(t *testing.T) {
 	}
 }
 
+func TestIteratorCacheUsage(t *testing.T) {
+	stream := newFakeStream(
+		&brtypes.ConverseStreamOutputMemberMessageStop{
+			Value: brtypes.MessageStopEvent{StopReason: brtypes.StopReasonEndTurn},
+		},
+		&brtypes.ConverseStreamOutputMemberMetadata{
+			Value: brtypes.ConverseStreamMetadataEvent{
+				Usage: &brtypes.TokenUsage{
+					InputTokens:           aws.Int32(12),
+					OutputTokens:          aws.Int32(5),
+					CacheReadInputTokens:  aws.Int32(9000),
+					CacheWriteInputTokens: aws.Int32(400),
+				},
+				ServiceTier: &brtypes.ServiceTier{Type: brtypes.ServiceTierTypeFlex},
+			},
+		},
+	)
+
+	iter := &bedrockIterator{stream: stream, events: stream.Events()}
+	chunk, err := iter.Next()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := provider.Usage{InputTokens: 12, OutputTokens: 5, CacheReadTokens: 9000, CacheWriteTokens: 400, ServiceTier: "flex"}
+	if chunk.Usage == nil || *chunk.Usage != want {
+		t.Errorf("usage: got %+v, want %+v", chunk.Usage, want)
+	}
+}
+
+func TestIteratorReasoningStream(t *testing.T) {
+	reasoning := func(d brtypes.ReasoningContentBlockDelta) brtypes.ConverseStreamOutput {
+		return &brtypes.ConverseStreamOutputMemberContentBlockDelta{
+			Value: brtypes.ContentBlockDeltaEvent{
+				Delta: &brtypes.ContentBlockDeltaMemberReasoningContent{Value: d},
+			},
+		}
+	}
+	stream := newFakeStream(
+		reasoning(&brtypes.ReasoningContentBlockDeltaMemberText{Value: "Let me think"}),
+		reasoning(&brtypes.ReasoningContentBlockDeltaMemberSignature{Value: "sig"}),
+		&brtypes.ConverseStreamOutputMemberContentBlockStop{
+			Value: brtypes.ContentBlockStopEvent{ContentBlockIndex: aws.Int32(0)},
+		},
+		reasoning(&brtypes.ReasoningContentBlockDeltaMemberRedactedContent{Value: []byte{0xff}}),
+		&brtypes.ConverseStreamOutputMemberContentBlockDelta{
+			Value: brtypes.ContentBlockDeltaEvent{
+				Delta: &brtypes.ContentBlockDeltaMemberText{Value: "Answer"},
+			},
+		},
+	)
+
+	iter := &bedrockIterator{stream: stream, events: stream.Events()}
+	var chunks []provider.StreamChunk
+	for {
+		chunk, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	if len(chunks) != 4 {
+		t.Fatalf("expected 4 chunks, got %d: %+v", len(chunks), chunks)
+	}
+	if chunks[0].Event != provider.EventReasoningDelta || chunks[0].Text != "Let me think" {
+		t.Errorf("chunk 0: got %+v", chunks[0])
+	}
+	if chunks[1].Event != provider.EventReasoningSignature || chunks[1].Signature != "sig" {
+		t.Errorf("chunk 1: got %+v", chunks[1])
+	}
+	if chunks[2].Event != provider.EventReasoningRedacted || len(chunks[2].Redacted) != 1 {
+		t.Errorf("chunk 2: got %+v", chunks[2])
+	}
+	if chunks[3].Event != provider.EventTextDelta {
+		t.Errorf("chunk 3: got %+v", chunks[3])
+	}
+}
+
 func TestIteratorToolUseStream(t *testing.T) {
 	stream := newFakeStream(
 		&brtypes.ConverseStreamOutputMemberMessageStart{},
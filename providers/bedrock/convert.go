@@ -3,6 +3,10 @@ package bedrock
 import (
 	"cosmos/core/provider"
 	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
@@ -12,8 +16,36 @@ import (
 
 const defaultMaxTokens = 4096
 
+// promptCacheFamilies lists model ID fragments for which Bedrock accepts
+// cache points. Other models reject requests containing them, so cache
+// breakpoints are silently dropped for anything not listed here.
+var promptCacheFamilies = []string{
+	"claude-3-5-haiku",
+	"claude-3-7-sonnet",
+	"claude-sonnet-4",
+	"claude-opus-4",
+	"amazon.nova",
+}
+
+// supportsPromptCache reports whether modelID (base or inference profile)
+// accepts cache points.
+func supportsPromptCache(modelID string) bool {
+	for _, family := range promptCacheFamilies {
+		if strings.Contains(modelID, family) {
+			return true
+		}
+	}
+	return false
+}
+
+func cachePoint() brtypes.CachePointBlock {
+	return brtypes.CachePointBlock{Type: brtypes.CachePointTypeDefault}
+}
+
 func buildConverseStreamInput(req provider.Request) (*bedrockruntime.ConverseStreamInput, error) {
-	msgs, err := toBedrockMessages(req.Messages)
+	useCache := supportsPromptCache(req.Model)
+
+	msgs, err := toBedrockMessages(req.Messages, useCache)
 	if err != nil {
 		return nil, err
 	}
@@ -27,34 +59,139 @@ func buildConverseStreamInput(req provider.Request) (*bedrockruntime.ConverseStr
 		input.System = []brtypes.SystemContentBlock{
 			&brtypes.SystemContentBlockMemberText{Value: req.System},
 		}
+		if useCache && req.CacheSystem {
+			input.System = append(input.System, &brtypes.SystemContentBlockMemberCachePoint{Value: cachePoint()})
+		}
 	}
 
 	maxTokens := req.MaxTokens
 	if maxTokens <= 0 {
 		maxTokens = defaultMaxTokens
 	}
+	if req.ThinkingBudget > 0 {
+		// The thinking budget counts toward max_tokens, which must exceed it.
+		if maxTokens <= req.ThinkingBudget {
+			maxTokens += req.ThinkingBudget
+		}
+		input.AdditionalModelRequestFields = brdocument.NewLazyDocument(map[string]any{
+			"thinking": map[string]any{
+				"type":          "enabled",
+				"budget_tokens": req.ThinkingBudget,
+			},
+		})
+	}
 	input.InferenceConfig = &brtypes.InferenceConfiguration{
-		MaxTokens: aws.Int32(int32(maxTokens)),
+		MaxTokens:     aws.Int32(int32(maxTokens)),
+		StopSequences: req.StopSequences,
+	}
+	if req.Temperature != nil {
+		input.InferenceConfig.Temperature = aws.Float32(float32(*req.Temperature))
+	}
+	if req.TopP != nil {
+		input.InferenceConfig.TopP = aws.Float32(float32(*req.TopP))
 	}
 
-	if len(req.Tools) > 0 {
+	if len(req.Tools) > 0 && !omitTools(req) {
 		tc, err := toBedrockToolConfig(req.Tools)
 		if err != nil {
 			return nil, err
 		}
+		if useCache && req.CacheTools {
+			tc.Tools = append(tc.Tools, &brtypes.ToolMemberCachePoint{Value: cachePoint()})
+		}
+		if tc.ToolChoice, err = toBedrockToolChoice(req.ToolChoice); err != nil {
+			return nil, err
+		}
 		input.ToolConfig = tc
+	} else if req.ToolChoice.Forces() {
+		return nil, fmt.Errorf("tool choice %q requires tool definitions", req.ToolChoice.Mode)
 	}
 
 	return input, nil
 }
 
-func toBedrockMessages(msgs []provider.Message) ([]brtypes.Message, error) {
+// omitTools reports whether tool definitions are left out to honor
+// ToolChoiceNone, which Converse has no setting for. Once the conversation
+// contains tool use, Bedrock requires the definitions, so they are kept and
+// the model is left to decide.
+func omitTools(req provider.Request) bool {
+	if req.ToolChoice == nil || req.ToolChoice.Mode != provider.ToolChoiceNone {
+		return false
+	}
+	for _, m := range req.Messages {
+		if len(m.ToolCalls) > 0 || len(m.ToolResults) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// toBedrockToolChoice maps a tool choice; nil, auto and none yield nil,
+// which Bedrock treats as auto.
+func toBedrockToolChoice(c *provider.ToolChoice) (brtypes.ToolChoice, error) {
+	if c == nil {
+		return nil, nil
+	}
+	switch c.Mode {
+	case provider.ToolChoiceAuto, provider.ToolChoiceNone:
+		return nil, nil
+	case provider.ToolChoiceAny:
+		return &brtypes.ToolChoiceMemberAny{}, nil
+	case provider.ToolChoiceTool:
+		if c.Name == "" {
+			return nil, fmt.Errorf("tool choice %q requires a tool name", c.Mode)
+		}
+		return &brtypes.ToolChoiceMemberTool{Value: brtypes.SpecificToolChoice{Name: aws.String(c.Name)}}, nil
+	default:
+		return nil, fmt.Errorf("unknown tool choice %q", c.Mode)
+	}
+}
+
+// inferenceProfileGeos lists the geography prefixes of cross-region
+// inference profile IDs, e.g. "us." in "us.anthropic.claude-sonnet-4-...".
+var inferenceProfileGeos = []string{"us", "us-gov", "eu", "apac", "ap", "jp", "au", "ca", "global"}
+
+// foundationModelID returns the foundation model behind an inference profile
+// ID, or modelID unchanged if it has no geography prefix. Some APIs, such as
+// CountTokens, accept only foundation model IDs.
+func foundationModelID(modelID string) string {
+	geo, rest, ok := strings.Cut(modelID, ".")
+	if ok && strings.Contains(rest, ".") && slices.Contains(inferenceProfileGeos, geo) {
+		return rest
+	}
+	return modelID
+}
+
+// buildCountTokensInput wraps the Converse form of req for CountTokens.
+// Inference settings do not affect the count and are left out.
+func buildCountTokensInput(req provider.Request) (*bedrockruntime.CountTokensInput, error) {
+	converse, err := buildConverseStreamInput(req)
+	if err != nil {
+		return nil, err
+	}
+	return &bedrockruntime.CountTokensInput{
+		ModelId: aws.String(foundationModelID(req.Model)),
+		Input: &brtypes.CountTokensInputMemberConverse{Value: brtypes.ConverseTokensRequest{
+			Messages:                     converse.Messages,
+			System:                       converse.System,
+			ToolConfig:                   converse.ToolConfig,
+			AdditionalModelRequestFields: converse.AdditionalModelRequestFields,
+		}},
+	}, nil
+}
+
+// toBedrockMessages converts history to Bedrock messages. Message cache
+// points are emitted only when useCache is set (the model supports them).
+func toBedrockMessages(msgs []provider.Message, useCache bool) ([]brtypes.Message, error) {
 	out := make([]brtypes.Message, 0, len(msgs))
 	for _, m := range msgs {
 		bm, err := toBedrockMessage(m)
 		if err != nil {
 			return nil, err
 		}
+		if useCache && m.CachePoint {
+			bm.Content = append(bm.Content, &brtypes.ContentBlockMemberCachePoint{Value: cachePoint()})
+		}
 		out = append(out, bm)
 	}
 	return out, nil
@@ -68,6 +205,19 @@ func toBedrockMessage(m provider.Message) (brtypes.Message, error) {
 
 	msg := brtypes.Message{Role: role}
 
+	// Reasoning must precede the text and tool use it led to.
+	for _, r := range m.Reasoning {
+		msg.Content = append(msg.Content, toBedrockReasoning(r))
+	}
+
+	for _, b := range m.Blocks {
+		block, err := toBedrockContent(b)
+		if err != nil {
+			return brtypes.Message{}, err
+		}
+		msg.Content = append(msg.Content, block)
+	}
+
 	if m.Content != "" {
 		msg.Content = append(msg.Content, &brtypes.ContentBlockMemberText{Value: m.Content})
 	}
@@ -87,13 +237,21 @@ func toBedrockMessage(m provider.Message) (brtypes.Message, error) {
 		if tr.IsError {
 			status = brtypes.ToolResultStatusError
 		}
+		content := []brtypes.ToolResultContentBlock{
+			&brtypes.ToolResultContentBlockMemberText{Value: tr.Content},
+		}
+		for _, b := range tr.Blocks {
+			block, err := toBedrockToolResultContent(b)
+			if err != nil {
+				return brtypes.Message{}, err
+			}
+			content = append(content, block)
+		}
 		msg.Content = append(msg.Content, &brtypes.ContentBlockMemberToolResult{
 			Value: brtypes.ToolResultBlock{
 				ToolUseId: aws.String(tr.ToolUseID),
 				Status:    status,
-				Content: []brtypes.ToolResultContentBlock{
-					&brtypes.ToolResultContentBlockMemberText{Value: tr.Content},
-				},
+				Content:   content,
 			},
 		})
 	}
@@ -105,6 +263,122 @@ func toBedrockMessage(m provider.Message) (brtypes.Message, error) {
 	return msg, nil
 }
 
+func toBedrockReasoning(r provider.ReasoningBlock) brtypes.ContentBlock {
+	if len(r.Redacted) > 0 {
+		return &brtypes.ContentBlockMemberReasoningContent{
+			Value: &brtypes.ReasoningContentBlockMemberRedactedContent{Value: r.Redacted},
+		}
+	}
+	block := brtypes.ReasoningTextBlock{Text: aws.String(r.Text)}
+	if r.Signature != "" {
+		block.Signature = aws.String(r.Signature)
+	}
+	return &brtypes.ContentBlockMemberReasoningContent{
+		Value: &brtypes.ReasoningContentBlockMemberReasoningText{Value: block},
+	}
+}
+
+var imageFormats = map[string]brtypes.ImageFormat{
+	"image/png":  brtypes.ImageFormatPng,
+	"image/jpeg": brtypes.ImageFormatJpeg,
+	"image/gif":  brtypes.ImageFormatGif,
+	"image/webp": brtypes.ImageFormatWebp,
+}
+
+var documentFormats = map[string]brtypes.DocumentFormat{
+	"application/pdf": brtypes.DocumentFormatPdf,
+	"text/plain":      brtypes.DocumentFormatTxt,
+	"text/markdown":   brtypes.DocumentFormatMd,
+	"text/csv":        brtypes.DocumentFormatCsv,
+	"text/html":       brtypes.DocumentFormatHtml,
+}
+
+func toBedrockContent(b provider.ContentBlock) (brtypes.ContentBlock, error) {
+	switch b.Type {
+	case provider.ContentText:
+		return &brtypes.ContentBlockMemberText{Value: b.Text}, nil
+	case provider.ContentImage:
+		img, err := toBedrockImage(b)
+		if err != nil {
+			return nil, err
+		}
+		return &brtypes.ContentBlockMemberImage{Value: img}, nil
+	case provider.ContentDocument:
+		doc, err := toBedrockDocument(b)
+		if err != nil {
+			return nil, err
+		}
+		return &brtypes.ContentBlockMemberDocument{Value: doc}, nil
+	default:
+		return nil, fmt.Errorf("unsupported content block type %q", b.Type)
+	}
+}
+
+func toBedrockToolResultContent(b provider.ContentBlock) (brtypes.ToolResultContentBlock, error) {
+	switch b.Type {
+	case provider.ContentText:
+		return &brtypes.ToolResultContentBlockMemberText{Value: b.Text}, nil
+	case provider.ContentImage:
+		img, err := toBedrockImage(b)
+		if err != nil {
+			return nil, err
+		}
+		return &brtypes.ToolResultContentBlockMemberImage{Value: img}, nil
+	case provider.ContentDocument:
+		doc, err := toBedrockDocument(b)
+		if err != nil {
+			return nil, err
+		}
+		return &brtypes.ToolResultContentBlockMemberDocument{Value: doc}, nil
+	default:
+		return nil, fmt.Errorf("unsupported content block type %q", b.Type)
+	}
+}
+
+func toBedrockImage(b provider.ContentBlock) (brtypes.ImageBlock, error) {
+	format, ok := imageFormats[b.MediaType]
+	if !ok {
+		return brtypes.ImageBlock{}, fmt.Errorf("unsupported image media type %q", b.MediaType)
+	}
+	return brtypes.ImageBlock{
+		Format: format,
+		Source: &brtypes.ImageSourceMemberBytes{Value: b.Data},
+	}, nil
+}
+
+func toBedrockDocument(b provider.ContentBlock) (brtypes.DocumentBlock, error) {
+	format, ok := documentFormats[b.MediaType]
+	if !ok {
+		return brtypes.DocumentBlock{}, fmt.Errorf("unsupported document media type %q", b.MediaType)
+	}
+	return brtypes.DocumentBlock{
+		Format: format,
+		Name:   aws.String(documentName(b.Name)),
+		Source: &brtypes.DocumentSourceMemberBytes{Value: b.Data},
+	}, nil
+}
+
+// documentName reduces a file name to the characters Bedrock allows in
+// document names: alphanumerics, single spaces, hyphens, parentheses and
+// square brackets. The extension is dropped since dots are rejected.
+func documentName(name string) string {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	cleaned := strings.Map(func(r rune) rune {
+		switch {
+		case r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)),
+			strings.ContainsRune("-()[]", r):
+			return r
+		default:
+			return ' '
+		}
+	}, name)
+	cleaned = strings.Join(strings.Fields(cleaned), " ")
+	if cleaned == "" {
+		return "document"
+	}
+	return cleaned
+}
+
 func toBedrockRole(r provider.Role) (brtypes.ConversationRole, error) {
 	switch r {
 	case provider.RoleUser:
@@ -124,8 +124,24 @@ type comparison struct {
 	OutputIsGlobal           *bool   `json:"output_is_global"`
 	InputUsageType           *string `json:"input_usagetype"`
 	OutputUsageType          *string `json:"output_usagetype"`
+
+	// Standard-tier prices for requests above the long-context threshold,
+	// and short-context prices per non-standard service tier.
+	LongContextInputUSDPer1MTokens  *string              `json:"long_context_input_usd_per_1m_tokens,omitempty"`
+	LongContextOutputUSDPer1MTokens *string              `json:"long_context_output_usd_per_1m_tokens,omitempty"`
+	Tiers                           map[string]tierPrice `json:"tiers,omitempty"`
+}
+
+// tierPrice is a service tier's input and output price per 1M tokens.
+type tierPrice struct {
+	InputUSDPer1MTokens  *string `json:"input_usd_per_1m_tokens"`
+	OutputUSDPer1MTokens *string `json:"output_usd_per_1m_tokens"`
 }
 
+// pricedTiers are the service tiers whose prices the report carries besides
+// standard.
+var pricedTiers = []string{"priority", "flex", "batch"}
+
 // BedrockPricingOptions holds configuration for pricing fetch operations.
 type BedrockPricingOptions struct {
 	Location     string
@@ -854,6 +870,22 @@ func preferredRow(rows []row) *row {
 	return &out
 }
 
+// ratePer1M returns the per-1M token price of the preferred row among those
+// matching, or nil if there is none.
+func ratePer1M(rows []row, match func(row) bool) *big.Rat {
+	var matched []row
+	for _, r := range rows {
+		if match(r) {
+			matched = append(matched, r)
+		}
+	}
+	best := preferredRow(matched)
+	if best == nil {
+		return nil
+	}
+	return ratMul(parseRat(best.USD), tokenScaleTo1M(best.Unit, best.Description))
+}
+
 func strPtrOrNil(s string) *string {
 	if s == "" {
 		return nil
@@ -994,6 +1026,23 @@ func buildReport(rows []row) (map[string]*string, []comparison) {
 				outputUsage = strPtrOrNil(outputRow.UsageType)
 			}
 
+			isLongContext := func(r row) bool { return r.IsLongContext && r.Tier == "standard" }
+			longContextInput := ratePer1M(inputRows, isLongContext)
+			longContextOutput := ratePer1M(outputRows, isLongContext)
+
+			var tiers map[string]tierPrice
+			for _, tier := range pricedTiers {
+				inTier := func(r row) bool { return r.Tier == tier && !r.IsLongContext }
+				in, out := ratePer1M(inputRows, inTier), ratePer1M(outputRows, inTier)
+				if in == nil || out == nil {
+					continue
+				}
+				if tiers == nil {
+					tiers = map[string]tierPrice{}
+				}
+				tiers[tier] = tierPrice{InputUSDPer1MTokens: formatRat(in), OutputUSDPer1MTokens: formatRat(out)}
+			}
+
 			comparisons = append(comparisons, comparison{
 				Family:                   family,
 				LatestVersion:            versionToString(versionTuple),
@@ -1015,6 +1064,10 @@ func buildReport(rows []row) (map[string]*string, []comparison) {
 				OutputIsGlobal:           outputIsGlobal,
 				InputUsageType:           inputUsage,
 				OutputUsageType:          outputUsage,
+
+				LongContextInputUSDPer1MTokens:  formatRat(longContextInput),
+				LongContextOutputUSDPer1MTokens: formatRat(longContextOutput),
+				Tiers:                           tiers,
 			})
 		}
 	}
@@ -1050,6 +1103,28 @@ func buildReport(rows []row) (map[string]*string, []comparison) {
 	return latestVersions, comparisons
 }
 
+// Prompt cache prices relative to the base input price.
+const (
+	cacheReadMultiplier  = 0.1
+	cacheWriteMultiplier = 1.25
+)
+
+// longContextThreshold is the input size above which Bedrock bills a
+// request at long-context prices.
+const longContextThreshold = 200_000
+
+// modelRates builds rates from input and output prices. The pricing report
+// has no cache columns; cache prices are derived from the input price using
+// Bedrock's published multipliers.
+func modelRates(modelID string, input, output float64) provider.Rates {
+	rates := provider.Rates{InputCostPer1M: input, OutputCostPer1M: output}
+	if supportsPromptCache(modelID) {
+		rates.CacheReadCostPer1M = input * cacheReadMultiplier
+		rates.CacheWriteCostPer1M = input * cacheWriteMultiplier
+	}
+	return rates
+}
+
 // pricingReportToModelInfo converts a BedrockPricingReport to a map of ModelInfo.
 // Maps model families to Bedrock model IDs and extracts pricing per region.
 func pricingReportToModelInfo(report *BedrockPricingReport, regionCode string) map[string]provider.ModelInfo {
@@ -1078,18 +1153,38 @@ func pricingReportToModelInfo(report *BedrockPricingReport, regionCode string) m
 			// Use static knownModels for ContextWindow (not in pricing API)
 			contextWindow := 200_000 // default
 			modelName := id
+			maxOutput := 0
 			if known, ok := knownModels[id]; ok {
 				contextWindow = known.ContextWindow
 				modelName = known.Name
+				maxOutput = known.MaxOutputTokens
 			}
 
-			cache[id] = provider.ModelInfo{
-				ID:              id,
-				Name:            modelName,
-				ContextWindow:   contextWindow,
-				InputCostPer1M:  inputCost,
-				OutputCostPer1M: outputCost,
+			rates := modelRates(id, inputCost, outputCost)
+			info := provider.ModelInfo{
+				ID:                  id,
+				Name:                modelName,
+				ContextWindow:       contextWindow,
+				InputCostPer1M:      rates.InputCostPer1M,
+				OutputCostPer1M:     rates.OutputCostPer1M,
+				CacheReadCostPer1M:  rates.CacheReadCostPer1M,
+				CacheWriteCostPer1M: rates.CacheWriteCostPer1M,
+				MaxOutputTokens:     maxOutput,
+			}
+			lcInput := parsePriceString(comp.LongContextInputUSDPer1MTokens)
+			lcOutput := parsePriceString(comp.LongContextOutputUSDPer1MTokens)
+			if lcInput > 0 && lcOutput > 0 {
+				lc := modelRates(id, lcInput, lcOutput)
+				info.LongContextThreshold = longContextThreshold
+				info.LongContext = &lc
+			}
+			for tier, p := range comp.Tiers {
+				if info.Tiers == nil {
+					info.Tiers = map[string]provider.Rates{}
+				}
+				info.Tiers[tier] = modelRates(id, parsePriceString(p.InputUSDPer1MTokens), parsePriceString(p.OutputUSDPer1MTokens))
 			}
+			cache[id] = info
 		}
 	}
 
@@ -3,6 +3,7 @@ package bedrock
 import (
 	"context"
 	"cosmos/core/provider"
+	"cosmos/providers/pricetable"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -536,3 +537,50 @@ func TestListModelsThreeTierFallbackOrder(t *testing.T) {
 		t.Errorf("future name: got %q, want %q", future.Name, "Claude Future")
 	}
 }
+
+func TestKnownModelsPriced(t *testing.T) {
+	for id := range knownModels {
+		if _, ok := pricetable.Lookup(id); !ok {
+			t.Errorf("%s has no entry in the embedded price table", id)
+		}
+	}
+}
+
+func TestBuildReportLongContextAndTiers(t *testing.T) {
+	price := func(io, tier string, long bool, usd string) row {
+		return row{
+			Family: "anthropic_sonnet", VersionTuple: []int{4, 0, 0}, RegionCode: "us-east-1",
+			IOType: io, Tier: tier, IsLongContext: long, USD: usd, Unit: "1M tokens",
+		}
+	}
+	_, comparisons := buildReport([]row{
+		price("input", "standard", false, "3"),
+		price("output", "standard", false, "15"),
+		price("input", "standard", true, "6"),
+		price("output", "standard", true, "22.5"),
+		price("input", "flex", false, "1.5"),
+		price("output", "flex", false, "7.5"),
+		price("input", "priority", false, "5.25"), // no output price: tier omitted
+	})
+	if len(comparisons) != 1 {
+		t.Fatalf("comparisons = %d, want 1", len(comparisons))
+	}
+	comp := comparisons[0]
+	if parsePriceString(comp.InputUSDPer1MTokens) != 3 || parsePriceString(comp.LongContextInputUSDPer1MTokens) != 6 {
+		t.Errorf("input = %v, long-context input = %v", comp.InputUSDPer1MTokens, comp.LongContextInputUSDPer1MTokens)
+	}
+	if _, ok := comp.Tiers["priority"]; ok || len(comp.Tiers) != 1 {
+		t.Errorf("tiers = %+v, want flex only", comp.Tiers)
+	}
+
+	info := pricingReportToModelInfo(&BedrockPricingReport{Comparisons: comparisons}, "us-east-1")["anthropic.claude-sonnet-4-20250514-v1:0"]
+	if info.LongContextThreshold != 200_000 || info.LongContext == nil {
+		t.Fatalf("long context = %d, %+v", info.LongContextThreshold, info.LongContext)
+	}
+	if lc := *info.LongContext; lc.InputCostPer1M != 6 || lc.OutputCostPer1M != 22.5 || lc.CacheWriteCostPer1M != 7.5 {
+		t.Errorf("long-context rates = %+v", lc)
+	}
+	if flex := info.Tiers["flex"]; flex.InputCostPer1M != 1.5 || flex.OutputCostPer1M != 7.5 {
+		t.Errorf("flex rates = %+v", flex)
+	}
+}
@@ -0,0 +1,146 @@
+package bedrock
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"cosmos/providers/pricetable"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+)
+
+// Inference profiles and provisioned throughput are invoked by their own
+// IDs or ARNs, but priced and sized like the foundation model they run.
+// ListModels lists them with the foundation model's metadata and records the
+// mapping, so APIs that accept only foundation models (CountTokens) can
+// resolve them.
+
+// foundationModelArnMarker precedes the model ID in a foundation model ARN,
+// e.g. arn:aws:bedrock:us-east-1::foundation-model/anthropic.claude-...
+const foundationModelArnMarker = ":foundation-model/"
+
+// baseModelFromArn returns the foundation model ID in a foundation model
+// ARN, or "" if arn is not one.
+func baseModelFromArn(arn string) string {
+	_, id, ok := strings.Cut(arn, foundationModelArnMarker)
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+// listInferenceProfiles returns the active system-defined (cross-region)
+// and application inference profiles.
+func (b *Bedrock) listInferenceProfiles(ctx context.Context) ([]bedrocktypes.InferenceProfileSummary, error) {
+	var profiles []bedrocktypes.InferenceProfileSummary
+	for _, typ := range []bedrocktypes.InferenceProfileType{
+		bedrocktypes.InferenceProfileTypeSystemDefined,
+		bedrocktypes.InferenceProfileTypeApplication,
+	} {
+		var token *string
+		for {
+			out, err := b.catalog.ListInferenceProfiles(ctx, &bedrock.ListInferenceProfilesInput{
+				TypeEquals: typ,
+				NextToken:  token,
+			})
+			if err != nil {
+				return nil, classifyErr(err)
+			}
+			for _, p := range out.InferenceProfileSummaries {
+				if p.Status == bedrocktypes.InferenceProfileStatusActive {
+					profiles = append(profiles, p)
+				}
+			}
+			if token = out.NextToken; token == nil {
+				break
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// listProvisionedModels returns the provisioned throughputs in service.
+func (b *Bedrock) listProvisionedModels(ctx context.Context) ([]bedrocktypes.ProvisionedModelSummary, error) {
+	var models []bedrocktypes.ProvisionedModelSummary
+	var token *string
+	for {
+		out, err := b.catalog.ListProvisionedModelThroughputs(ctx, &bedrock.ListProvisionedModelThroughputsInput{
+			StatusEquals: bedrocktypes.ProvisionedModelStatusInService,
+			NextToken:    token,
+		})
+		if err != nil {
+			return nil, classifyErr(err)
+		}
+		models = append(models, out.ProvisionedModelSummaries...)
+		if token = out.NextToken; token == nil {
+			return models, nil
+		}
+	}
+}
+
+// profileModelInfo describes an inference profile. Application profiles are
+// invoked by ARN, system-defined ones by ID. Returns false for profiles that
+// do not run an Anthropic model.
+func (b *Bedrock) profileModelInfo(p bedrocktypes.InferenceProfileSummary) (provider.ModelInfo, string, bool) {
+	if len(p.Models) == 0 {
+		return provider.ModelInfo{}, "", false
+	}
+	// Cross-region profiles list the same model once per region.
+	base := baseModelFromArn(aws.ToString(p.Models[0].ModelArn))
+	if !strings.HasPrefix(base, "anthropic.") {
+		return provider.ModelInfo{}, "", false
+	}
+	info := b.foundationModelInfo(base, "")
+	info.ID = aws.ToString(p.InferenceProfileId)
+	if p.Type == bedrocktypes.InferenceProfileTypeApplication {
+		info.ID = aws.ToString(p.InferenceProfileArn)
+	}
+	info.Name = aws.ToString(p.InferenceProfileName)
+	return info, base, true
+}
+
+// provisionedModelInfo describes a provisioned throughput. It is billed by
+// the hour rather than per token, so it carries no token prices.
+func (b *Bedrock) provisionedModelInfo(p bedrocktypes.ProvisionedModelSummary) (provider.ModelInfo, string, bool) {
+	base := baseModelFromArn(aws.ToString(p.FoundationModelArn))
+	if !strings.HasPrefix(base, "anthropic.") {
+		return provider.ModelInfo{}, "", false
+	}
+	known := b.foundationModelInfo(base, "")
+	return provider.ModelInfo{
+		ID:              aws.ToString(p.ProvisionedModelArn),
+		Name:            aws.ToString(p.ProvisionedModelName),
+		ContextWindow:   known.ContextWindow,
+		MaxOutputTokens: known.MaxOutputTokens,
+	}, base, true
+}
+
+// foundationModelInfo returns metadata for a foundation model: dynamic
+// pricing if fetched, otherwise knownModels (or just the name) priced from
+// the embedded table.
+func (b *Bedrock) foundationModelInfo(id, name string) provider.ModelInfo {
+	if info, ok := b.dynamicPricing[id]; ok {
+		return info
+	}
+	info := provider.ModelInfo{ID: id, Name: name}
+	if known, ok := knownModels[id]; ok {
+		info = known
+	}
+	pricetable.Fill(&info)
+	return info
+}
+
+// baseModel returns the foundation model behind a model ID: the mapping
+// recorded by ListModels for profiles and provisioned throughput, or the ID
+// with its cross-region geography prefix removed.
+func (b *Bedrock) baseModel(modelID string) string {
+	b.mu.Lock()
+	base, ok := b.baseModels[modelID]
+	b.mu.Unlock()
+	if ok {
+		return base
+	}
+	return foundationModelID(modelID)
+}
@@ -85,8 +85,13 @@ func (it *bedrockIterator) translate(event brtypes.ConverseStreamOutput) (provid
 	case *brtypes.ConverseStreamOutputMemberMetadata:
 		if it.pendingStop != nil && v.Value.Usage != nil {
 			it.pendingStop.Usage = &provider.Usage{
-				InputTokens:  int(aws.ToInt32(v.Value.Usage.InputTokens)),
-				OutputTokens: int(aws.ToInt32(v.Value.Usage.OutputTokens)),
+				InputTokens:      int(aws.ToInt32(v.Value.Usage.InputTokens)),
+				OutputTokens:     int(aws.ToInt32(v.Value.Usage.OutputTokens)),
+				CacheReadTokens:  int(aws.ToInt32(v.Value.Usage.CacheReadInputTokens)),
+				CacheWriteTokens: int(aws.ToInt32(v.Value.Usage.CacheWriteInputTokens)),
+			}
+			if tier := v.Value.ServiceTier; tier != nil && tier.Type != brtypes.ServiceTierTypeDefault {
+				it.pendingStop.Usage.ServiceTier = string(tier.Type)
 			}
 		}
 		return provider.StreamChunk{}, false
@@ -123,6 +128,21 @@ func (it *bedrockIterator) handleBlockDelta(event brtypes.ContentBlockDeltaEvent
 			Event:      provider.EventToolDelta,
 			InputDelta: aws.ToString(delta.Value.Input),
 		}, true
+	case *brtypes.ContentBlockDeltaMemberReasoningContent:
+		return translateReasoningDelta(delta.Value)
+	default:
+		return provider.StreamChunk{}, false
+	}
+}
+
+func translateReasoningDelta(delta brtypes.ReasoningContentBlockDelta) (provider.StreamChunk, bool) {
+	switch r := delta.(type) {
+	case *brtypes.ReasoningContentBlockDeltaMemberText:
+		return provider.StreamChunk{Event: provider.EventReasoningDelta, Text: r.Value}, true
+	case *brtypes.ReasoningContentBlockDeltaMemberSignature:
+		return provider.StreamChunk{Event: provider.EventReasoningSignature, Signature: r.Value}, true
+	case *brtypes.ReasoningContentBlockDeltaMemberRedactedContent:
+		return provider.StreamChunk{Event: provider.EventReasoningRedacted, Redacted: r.Value}, true
 	default:
 		return provider.StreamChunk{}, false
 	}
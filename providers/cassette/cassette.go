@@ -0,0 +1,321 @@
+// Package cassette provides a record/replay decorator for provider.Provider.
+//
+// In record mode every request and the stream chunks it produced are appended
+// to a JSON-lines cassette file. In replay mode the cassette is served back,
+// keyed by a hash of the request, without constructing a real provider, so a
+// recorded session can be reproduced exactly and offline.
+package cassette
+
+import (
+	"bufio"
+	"context"
+	"cosmos/core/provider"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Entry kinds in a cassette file.
+const (
+	kindModels = "models"
+	kindSend   = "send"
+)
+
+// entry is one line of a cassette file.
+type entry struct {
+	Kind    string                 `json:"kind"`
+	Key     string                 `json:"key,omitempty"`
+	Request *provider.Request      `json:"request,omitempty"`
+	Chunks  []provider.StreamChunk `json:"chunks,omitempty"`
+	Models  []provider.ModelInfo   `json:"models,omitempty"`
+	Error   *recordedError         `json:"error,omitempty"`
+	// SendFailed distinguishes an error returned by Send from one returned
+	// by the iterator after Chunks were streamed.
+	SendFailed bool `json:"sendFailed,omitempty"`
+}
+
+// recordedError preserves an error message and, if it wrapped one of the
+// provider sentinels, which one — so errors.Is keeps working on replay.
+type recordedError struct {
+	Message  string `json:"message"`
+	Sentinel string `json:"sentinel,omitempty"`
+}
+
+var sentinels = map[string]error{
+	"throttled":       provider.ErrThrottled,
+	"access_denied":   provider.ErrAccessDenied,
+	"model_not_found": provider.ErrModelNotFound,
+	"model_not_ready": provider.ErrModelNotReady,
+}
+
+func newRecordedError(err error) *recordedError {
+	rec := &recordedError{Message: err.Error()}
+	for name, sentinel := range sentinels {
+		if errors.Is(err, sentinel) {
+			rec.Sentinel = name
+			break
+		}
+	}
+	return rec
+}
+
+func (r *recordedError) err() error {
+	if sentinel, ok := sentinels[r.Sentinel]; ok {
+		return fmt.Errorf("%w (replayed: %s)", sentinel, r.Message)
+	}
+	return fmt.Errorf("replayed: %s", r.Message)
+}
+
+// RequestKey returns the stable hash used to match a request on replay.
+// encoding/json sorts map keys, so identical requests hash identically.
+func RequestKey(req provider.Request) (string, error) {
+	raw, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("hash request: %w", err)
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// --- Recording ---
+
+// Recorder wraps a provider and appends every interaction to a cassette file.
+type Recorder struct {
+	inner provider.Provider
+	mu    sync.Mutex
+	file  *os.File
+}
+
+// NewRecorder creates (or truncates) the cassette at path and returns a
+// provider that records everything passing through inner.
+func NewRecorder(inner provider.Provider, path string) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create cassette dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open cassette: %w", err)
+	}
+	return &Recorder{inner: inner, file: f}, nil
+}
+
+// Send forwards to the wrapped provider and records the streamed chunks once
+// the iterator is drained, fails, or is closed.
+func (r *Recorder) Send(ctx context.Context, req provider.Request) (provider.StreamIterator, error) {
+	key, err := RequestKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := r.inner.Send(ctx, req)
+	if err != nil {
+		r.write(entry{Kind: kindSend, Key: key, Request: &req, Error: newRecordedError(err), SendFailed: true})
+		return nil, err
+	}
+
+	return &recordingIterator{
+		inner:    iter,
+		recorder: r,
+		entry:    entry{Kind: kindSend, Key: key, Request: &req},
+	}, nil
+}
+
+// ListModels forwards to the wrapped provider and records the result.
+func (r *Recorder) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
+	models, err := r.inner.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.write(entry{Kind: kindModels, Models: models})
+	return models, nil
+}
+
+// CountTokens satisfies provider.TokenCounter by delegation. Counts are not
+// recorded: they are not part of the conversation a cassette replays.
+func (r *Recorder) CountTokens(ctx context.Context, req provider.Request) (int, error) {
+	tc, ok := r.inner.(provider.TokenCounter)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	return tc.CountTokens(ctx, req)
+}
+
+// Close flushes and closes the cassette file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *Recorder) write(e entry) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cosmos: cassette: encode entry: %v\n", err)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return
+	}
+	if _, err := fmt.Fprintf(r.file, "%s\n", line); err != nil {
+		fmt.Fprintf(os.Stderr, "cosmos: cassette: write entry: %v\n", err)
+	}
+}
+
+type recordingIterator struct {
+	inner    provider.StreamIterator
+	recorder *Recorder
+	entry    entry
+	written  bool
+}
+
+func (it *recordingIterator) Next() (provider.StreamChunk, error) {
+	chunk, err := it.inner.Next()
+	if err == io.EOF {
+		it.flush()
+		return chunk, err
+	}
+	if err != nil {
+		it.entry.Error = newRecordedError(err)
+		it.flush()
+		return chunk, err
+	}
+	it.entry.Chunks = append(it.entry.Chunks, chunk)
+	return chunk, nil
+}
+
+func (it *recordingIterator) Close() error {
+	// A stream abandoned early is still recorded with what was consumed.
+	it.flush()
+	return it.inner.Close()
+}
+
+func (it *recordingIterator) flush() {
+	if it.written {
+		return
+	}
+	it.written = true
+	it.recorder.write(it.entry)
+}
+
+// --- Replay ---
+
+// Replayer serves a recorded cassette. Identical requests recorded more than
+// once are replayed in their recorded order.
+type Replayer struct {
+	mu     sync.Mutex
+	sends  map[string][]entry
+	models []provider.ModelInfo
+}
+
+// NewReplayer loads the cassette at path.
+func NewReplayer(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open cassette: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	r := &Replayer{sends: make(map[string][]entry)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("cassette line %d: %w", lineNo, err)
+		}
+		switch e.Kind {
+		case kindModels:
+			r.models = e.Models
+		case kindSend:
+			r.sends[e.Key] = append(r.sends[e.Key], e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+	return r, nil
+}
+
+// Send returns the next recorded response for req.
+func (r *Replayer) Send(_ context.Context, req provider.Request) (provider.StreamIterator, error) {
+	key, err := RequestKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	queue := r.sends[key]
+	if len(queue) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("cassette: no recorded response for request %s (model %s, %d messages)", key[:12], req.Model, len(req.Messages))
+	}
+	e := queue[0]
+	r.sends[key] = queue[1:]
+	r.mu.Unlock()
+
+	if e.SendFailed && e.Error != nil {
+		return nil, e.Error.err()
+	}
+	return &replayIterator{chunks: e.Chunks, err: e.Error}, nil
+}
+
+// ListModels returns the recorded model list.
+func (r *Replayer) ListModels(context.Context) ([]provider.ModelInfo, error) {
+	if r.models == nil {
+		return nil, fmt.Errorf("cassette: no recorded model list")
+	}
+	return append([]provider.ModelInfo(nil), r.models...), nil
+}
+
+type replayIterator struct {
+	chunks []provider.StreamChunk
+	err    *recordedError
+	pos    int
+	closed bool
+}
+
+func (it *replayIterator) Next() (provider.StreamChunk, error) {
+	if it.closed {
+		return provider.StreamChunk{}, io.EOF
+	}
+	if it.pos < len(it.chunks) {
+		chunk := it.chunks[it.pos]
+		it.pos++
+		return chunk, nil
+	}
+	if it.err != nil {
+		err := it.err.err()
+		it.err = nil
+		return provider.StreamChunk{}, err
+	}
+	return provider.StreamChunk{}, io.EOF
+}
+
+func (it *replayIterator) Close() error {
+	it.closed = true
+	return nil
+}
+
+// Compile-time checks that both modes implement provider.Provider
+var (
+	_ provider.Provider = (*Recorder)(nil)
+	_ provider.Provider = (*Replayer)(nil)
+)
@@ -0,0 +1,366 @@
+package cassette
+
+import (
+	"context"
+	"cosmos/core"
+	"cosmos/core/provider"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Compile-time checks: both modes satisfy Provider, and the recorder
+// passes on token counting.
+var (
+	_ provider.Provider     = (*Recorder)(nil)
+	_ provider.Provider     = (*Replayer)(nil)
+	_ provider.TokenCounter = (*Recorder)(nil)
+)
+
+// --- Stub provider ---
+
+type stubIterator struct {
+	chunks []provider.StreamChunk
+	err    error // returned after chunks are exhausted (nil = io.EOF)
+	idx    int
+}
+
+func (it *stubIterator) Next() (provider.StreamChunk, error) {
+	if it.idx >= len(it.chunks) {
+		if it.err != nil {
+			return provider.StreamChunk{}, it.err
+		}
+		return provider.StreamChunk{}, io.EOF
+	}
+	c := it.chunks[it.idx]
+	it.idx++
+	return c, nil
+}
+
+func (it *stubIterator) Close() error { return nil }
+
+// stubProvider serves one scripted response per Send call.
+type stubProvider struct {
+	mu        sync.Mutex
+	responses []*stubIterator
+	sendErrs  []error
+	models    []provider.ModelInfo
+	sends     int
+}
+
+func (p *stubProvider) Send(_ context.Context, _ provider.Request) (provider.StreamIterator, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := p.sends
+	p.sends++
+	if i < len(p.sendErrs) && p.sendErrs[i] != nil {
+		return nil, p.sendErrs[i]
+	}
+	if i >= len(p.responses) {
+		return nil, fmt.Errorf("unexpected Send call #%d", i+1)
+	}
+	return p.responses[i], nil
+}
+
+func (p *stubProvider) ListModels(context.Context) ([]provider.ModelInfo, error) {
+	return p.models, nil
+}
+
+// countingStub is a stubProvider that can count tokens.
+type countingStub struct {
+	stubProvider
+	tokens int
+}
+
+func (p *countingStub) CountTokens(context.Context, provider.Request) (int, error) {
+	return p.tokens, nil
+}
+
+func drain(t *testing.T, it provider.StreamIterator) ([]provider.StreamChunk, error) {
+	t.Helper()
+	var chunks []provider.StreamChunk
+	for {
+		c, err := it.Next()
+		if err == io.EOF {
+			return chunks, nil
+		}
+		if err != nil {
+			return chunks, err
+		}
+		chunks = append(chunks, c)
+	}
+}
+
+func textChunks(text string) []provider.StreamChunk {
+	return []provider.StreamChunk{
+		{Event: provider.EventTextDelta, Text: text},
+		{Event: provider.EventMessageStop, StopReason: "end_turn", Usage: &provider.Usage{InputTokens: 10, OutputTokens: 5}},
+	}
+}
+
+func request(text string) provider.Request {
+	return provider.Request{
+		Model:    "m",
+		System:   "sys",
+		Messages: []provider.Message{{Role: provider.RoleUser, Content: text}},
+	}
+}
+
+// --- Tests ---
+
+func TestRequestKeyStable(t *testing.T) {
+	a := request("hi")
+	a.Messages = append(a.Messages, provider.Message{
+		Role:      provider.RoleAssistant,
+		ToolCalls: []provider.ToolCall{{ID: "t1", Name: "x", Input: map[string]any{"b": 1.0, "a": "z"}}},
+	})
+	b := request("hi")
+	b.Messages = append(b.Messages, provider.Message{
+		Role:      provider.RoleAssistant,
+		ToolCalls: []provider.ToolCall{{ID: "t1", Name: "x", Input: map[string]any{"a": "z", "b": 1.0}}},
+	})
+
+	ka, err := RequestKey(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb, _ := RequestKey(b)
+	if ka != kb {
+		t.Errorf("identical requests hashed differently: %s vs %s", ka, kb)
+	}
+	kc, _ := RequestKey(request("bye"))
+	if ka == kc {
+		t.Error("different requests hashed identically")
+	}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "cassette.jsonl")
+	models := []provider.ModelInfo{{ID: "m", Name: "Model", ContextWindow: 1000, InputCostPer1M: 1}}
+	inner := &stubProvider{
+		responses: []*stubIterator{
+			{chunks: textChunks("first")},
+			{chunks: textChunks("second")},
+			{chunks: textChunks("again")},
+		},
+		models: models,
+	}
+
+	rec, err := NewRecorder(inner, path)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	if _, err := rec.ListModels(context.Background()); err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	var recorded [][]provider.StreamChunk
+	for _, text := range []string{"one", "two", "one"} {
+		it, err := rec.Send(context.Background(), request(text))
+		if err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		chunks, err := drain(t, it)
+		if err != nil {
+			t.Fatalf("drain: %v", err)
+		}
+		_ = it.Close()
+		recorded = append(recorded, chunks)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rep, err := NewReplayer(path)
+	if err != nil {
+		t.Fatalf("NewReplayer: %v", err)
+	}
+	gotModels, err := rep.ListModels(context.Background())
+	if err != nil || !reflect.DeepEqual(gotModels, models) {
+		t.Errorf("ListModels = %+v, %v; want %+v", gotModels, err, models)
+	}
+
+	// Out of recorded order: keyed by request, repeated requests in order.
+	for _, tc := range []struct {
+		text string
+		want []provider.StreamChunk
+	}{
+		{"two", recorded[1]},
+		{"one", recorded[0]},
+		{"one", recorded[2]},
+	} {
+		it, err := rep.Send(context.Background(), request(tc.text))
+		if err != nil {
+			t.Fatalf("replay Send(%q): %v", tc.text, err)
+		}
+		got, err := drain(t, it)
+		if err != nil {
+			t.Fatalf("replay drain: %v", err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("replay %q: got %+v, want %+v", tc.text, got, tc.want)
+		}
+	}
+
+	// Exhausted and unknown requests fail without a network fallback.
+	if _, err := rep.Send(context.Background(), request("one")); err == nil {
+		t.Error("expected error once recorded responses are exhausted")
+	}
+	if _, err := rep.Send(context.Background(), request("never")); err == nil {
+		t.Error("expected error for unrecorded request")
+	}
+}
+
+func TestReplayPreservesErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	inner := &stubProvider{
+		sendErrs: []error{fmt.Errorf("%w: slow down", provider.ErrThrottled)},
+		responses: []*stubIterator{
+			nil,
+			{chunks: textChunks("partial")[:1], err: errors.New("connection reset")},
+		},
+	}
+
+	rec, err := NewRecorder(inner, path)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	if _, err := rec.Send(context.Background(), request("a")); !errors.Is(err, provider.ErrThrottled) {
+		t.Fatalf("expected throttled from inner, got %v", err)
+	}
+	it, err := rec.Send(context.Background(), request("b"))
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if _, err := drain(t, it); err == nil {
+		t.Fatal("expected stream error")
+	}
+	_ = rec.Close()
+
+	rep, err := NewReplayer(path)
+	if err != nil {
+		t.Fatalf("NewReplayer: %v", err)
+	}
+	if _, err := rep.Send(context.Background(), request("a")); !errors.Is(err, provider.ErrThrottled) {
+		t.Errorf("replayed Send error should wrap ErrThrottled, got %v", err)
+	}
+	it, err = rep.Send(context.Background(), request("b"))
+	if err != nil {
+		t.Fatalf("replay Send: %v", err)
+	}
+	chunks, err := drain(t, it)
+	if len(chunks) != 1 || err == nil {
+		t.Errorf("expected 1 chunk then error, got %d chunks, err %v", len(chunks), err)
+	}
+}
+
+func TestReplayMissingCassette(t *testing.T) {
+	if _, err := NewReplayer(filepath.Join(t.TempDir(), "missing.jsonl")); err == nil {
+		t.Fatal("expected error for missing cassette")
+	}
+}
+
+// --- End-to-end: a core.Session driven by a recorded cassette ---
+
+type echoExecutor struct{}
+
+func (echoExecutor) Execute(_ context.Context, name string, input map[string]any) (string, error) {
+	return fmt.Sprintf("%s(%v)", name, input["path"]), nil
+}
+
+type eventCollector struct {
+	mu   sync.Mutex
+	msgs []any
+	done chan struct{}
+}
+
+func (c *eventCollector) Send(msg any) {
+	c.mu.Lock()
+	c.msgs = append(c.msgs, msg)
+	c.mu.Unlock()
+	if _, ok := msg.(core.CompletionEvent); ok {
+		c.done <- struct{}{}
+	}
+}
+
+// runSession sends one prompt through a fresh session and returns the final history.
+func runSession(t *testing.T, prov provider.Provider) []provider.Message {
+	t.Helper()
+	collector := &eventCollector{done: make(chan struct{}, 4)}
+	session := core.NewSession("cassette-test", prov, core.NewTracker(nil, nil), collector,
+		"m", "sys", 1024, echoExecutor{}, nil, nil, nil)
+	session.Start(context.Background())
+	defer session.Stop()
+
+	session.SubmitMessage("read the file")
+	select {
+	case <-collector.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for completion")
+	}
+	return session.HistorySnapshot()
+}
+
+func TestSessionEndToEndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	inner := &stubProvider{
+		responses: []*stubIterator{
+			{chunks: []provider.StreamChunk{
+				{Event: provider.EventToolStart, ToolCallID: "tool-1", ToolName: "read"},
+				{Event: provider.EventToolDelta, InputDelta: `{"path":"a.txt"}`},
+				{Event: provider.EventToolEnd},
+				{Event: provider.EventMessageStop, StopReason: "tool_use", Usage: &provider.Usage{InputTokens: 10, OutputTokens: 5}},
+			}},
+			{chunks: textChunks("done reading")},
+		},
+		models: []provider.ModelInfo{{ID: "m", ContextWindow: 100_000}},
+	}
+
+	rec, err := NewRecorder(inner, path)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	recorded := runSession(t, rec)
+	_ = rec.Close()
+
+	rep, err := NewReplayer(path)
+	if err != nil {
+		t.Fatalf("NewReplayer: %v", err)
+	}
+	replayed := runSession(t, rep)
+
+	if len(replayed) != 4 {
+		t.Fatalf("expected 4 history messages, got %d: %+v", len(replayed), replayed)
+	}
+	if !reflect.DeepEqual(recorded, replayed) {
+		t.Errorf("replayed history differs from recording:\nrecorded %+v\nreplayed %+v", recorded, replayed)
+	}
+	if replayed[3].Content != "done reading" {
+		t.Errorf("final answer = %q", replayed[3].Content)
+	}
+}
+
+func TestRecorderDelegatesCountTokens(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := NewRecorder(&countingStub{tokens: 42}, filepath.Join(dir, "count.jsonl"))
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	defer rec.Close()
+	if n, err := rec.CountTokens(context.Background(), request("hi")); err != nil || n != 42 {
+		t.Errorf("CountTokens = %d, %v; want the inner provider's 42", n, err)
+	}
+
+	plain, err := NewRecorder(&stubProvider{}, filepath.Join(dir, "plain.jsonl"))
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	defer plain.Close()
+	if _, err := plain.CountTokens(context.Background(), request("hi")); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("CountTokens err = %v, want ErrUnsupported", err)
+	}
+}
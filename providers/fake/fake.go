@@ -0,0 +1,318 @@
+// Package fake implements a scripted provider.Provider for offline demos and
+// agent development. Each Send call plays back the next turn of a JSON or
+// YAML script as stream chunks, so the real tool loop, permission prompts
+// and V8 runtime can be exercised without any model or credentials.
+package fake
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultModelID is used when a script does not declare any models.
+const DefaultModelID = "fake-model"
+
+// Script is the on-disk format of an offline script. Scripts are JSON, or
+// YAML with the same keys when the file ends in .yaml or .yml.
+//
+//	{
+//	  "models": [{"id": "demo", "contextWindow": 200000, "inputCostPer1M": 3}],
+//	  "delayMs": 20,
+//	  "turns": [
+//	    {"text": "Let me look.", "toolCalls": [{"name": "read", "input": {"path": "README.md"}}]},
+//	    {"deltas": ["All ", "done."], "usage": {"inputTokens": 1200, "outputTokens": 40}}
+//	  ]
+//	}
+type Script struct {
+	Models  []ScriptModel `json:"models"`
+	DelayMs int           `json:"delayMs"` // pause between chunks, for a typing effect
+	Turns   []Turn        `json:"turns"`
+}
+
+// ScriptModel declares a model reported by ListModels.
+type ScriptModel struct {
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	ContextWindow   int     `json:"contextWindow"`
+	InputCostPer1M  float64 `json:"inputCostPer1M"`
+	OutputCostPer1M float64 `json:"outputCostPer1M"`
+}
+
+// Turn is one scripted model response.
+type Turn struct {
+	// Thinking, if set, is streamed word by word as reasoning before the
+	// response, followed by a fake signature.
+	Thinking string `json:"thinking"`
+
+	// Text is streamed word by word. Deltas, if set, are streamed verbatim
+	// instead and take precedence.
+	Text   string   `json:"text"`
+	Deltas []string `json:"deltas"`
+
+	ToolCalls []ScriptToolCall `json:"toolCalls"`
+
+	// StopReason defaults to "tool_use" when ToolCalls is non-empty and
+	// "end_turn" otherwise.
+	StopReason string `json:"stopReason"`
+
+	// Usage defaults to a rough estimate (4 characters per token).
+	Usage *ScriptUsage `json:"usage"`
+
+	// Error, if set, is returned from Send instead of a response.
+	Error string `json:"error"`
+}
+
+// ScriptToolCall is a scripted tool invocation.
+type ScriptToolCall struct {
+	ID    string         `json:"id"` // generated when empty
+	Name  string         `json:"name"`
+	Input map[string]any `json:"input"`
+}
+
+// ScriptUsage overrides the token counts reported for a turn.
+type ScriptUsage struct {
+	InputTokens  int `json:"inputTokens"`
+	OutputTokens int `json:"outputTokens"`
+}
+
+// Fake plays back a Script, one turn per Send call.
+type Fake struct {
+	script Script
+	mu     sync.Mutex
+	next   int
+}
+
+// New creates a Fake that plays back script.
+func New(script Script) (*Fake, error) {
+	for i, turn := range script.Turns {
+		for j, tc := range turn.ToolCalls {
+			if tc.Name == "" {
+				return nil, fmt.Errorf("fake script: turn %d tool call %d has no name", i+1, j+1)
+			}
+		}
+	}
+	return &Fake{script: script}, nil
+}
+
+// NewFromFile loads a script from path: YAML if it ends in .yaml or .yml,
+// JSON otherwise.
+func NewFromFile(path string) (*Fake, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fake script: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("parse fake script %s: %w", path, err)
+		}
+	}
+	var script Script
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("parse fake script %s: %w", path, err)
+	}
+	return New(script)
+}
+
+// yamlToJSON converts a YAML document to JSON, so both formats share the
+// JSON field names of Script.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// DefaultModel returns the first scripted model ID, for use as the session model.
+func (f *Fake) DefaultModel() string {
+	if len(f.script.Models) > 0 && f.script.Models[0].ID != "" {
+		return f.script.Models[0].ID
+	}
+	return DefaultModelID
+}
+
+// Send plays back the next scripted turn.
+func (f *Fake) Send(ctx context.Context, req provider.Request) (provider.StreamIterator, error) {
+	f.mu.Lock()
+	idx := f.next
+	if idx >= len(f.script.Turns) {
+		f.mu.Unlock()
+		return nil, fmt.Errorf("fake script exhausted after %d turns", len(f.script.Turns))
+	}
+	f.next++
+	f.mu.Unlock()
+
+	turn := f.script.Turns[idx]
+	if turn.Error != "" {
+		return nil, fmt.Errorf("fake: %s", turn.Error)
+	}
+	return &iterator{
+		ctx:    ctx,
+		chunks: turnChunks(idx, turn, req),
+		delay:  time.Duration(f.script.DelayMs) * time.Millisecond,
+	}, nil
+}
+
+// ListModels returns the scripted models, or a single free default model.
+func (f *Fake) ListModels(context.Context) ([]provider.ModelInfo, error) {
+	if len(f.script.Models) == 0 {
+		return []provider.ModelInfo{{ID: DefaultModelID, Name: "Fake Model", ContextWindow: 200_000}}, nil
+	}
+	models := make([]provider.ModelInfo, 0, len(f.script.Models))
+	for _, m := range f.script.Models {
+		name := m.Name
+		if name == "" {
+			name = m.ID
+		}
+		models = append(models, provider.ModelInfo{
+			ID:              m.ID,
+			Name:            name,
+			ContextWindow:   m.ContextWindow,
+			InputCostPer1M:  m.InputCostPer1M,
+			OutputCostPer1M: m.OutputCostPer1M,
+		})
+	}
+	return models, nil
+}
+
+// turnChunks expands a scripted turn into the chunk sequence a real provider
+// would stream.
+func turnChunks(idx int, turn Turn, req provider.Request) []provider.StreamChunk {
+	var chunks []provider.StreamChunk
+	outputChars := 0
+
+	if turn.Thinking != "" {
+		for _, d := range splitWords(turn.Thinking) {
+			chunks = append(chunks, provider.StreamChunk{Event: provider.EventReasoningDelta, Text: d})
+		}
+		chunks = append(chunks, provider.StreamChunk{
+			Event:     provider.EventReasoningSignature,
+			Signature: fmt.Sprintf("fake-signature-%d", idx+1),
+		})
+		outputChars += len(turn.Thinking)
+	}
+
+	deltas := turn.Deltas
+	if len(deltas) == 0 && turn.Text != "" {
+		deltas = splitWords(turn.Text)
+	}
+	for _, d := range deltas {
+		chunks = append(chunks, provider.StreamChunk{Event: provider.EventTextDelta, Text: d})
+		outputChars += len(d)
+	}
+
+	for i, tc := range turn.ToolCalls {
+		id := tc.ID
+		if id == "" {
+			id = fmt.Sprintf("fake-tool-%d-%d", idx+1, i+1)
+		}
+		input := "{}"
+		if len(tc.Input) > 0 {
+			raw, _ := json.Marshal(tc.Input) // decoded from JSON, so always encodable
+			input = string(raw)
+		}
+		outputChars += len(input)
+		chunks = append(chunks,
+			provider.StreamChunk{Event: provider.EventToolStart, ToolCallID: id, ToolName: tc.Name},
+			provider.StreamChunk{Event: provider.EventToolDelta, InputDelta: input},
+			provider.StreamChunk{Event: provider.EventToolEnd},
+		)
+	}
+
+	stopReason := turn.StopReason
+	if stopReason == "" {
+		stopReason = "end_turn"
+		if len(turn.ToolCalls) > 0 {
+			stopReason = "tool_use"
+		}
+	}
+
+	usage := provider.Usage{
+		InputTokens:  estimateInputTokens(req),
+		OutputTokens: outputChars / 4,
+	}
+	if turn.Usage != nil {
+		usage = provider.Usage{
+			InputTokens:  turn.Usage.InputTokens,
+			OutputTokens: turn.Usage.OutputTokens,
+		}
+	}
+
+	return append(chunks, provider.StreamChunk{
+		Event:      provider.EventMessageStop,
+		StopReason: stopReason,
+		Usage:      &usage,
+	})
+}
+
+// splitWords splits text into word-sized deltas, keeping whitespace attached
+// so the concatenation reproduces the original text exactly.
+func splitWords(text string) []string {
+	var out []string
+	start := 0
+	for i := 1; i < len(text); i++ {
+		if text[i] == ' ' && text[i-1] != ' ' {
+			out = append(out, text[start:i])
+			start = i
+		}
+	}
+	return append(out, text[start:])
+}
+
+// estimateInputTokens approximates prompt size at 4 characters per token.
+func estimateInputTokens(req provider.Request) int {
+	chars := len(req.System)
+	for _, m := range req.Messages {
+		chars += len(m.Content)
+		for _, tr := range m.ToolResults {
+			chars += len(tr.Content)
+		}
+		for _, tc := range m.ToolCalls {
+			chars += len(tc.Name)
+		}
+	}
+	return chars / 4
+}
+
+type iterator struct {
+	ctx    context.Context
+	chunks []provider.StreamChunk
+	delay  time.Duration
+	idx    int
+	closed bool
+}
+
+func (it *iterator) Next() (provider.StreamChunk, error) {
+	if it.closed || it.idx >= len(it.chunks) {
+		return provider.StreamChunk{}, io.EOF
+	}
+	if it.delay > 0 && it.idx > 0 {
+		select {
+		case <-time.After(it.delay):
+		case <-it.ctx.Done():
+			return provider.StreamChunk{}, it.ctx.Err()
+		}
+	}
+	c := it.chunks[it.idx]
+	it.idx++
+	return c, nil
+}
+
+func (it *iterator) Close() error {
+	it.closed = true
+	return nil
+}
+
+// Compile-time check that Fake implements provider.Provider
+var _ provider.Provider = (*Fake)(nil)
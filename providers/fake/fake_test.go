@@ -0,0 +1,246 @@
+package fake
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Compile-time check: Fake satisfies Provider.
+var _ provider.Provider = (*Fake)(nil)
+
+func drain(t *testing.T, it provider.StreamIterator) []provider.StreamChunk {
+	t.Helper()
+	var chunks []provider.StreamChunk
+	for {
+		c, err := it.Next()
+		if err == io.EOF {
+			return chunks
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		chunks = append(chunks, c)
+	}
+}
+
+func writeScript(t *testing.T, content string) string {
+	t.Helper()
+	return writeScriptAs(t, "script.json", content)
+}
+
+func writeScriptAs(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewFromFilePlaysTurnsInOrder(t *testing.T) {
+	path := writeScript(t, `{
+		"models": [{"id": "demo", "contextWindow": 1000, "inputCostPer1M": 3}],
+		"turns": [
+			{"text": "Let me look.", "toolCalls": [{"name": "read", "input": {"path": "README.md"}}]},
+			{"deltas": ["All ", "done."], "usage": {"inputTokens": 1200, "outputTokens": 40}}
+		]
+	}`)
+
+	f, err := NewFromFile(path)
+	if err != nil {
+		t.Fatalf("NewFromFile: %v", err)
+	}
+	if f.DefaultModel() != "demo" {
+		t.Errorf("DefaultModel = %q, want demo", f.DefaultModel())
+	}
+
+	// Turn 1: text split by words, then a tool call.
+	it, err := f.Send(context.Background(), provider.Request{})
+	if err != nil {
+		t.Fatalf("Send 1: %v", err)
+	}
+	chunks := drain(t, it)
+
+	var text strings.Builder
+	var toolStart, toolDelta provider.StreamChunk
+	for _, c := range chunks {
+		switch c.Event {
+		case provider.EventTextDelta:
+			text.WriteString(c.Text)
+		case provider.EventToolStart:
+			toolStart = c
+		case provider.EventToolDelta:
+			toolDelta = c
+		}
+	}
+	if text.String() != "Let me look." {
+		t.Errorf("text = %q", text.String())
+	}
+	if toolStart.ToolName != "read" || toolStart.ToolCallID != "fake-tool-1-1" {
+		t.Errorf("tool start = %+v", toolStart)
+	}
+	if toolDelta.InputDelta != `{"path":"README.md"}` {
+		t.Errorf("tool input = %q", toolDelta.InputDelta)
+	}
+	stop := chunks[len(chunks)-1]
+	if stop.Event != provider.EventMessageStop || stop.StopReason != "tool_use" {
+		t.Errorf("stop chunk = %+v, want tool_use stop", stop)
+	}
+	if stop.Usage == nil {
+		t.Error("expected estimated usage")
+	}
+
+	// Turn 2: verbatim deltas and explicit usage.
+	it, err = f.Send(context.Background(), provider.Request{})
+	if err != nil {
+		t.Fatalf("Send 2: %v", err)
+	}
+	chunks = drain(t, it)
+	if len(chunks) != 3 || chunks[0].Text != "All " || chunks[1].Text != "done." {
+		t.Fatalf("turn 2 chunks = %+v", chunks)
+	}
+	if chunks[2].StopReason != "end_turn" || chunks[2].Usage.InputTokens != 1200 || chunks[2].Usage.OutputTokens != 40 {
+		t.Errorf("turn 2 stop = %+v", chunks[2])
+	}
+
+	// Script exhausted.
+	if _, err := f.Send(context.Background(), provider.Request{}); err == nil {
+		t.Error("expected error after last turn")
+	}
+}
+
+func TestNewFromFileReadsYAML(t *testing.T) {
+	path := writeScriptAs(t, "script.yaml", `
+models:
+  - id: demo
+    contextWindow: 1000
+delayMs: 0
+turns:
+  - text: Let me look.
+    toolCalls:
+      - name: read
+        input: {path: README.md}
+  - deltas: ["All ", "done."]
+    usage: {inputTokens: 1200, outputTokens: 40}
+`)
+
+	f, err := NewFromFile(path)
+	if err != nil {
+		t.Fatalf("NewFromFile: %v", err)
+	}
+	want := Script{
+		Models: []ScriptModel{{ID: "demo", ContextWindow: 1000}},
+		Turns: []Turn{
+			{Text: "Let me look.", ToolCalls: []ScriptToolCall{{Name: "read", Input: map[string]any{"path": "README.md"}}}},
+			{Deltas: []string{"All ", "done."}, Usage: &ScriptUsage{InputTokens: 1200, OutputTokens: 40}},
+		},
+	}
+	if !reflect.DeepEqual(f.script, want) {
+		t.Errorf("script = %+v, want %+v", f.script, want)
+	}
+
+	if _, err := NewFromFile(writeScriptAs(t, "bad.yml", "turns: [")); err == nil {
+		t.Error("expected error for malformed YAML")
+	}
+}
+
+func TestListModels(t *testing.T) {
+	f, _ := New(Script{})
+	models, err := f.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if len(models) != 1 || models[0].ID != DefaultModelID || f.DefaultModel() != DefaultModelID {
+		t.Errorf("default models = %+v", models)
+	}
+
+	f, _ = New(Script{Models: []ScriptModel{{ID: "a", InputCostPer1M: 1}, {ID: "b", Name: "Bee"}}})
+	models, _ = f.ListModels(context.Background())
+	if len(models) != 2 || models[0].Name != "a" || models[0].InputCostPer1M != 1 || models[1].Name != "Bee" {
+		t.Errorf("scripted models = %+v", models)
+	}
+}
+
+func TestScriptedThinking(t *testing.T) {
+	f, _ := New(Script{Turns: []Turn{{Thinking: "Check the file.", Text: "Done."}}})
+	it, err := f.Send(context.Background(), provider.Request{})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	chunks := drain(t, it)
+
+	var thinking strings.Builder
+	var signed bool
+	for i, c := range chunks {
+		switch c.Event {
+		case provider.EventReasoningDelta:
+			thinking.WriteString(c.Text)
+		case provider.EventReasoningSignature:
+			signed = c.Signature != ""
+		case provider.EventTextDelta:
+			if !signed {
+				t.Errorf("text chunk %d streamed before the reasoning signature", i)
+			}
+		}
+	}
+	if thinking.String() != "Check the file." || !signed {
+		t.Errorf("thinking = %q, signed = %v", thinking.String(), signed)
+	}
+}
+
+func TestScriptedError(t *testing.T) {
+	f, _ := New(Script{Turns: []Turn{{Error: "simulated outage"}}})
+	_, err := f.Send(context.Background(), provider.Request{})
+	if err == nil || !strings.Contains(err.Error(), "simulated outage") {
+		t.Fatalf("expected scripted error, got %v", err)
+	}
+}
+
+func TestInvalidScripts(t *testing.T) {
+	if _, err := New(Script{Turns: []Turn{{ToolCalls: []ScriptToolCall{{}}}}}); err == nil {
+		t.Error("expected error for tool call without name")
+	}
+	if _, err := NewFromFile(writeScript(t, `{"turns": [`)); err == nil {
+		t.Error("expected error for malformed JSON")
+	}
+	if _, err := NewFromFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestDelayHonorsContext(t *testing.T) {
+	f, _ := New(Script{DelayMs: 10_000, Turns: []Turn{{Deltas: []string{"a", "b"}}}})
+	ctx, cancel := context.WithCancel(context.Background())
+	it, err := f.Send(ctx, provider.Request{})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if _, err := it.Next(); err != nil {
+		t.Fatalf("first chunk should not be delayed: %v", err)
+	}
+
+	cancel()
+	start := time.Now()
+	if _, err := it.Next(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("cancellation did not interrupt the delay")
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	for _, text := range []string{"one", "two words", "  leading and  double  spaces ", "x"} {
+		if got := strings.Join(splitWords(text), ""); got != text {
+			t.Errorf("splitWords(%q) joined = %q", text, got)
+		}
+	}
+}
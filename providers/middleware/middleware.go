@@ -0,0 +1,233 @@
+// Package middleware provides composable decorators for provider.Provider.
+//
+// Retry resends requests that fail with throttling or transient network
+// errors, and StallTimeout aborts streams that stop producing chunks. Both
+// wrap any provider, so they apply equally to Bedrock, Anthropic and
+// OpenAI-compatible backends:
+//
+//	p = middleware.Chain(p, middleware.Retry(rc), middleware.StallTimeout(sc))
+package middleware
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Middleware wraps a provider with additional behavior.
+type Middleware func(provider.Provider) provider.Provider
+
+// Chain applies middlewares to p. The first middleware is the outermost:
+// it sees each request first and each error last.
+func Chain(p provider.Provider, mws ...Middleware) provider.Provider {
+	for i := len(mws) - 1; i >= 0; i-- {
+		p = mws[i](p)
+	}
+	return p
+}
+
+// Retry defaults, used when the corresponding RetryConfig field is zero.
+const (
+	defaultBaseDelay = time.Second
+	defaultMaxDelay  = 30 * time.Second
+)
+
+// RetryConfig controls Retry.
+type RetryConfig struct {
+	MaxRetries int           // Retries after the first attempt; 0 disables retrying
+	BaseDelay  time.Duration // Delay before the first retry, doubled per retry
+	MaxDelay   time.Duration // Upper bound on a single delay
+
+	// OnRetry, if set, is called before each retry delay.
+	OnRetry func(RetryInfo)
+}
+
+// RetryInfo describes a retry about to happen.
+type RetryInfo struct {
+	Attempt    int           // 1 for the first retry
+	MaxRetries int           // Configured retry limit
+	Delay      time.Duration // Wait before resending
+	Err        error         // The failure being retried
+}
+
+// Retry returns middleware that resends a request when it fails with a
+// retryable error (see Retryable). A stream is only retried if it fails
+// before delivering its first chunk: once output has reached the caller,
+// resending would duplicate it, so later errors are returned as-is.
+func Retry(cfg RetryConfig) Middleware {
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = defaultBaseDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = defaultMaxDelay
+	}
+	return func(inner provider.Provider) provider.Provider {
+		return &retryProvider{inner: inner, cfg: cfg}
+	}
+}
+
+// Retryable reports whether err is worth retrying: throttling, a model that
+// is overloaded or still loading, a stalled stream, or a transient network
+// failure. Cancellation and validation errors are not.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, provider.ErrThrottled) ||
+		errors.Is(err, provider.ErrModelNotReady) ||
+		errors.Is(err, ErrStalled) {
+		return true
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsTemporary
+}
+
+type retryProvider struct {
+	inner provider.Provider
+	cfg   RetryConfig
+}
+
+func (r *retryProvider) Send(ctx context.Context, req provider.Request) (provider.StreamIterator, error) {
+	iter, attempt, err := r.send(ctx, req, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &retryIterator{r: r, ctx: ctx, req: req, inner: iter, attempt: attempt}, nil
+}
+
+func (r *retryProvider) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
+	for attempt := 0; ; attempt++ {
+		models, err := r.inner.ListModels(ctx)
+		if err == nil || attempt >= r.cfg.MaxRetries || !Retryable(err) || ctx.Err() != nil {
+			return models, err
+		}
+		if err := r.wait(ctx, attempt+1, err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// CountTokens delegates to the inner provider without retrying: counts feed
+// interactive displays, where a fast fallback beats waiting out a backoff.
+func (r *retryProvider) CountTokens(ctx context.Context, req provider.Request) (int, error) {
+	return countTokens(ctx, r.inner, req)
+}
+
+// send calls the inner provider until Send succeeds, retries run out, or the
+// error is not retryable. attempt is the number of retries already spent on
+// this request; the updated count is returned.
+func (r *retryProvider) send(ctx context.Context, req provider.Request, attempt int) (provider.StreamIterator, int, error) {
+	for {
+		iter, err := r.inner.Send(ctx, req)
+		if err == nil {
+			return iter, attempt, nil
+		}
+		if attempt >= r.cfg.MaxRetries || !Retryable(err) || ctx.Err() != nil {
+			return nil, attempt, err
+		}
+		attempt++
+		if err := r.wait(ctx, attempt, err); err != nil {
+			return nil, attempt, err
+		}
+	}
+}
+
+// wait reports the retry and sleeps for its backoff delay.
+func (r *retryProvider) wait(ctx context.Context, attempt int, cause error) error {
+	delay := r.backoff(attempt)
+	if r.cfg.OnRetry != nil {
+		r.cfg.OnRetry(RetryInfo{Attempt: attempt, MaxRetries: r.cfg.MaxRetries, Delay: delay, Err: cause})
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("retry cancelled: %w (last error: %v)", ctx.Err(), cause)
+	}
+}
+
+// backoff returns the delay before retry number attempt: exponential growth
+// capped at MaxDelay, with the upper half jittered so concurrent clients
+// throttled together do not retry in lockstep.
+func (r *retryProvider) backoff(attempt int) time.Duration {
+	d := r.cfg.BaseDelay << (attempt - 1)
+	if d <= 0 || d > r.cfg.MaxDelay {
+		d = r.cfg.MaxDelay
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// retryIterator re-sends the request if the stream fails before its first
+// chunk.
+type retryIterator struct {
+	r       *retryProvider
+	ctx     context.Context
+	req     provider.Request
+	inner   provider.StreamIterator
+	attempt int
+	started bool
+}
+
+func (it *retryIterator) Next() (provider.StreamChunk, error) {
+	for {
+		chunk, err := it.inner.Next()
+		if err == nil {
+			it.started = true
+			return chunk, nil
+		}
+		if err == io.EOF || it.started || it.attempt >= it.r.cfg.MaxRetries || !Retryable(err) || it.ctx.Err() != nil {
+			return chunk, err
+		}
+
+		_ = it.inner.Close()
+		it.attempt++
+		if werr := it.r.wait(it.ctx, it.attempt, err); werr != nil {
+			it.inner = failedIterator{err: werr}
+			return provider.StreamChunk{}, werr
+		}
+		iter, attempt, serr := it.r.send(it.ctx, it.req, it.attempt)
+		it.attempt = attempt
+		if serr != nil {
+			it.inner = failedIterator{err: serr}
+			return provider.StreamChunk{}, serr
+		}
+		it.inner = iter
+	}
+}
+
+func (it *retryIterator) Close() error {
+	return it.inner.Close()
+}
+
+// countTokens forwards to p if it implements provider.TokenCounter.
+func countTokens(ctx context.Context, p provider.Provider, req provider.Request) (int, error) {
+	if tc, ok := p.(provider.TokenCounter); ok {
+		return tc.CountTokens(ctx, req)
+	}
+	return 0, errors.ErrUnsupported
+}
+
+// failedIterator stands in for a stream that could not be re-established.
+type failedIterator struct{ err error }
+
+func (f failedIterator) Next() (provider.StreamChunk, error) { return provider.StreamChunk{}, f.err }
+func (f failedIterator) Close() error                        { return nil }
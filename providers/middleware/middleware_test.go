@@ -0,0 +1,325 @@
+package middleware
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Compile-time checks: every decorator satisfies Provider and forwards
+// token counting.
+var (
+	_ provider.Provider = (*retryProvider)(nil)
+	_ provider.Provider = (*stallProvider)(nil)
+
+	_ provider.TokenCounter = (*retryProvider)(nil)
+	_ provider.TokenCounter = (*stallProvider)(nil)
+)
+
+// --- Stub provider ---
+
+// step is one scripted Next result; a positive delay blocks first (honoring
+// the request context, as real providers do).
+type step struct {
+	chunk provider.StreamChunk
+	err   error
+	delay time.Duration
+}
+
+type stubIterator struct {
+	ctx    context.Context
+	steps  []step
+	idx    int
+	closed bool
+}
+
+func (it *stubIterator) Next() (provider.StreamChunk, error) {
+	if it.idx >= len(it.steps) {
+		return provider.StreamChunk{}, io.EOF
+	}
+	s := it.steps[it.idx]
+	it.idx++
+	if s.delay > 0 {
+		select {
+		case <-time.After(s.delay):
+		case <-it.ctx.Done():
+			return provider.StreamChunk{}, it.ctx.Err()
+		}
+	}
+	return s.chunk, s.err
+}
+
+func (it *stubIterator) Close() error {
+	it.closed = true
+	return nil
+}
+
+// stubProvider serves one scripted response per Send call: a Send error, or
+// a sequence of steps.
+type stubProvider struct {
+	mu        sync.Mutex
+	sendErrs  []error
+	responses [][]step
+	sends     int
+	iters     []*stubIterator
+}
+
+func (p *stubProvider) Send(ctx context.Context, _ provider.Request) (provider.StreamIterator, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := p.sends
+	p.sends++
+	if i < len(p.sendErrs) && p.sendErrs[i] != nil {
+		return nil, p.sendErrs[i]
+	}
+	if i >= len(p.responses) {
+		return nil, fmt.Errorf("unexpected Send #%d", i+1)
+	}
+	it := &stubIterator{ctx: ctx, steps: p.responses[i]}
+	p.iters = append(p.iters, it)
+	return it, nil
+}
+
+func (p *stubProvider) ListModels(context.Context) ([]provider.ModelInfo, error) {
+	return nil, nil
+}
+
+func text(s string) step {
+	return step{chunk: provider.StreamChunk{Event: provider.EventTextDelta, Text: s}}
+}
+
+func drain(t *testing.T, iter provider.StreamIterator) (string, error) {
+	t.Helper()
+	defer func() { _ = iter.Close() }()
+	var out string
+	for {
+		chunk, err := iter.Next()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		out += chunk.Text
+	}
+}
+
+func fastRetry(maxRetries int, infos *[]RetryInfo) Middleware {
+	return Retry(RetryConfig{
+		MaxRetries: maxRetries,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   2 * time.Millisecond,
+		OnRetry:    func(r RetryInfo) { *infos = append(*infos, r) },
+	})
+}
+
+// --- Retry ---
+
+func TestRetrySendThrottled(t *testing.T) {
+	stub := &stubProvider{
+		sendErrs:  []error{provider.ErrThrottled, fmt.Errorf("wrapped: %w", provider.ErrModelNotReady)},
+		responses: [][]step{nil, nil, {text("ok")}},
+	}
+	var infos []RetryInfo
+	p := Chain(stub, fastRetry(3, &infos))
+
+	iter, err := p.Send(context.Background(), provider.Request{})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	out, err := drain(t, iter)
+	if err != nil || out != "ok" {
+		t.Fatalf("drain = %q, %v; want ok", out, err)
+	}
+	if len(infos) != 2 || infos[0].Attempt != 1 || infos[1].Attempt != 2 || infos[1].MaxRetries != 3 {
+		t.Errorf("retries = %+v, want attempts 1 and 2 of 3", infos)
+	}
+	if !errors.Is(infos[0].Err, provider.ErrThrottled) {
+		t.Errorf("first retry cause = %v", infos[0].Err)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	stub := &stubProvider{sendErrs: []error{provider.ErrThrottled, provider.ErrThrottled, provider.ErrThrottled}}
+	var infos []RetryInfo
+	p := Chain(stub, fastRetry(2, &infos))
+
+	_, err := p.Send(context.Background(), provider.Request{})
+	if !errors.Is(err, provider.ErrThrottled) {
+		t.Fatalf("err = %v, want ErrThrottled", err)
+	}
+	if stub.sends != 3 || len(infos) != 2 {
+		t.Errorf("sends = %d, retries = %d; want 3 and 2", stub.sends, len(infos))
+	}
+}
+
+func TestRetrySkipsPermanentErrors(t *testing.T) {
+	stub := &stubProvider{sendErrs: []error{provider.ErrAccessDenied}}
+	var infos []RetryInfo
+	p := Chain(stub, fastRetry(3, &infos))
+
+	if _, err := p.Send(context.Background(), provider.Request{}); !errors.Is(err, provider.ErrAccessDenied) {
+		t.Fatalf("err = %v, want ErrAccessDenied", err)
+	}
+	if stub.sends != 1 || len(infos) != 0 {
+		t.Errorf("sends = %d, retries = %d; want no retry", stub.sends, len(infos))
+	}
+}
+
+func TestRetryStreamFailsBeforeFirstChunk(t *testing.T) {
+	stub := &stubProvider{responses: [][]step{
+		{{err: fmt.Errorf("read: %w", syscall.ECONNRESET)}},
+		{text("hello")},
+	}}
+	var infos []RetryInfo
+	p := Chain(stub, fastRetry(3, &infos))
+
+	iter, err := p.Send(context.Background(), provider.Request{})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	out, err := drain(t, iter)
+	if err != nil || out != "hello" {
+		t.Fatalf("drain = %q, %v; want hello", out, err)
+	}
+	if len(infos) != 1 || !stub.iters[0].closed {
+		t.Errorf("retries = %d, first stream closed = %v; want 1 and true", len(infos), stub.iters[0].closed)
+	}
+}
+
+func TestRetryStreamFailsAfterFirstChunk(t *testing.T) {
+	stub := &stubProvider{responses: [][]step{
+		{text("partial"), {err: provider.ErrThrottled}},
+		{text("never")},
+	}}
+	var infos []RetryInfo
+	p := Chain(stub, fastRetry(3, &infos))
+
+	iter, err := p.Send(context.Background(), provider.Request{})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	out, err := drain(t, iter)
+	if !errors.Is(err, provider.ErrThrottled) || out != "partial" {
+		t.Fatalf("drain = %q, %v; want partial output then ErrThrottled", out, err)
+	}
+	if stub.sends != 1 || len(infos) != 0 {
+		t.Errorf("sends = %d, retries = %d; output already streamed must not be resent", stub.sends, len(infos))
+	}
+}
+
+func TestRetryCancelledDuringBackoff(t *testing.T) {
+	stub := &stubProvider{sendErrs: []error{provider.ErrThrottled}}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := Chain(stub, Retry(RetryConfig{
+		MaxRetries: 3,
+		BaseDelay:  time.Hour,
+		OnRetry:    func(RetryInfo) { cancel() },
+	}))
+
+	_, err := p.Send(ctx, provider.Request{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestBackoffBounds(t *testing.T) {
+	r := &retryProvider{cfg: RetryConfig{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}}
+	for attempt, maxWant := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		for range 20 {
+			d := r.backoff(attempt)
+			if d < maxWant/2 || d > maxWant {
+				t.Fatalf("backoff(%d) = %s, want within [%s, %s]", attempt, d, maxWant/2, maxWant)
+			}
+		}
+	}
+}
+
+// --- Stall detection ---
+
+func TestStallBeforeFirstChunk(t *testing.T) {
+	stub := &stubProvider{responses: [][]step{{{delay: time.Minute}}}}
+	p := Chain(stub, StallTimeout(StallConfig{FirstToken: 20 * time.Millisecond}))
+
+	iter, err := p.Send(context.Background(), provider.Request{})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	_, err = drain(t, iter)
+	if !errors.Is(err, ErrStalled) {
+		t.Fatalf("err = %v, want ErrStalled", err)
+	}
+	if !stub.iters[0].closed {
+		t.Error("stalled stream should be closed")
+	}
+}
+
+func TestStallBetweenChunks(t *testing.T) {
+	stub := &stubProvider{responses: [][]step{{text("a"), {chunk: provider.StreamChunk{Text: "b"}, delay: time.Minute}}}}
+	p := Chain(stub, StallTimeout(StallConfig{Idle: 20 * time.Millisecond}))
+
+	iter, err := p.Send(context.Background(), provider.Request{})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	out, err := drain(t, iter)
+	if !errors.Is(err, ErrStalled) || out != "a" {
+		t.Fatalf("drain = %q, %v; want a then ErrStalled", out, err)
+	}
+}
+
+func TestStallRetried(t *testing.T) {
+	stub := &stubProvider{responses: [][]step{
+		{{delay: time.Minute}},
+		{text("recovered")},
+	}}
+	var infos []RetryInfo
+	p := Chain(stub, fastRetry(2, &infos), StallTimeout(StallConfig{FirstToken: 20 * time.Millisecond}))
+
+	iter, err := p.Send(context.Background(), provider.Request{})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	out, err := drain(t, iter)
+	if err != nil || out != "recovered" {
+		t.Fatalf("drain = %q, %v; want recovered", out, err)
+	}
+	if len(infos) != 1 || !errors.Is(infos[0].Err, ErrStalled) {
+		t.Errorf("retries = %+v, want one stall retry", infos)
+	}
+}
+
+func TestStallTimeoutDisabled(t *testing.T) {
+	stub := &stubProvider{}
+	if p := StallTimeout(StallConfig{})(stub); p != stub {
+		t.Error("zero StallConfig should return the provider unwrapped")
+	}
+}
+
+// --- Token counting ---
+
+type countingStub struct{ stubProvider }
+
+func (c *countingStub) CountTokens(context.Context, provider.Request) (int, error) { return 42, nil }
+
+func TestCountTokensPassesThrough(t *testing.T) {
+	var infos []RetryInfo
+	stall := StallTimeout(StallConfig{Idle: time.Minute})
+
+	p := Chain(&countingStub{}, fastRetry(1, &infos), stall)
+	n, err := p.(provider.TokenCounter).CountTokens(context.Background(), provider.Request{})
+	if err != nil || n != 42 {
+		t.Errorf("CountTokens = %d, %v; want 42", n, err)
+	}
+
+	p = Chain(&stubProvider{}, fastRetry(1, &infos), stall)
+	if _, err := p.(provider.TokenCounter).CountTokens(context.Background(), provider.Request{}); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("err = %v, want ErrUnsupported when the inner provider cannot count", err)
+	}
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrStalled is returned when a stream produces no chunk within its timeout.
+// Retry treats it as transient.
+var ErrStalled = errors.New("provider: stream stalled")
+
+// stallCloseGrace bounds how long Close waits for a read that was abandoned
+// on stall to return before closing the underlying stream. A provider that
+// ignores cancellation would otherwise hang Close forever.
+const stallCloseGrace = 5 * time.Second
+
+// StallConfig controls StallTimeout. A zero duration disables that check.
+type StallConfig struct {
+	FirstToken time.Duration // Max wait from Send to the first chunk
+	Idle       time.Duration // Max gap between consecutive chunks
+}
+
+// StallTimeout returns middleware that fails a stream with ErrStalled when
+// Next blocks longer than the configured timeout. The request context is
+// cancelled on stall so the provider can abort the underlying connection.
+func StallTimeout(cfg StallConfig) Middleware {
+	return func(inner provider.Provider) provider.Provider {
+		if cfg.FirstToken <= 0 && cfg.Idle <= 0 {
+			return inner
+		}
+		return &stallProvider{inner: inner, cfg: cfg}
+	}
+}
+
+type stallProvider struct {
+	inner provider.Provider
+	cfg   StallConfig
+}
+
+func (p *stallProvider) Send(ctx context.Context, req provider.Request) (provider.StreamIterator, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	iter, err := p.inner.Send(ctx, req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &stallIterator{inner: iter, cfg: p.cfg, cancel: cancel}, nil
+}
+
+func (p *stallProvider) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
+	return p.inner.ListModels(ctx)
+}
+
+func (p *stallProvider) CountTokens(ctx context.Context, req provider.Request) (int, error) {
+	return countTokens(ctx, p.inner, req)
+}
+
+type nextResult struct {
+	chunk provider.StreamChunk
+	err   error
+}
+
+type stallIterator struct {
+	inner   provider.StreamIterator
+	cfg     StallConfig
+	cancel  context.CancelFunc
+	started bool
+	err     error           // sticky ErrStalled once the stream has stalled
+	pending chan nextResult // the abandoned read after a stall
+}
+
+func (it *stallIterator) Next() (provider.StreamChunk, error) {
+	if it.err != nil {
+		return provider.StreamChunk{}, it.err
+	}
+
+	timeout, what := it.cfg.Idle, "between chunks"
+	if !it.started {
+		timeout, what = it.cfg.FirstToken, "before first chunk"
+	}
+	if timeout <= 0 {
+		chunk, err := it.inner.Next()
+		if err == nil {
+			it.started = true
+		}
+		return chunk, err
+	}
+
+	ch := make(chan nextResult, 1)
+	go func() {
+		chunk, err := it.inner.Next()
+		ch <- nextResult{chunk, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		if r.err == nil {
+			it.started = true
+		}
+		return r.chunk, r.err
+	case <-timer.C:
+		it.cancel()
+		it.pending = ch
+		it.err = fmt.Errorf("%w: no data for %s %s", ErrStalled, timeout, what)
+		return provider.StreamChunk{}, it.err
+	}
+}
+
+func (it *stallIterator) Close() error {
+	it.cancel()
+	if it.pending != nil {
+		// The inner iterator is not safe for concurrent use: wait for the
+		// abandoned read before closing it.
+		select {
+		case <-it.pending:
+		case <-time.After(stallCloseGrace):
+			fmt.Fprintf(os.Stderr, "cosmos: stalled provider stream did not stop after cancellation\n")
+			return nil
+		}
+		it.pending = nil
+	}
+	return it.inner.Close()
+}
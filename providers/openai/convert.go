@@ -0,0 +1,220 @@
+package openai
+
+import (
+	"cosmos/core/provider"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const defaultMaxTokens = 4096
+
+// chatRequest is the JSON body of a POST /v1/chat/completions call.
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []chatMessage  `json:"messages"`
+	Tools         []chatTool     `json:"tools,omitempty"`
+	MaxTokens     int            `json:"max_tokens"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+
+	// ToolChoice is "auto", "required", "none", or a chatNamedToolChoice.
+	ToolChoice  any      `json:"tool_choice,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+type chatNamedToolChoice struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name string `json:"name"`
+	} `json:"function"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    *string        `json:"content"` // null for tool-call-only assistant turns
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+type chatToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function chatFunction `json:"function"`
+}
+
+type chatFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type chatTool struct {
+	Type     string           `json:"type"`
+	Function chatToolFunction `json:"function"`
+}
+
+type chatToolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+func buildChatRequest(req provider.Request) (*chatRequest, error) {
+	var msgs []chatMessage
+	if req.System != "" {
+		msgs = append(msgs, chatMessage{Role: "system", Content: strPtr(req.System)})
+	}
+	for _, m := range req.Messages {
+		converted, err := toChatMessages(m)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, converted...)
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+
+	out := &chatRequest{
+		Model:         req.Model,
+		Messages:      msgs,
+		MaxTokens:     maxTokens,
+		Stream:        true,
+		StreamOptions: &streamOptions{IncludeUsage: true},
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		Stop:          req.StopSequences,
+	}
+
+	for _, t := range req.Tools {
+		params := t.InputSchema
+		if params == nil {
+			params = map[string]any{"type": "object"}
+		}
+		out.Tools = append(out.Tools, chatTool{
+			Type: "function",
+			Function: chatToolFunction{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  params,
+			},
+		})
+	}
+
+	if req.ToolChoice != nil {
+		choice, err := toChatToolChoice(req.ToolChoice, len(out.Tools) > 0)
+		if err != nil {
+			return nil, err
+		}
+		out.ToolChoice = choice
+	}
+
+	return out, nil
+}
+
+func toChatToolChoice(c *provider.ToolChoice, haveTools bool) (any, error) {
+	if c.Forces() && !haveTools {
+		return nil, fmt.Errorf("tool choice %q requires tool definitions", c.Mode)
+	}
+	switch c.Mode {
+	case provider.ToolChoiceAuto, provider.ToolChoiceNone:
+		return string(c.Mode), nil
+	case provider.ToolChoiceAny:
+		return "required", nil
+	case provider.ToolChoiceTool:
+		if c.Name == "" {
+			return nil, fmt.Errorf("tool choice %q requires a tool name", c.Mode)
+		}
+		named := chatNamedToolChoice{Type: "function"}
+		named.Function.Name = c.Name
+		return named, nil
+	default:
+		return nil, fmt.Errorf("unknown tool choice %q", c.Mode)
+	}
+}
+
+// toChatMessages converts one provider message into chat messages.
+// Tool results fan out into one "tool" role message each, which is how the
+// chat completions protocol carries them; any accompanying text follows
+// as a separate user message.
+func toChatMessages(m provider.Message) ([]chatMessage, error) {
+	switch m.Role {
+	case provider.RoleUser:
+		var out []chatMessage
+		for _, tr := range m.ToolResults {
+			content := withBlocks(tr.Content, tr.Blocks)
+			if tr.IsError {
+				// No is_error flag in this protocol; make failures explicit.
+				content = "Error: " + content
+			}
+			out = append(out, chatMessage{
+				Role:       "tool",
+				Content:    strPtr(content),
+				ToolCallID: tr.ToolUseID,
+			})
+		}
+		if text := withBlocks(m.Content, m.Blocks); text != "" {
+			out = append(out, chatMessage{Role: "user", Content: strPtr(text)})
+		}
+		if len(out) == 0 {
+			return nil, fmt.Errorf("message with role %q has no content (need text or tool results)", m.Role)
+		}
+		return out, nil
+
+	case provider.RoleAssistant:
+		msg := chatMessage{Role: "assistant"}
+		if m.Content != "" {
+			msg.Content = strPtr(m.Content)
+		}
+		for _, tc := range m.ToolCalls {
+			args := "{}"
+			if len(tc.Input) > 0 {
+				raw, err := json.Marshal(tc.Input)
+				if err != nil {
+					return nil, fmt.Errorf("encoding arguments for tool %s: %w", tc.Name, err)
+				}
+				args = string(raw)
+			}
+			msg.ToolCalls = append(msg.ToolCalls, chatToolCall{
+				ID:       tc.ID,
+				Type:     "function",
+				Function: chatFunction{Name: tc.Name, Arguments: args},
+			})
+		}
+		if msg.Content == nil && len(msg.ToolCalls) == 0 {
+			return nil, fmt.Errorf("message with role %q has no content (need text or tool calls)", m.Role)
+		}
+		return []chatMessage{msg}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown message role: %q", m.Role)
+	}
+}
+
+// withBlocks appends content blocks to text. Only text blocks survive:
+// attachments are replaced by a note, since compatible servers differ too
+// much in image and file support to send them blind.
+func withBlocks(text string, blocks []provider.ContentBlock) string {
+	var parts []string
+	if text != "" {
+		parts = append(parts, text)
+	}
+	for _, b := range blocks {
+		if b.Type == provider.ContentText {
+			parts = append(parts, b.Text)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("[%s %s omitted: not supported by this provider]", b.Type, b.Name))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func strPtr(s string) *string { return &s }
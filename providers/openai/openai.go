@@ -0,0 +1,171 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"cosmos/core/provider"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultBaseURL is the llama.cpp server default. vLLM and Ollama listen on
+// different ports; point openai_base_url at the server root (without /v1).
+const DefaultBaseURL = "http://localhost:8080"
+
+// OpenAI implements Provider using the OpenAI-compatible chat completions
+// streaming protocol, as served by llama.cpp, vLLM, Ollama and similar.
+type OpenAI struct {
+	baseURL    string
+	apiKey     string
+	models     map[string]provider.ModelInfo // configured metadata, keyed by model ID
+	httpClient *http.Client
+}
+
+// NewOpenAI creates a provider talking to the server at baseURL.
+// apiKey is optional; local servers usually do not check it.
+// models supplies context windows and pricing for model IDs, since
+// /v1/models carries neither. Unlisted models are reported with zero cost.
+func NewOpenAI(baseURL, apiKey string, models map[string]provider.ModelInfo, httpClient *http.Client) *OpenAI {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	base := DefaultBaseURL
+	if trimmed := strings.TrimSpace(baseURL); trimmed != "" {
+		base = strings.TrimRight(trimmed, "/")
+	}
+	return &OpenAI{
+		baseURL:    base,
+		apiKey:     strings.TrimSpace(apiKey),
+		models:     models,
+		httpClient: httpClient,
+	}
+}
+
+// Send starts a streaming chat completion with the model specified in req.
+func (o *OpenAI) Send(ctx context.Context, req provider.Request) (provider.StreamIterator, error) {
+	body, err := buildChatRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("building request: %w", err)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("encoding request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/v1/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("openai: %w", err)
+	}
+	o.setHeaders(httpReq)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := o.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("openai: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, classifyResponse(resp)
+	}
+
+	return newOpenAIIterator(resp.Body), nil
+}
+
+// ListModels returns the models served at /v1/models, enriched with the
+// configured metadata where available.
+func (o *OpenAI) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.baseURL+"/v1/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("openai: %w", err)
+	}
+	o.setHeaders(req)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("openai: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, classifyResponse(resp)
+	}
+
+	var out struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("openai: decode models: %w", err)
+	}
+
+	models := make([]provider.ModelInfo, 0, len(out.Data))
+	for _, m := range out.Data {
+		if info, ok := o.models[m.ID]; ok {
+			info.ID = m.ID
+			if info.Name == "" {
+				info.Name = m.ID
+			}
+			models = append(models, info)
+			continue
+		}
+		models = append(models, provider.ModelInfo{ID: m.ID, Name: m.ID})
+	}
+	return models, nil
+}
+
+func (o *OpenAI) setHeaders(req *http.Request) {
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+}
+
+// classifyResponse reads an error response body and maps it to a provider sentinel.
+// Servers disagree on the body shape, so both {"error":{"message":...}} and
+// {"error":"..."} are accepted before falling back to the raw text.
+func classifyResponse(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	msg := errorMessage(raw)
+	if msg == "" {
+		msg = resp.Status
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s", provider.ErrThrottled, msg)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: %s", provider.ErrAccessDenied, msg)
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", provider.ErrModelNotFound, msg)
+	case http.StatusServiceUnavailable:
+		// llama.cpp returns 503 while the model is still loading.
+		return fmt.Errorf("%w: %s", provider.ErrModelNotReady, msg)
+	}
+	return fmt.Errorf("openai: status %d: %s", resp.StatusCode, msg)
+}
+
+func errorMessage(raw []byte) string {
+	var structured struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &structured); err == nil && structured.Error.Message != "" {
+		return structured.Error.Message
+	}
+	var flat struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &flat); err == nil && flat.Error != "" {
+		return flat.Error
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// Compile-time check that OpenAI implements provider.Provider
+var _ provider.Provider = (*OpenAI)(nil)
@@ -0,0 +1,400 @@
+package openai
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Compile-time check: OpenAI satisfies Provider.
+var _ provider.Provider = (*OpenAI)(nil)
+
+func sseBody(chunks ...string) string {
+	var b strings.Builder
+	for _, c := range chunks {
+		b.WriteString("data: " + c + "\n\n")
+	}
+	return b.String()
+}
+
+func newTestProvider(t *testing.T, models map[string]provider.ModelInfo, handler http.HandlerFunc) *OpenAI {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewOpenAI(srv.URL, "", models, srv.Client())
+}
+
+func collect(t *testing.T, it provider.StreamIterator) []provider.StreamChunk {
+	t.Helper()
+	var chunks []provider.StreamChunk
+	for {
+		chunk, err := it.Next()
+		if err == io.EOF {
+			return chunks
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+func userRequest() provider.Request {
+	return provider.Request{
+		Model:    "llama3",
+		Messages: []provider.Message{{Role: provider.RoleUser, Content: "hi"}},
+	}
+}
+
+// --- Request conversion tests ---
+
+func TestBuildChatRequest(t *testing.T) {
+	req := provider.Request{
+		Model:  "llama3",
+		System: "be brief",
+		Messages: []provider.Message{
+			{Role: provider.RoleUser, Content: "Hello"},
+			{
+				Role: provider.RoleAssistant,
+				ToolCalls: []provider.ToolCall{
+					{ID: "call_1", Name: "readFile", Input: map[string]any{"path": "/tmp/x"}},
+					{ID: "call_2", Name: "listAll"},
+				},
+			},
+			{
+				Role: provider.RoleUser,
+				ToolResults: []provider.ToolResult{
+					{ToolUseID: "call_1", Content: "data"},
+					{ToolUseID: "call_2", Content: "boom", IsError: true},
+				},
+			},
+		},
+		Tools: []provider.ToolDefinition{
+			{Name: "readFile", Description: "reads", InputSchema: map[string]any{"type": "object"}},
+		},
+	}
+
+	out, err := buildChatRequest(req)
+	if err != nil {
+		t.Fatalf("buildChatRequest: %v", err)
+	}
+	if out.MaxTokens != defaultMaxTokens {
+		t.Errorf("MaxTokens = %d, want %d", out.MaxTokens, defaultMaxTokens)
+	}
+	if out.StreamOptions == nil || !out.StreamOptions.IncludeUsage {
+		t.Error("expected stream_options.include_usage")
+	}
+
+	// system + user + assistant + 2 tool messages
+	if len(out.Messages) != 5 {
+		t.Fatalf("expected 5 messages, got %d", len(out.Messages))
+	}
+	if out.Messages[0].Role != "system" || *out.Messages[0].Content != "be brief" {
+		t.Errorf("system message: %+v", out.Messages[0])
+	}
+
+	asst := out.Messages[2]
+	if asst.Content != nil {
+		t.Errorf("tool-only assistant content should be null, got %q", *asst.Content)
+	}
+	if len(asst.ToolCalls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d", len(asst.ToolCalls))
+	}
+	if asst.ToolCalls[0].Function.Arguments != `{"path":"/tmp/x"}` {
+		t.Errorf("arguments = %q", asst.ToolCalls[0].Function.Arguments)
+	}
+	if asst.ToolCalls[1].Function.Arguments != "{}" {
+		t.Errorf("no-arg arguments = %q, want {}", asst.ToolCalls[1].Function.Arguments)
+	}
+
+	if out.Messages[3].Role != "tool" || out.Messages[3].ToolCallID != "call_1" {
+		t.Errorf("tool message 0: %+v", out.Messages[3])
+	}
+	if !strings.HasPrefix(*out.Messages[4].Content, "Error: ") {
+		t.Errorf("error tool result should be marked, got %q", *out.Messages[4].Content)
+	}
+
+	if len(out.Tools) != 1 || out.Tools[0].Type != "function" || out.Tools[0].Function.Name != "readFile" {
+		t.Errorf("tools: %+v", out.Tools)
+	}
+}
+
+func TestBuildChatRequestEmptyMessage(t *testing.T) {
+	for _, role := range []provider.Role{provider.RoleUser, provider.RoleAssistant} {
+		_, err := buildChatRequest(provider.Request{Messages: []provider.Message{{Role: role}}})
+		if err == nil {
+			t.Errorf("role %q: expected error for empty message", role)
+		}
+	}
+	_, err := buildChatRequest(provider.Request{Messages: []provider.Message{{Role: "system", Content: "x"}}})
+	if err == nil {
+		t.Error("expected error for unknown role")
+	}
+}
+
+func TestBuildChatRequestContentBlocks(t *testing.T) {
+	out, err := buildChatRequest(provider.Request{Messages: []provider.Message{{
+		Role:    provider.RoleUser,
+		Content: "Look",
+		Blocks:  []provider.ContentBlock{{Type: provider.ContentImage, MediaType: "image/png", Name: "a.png", Data: []byte("png")}},
+	}}})
+	if err != nil {
+		t.Fatalf("buildChatRequest: %v", err)
+	}
+	want := "Look\n\n[image a.png omitted: not supported by this provider]"
+	if got := *out.Messages[0].Content; got != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}
+
+func TestBuildChatRequestToolChoiceAndSampling(t *testing.T) {
+	temp := 0.0
+	req := provider.Request{
+		Messages:      []provider.Message{{Role: provider.RoleUser, Content: "Hi"}},
+		Tools:         []provider.ToolDefinition{{Name: "extract"}},
+		ToolChoice:    &provider.ToolChoice{Mode: provider.ToolChoiceTool, Name: "extract"},
+		Temperature:   &temp,
+		StopSequences: []string{"END"},
+	}
+	out, err := buildChatRequest(req)
+	if err != nil {
+		t.Fatalf("buildChatRequest: %v", err)
+	}
+	raw, _ := json.Marshal(out)
+	for _, want := range []string{
+		`"tool_choice":{"type":"function","function":{"name":"extract"}}`,
+		`"temperature":0`, // explicit zero must be sent
+		`"stop":["END"]`,
+	} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("request missing %s: %s", want, raw)
+		}
+	}
+	if strings.Contains(string(raw), "top_p") {
+		t.Errorf("unset top_p should be omitted: %s", raw)
+	}
+
+	for mode, want := range map[provider.ToolChoiceMode]string{
+		provider.ToolChoiceAny:  "required",
+		provider.ToolChoiceNone: "none",
+		provider.ToolChoiceAuto: "auto",
+	} {
+		req.ToolChoice = &provider.ToolChoice{Mode: mode}
+		out, err := buildChatRequest(req)
+		if err != nil || out.ToolChoice != want {
+			t.Errorf("%s: tool_choice = %v, %v; want %q", mode, out.ToolChoice, err, want)
+		}
+	}
+
+	req.Tools = nil
+	req.ToolChoice = &provider.ToolChoice{Mode: provider.ToolChoiceAny}
+	if _, err := buildChatRequest(req); err == nil {
+		t.Error("expected error forcing a tool call without tools")
+	}
+}
+
+// --- Streaming tests ---
+
+func TestSendStreamsTextAndToolCalls(t *testing.T) {
+	var gotBody map[string]any
+	var gotAuth string
+	o := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, sseBody(
+			`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Let me "}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"check."}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"readFile","arguments":""}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"a\"}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"listAll","arguments":"{}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":30,"completion_tokens":12,"total_tokens":42}}`,
+			`[DONE]`,
+		))
+	})
+
+	it, err := o.Send(context.Background(), userRequest())
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	defer func() { _ = it.Close() }()
+
+	if gotAuth != "" {
+		t.Errorf("no API key configured, but Authorization = %q", gotAuth)
+	}
+	if gotBody["stream"] != true {
+		t.Errorf("request body: %v", gotBody)
+	}
+
+	chunks := collect(t, it)
+	wantEvents := []provider.StreamEvent{
+		provider.EventTextDelta,
+		provider.EventTextDelta,
+		provider.EventToolStart,
+		provider.EventToolDelta,
+		provider.EventToolDelta,
+		provider.EventToolEnd,
+		provider.EventToolStart,
+		provider.EventToolDelta,
+		provider.EventToolEnd,
+		provider.EventMessageStop,
+	}
+	if len(chunks) != len(wantEvents) {
+		t.Fatalf("got %d chunks, want %d: %+v", len(chunks), len(wantEvents), chunks)
+	}
+	for i, want := range wantEvents {
+		if chunks[i].Event != want {
+			t.Errorf("chunk %d: event = %d, want %d", i, chunks[i].Event, want)
+		}
+	}
+
+	if chunks[2].ToolCallID != "call_a" || chunks[2].ToolName != "readFile" {
+		t.Errorf("tool start: %+v", chunks[2])
+	}
+	if chunks[3].InputDelta+chunks[4].InputDelta != `{"path":"a"}` {
+		t.Errorf("tool input = %q", chunks[3].InputDelta+chunks[4].InputDelta)
+	}
+	if chunks[6].ToolCallID != "call_b" {
+		t.Errorf("second tool start: %+v", chunks[6])
+	}
+
+	stop := chunks[9]
+	if stop.StopReason != "tool_use" {
+		t.Errorf("StopReason = %q, want tool_use", stop.StopReason)
+	}
+	if stop.Usage == nil || stop.Usage.InputTokens != 30 || stop.Usage.OutputTokens != 12 {
+		t.Errorf("usage = %+v, want {30 12}", stop.Usage)
+	}
+}
+
+func TestSendWithoutDoneMarker(t *testing.T) {
+	o := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, sseBody(
+			`{"choices":[{"index":0,"delta":{"content":"hi"}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"length"}],"usage":{"prompt_tokens":3,"completion_tokens":1}}`,
+		))
+	})
+
+	it, err := o.Send(context.Background(), userRequest())
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	defer func() { _ = it.Close() }()
+
+	chunks := collect(t, it)
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %+v", chunks)
+	}
+	if chunks[1].StopReason != "max_tokens" {
+		t.Errorf("StopReason = %q, want max_tokens", chunks[1].StopReason)
+	}
+	if chunks[1].Usage == nil || chunks[1].Usage.InputTokens != 3 {
+		t.Errorf("usage = %+v", chunks[1].Usage)
+	}
+}
+
+func TestSendTruncatedStream(t *testing.T) {
+	o := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, sseBody(`{"choices":[{"index":0,"delta":{"content":"par"}}]}`))
+	})
+
+	it, err := o.Send(context.Background(), userRequest())
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	defer func() { _ = it.Close() }()
+
+	if _, err := it.Next(); err != nil {
+		t.Fatalf("first Next: %v", err)
+	}
+	if _, err := it.Next(); err == nil || err == io.EOF {
+		t.Fatalf("expected truncation error, got %v", err)
+	}
+}
+
+func TestSendAPIKeyHeader(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		_, _ = io.WriteString(w, sseBody(`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`, `[DONE]`))
+	}))
+	defer srv.Close()
+
+	o := NewOpenAI(srv.URL+"/", "sk-test", nil, srv.Client())
+	it, err := o.Send(context.Background(), userRequest())
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	defer func() { _ = it.Close() }()
+
+	if gotAuth != "Bearer sk-test" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	chunks := collect(t, it)
+	if len(chunks) != 1 || chunks[0].StopReason != "end_turn" {
+		t.Errorf("chunks = %+v", chunks)
+	}
+}
+
+func TestSendHTTPErrors(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusTooManyRequests, `{"error":{"message":"slow down"}}`, provider.ErrThrottled},
+		{http.StatusUnauthorized, `{"error":"bad key"}`, provider.ErrAccessDenied},
+		{http.StatusNotFound, `{"error":{"message":"model 'x' not found"}}`, provider.ErrModelNotFound},
+		{http.StatusServiceUnavailable, `Loading model`, provider.ErrModelNotReady},
+	}
+
+	for _, tc := range cases {
+		o := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			_, _ = io.WriteString(w, tc.body)
+		})
+		_, err := o.Send(context.Background(), userRequest())
+		if !errors.Is(err, tc.want) {
+			t.Errorf("status %d: got %v, want %v", tc.status, err, tc.want)
+		}
+	}
+}
+
+// --- ListModels tests ---
+
+func TestListModels(t *testing.T) {
+	models := map[string]provider.ModelInfo{
+		"qwen": {ContextWindow: 32768, InputCostPer1M: 0.1},
+	}
+	o := newTestProvider(t, models, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		_, _ = io.WriteString(w, `{"object":"list","data":[{"id":"qwen","object":"model"},{"id":"llama3","object":"model"}]}`)
+	})
+
+	got, err := o.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 models, got %d", len(got))
+	}
+	if got[0].ID != "qwen" || got[0].Name != "qwen" || got[0].ContextWindow != 32768 || got[0].InputCostPer1M != 0.1 {
+		t.Errorf("configured model: %+v", got[0])
+	}
+	if got[1].ID != "llama3" || got[1].InputCostPer1M != 0 || got[1].OutputCostPer1M != 0 {
+		t.Errorf("unconfigured model should be free: %+v", got[1])
+	}
+}
@@ -0,0 +1,204 @@
+package openai
+
+import (
+	"bufio"
+	"cosmos/core/provider"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxLineSize bounds a single SSE data line.
+const maxLineSize = 1 << 20
+
+// chatChunk is one chat.completion.chunk payload.
+type chatChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// openaiIterator reads a chat completions SSE stream. Tool call deltas are
+// keyed by index rather than bracketed by start/stop events, so the iterator
+// synthesizes EventToolStart/EventToolEnd around each call and buffers the
+// resulting chunks in a queue.
+type openaiIterator struct {
+	scanner    *bufio.Scanner
+	body       io.ReadCloser
+	queue      []provider.StreamChunk
+	toolOpen   bool
+	toolIndex  int
+	stopReason string
+	usage      *provider.Usage
+	finished   bool // finish_reason seen
+	done       bool
+}
+
+func newOpenAIIterator(body io.ReadCloser) *openaiIterator {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &openaiIterator{body: body, scanner: scanner}
+}
+
+func (it *openaiIterator) Next() (provider.StreamChunk, error) {
+	for {
+		if len(it.queue) > 0 {
+			chunk := it.queue[0]
+			it.queue = it.queue[1:]
+			return chunk, nil
+		}
+		if it.done {
+			return provider.StreamChunk{}, io.EOF
+		}
+
+		data, err := it.nextData()
+		if err == io.EOF {
+			// Some servers close without [DONE]; accept that once the
+			// model reported a finish reason.
+			if it.finished {
+				it.finish()
+				continue
+			}
+			it.done = true
+			return provider.StreamChunk{}, fmt.Errorf("openai stream: connection closed before completion")
+		}
+		if err != nil {
+			it.done = true
+			return provider.StreamChunk{}, fmt.Errorf("openai stream: %w", err)
+		}
+
+		if data == "[DONE]" {
+			it.finish()
+			continue
+		}
+
+		var chunk chatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			it.done = true
+			return provider.StreamChunk{}, fmt.Errorf("openai stream: decode chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			it.done = true
+			return provider.StreamChunk{}, fmt.Errorf("openai stream: %s", chunk.Error.Message)
+		}
+		it.translate(chunk)
+	}
+}
+
+func (it *openaiIterator) Close() error {
+	it.done = true
+	it.queue = nil
+	return it.body.Close()
+}
+
+// nextData returns the payload of the next "data:" line.
+func (it *openaiIterator) nextData() (string, error) {
+	for it.scanner.Scan() {
+		line := it.scanner.Text()
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	if err := it.scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+func (it *openaiIterator) translate(chunk chatChunk) {
+	if chunk.Usage != nil {
+		it.usage = &provider.Usage{
+			InputTokens:  chunk.Usage.PromptTokens,
+			OutputTokens: chunk.Usage.CompletionTokens,
+		}
+	}
+
+	for _, choice := range chunk.Choices {
+		if choice.Delta.Content != "" {
+			it.closeTool()
+			it.queue = append(it.queue, provider.StreamChunk{
+				Event: provider.EventTextDelta,
+				Text:  choice.Delta.Content,
+			})
+		}
+
+		for _, tc := range choice.Delta.ToolCalls {
+			if !it.toolOpen || tc.Index != it.toolIndex {
+				it.closeTool()
+				it.toolOpen = true
+				it.toolIndex = tc.Index
+				it.queue = append(it.queue, provider.StreamChunk{
+					Event:      provider.EventToolStart,
+					ToolCallID: tc.ID,
+					ToolName:   tc.Function.Name,
+				})
+			}
+			if tc.Function.Arguments != "" {
+				it.queue = append(it.queue, provider.StreamChunk{
+					Event:      provider.EventToolDelta,
+					InputDelta: tc.Function.Arguments,
+				})
+			}
+		}
+
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			it.closeTool()
+			it.finished = true
+			it.stopReason = toStopReason(*choice.FinishReason)
+		}
+	}
+}
+
+func (it *openaiIterator) closeTool() {
+	if it.toolOpen {
+		it.toolOpen = false
+		it.queue = append(it.queue, provider.StreamChunk{Event: provider.EventToolEnd})
+	}
+}
+
+// finish emits the terminal EventMessageStop. Usage arrives in a trailing
+// chunk after finish_reason (stream_options.include_usage), so the stop
+// chunk is deferred until the stream ends.
+func (it *openaiIterator) finish() {
+	it.closeTool()
+	it.done = true
+	it.queue = append(it.queue, provider.StreamChunk{
+		Event:      provider.EventMessageStop,
+		StopReason: it.stopReason,
+		Usage:      it.usage,
+	})
+}
+
+// toStopReason maps chat completions finish reasons onto the
+// Anthropic-style stop reasons the core loop understands.
+func toStopReason(reason string) string {
+	switch reason {
+	case "stop":
+		return "end_turn"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "length":
+		return "max_tokens"
+	default:
+		return reason
+	}
+}
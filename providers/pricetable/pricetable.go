@@ -0,0 +1,161 @@
+// Package pricetable provides offline model prices: a versioned table
+// embedded at build time, plus user overrides from config.toml.
+//
+// Providers whose APIs do not report prices (or, like the AWS Pricing API,
+// may be unreachable) fill in ModelInfo from the table, so costs do not
+// silently read zero. Overrides wrap any provider and take precedence over
+// every other source:
+//
+//	p = middleware.Chain(p, pricetable.Overrides(prices))
+package pricetable
+
+import (
+	"context"
+	"cosmos/core/provider"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+//go:embed prices.json
+var embedded []byte
+
+// Price is a model's price in USD per million tokens. Zero cache prices
+// mean the model bills cached tokens at the input price.
+type Price struct {
+	InputCostPer1M      float64 `json:"input_cost_per_1m"`
+	OutputCostPer1M     float64 `json:"output_cost_per_1m"`
+	CacheReadCostPer1M  float64 `json:"cache_read_cost_per_1m,omitempty"`
+	CacheWriteCostPer1M float64 `json:"cache_write_cost_per_1m,omitempty"`
+
+	// Requests with more than LongContextThreshold input tokens are billed
+	// at LongContext prices.
+	LongContextThreshold int    `json:"long_context_threshold,omitempty"`
+	LongContext          *Price `json:"long_context,omitempty"`
+}
+
+// Apply sets the prices of info to p. Service-tier rates are cleared, so
+// requests on any tier are billed at p.
+func (p Price) Apply(info *provider.ModelInfo) {
+	info.InputCostPer1M = p.InputCostPer1M
+	info.OutputCostPer1M = p.OutputCostPer1M
+	info.CacheReadCostPer1M = p.CacheReadCostPer1M
+	info.CacheWriteCostPer1M = p.CacheWriteCostPer1M
+	info.LongContextThreshold, info.LongContext = 0, nil
+	info.Tiers = nil
+	if p.LongContext != nil && p.LongContextThreshold > 0 {
+		info.LongContextThreshold = p.LongContextThreshold
+		info.LongContext = &provider.Rates{
+			InputCostPer1M:      p.LongContext.InputCostPer1M,
+			OutputCostPer1M:     p.LongContext.OutputCostPer1M,
+			CacheReadCostPer1M:  p.LongContext.CacheReadCostPer1M,
+			CacheWriteCostPer1M: p.LongContext.CacheWriteCostPer1M,
+		}
+	}
+}
+
+type table struct {
+	Version string           `json:"version"`
+	Models  map[string]Price `json:"models"`
+}
+
+var builtin = mustParse(embedded)
+
+func mustParse(data []byte) table {
+	var t table
+	if err := json.Unmarshal(data, &t); err != nil {
+		panic(fmt.Sprintf("pricetable: embedded prices.json: %v", err))
+	}
+	return t
+}
+
+// Version identifies the embedded table, as the date its prices were last
+// checked against the providers' published prices.
+func Version() string { return builtin.Version }
+
+// Lookup returns the embedded price of a model by its provider-specific ID.
+func Lookup(modelID string) (Price, bool) {
+	p, ok := builtin.Models[modelID]
+	return p, ok
+}
+
+// Fill sets the prices of info from the embedded table if it has none.
+func Fill(info *provider.ModelInfo) {
+	if info.InputCostPer1M != 0 || info.OutputCostPer1M != 0 {
+		return
+	}
+	if p, ok := Lookup(info.ID); ok {
+		p.Apply(info)
+	}
+}
+
+// Overrides returns middleware that replaces the prices ListModels reports
+// for the models in prices, keyed by model ID. Overridden models the
+// provider does not list are added, so their usage is priced too.
+func Overrides(prices map[string]Price) func(provider.Provider) provider.Provider {
+	return func(p provider.Provider) provider.Provider {
+		if len(prices) == 0 {
+			return p
+		}
+		return &overrideProvider{inner: p, prices: prices}
+	}
+}
+
+type overrideProvider struct {
+	inner  provider.Provider
+	prices map[string]Price
+}
+
+func (o *overrideProvider) Send(ctx context.Context, req provider.Request) (provider.StreamIterator, error) {
+	return o.inner.Send(ctx, req)
+}
+
+func (o *overrideProvider) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
+	models, err := o.inner.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]provider.ModelInfo, len(models))
+	listed := make(map[string]bool, len(models))
+	for i, m := range models {
+		if p, ok := o.prices[m.ID]; ok {
+			p.Apply(&m)
+		}
+		out[i] = m
+		listed[m.ID] = true
+	}
+	for _, id := range slices.Sorted(maps.Keys(o.prices)) {
+		if !listed[id] {
+			info := unlistedModel(id, models)
+			o.prices[id].Apply(&info)
+			out = append(out, info)
+		}
+	}
+	return out, nil
+}
+
+// unlistedModel describes a model the provider does not list. An ID with a
+// prefix, such as a Bedrock inference profile, takes the metadata of the
+// listed model it runs.
+func unlistedModel(id string, models []provider.ModelInfo) provider.ModelInfo {
+	for _, m := range models {
+		if strings.HasSuffix(id, "."+m.ID) {
+			m.ID = id
+			return m
+		}
+	}
+	return provider.ModelInfo{ID: id, Name: id}
+}
+
+// CountTokens satisfies provider.TokenCounter by delegation.
+func (o *overrideProvider) CountTokens(ctx context.Context, req provider.Request) (int, error) {
+	tc, ok := o.inner.(provider.TokenCounter)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	return tc.CountTokens(ctx, req)
+}
@@ -0,0 +1,135 @@
+package pricetable
+
+import (
+	"context"
+	"cosmos/core"
+	"cosmos/core/provider"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEmbeddedTable(t *testing.T) {
+	if Version() == "" {
+		t.Error("embedded table has no version")
+	}
+	p, ok := Lookup("claude-sonnet-4-20250514")
+	if !ok || p.InputCostPer1M != 3 || p.OutputCostPer1M != 15 || p.CacheReadCostPer1M != 0.3 {
+		t.Errorf("Lookup(claude-sonnet-4-20250514) = %+v, %v", p, ok)
+	}
+	for id, p := range builtin.Models {
+		if p.InputCostPer1M <= 0 || p.OutputCostPer1M <= 0 {
+			t.Errorf("%s: missing input or output price: %+v", id, p)
+		}
+	}
+}
+
+func TestFill(t *testing.T) {
+	info := provider.ModelInfo{ID: "claude-opus-4-20250514"}
+	Fill(&info)
+	if info.InputCostPer1M != 15 || info.CacheWriteCostPer1M != 18.75 {
+		t.Errorf("Fill = %+v", info)
+	}
+
+	dynamic := provider.ModelInfo{ID: "claude-opus-4-20250514", InputCostPer1M: 14, OutputCostPer1M: 70}
+	Fill(&dynamic)
+	if dynamic.InputCostPer1M != 14 {
+		t.Error("Fill must not replace prices the provider reported")
+	}
+
+	long := provider.ModelInfo{ID: "claude-sonnet-4-20250514"}
+	Fill(&long)
+	if long.LongContextThreshold != 200_000 || long.LongContext == nil || long.LongContext.InputCostPer1M != 6 {
+		t.Errorf("Fill long context = %+v, %+v", long, long.LongContext)
+	}
+
+	unknown := provider.ModelInfo{ID: "qwen2.5-coder"}
+	Fill(&unknown)
+	if unknown.InputCostPer1M != 0 {
+		t.Errorf("unknown model priced: %+v", unknown)
+	}
+}
+
+type stubProvider struct{ models []provider.ModelInfo }
+
+func (s *stubProvider) Send(context.Context, provider.Request) (provider.StreamIterator, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *stubProvider) ListModels(context.Context) ([]provider.ModelInfo, error) {
+	return s.models, nil
+}
+
+func TestOverrides(t *testing.T) {
+	inner := &stubProvider{models: []provider.ModelInfo{
+		{ID: "a", Name: "A", ContextWindow: 1000, InputCostPer1M: 3, OutputCostPer1M: 15, CacheReadCostPer1M: 0.3},
+		{ID: "b", InputCostPer1M: 1, OutputCostPer1M: 5},
+	}}
+	p := Overrides(map[string]Price{"a": {InputCostPer1M: 2.7, OutputCostPer1M: 13.5}})(inner)
+
+	models, err := p.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	want := provider.ModelInfo{ID: "a", Name: "A", ContextWindow: 1000, InputCostPer1M: 2.7, OutputCostPer1M: 13.5}
+	if !reflect.DeepEqual(models[0], want) {
+		t.Errorf("overridden = %+v, want %+v", models[0], want)
+	}
+	if models[1].InputCostPer1M != 1 {
+		t.Errorf("model without override changed: %+v", models[1])
+	}
+	if inner.models[0].InputCostPer1M != 3 {
+		t.Error("Overrides must not modify the inner provider's models")
+	}
+
+	if _, err := p.(provider.TokenCounter).CountTokens(context.Background(), provider.Request{}); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("CountTokens err = %v, want ErrUnsupported", err)
+	}
+	if Overrides(nil)(inner) != provider.Provider(inner) {
+		t.Error("no overrides should leave the provider unwrapped")
+	}
+}
+
+func TestOverrideAppliesToTieredRequests(t *testing.T) {
+	inner := &stubProvider{models: []provider.ModelInfo{{
+		ID: "a", InputCostPer1M: 3, OutputCostPer1M: 15,
+		Tiers: map[string]provider.Rates{"priority": {InputCostPer1M: 5, OutputCostPer1M: 25}},
+	}}}
+	p := Overrides(map[string]Price{"a": {InputCostPer1M: 2, OutputCostPer1M: 10}})(inner)
+
+	models, err := p.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	tracker := core.NewTracker(nil, nil)
+	tracker.Record(models[0], provider.Usage{InputTokens: 1_000_000, OutputTokens: 1_000_000, ServiceTier: "priority"}, core.SourcePrompt)
+	if got := tracker.Snapshot().TotalCost; got != 12 {
+		t.Errorf("priority request cost = %v, want 12 at the override's rates", got)
+	}
+	if inner.models[0].Tiers == nil {
+		t.Error("Overrides must not modify the inner provider's models")
+	}
+}
+
+func TestOverrideAddsUnlistedModels(t *testing.T) {
+	inner := &stubProvider{models: []provider.ModelInfo{
+		{ID: "anthropic.claude-x", Name: "Claude X", ContextWindow: 200_000, InputCostPer1M: 3, OutputCostPer1M: 15},
+	}}
+	p := Overrides(map[string]Price{
+		"us.anthropic.claude-x": {InputCostPer1M: 2.7, OutputCostPer1M: 13.5},
+		"local-model":           {InputCostPer1M: 0.1, OutputCostPer1M: 0.2},
+	})(inner)
+
+	models, err := p.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	want := []provider.ModelInfo{
+		{ID: "anthropic.claude-x", Name: "Claude X", ContextWindow: 200_000, InputCostPer1M: 3, OutputCostPer1M: 15},
+		{ID: "local-model", Name: "local-model", InputCostPer1M: 0.1, OutputCostPer1M: 0.2},
+		{ID: "us.anthropic.claude-x", Name: "Claude X", ContextWindow: 200_000, InputCostPer1M: 2.7, OutputCostPer1M: 13.5},
+	}
+	if !reflect.DeepEqual(models, want) {
+		t.Errorf("models = %+v, want %+v", models, want)
+	}
+}
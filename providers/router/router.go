@@ -0,0 +1,258 @@
+// Package router dispatches requests across several configured providers.
+//
+// Each request is routed to a backend by the longest matching model ID
+// prefix. A model may list fallbacks: when the backend serving it is
+// throttled, overloaded, or denies access, the request is resent with the
+// next model in the chain, which may live on a different backend:
+//
+//	us.anthropic.claude-sonnet-4 (bedrock) -> claude-sonnet-4 (anthropic) -> qwen2.5-coder (openai)
+package router
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Config describes the backends and how models map onto them.
+type Config struct {
+	// Backends by name, e.g. "bedrock", "anthropic", "openai".
+	Backends map[string]provider.Provider
+
+	// Routes maps model ID prefixes to backend names. The longest matching
+	// prefix wins; models matching no prefix go to Default.
+	Routes  map[string]string
+	Default string
+
+	// Fallbacks maps a model ID to the ordered models tried after it fails
+	// with a fallback error (see Fallbackable).
+	Fallbacks map[string][]string
+
+	// OnServe, if set, is called once per request when a backend delivers
+	// the first chunk of its response.
+	OnServe func(Served)
+}
+
+// Served identifies the backend and model that answered a request.
+type Served struct {
+	Backend  string
+	Model    string
+	Fallback bool // Model differs from the requested one
+}
+
+// Router is a provider.Provider that forwards each request to the backend
+// its model routes to, falling back along the model's chain on failure.
+type Router struct {
+	backends  map[string]provider.Provider
+	order     []string // backend names, Default first, for ListModels
+	prefixes  []string // route prefixes, longest first
+	routes    map[string]string
+	def       string
+	fallbacks map[string][]string
+	onServe   func(Served)
+}
+
+// NewRouter validates cfg and returns a Router. The default and every route
+// must name a configured backend.
+func NewRouter(cfg Config) (*Router, error) {
+	if _, ok := cfg.Backends[cfg.Default]; !ok {
+		return nil, fmt.Errorf("router: default backend %q is not configured", cfg.Default)
+	}
+	r := &Router{
+		backends:  cfg.Backends,
+		routes:    cfg.Routes,
+		def:       cfg.Default,
+		fallbacks: cfg.Fallbacks,
+		onServe:   cfg.OnServe,
+	}
+
+	for prefix, name := range cfg.Routes {
+		if _, ok := cfg.Backends[name]; !ok {
+			return nil, fmt.Errorf("router: route %q uses unknown backend %q", prefix, name)
+		}
+		r.prefixes = append(r.prefixes, prefix)
+	}
+	sort.Slice(r.prefixes, func(i, j int) bool {
+		if len(r.prefixes[i]) != len(r.prefixes[j]) {
+			return len(r.prefixes[i]) > len(r.prefixes[j])
+		}
+		return r.prefixes[i] < r.prefixes[j]
+	})
+
+	r.order = append(r.order, cfg.Default)
+	names := make([]string, 0, len(cfg.Backends))
+	for name := range cfg.Backends {
+		if name != cfg.Default {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	r.order = append(r.order, names...)
+	return r, nil
+}
+
+// Route returns the name of the backend that serves model.
+func (r *Router) Route(model string) string {
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(model, prefix) {
+			return r.routes[prefix]
+		}
+	}
+	return r.def
+}
+
+// Chain returns model followed by its fallbacks, without repeats.
+func (r *Router) Chain(model string) []string {
+	chain := []string{model}
+	seen := map[string]bool{model: true}
+	for _, m := range r.fallbacks[model] {
+		if !seen[m] {
+			seen[m] = true
+			chain = append(chain, m)
+		}
+	}
+	return chain
+}
+
+// Fallbackable reports whether err should move a request to the next model
+// in its chain: the backend is throttling, the model is overloaded or not
+// loaded, or access to it is denied.
+func Fallbackable(err error) bool {
+	return errors.Is(err, provider.ErrThrottled) ||
+		errors.Is(err, provider.ErrModelNotReady) ||
+		errors.Is(err, provider.ErrAccessDenied)
+}
+
+func (r *Router) Send(ctx context.Context, req provider.Request) (provider.StreamIterator, error) {
+	it := &routerIterator{r: r, ctx: ctx, req: req, chain: r.Chain(req.Model)}
+	if err := it.open(nil); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// CountTokens asks the backend req.Model routes to. Fallback models are not
+// consulted: a count is only meaningful for the model it was made for.
+func (r *Router) CountTokens(ctx context.Context, req provider.Request) (int, error) {
+	if tc, ok := r.backends[r.Route(req.Model)].(provider.TokenCounter); ok {
+		return tc.CountTokens(ctx, req)
+	}
+	return 0, errors.ErrUnsupported
+}
+
+// ListModels merges the models of all backends. When two backends list the
+// same ID, the default backend wins, then backends in name order. Backends
+// that fail are skipped unless all of them fail.
+func (r *Router) ListModels(ctx context.Context) ([]provider.ModelInfo, error) {
+	var (
+		models []provider.ModelInfo
+		errs   []error
+		ok     bool
+	)
+	seen := make(map[string]bool)
+	for _, name := range r.order {
+		list, err := r.backends[name].ListModels(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		ok = true
+		for _, m := range list {
+			if !seen[m.ID] {
+				seen[m.ID] = true
+				models = append(models, m)
+			}
+		}
+	}
+	if !ok {
+		return nil, errors.Join(errs...)
+	}
+	return models, nil
+}
+
+// routerIterator streams from the current model in the chain and moves to
+// the next one if the stream fails before its first chunk.
+type routerIterator struct {
+	r       *Router
+	ctx     context.Context
+	req     provider.Request
+	chain   []string
+	idx     int // position in chain of the model being streamed
+	backend string
+	inner   provider.StreamIterator
+	started bool
+}
+
+// open sends the request to chain[idx], advancing along the chain while
+// sends fail with fallback errors. prev is the error that ended the previous
+// attempt, if any, and is reported when no model is left to try.
+func (it *routerIterator) open(prev error) error {
+	for ; it.idx < len(it.chain); it.idx++ {
+		model := it.chain[it.idx]
+		backend := it.r.Route(model)
+		req := it.req
+		req.Model = model
+
+		iter, err := it.r.backends[backend].Send(it.ctx, req)
+		if err == nil {
+			it.backend, it.inner = backend, iter
+			return nil
+		}
+		prev = fmt.Errorf("%s (%s): %w", model, backend, err)
+		if !Fallbackable(err) || it.ctx.Err() != nil {
+			break
+		}
+	}
+	it.inner = failedIterator{err: prev}
+	return prev
+}
+
+func (it *routerIterator) Next() (provider.StreamChunk, error) {
+	for {
+		chunk, err := it.inner.Next()
+		if err == nil {
+			if !it.started {
+				it.started = true
+				it.served()
+			}
+			if chunk.Event == provider.EventMessageStop && it.idx > 0 {
+				chunk.Model = it.chain[it.idx]
+			}
+			return chunk, nil
+		}
+		if err == io.EOF || it.started || !Fallbackable(err) || it.idx+1 >= len(it.chain) || it.ctx.Err() != nil {
+			return chunk, err
+		}
+
+		_ = it.inner.Close()
+		it.idx++
+		if oerr := it.open(fmt.Errorf("%s (%s): %w", it.chain[it.idx-1], it.backend, err)); oerr != nil {
+			return provider.StreamChunk{}, oerr
+		}
+	}
+}
+
+func (it *routerIterator) served() {
+	if it.r.onServe == nil {
+		return
+	}
+	it.r.onServe(Served{
+		Backend:  it.backend,
+		Model:    it.chain[it.idx],
+		Fallback: it.idx > 0,
+	})
+}
+
+func (it *routerIterator) Close() error {
+	return it.inner.Close()
+}
+
+// failedIterator stands in for a chain with no model left to try.
+type failedIterator struct{ err error }
+
+func (f failedIterator) Next() (provider.StreamChunk, error) { return provider.StreamChunk{}, f.err }
+func (f failedIterator) Close() error                        { return nil }
@@ -0,0 +1,283 @@
+package router
+
+import (
+	"context"
+	"cosmos/core/provider"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+var (
+	_ provider.Provider     = (*Router)(nil)
+	_ provider.TokenCounter = (*Router)(nil)
+)
+
+// --- Stub backend ---
+
+type stubIterator struct {
+	chunks []provider.StreamChunk
+	err    error // returned once the chunks run out, instead of io.EOF
+	closed bool
+}
+
+func (it *stubIterator) Next() (provider.StreamChunk, error) {
+	if len(it.chunks) == 0 {
+		if it.err != nil {
+			return provider.StreamChunk{}, it.err
+		}
+		return provider.StreamChunk{}, io.EOF
+	}
+	c := it.chunks[0]
+	it.chunks = it.chunks[1:]
+	return c, nil
+}
+
+func (it *stubIterator) Close() error {
+	it.closed = true
+	return nil
+}
+
+// stubBackend answers every request with text naming the model, unless a
+// send or stream error is configured for that model.
+type stubBackend struct {
+	sendErr   map[string]error
+	streamErr map[string]error
+	models    []provider.ModelInfo
+	listErr   error
+	requests  []string
+	iters     []*stubIterator
+}
+
+func (b *stubBackend) Send(_ context.Context, req provider.Request) (provider.StreamIterator, error) {
+	b.requests = append(b.requests, req.Model)
+	if err := b.sendErr[req.Model]; err != nil {
+		return nil, err
+	}
+	it := &stubIterator{err: b.streamErr[req.Model]}
+	if it.err == nil {
+		it.chunks = []provider.StreamChunk{
+			{Event: provider.EventTextDelta, Text: req.Model},
+			{Event: provider.EventMessageStop, StopReason: "end_turn", Usage: &provider.Usage{OutputTokens: 1}},
+		}
+	}
+	b.iters = append(b.iters, it)
+	return it, nil
+}
+
+func (b *stubBackend) ListModels(context.Context) ([]provider.ModelInfo, error) {
+	return b.models, b.listErr
+}
+
+func drain(t *testing.T, iter provider.StreamIterator) (text string, stop provider.StreamChunk, err error) {
+	t.Helper()
+	defer func() { _ = iter.Close() }()
+	for {
+		chunk, err := iter.Next()
+		if err == io.EOF {
+			return text, stop, nil
+		}
+		if err != nil {
+			return text, stop, err
+		}
+		text += chunk.Text
+		if chunk.Event == provider.EventMessageStop {
+			stop = chunk
+		}
+	}
+}
+
+// newTestRouter routes "us.anthropic." to bedrock, "claude-" to anthropic,
+// and everything else to openai, with a three-step Sonnet chain.
+func newTestRouter(t *testing.T, bedrock, anthropic, openai *stubBackend, served *[]Served) *Router {
+	t.Helper()
+	r, err := NewRouter(Config{
+		Backends: map[string]provider.Provider{"bedrock": bedrock, "anthropic": anthropic, "openai": openai},
+		Routes:   map[string]string{"us.anthropic.": "bedrock", "claude-": "anthropic"},
+		Default:  "openai",
+		Fallbacks: map[string][]string{
+			"us.anthropic.sonnet": {"claude-sonnet", "qwen"},
+		},
+		OnServe: func(s Served) { *served = append(*served, s) },
+	})
+	if err != nil {
+		t.Fatalf("NewRouter: %v", err)
+	}
+	return r
+}
+
+func TestRoute(t *testing.T) {
+	r, err := NewRouter(Config{
+		Backends: map[string]provider.Provider{"a": &stubBackend{}, "b": &stubBackend{}, "c": &stubBackend{}},
+		Routes:   map[string]string{"claude-": "b", "claude-3-": "c"},
+		Default:  "a",
+	})
+	if err != nil {
+		t.Fatalf("NewRouter: %v", err)
+	}
+	for model, want := range map[string]string{
+		"claude-sonnet-4": "b",
+		"claude-3-haiku":  "c", // longest prefix wins
+		"qwen2.5-coder":   "a",
+	} {
+		if got := r.Route(model); got != want {
+			t.Errorf("Route(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestNewRouterUnknownBackend(t *testing.T) {
+	backends := map[string]provider.Provider{"bedrock": &stubBackend{}}
+	if _, err := NewRouter(Config{Backends: backends, Default: "anthropic"}); err == nil {
+		t.Error("expected error for unknown default backend")
+	}
+	if _, err := NewRouter(Config{Backends: backends, Default: "bedrock", Routes: map[string]string{"qwen": "openai"}}); err == nil {
+		t.Error("expected error for route to unknown backend")
+	}
+}
+
+func TestSendPrimary(t *testing.T) {
+	bedrock, anthropic, openai := &stubBackend{}, &stubBackend{}, &stubBackend{}
+	var served []Served
+	r := newTestRouter(t, bedrock, anthropic, openai, &served)
+
+	iter, err := r.Send(context.Background(), provider.Request{Model: "us.anthropic.sonnet"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	text, stop, err := drain(t, iter)
+	if err != nil || text != "us.anthropic.sonnet" {
+		t.Fatalf("drain = %q, %v", text, err)
+	}
+	if stop.Model != "" {
+		t.Errorf("stop.Model = %q, want empty when the requested model served", stop.Model)
+	}
+	if len(served) != 1 || served[0] != (Served{Backend: "bedrock", Model: "us.anthropic.sonnet"}) {
+		t.Errorf("served = %+v", served)
+	}
+}
+
+func TestSendFallsBackOnSendError(t *testing.T) {
+	bedrock := &stubBackend{sendErr: map[string]error{"us.anthropic.sonnet": fmt.Errorf("bedrock: %w", provider.ErrThrottled)}}
+	anthropic := &stubBackend{sendErr: map[string]error{"claude-sonnet": provider.ErrAccessDenied}}
+	openai := &stubBackend{}
+	var served []Served
+	r := newTestRouter(t, bedrock, anthropic, openai, &served)
+
+	iter, err := r.Send(context.Background(), provider.Request{Model: "us.anthropic.sonnet"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	text, stop, err := drain(t, iter)
+	if err != nil || text != "qwen" {
+		t.Fatalf("drain = %q, %v; want the last model in the chain", text, err)
+	}
+	if stop.Model != "qwen" {
+		t.Errorf("stop.Model = %q, want qwen", stop.Model)
+	}
+	if len(anthropic.requests) != 1 || len(openai.requests) != 1 {
+		t.Errorf("requests: anthropic %v, openai %v", anthropic.requests, openai.requests)
+	}
+	if len(served) != 1 || served[0] != (Served{Backend: "openai", Model: "qwen", Fallback: true}) {
+		t.Errorf("served = %+v", served)
+	}
+}
+
+func TestSendFallsBackOnStreamError(t *testing.T) {
+	bedrock := &stubBackend{streamErr: map[string]error{"us.anthropic.sonnet": provider.ErrModelNotReady}}
+	anthropic, openai := &stubBackend{}, &stubBackend{}
+	var served []Served
+	r := newTestRouter(t, bedrock, anthropic, openai, &served)
+
+	iter, err := r.Send(context.Background(), provider.Request{Model: "us.anthropic.sonnet"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	text, _, err := drain(t, iter)
+	if err != nil || text != "claude-sonnet" {
+		t.Fatalf("drain = %q, %v", text, err)
+	}
+	if !bedrock.iters[0].closed {
+		t.Error("failed stream should be closed before falling back")
+	}
+	if len(served) != 1 || served[0].Backend != "anthropic" {
+		t.Errorf("served = %+v", served)
+	}
+}
+
+func TestSendNoFallbackOnOtherErrors(t *testing.T) {
+	validation := errors.New("validation failed")
+	bedrock := &stubBackend{sendErr: map[string]error{"us.anthropic.sonnet": validation}}
+	anthropic, openai := &stubBackend{}, &stubBackend{}
+	var served []Served
+	r := newTestRouter(t, bedrock, anthropic, openai, &served)
+
+	if _, err := r.Send(context.Background(), provider.Request{Model: "us.anthropic.sonnet"}); !errors.Is(err, validation) {
+		t.Fatalf("err = %v, want validation error", err)
+	}
+	if len(anthropic.requests) != 0 {
+		t.Errorf("fallback attempted for a non-fallback error: %v", anthropic.requests)
+	}
+}
+
+func TestSendChainExhausted(t *testing.T) {
+	bedrock := &stubBackend{sendErr: map[string]error{"us.anthropic.sonnet": provider.ErrThrottled}}
+	anthropic := &stubBackend{sendErr: map[string]error{"claude-sonnet": provider.ErrThrottled}}
+	openai := &stubBackend{sendErr: map[string]error{"qwen": provider.ErrModelNotReady}}
+	var served []Served
+	r := newTestRouter(t, bedrock, anthropic, openai, &served)
+
+	_, err := r.Send(context.Background(), provider.Request{Model: "us.anthropic.sonnet"})
+	if !errors.Is(err, provider.ErrModelNotReady) {
+		t.Fatalf("err = %v, want the last model's error", err)
+	}
+	if len(served) != 0 {
+		t.Errorf("served = %+v, want none", served)
+	}
+}
+
+func TestListModelsMerges(t *testing.T) {
+	bedrock := &stubBackend{listErr: errors.New("no credentials")}
+	anthropic := &stubBackend{models: []provider.ModelInfo{{ID: "claude-sonnet", Name: "anthropic"}}}
+	openai := &stubBackend{models: []provider.ModelInfo{{ID: "qwen"}, {ID: "claude-sonnet", Name: "openai"}}}
+	var served []Served
+	r := newTestRouter(t, bedrock, anthropic, openai, &served)
+
+	models, err := r.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if len(models) != 2 || models[0].ID != "qwen" || models[1].Name != "openai" {
+		t.Errorf("models = %+v; want default backend first and duplicates dropped", models)
+	}
+
+	openai.listErr = errors.New("down")
+	anthropic.listErr = errors.New("down")
+	if _, err := r.ListModels(context.Background()); err == nil {
+		t.Error("expected error when every backend fails")
+	}
+}
+
+type countingBackend struct{ stubBackend }
+
+func (c *countingBackend) CountTokens(_ context.Context, req provider.Request) (int, error) {
+	return len(req.Model), nil
+}
+
+func TestCountTokensRoutes(t *testing.T) {
+	r, err := NewRouter(Config{
+		Backends: map[string]provider.Provider{"anthropic": &countingBackend{}, "openai": &stubBackend{}},
+		Routes:   map[string]string{"claude-": "anthropic"},
+		Default:  "openai",
+	})
+	if err != nil {
+		t.Fatalf("NewRouter: %v", err)
+	}
+	if n, err := r.CountTokens(context.Background(), provider.Request{Model: "claude-x"}); err != nil || n != 8 {
+		t.Errorf("CountTokens(claude-x) = %d, %v; want the anthropic count", n, err)
+	}
+	if _, err := r.CountTokens(context.Background(), provider.Request{Model: "qwen"}); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("err = %v, want ErrUnsupported for a backend that cannot count", err)
+	}
+}
@@ -76,7 +76,7 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg.(type) {
 	case ChatPermissionRequestMsg:
 		a.permissionPending = true
-	case PermissionDecisionMsg, ChatPermissionTimeoutMsg:
+	case PermissionDecisionMsg, ChatPermissionTimeoutMsg, ChatTurnCancelledMsg:
 		a.permissionPending = false
 	}
 
@@ -60,7 +60,7 @@ type permissionRequestInfo struct {
 	description string
 	respondFunc func(allowed, remember bool) // Adapter-provided callback
 	resolved    bool
-	decision    string // "granted", "denied", or "timed out"
+	decision    string // "granted", "denied", "timed out" or "cancelled"
 }
 
 type chatMessage struct {
@@ -72,6 +72,10 @@ type chatMessage struct {
 	isSystem  bool // for command responses (/clear, /context, /model, /restore)
 	tool      *toolInfo
 
+	// Model reasoning, shown collapsed to one line; ctrl+t toggles the latest.
+	isThinking bool
+	expanded   bool
+
 	// Permission request handling
 	isPermissionRequest bool
 	permissionRequest   *permissionRequestInfo
@@ -86,6 +90,7 @@ type ChatModel struct {
 
 	messages               []chatMessage
 	accumulatedText        string // Buffer for current assistant message
+	accumulatedThinking    string // Buffer for reasoning streamed before the reply
 	assistantHeaderPrinted bool   // Whether we've printed the bar for assistant
 	width                  int
 	height                 int
@@ -132,10 +137,55 @@ func (m *ChatModel) renderMessageMarkdown(text string) ([]string, error) {
 	return lines, nil
 }
 
+// finalizeThinking moves streamed reasoning into a collapsed thinking message.
+// This is a no-op if accumulatedThinking is empty.
+func (m *ChatModel) finalizeThinking() {
+	if m.accumulatedThinking == "" {
+		return
+	}
+	m.messages = append(m.messages, chatMessage{
+		text:       m.accumulatedThinking,
+		isThinking: true,
+	})
+	m.accumulatedThinking = ""
+}
+
+// toggleLatestThinking expands or collapses the most recent thinking message.
+func (m *ChatModel) toggleLatestThinking() {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].isThinking {
+			m.messages[i].expanded = !m.messages[i].expanded
+			return
+		}
+	}
+}
+
+// thinkingLines lays out a reasoning block as unstyled lines. Callers apply
+// the dim style, so View() and buildAllRenderedLines() share one layout.
+// Collapsed blocks take a single line; streaming blocks are always expanded.
+func thinkingLines(text string, expanded, streaming bool, width int) []string {
+	var header string
+	switch {
+	case streaming:
+		header = "▾ Thinking…"
+	case expanded:
+		header = "▾ Thinking (ctrl+t to collapse)"
+	default:
+		return []string{fmt.Sprintf("▸ Thinking · %d words (ctrl+t to expand)", len(strings.Fields(text)))}
+	}
+	lines := []string{header}
+	for _, line := range wrapText(strings.TrimSpace(text), width) {
+		lines = append(lines, "  "+line)
+	}
+	return lines
+}
+
 // finalizeAccumulatedText renders the current accumulatedText as a markdown
 // assistant message, appends it to m.messages, and resets the accumulator.
-// This is a no-op if accumulatedText is empty.
+// Pending reasoning is finalized first so it stays above the reply.
+// This is a no-op if both buffers are empty.
 func (m *ChatModel) finalizeAccumulatedText() {
+	m.finalizeThinking()
 	if m.accumulatedText == "" {
 		return
 	}
@@ -215,6 +265,17 @@ func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if msg.String() == "ctrl+t" {
+			m.toggleLatestThinking()
+			return m, nil
+		}
+		if msg.String() == "esc" {
+			if c, ok := m.session.(TurnCanceller); ok {
+				c.CancelTurn()
+			}
+			return m, nil
+		}
+
 		// Check if we have an active permission request
 		activeRequest := m.getActivePermissionRequest()
 		if activeRequest != nil {
@@ -277,6 +338,7 @@ func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Start accumulating assistant response
 		m.accumulatedText = ""
+		m.accumulatedThinking = ""
 		m.assistantHeaderPrinted = false
 
 		// Check if old messages need to be flushed to stdout (scrolled off screen)
@@ -290,7 +352,17 @@ func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, tea.Sequence(cmds...)
 
+	case ChatThinkingMsg:
+		// Reasoning interleaved after reply text starts a new block below it.
+		if m.accumulatedText != "" {
+			m.finalizeAccumulatedText()
+		}
+		m.accumulatedThinking += msg.Text
+		return m, nil
+
 	case ChatTokenMsg:
+		// The reply has started: collapse the reasoning that preceded it.
+		m.finalizeThinking()
 		// Accumulate tokens from streaming - they will be displayed in View()
 		m.accumulatedText += msg.Text
 		// The streaming effect happens in View() by showing accumulatedText
@@ -370,6 +442,7 @@ func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case ChatErrorMsg:
+		m.finalizeThinking()
 		// Display error as a system message
 		errorText := "Error: " + msg.Error
 		errorMsg := chatMessage{
@@ -523,12 +596,47 @@ func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case ChatReplayMsg:
+		m.finalizeAccumulatedText()
+		for _, e := range msg.Entries {
+			if e.IsUser {
+				m.messages = append(m.messages, chatMessage{text: e.Text, isUser: true})
+				continue
+			}
+			m.accumulatedText = e.Text
+			m.finalizeAccumulatedText()
+		}
+		flushCmd := m.flushOldMessages()
+		if flushCmd != nil {
+			return m, flushCmd
+		}
+		return m, nil
+
+	case ChatTurnCancelledMsg:
+		m.finalizeAccumulatedText()
+		for i := range m.messages {
+			if req := m.messages[i].permissionRequest; req != nil && !req.resolved {
+				req.resolved = true
+				req.decision = "cancelled"
+			}
+		}
+		m.messages = append(m.messages, chatMessage{
+			text:     "Turn cancelled.",
+			isSystem: true,
+		})
+		flushCmd := m.flushOldMessages()
+		if flushCmd != nil {
+			return m, flushCmd
+		}
+		return m, nil
+
 	case ChatClearMsg:
 		// Flush everything to scrollback, then reset state.
 		m.finalizeAccumulatedText()
 		flushCmd := m.flushAllMessages()
 		m.messages = nil
 		m.accumulatedText = ""
+		m.accumulatedThinking = ""
 		m.assistantHeaderPrinted = false
 		m.flushedLineCount = 0
 		if flushCmd != nil {
@@ -642,7 +750,7 @@ func (m *ChatModel) View() string {
 	// See CLAUDE.md "Terminal Scrollback Strategy" for full details.
 	// ============================================================================
 
-	if len(m.messages) == 0 && m.accumulatedText == "" {
+	if len(m.messages) == 0 && m.accumulatedText == "" && m.accumulatedThinking == "" {
 		return NewSplash().View()
 	}
 
@@ -720,6 +828,14 @@ func (m *ChatModel) View() string {
 			continue
 		}
 
+		if msg.isThinking {
+			for _, line := range thinkingLines(msg.text, msg.expanded, false, availableWidth) {
+				content.WriteString(dimStyle.Render(line) + "\n")
+			}
+			content.WriteString("\n") // blank separator
+			continue
+		}
+
 		if msg.isTool && msg.tool != nil {
 			// Tool-specific rendering: no bar, compact display
 			switch msg.tool.state {
@@ -773,6 +889,13 @@ func (m *ChatModel) View() string {
 		}
 	}
 
+	// Render streaming reasoning (if any)
+	if m.accumulatedThinking != "" {
+		for _, line := range thinkingLines(m.accumulatedThinking, true, true, availableWidth) {
+			content.WriteString(dimStyle.Render(line) + "\n")
+		}
+	}
+
 	// Render streaming message (if any)
 	if m.accumulatedText != "" {
 		wrappedLines := wrapText(m.accumulatedText, availableWidth)
@@ -981,7 +1104,7 @@ func (m *ChatModel) flushOldMessages() tea.Cmd {
 // flushAllMessages writes ALL remaining unflushed lines (including on-screen ones)
 // to stdout. Used before clearing chat state so nothing is lost from scrollback.
 func (m *ChatModel) flushAllMessages() tea.Cmd {
-	if len(m.messages) == 0 && m.accumulatedText == "" {
+	if len(m.messages) == 0 && m.accumulatedText == "" && m.accumulatedThinking == "" {
 		return nil
 	}
 
@@ -1084,6 +1207,14 @@ func (m *ChatModel) buildAllRenderedLines(availableWidth int) []string {
 			continue
 		}
 
+		if msg.isThinking {
+			for _, line := range thinkingLines(msg.text, msg.expanded, false, availableWidth) {
+				lines = append(lines, ansiDim+line+ansiReset)
+			}
+			lines = append(lines, "") // blank separator
+			continue
+		}
+
 		if msg.isTool && msg.tool != nil {
 			// Tool-specific rendering: no bar, compact display
 			switch msg.tool.state {
@@ -1134,6 +1265,13 @@ func (m *ChatModel) buildAllRenderedLines(availableWidth int) []string {
 		}
 	}
 
+	// Render streaming reasoning (if any) - MUST match View() behavior!
+	if m.accumulatedThinking != "" {
+		for _, line := range thinkingLines(m.accumulatedThinking, true, true, availableWidth) {
+			lines = append(lines, ansiDim+line+ansiReset)
+		}
+	}
+
 	// Render streaming message (if any) - MUST match View() behavior!
 	if m.accumulatedText != "" {
 		bar := ansiOrange + "▌" + ansiReset
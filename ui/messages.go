@@ -7,6 +7,11 @@ type ChatTokenMsg struct {
 	Text string
 }
 
+// ChatThinkingMsg carries a reasoning delta from LLM streaming.
+type ChatThinkingMsg struct {
+	Text string
+}
+
 // ChatCompletionMsg signals the assistant message is complete
 type ChatCompletionMsg struct{}
 
@@ -95,6 +100,10 @@ type ChatPermissionTimeoutMsg struct {
 	Allowed    bool // Whether the default was to allow
 }
 
+// ChatTurnCancelledMsg signals the turn in progress was cancelled (Esc).
+// Unanswered permission prompts of the turn are resolved.
+type ChatTurnCancelledMsg struct{}
+
 // PermissionDecisionMsg is sent by the user when they press y/n on a permission prompt.
 // This is an internal UI message, not sent from core.
 type PermissionDecisionMsg struct {
@@ -108,6 +117,16 @@ type PermissionDecisionMsg struct {
 // with no colored bar.
 type ChatSystemMsg struct{ Text string }
 
+// ChatReplayMsg shows earlier prompts and replies as chat messages, e.g. the
+// conversation branch switched to with /branch.
+type ChatReplayMsg struct{ Entries []ChatReplayEntry }
+
+// ChatReplayEntry is one prompt (IsUser) or reply of a ChatReplayMsg.
+type ChatReplayEntry struct {
+	IsUser bool
+	Text   string
+}
+
 // ChatClearMsg instructs the chat page to flush all visible content to the
 // terminal scrollback and then reset its in-memory message state.
 type ChatClearMsg struct{}
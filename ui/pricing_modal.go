@@ -8,39 +8,58 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
-type PricingDetail struct {
-	ModelName    string
-	InputTokens  int64
-	OutputTokens int64
-	InputCost    float64
-	OutputCost   float64
-	TotalCost    float64
+// UsageRow is one line of a spend report, e.g. one day or one model.
+type UsageRow struct {
+	Key          string
+	Requests     int
+	InputTokens  int // including cached input
+	OutputTokens int
+	Cost         string // formatted in the display currency
 }
 
+// UsageReportMsg carries a spend report grouped By "day", "project",
+// "model" or "source".
+type UsageReportMsg struct {
+	By   string
+	Rows []UsageRow
+	Err  error
+}
+
+// UsageReportFunc loads a spend report grouped by the given dimension and
+// delivers it as a UsageReportMsg.
+type UsageReportFunc func(by string) tea.Cmd
+
+// usageGroups are the report dimensions, cycled with ←/→.
+var usageGroups = []string{"day", "project", "model", "source"}
+
+// maxUsageRows bounds the rows shown per report.
+const maxUsageRows = 12
+
 type PricingModal struct {
 	visible bool
 	width   int
 	height  int
-	details PricingDetail
+
+	report  UsageReportFunc // nil: no ledger, nothing to show
+	group   int             // index into usageGroups
+	rows    []UsageRow
+	err     error
+	loading bool
 }
 
 func NewPricingModal() *PricingModal {
-	// Mock data for initial implementation
-	return &PricingModal{
-		visible: false,
-		details: PricingDetail{
-			ModelName:    "claude-opus-4-6",
-			InputTokens:  15420,
-			OutputTokens: 8305,
-			InputCost:    0.46,
-			OutputCost:   0.27,
-			TotalCost:    0.73,
-		},
-	}
+	return &PricingModal{}
 }
 
-func (pm *PricingModal) Show() {
+// SetReportFunc sets the loader of the spend reports shown in the modal.
+func (pm *PricingModal) SetReportFunc(f UsageReportFunc) {
+	pm.report = f
+}
+
+// Show opens the modal and loads the current report.
+func (pm *PricingModal) Show() tea.Cmd {
 	pm.visible = true
+	return pm.load()
 }
 
 func (pm *PricingModal) Hide() {
@@ -56,10 +75,36 @@ func (pm *PricingModal) SetSize(width, height int) {
 	pm.height = height
 }
 
+func (pm *PricingModal) load() tea.Cmd {
+	if pm.report == nil {
+		return nil
+	}
+	pm.rows, pm.err, pm.loading = nil, nil, true
+	return pm.report(usageGroups[pm.group])
+}
+
+// HandleKey switches the report grouping with ←/→ (or h/l, tab).
+func (pm *PricingModal) HandleKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "right", "l", "tab":
+		pm.group = (pm.group + 1) % len(usageGroups)
+	case "left", "h", "shift+tab":
+		pm.group = (pm.group + len(usageGroups) - 1) % len(usageGroups)
+	default:
+		return nil
+	}
+	return pm.load()
+}
+
 func (pm *PricingModal) Update(msg tea.Msg) tea.Cmd {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		pm.SetSize(msg.Width, msg.Height)
+	case UsageReportMsg:
+		// Ignore a report that arrives after the grouping changed again.
+		if msg.By == usageGroups[pm.group] {
+			pm.rows, pm.err, pm.loading = msg.Rows, msg.Err, false
+		}
 	}
 	return nil
 }
@@ -83,7 +128,7 @@ func (pm *PricingModal) View() string {
 	valueStyle := lipgloss.NewStyle().
 		Bold(true)
 
-	totalStyle := lipgloss.NewStyle().
+	activeStyle := lipgloss.NewStyle().
 		Foreground(orangeColor).
 		Bold(true)
 
@@ -98,43 +143,60 @@ func (pm *PricingModal) View() string {
 	var b strings.Builder
 
 	// Title
-	b.WriteString(titleStyle.Render("💰 Cost Breakdown"))
+	b.WriteString(titleStyle.Render("💰 Cost Breakdown · last 30 days"))
 	b.WriteString("\n\n")
 
-	// Model name
-	b.WriteString(labelStyle.Render("Model: "))
-	b.WriteString(valueStyle.Render(pm.details.ModelName))
-	b.WriteString("\n\n")
-
-	// Divider
-	b.WriteString(dividerStyle.Render(strings.Repeat("─", 56)))
-	b.WriteString("\n\n")
-
-	// Input tokens
-	b.WriteString(labelStyle.Render("Input tokens:  "))
-	b.WriteString(valueStyle.Render(fmt.Sprintf("%d", pm.details.InputTokens)))
-	b.WriteString(labelStyle.Render("  →  "))
-	b.WriteString(valueStyle.Render(fmt.Sprintf("$%.4f", pm.details.InputCost)))
+	// Grouping tabs
+	for i, g := range usageGroups {
+		if i > 0 {
+			b.WriteString(labelStyle.Render("  ·  "))
+		}
+		if i == pm.group {
+			b.WriteString(activeStyle.Render("By " + g))
+		} else {
+			b.WriteString(labelStyle.Render("By " + g))
+		}
+	}
 	b.WriteString("\n")
 
-	// Output tokens
-	b.WriteString(labelStyle.Render("Output tokens: "))
-	b.WriteString(valueStyle.Render(fmt.Sprintf("%d", pm.details.OutputTokens)))
-	b.WriteString(labelStyle.Render("  →  "))
-	b.WriteString(valueStyle.Render(fmt.Sprintf("$%.4f", pm.details.OutputCost)))
-	b.WriteString("\n\n")
-
 	// Divider
-	b.WriteString(dividerStyle.Render(strings.Repeat("─", 56)))
-	b.WriteString("\n\n")
-
-	// Total cost
-	b.WriteString(labelStyle.Render("Total: "))
-	b.WriteString(totalStyle.Render(fmt.Sprintf("$%.2f", pm.details.TotalCost)))
+	b.WriteString(dividerStyle.Render(strings.Repeat("─", 64)))
 	b.WriteString("\n")
 
+	switch {
+	case pm.report == nil:
+		b.WriteString(labelStyle.Render("No usage ledger — spend is not being recorded."))
+		b.WriteString("\n")
+	case pm.err != nil:
+		b.WriteString(labelStyle.Render("Could not read the usage ledger: " + pm.err.Error()))
+		b.WriteString("\n")
+	case pm.loading:
+		b.WriteString(labelStyle.Render("Loading…"))
+		b.WriteString("\n")
+	case len(pm.rows) == 0:
+		b.WriteString(labelStyle.Render("No spend recorded yet."))
+		b.WriteString("\n")
+	default:
+		for i, row := range pm.rows {
+			if i == maxUsageRows {
+				b.WriteString(labelStyle.Render(fmt.Sprintf("… %d more (see `cosmos usage`)", len(pm.rows)-maxUsageRows)))
+				b.WriteString("\n")
+				break
+			}
+			key := row.Key
+			if key == "" {
+				key = "(unknown)"
+			}
+			b.WriteString(valueStyle.Render(fmt.Sprintf("%-24s", truncateLeft(key, 24))))
+			b.WriteString(labelStyle.Render(fmt.Sprintf(" %5d req  ▲%-6s ▼%-6s ",
+				row.Requests, formatCount(row.InputTokens), formatCount(row.OutputTokens))))
+			b.WriteString(valueStyle.Render(row.Cost))
+			b.WriteString("\n")
+		}
+	}
+
 	// Help text
-	b.WriteString(helpStyle.Render("Press Esc or Enter to close"))
+	b.WriteString(helpStyle.Render("←/→ change grouping · Esc or Enter to close"))
 
 	content := b.String()
 
@@ -143,7 +205,7 @@ func (pm *PricingModal) View() string {
 		Border(lipgloss.RoundedBorder()).
 		BorderForeground(orangeColor).
 		Padding(1, 2).
-		Width(60)
+		Width(72)
 
 	boxed := boxStyle.Render(content)
 
@@ -156,3 +218,13 @@ func (pm *PricingModal) View() string {
 		boxed,
 	)
 }
+
+// truncateLeft shortens s to n runes keeping its end, which is the
+// distinctive part of project paths and model IDs.
+func truncateLeft(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return "…" + string(r[len(r)-n+1:])
+}
@@ -71,6 +71,14 @@ func (s *Scaffold) GetNotifier() *Notifier {
 // For runtime updates from goroutines, use Notifier.Send() with typed messages
 // (e.g., StatusItemUpdateMsg).
 
+// SetUsageReportFunc sets the loader of the spend reports shown when the
+// cost status item is opened.
+// Setup-only: not safe to call from goroutines after Run().
+func (s *Scaffold) SetUsageReportFunc(f UsageReportFunc) *Scaffold {
+	s.pricingModal.SetReportFunc(f)
+	return s
+}
+
 // SetBorderColor sets the border color on tab bar, status bar, and body.
 // Setup-only: not safe to call from goroutines after Run().
 func (s *Scaffold) SetBorderColor(color string) *Scaffold {
@@ -230,7 +238,7 @@ func (s *Scaffold) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return s, nil
 			default:
 				// Modal consumes all other keys (focus trap)
-				return s, nil
+				return s, s.pricingModal.HandleKey(msg)
 			}
 		}
 
@@ -253,8 +261,8 @@ func (s *Scaffold) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return s, nil
 			case msg.String() == "enter":
 				selectedItem := s.statusBar.GetSelectedItem()
-				if selectedItem != nil && selectedItem.Key == "price" {
-					s.pricingModal.Show()
+				if selectedItem != nil && selectedItem.Key == "cost" {
+					return s, s.pricingModal.Show()
 				}
 				return s, nil
 			case msg.String() == "esc":
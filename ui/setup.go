@@ -13,6 +13,13 @@ type SessionSubmitter interface {
 	SubmitMessage(text string)
 }
 
+// TurnCanceller cancels the turn in progress when the user presses Esc in
+// the chat. core.Session satisfies this interface; it is optional for a
+// SessionSubmitter.
+type TurnCanceller interface {
+	CancelTurn()
+}
+
 // CompletionProvider provides tab completion strings for a given input prefix.
 // core.Session satisfies this interface without requiring a ui→core import.
 type CompletionProvider interface {
@@ -42,6 +49,7 @@ func ConfigureDefaultScaffold(s *Scaffold, currentDir string, model string) {
 	s.AddStatusItem("path", "□ "+currentDir)
 	s.AddStatusItem("branch", "⎇ main")
 	s.AddStatusItem("model", "⚙ "+FormatModelName(model))
+	s.AddStatusItem("mode", "✎ edit")
 	s.AddStatusItem("tokens", "▲0 ▼0")
 	s.AddStatusItem("context", "⚡0%")
 	s.AddActionableStatusItem("cost", "$0.00")
@@ -49,7 +57,11 @@ func ConfigureDefaultScaffold(s *Scaffold, currentDir string, model string) {
 
 // FormatModelName extracts a human-readable name from a full model ID.
 // e.g. "us.anthropic.claude-3-5-sonnet-20241022-v2:0" → "claude-3-5-sonnet-20241022-v2"
+// ARNs of inference profiles and provisioned models show their resource ID.
 func FormatModelName(modelID string) string {
+	if strings.HasPrefix(modelID, "arn:") {
+		return modelID[strings.LastIndex(modelID, "/")+1:]
+	}
 	// Strip regional prefix (e.g., "us.", "eu.", "ap.")
 	for _, prefix := range []string{"us.", "eu.", "ap."} {
 		modelID = strings.TrimPrefix(modelID, prefix)